@@ -28,9 +28,10 @@ Use "src admin [command] -h" for more information about a command.
 	}
 
 	commands = append(commands, &command{
-		flagSet: flagSet,
-		aliases: []string{"admin"},
-		handler: handler,
+		flagSet:     flagSet,
+		aliases:     []string{"admin"},
+		subcommands: &adminCommands,
+		handler:     handler,
 		usageFunc: func() {
 			fmt.Println(usage)
 		},
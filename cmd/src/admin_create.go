@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 
 	"github.com/sourcegraph/src-cli/internal/users"
@@ -48,7 +49,7 @@ Environmental variables
 			return err
 		}
 
-		ok, _, err := users.NeedsSiteInit(*urlFlag)
+		ok, _, err := users.NeedsSiteInit(http.DefaultClient, *urlFlag)
 		if err != nil {
 			return err
 		}
@@ -62,12 +63,12 @@ Environmental variables
 
 		switch {
 		case envAdminPass != "" && *passwordFlag == "":
-			client, err = users.SiteAdminInit(*urlFlag, *emailFlag, *usernameFlag, envAdminPass)
+			client, err = users.SiteAdminInit(http.DefaultClient, *urlFlag, *emailFlag, *usernameFlag, envAdminPass)
 			if err != nil {
 				return err
 			}
 		case envAdminPass == "" && *passwordFlag != "":
-			client, err = users.SiteAdminInit(*urlFlag, *emailFlag, *usernameFlag, *passwordFlag)
+			client, err = users.SiteAdminInit(http.DefaultClient, *urlFlag, *emailFlag, *usernameFlag, *passwordFlag)
 			if err != nil {
 				return err
 			}
@@ -11,8 +11,12 @@ import (
 
 	"github.com/sourcegraph/src-cli/internal/api"
 	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/gqlschema"
 
+	"github.com/itchyny/gojq"
 	"github.com/mattn/go-isatty"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+	"gopkg.in/yaml.v3"
 )
 
 func init() {
@@ -21,7 +25,8 @@ Exit codes:
 
   0: Success
   1: General failures (connection issues, invalid HTTP response, etc.)
-  2: GraphQL error response
+  2: GraphQL error response with -fail-on-graphql-errors, or a query that
+     isn't valid for the schema of the Sourcegraph instance
 
 Examples:
 
@@ -38,9 +43,29 @@ Examples:
 
     	$ echo 'query($query: String!) { search(query: $query) { results { resultCount } } }' | src api 'query=Router'
 
+  Load the query from a file, and the variables from a JSON or YAML file:
+
+    	$ src api -query=@query.graphql -vars-file=vars.yaml
+
+  Filter the response with a jq expression, printing strings without quotes:
+
+    	$ src api -query='query { currentUser { username } }' -jq '.data.currentUser.username' -raw
+
   Get the curl command for a query (just add '-get-curl' in the flags section):
 
     	$ src api -get-curl -query='query { currentUser { username } }'
+
+  Print the schema of the Sourcegraph instance, or a single type and its fields:
+
+    	$ src api schema
+    	$ src api schema -type Repository
+
+Queries and variables are validated against the schema of the Sourcegraph
+instance before they are sent. The schema is downloaded once per instance and
+version, and cached in the user cache directory, along with the version of the
+instance, which is checked again after an hour. Validation is skipped with
+-get-curl, -dump-requests, -record and -replay, so that only the request itself
+is sent. Use -validate=false to skip validation.
 `
 
 	flagSet := flag.NewFlagSet("api", flag.ExitOnError)
@@ -50,9 +75,14 @@ Examples:
 		fmt.Println(usage)
 	}
 	var (
-		queryFlag = flagSet.String("query", "", "GraphQL query to execute, e.g. 'query { currentUser { username } }' (stdin otherwise)")
-		varsFlag  = flagSet.String("vars", "", `GraphQL query variables to include as JSON string, e.g. '{"var": "val", "var2": "val2"}'`)
-		apiFlags  = api.NewFlags(flagSet)
+		queryFlag    = flagSet.String("query", "", "GraphQL query to execute, e.g. 'query { currentUser { username } }', or @path to read it from a file (stdin otherwise)")
+		varsFlag     = flagSet.String("vars", "", `GraphQL query variables to include as JSON string, e.g. '{"var": "val", "var2": "val2"}'`)
+		varsFileFlag = flagSet.String("vars-file", "", "Path to a JSON or YAML file of GraphQL query variables. Variables given with -vars or as arguments take precedence.")
+		jqFlag       = flagSet.String("jq", "", "jq expression to filter the response with, e.g. '.data.currentUser.username'")
+		rawFlag      = flagSet.Bool("raw", false, "Print strings in the output without quotes")
+		validateFlag = flagSet.Bool("validate", true, "Validate the query and variables against the schema of the Sourcegraph instance before sending them")
+		failFlag     = flagSet.Bool("fail-on-graphql-errors", false, "Exit with status 2 if the response contains GraphQL errors")
+		apiFlags     = api.NewFlags(flagSet)
 	)
 
 	handler := func(args []string) error {
@@ -61,9 +91,27 @@ Examples:
 			return err
 		}
 
+		if flagSet.Arg(0) == "schema" {
+			return apiSchema(flagSet.Args()[1:])
+		}
+
+		// Compile the filter up front, so that a typo doesn't cost a request.
+		var filter *gojq.Code
+		if *jqFlag != "" {
+			if filter, err = compileJQ(*jqFlag); err != nil {
+				return cmderrors.Usagef("invalid -jq expression: %s", err)
+			}
+		}
+
 		// Build the GraphQL request.
 		query := *queryFlag
-		if query == "" {
+		if path, ok := strings.CutPrefix(query, "@"); ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return errors.Wrap(err, "reading query")
+			}
+			query = string(data)
+		} else if query == "" {
 			// Read query from stdin instead.
 			if isatty.IsTerminal(os.Stdin.Fd()) {
 				return cmderrors.Usage("expected query to be piped into 'src api' or -query flag to be specified")
@@ -77,6 +125,11 @@ Examples:
 
 		// Determine which variables to use in the request.
 		vars := map[string]interface{}{}
+		if *varsFileFlag != "" {
+			if vars, err = readVarsFile(*varsFileFlag); err != nil {
+				return err
+			}
+		}
 		if *varsFlag != "" {
 			if err := json.Unmarshal([]byte(*varsFlag), &vars); err != nil {
 				return err
@@ -92,18 +145,44 @@ Examples:
 			vars[key] = value
 		}
 
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		if *validateFlag && !skipAPIValidation(apiFlags) {
+			if err := validateAPIRequest(ctx, client, query, vars); err != nil {
+				return err
+			}
+		}
+
 		// Perform the request.
 		var result interface{}
-		if ok, err := cfg.apiClient(apiFlags, flagSet.Output()).NewRequest(query, vars).DoRaw(context.Background(), &result); err != nil || !ok {
+		if ok, err := client.NewRequest(query, vars).DoRaw(ctx, &result); err != nil || !ok {
 			return err
 		}
 
+		outputs := []interface{}{result}
+		if filter != nil {
+			if outputs, err = runJQ(filter, result); err != nil {
+				return err
+			}
+		}
+
 		// Print the formatted JSON.
-		f, err := marshalIndent(result)
-		if err != nil {
-			return err
+		for _, output := range outputs {
+			if s, ok := output.(string); ok && *rawFlag {
+				fmt.Println(s)
+				continue
+			}
+			f, err := marshalIndent(output)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(f))
+		}
+
+		if *failFlag && hasGraphQLErrors(result) {
+			return cmderrors.ExitCode(cmderrors.GraphqlErrorsExitCode, nil)
 		}
-		fmt.Println(string(f))
 		return nil
 	}
 
@@ -114,3 +193,141 @@ Examples:
 		usageFunc: usageFunc,
 	})
 }
+
+// readVarsFile reads GraphQL variables from a JSON or YAML file. Since JSON
+// is a subset of YAML, both are read with the YAML decoder.
+func readVarsFile(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading variables")
+	}
+
+	vars := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &vars); err != nil {
+		return nil, errors.Wrapf(err, "parsing variables from %s", path)
+	}
+	return vars, nil
+}
+
+func compileJQ(expr string) (*gojq.Code, error) {
+	query, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return gojq.Compile(query)
+}
+
+// runJQ runs a compiled jq filter over v, which must be made up of the types
+// produced by decoding JSON into an interface{}, and returns every output.
+func runJQ(filter *gojq.Code, v interface{}) ([]interface{}, error) {
+	var outputs []interface{}
+	iter := filter.Run(v)
+	for {
+		output, ok := iter.Next()
+		if !ok {
+			return outputs, nil
+		}
+		if err, ok := output.(error); ok {
+			return nil, errors.Wrap(err, "running -jq expression")
+		}
+		outputs = append(outputs, output)
+	}
+}
+
+// hasGraphQLErrors returns true if the raw GraphQL response has any errors.
+func hasGraphQLErrors(result interface{}) bool {
+	m, ok := result.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	errs, ok := m["errors"].([]interface{})
+	return ok && len(errs) > 0
+}
+
+// skipAPIValidation returns true if the request must be sent exactly as given,
+// without the requests that validation makes: when it's printed as a curl
+// command, when requests are dumped, and when requests are recorded to or
+// replayed from a cassette, which must only contain the request itself.
+func skipAPIValidation(apiFlags *api.Flags) bool {
+	record, replay := apiFlags.Cassette()
+	return apiFlags.GetCurl() || apiFlags.DumpRequests() || record != "" || replay != ""
+}
+
+// validateAPIRequest validates a request against the cached schema of the
+// Sourcegraph instance. Validation is only a convenience, so failing to get
+// the schema, for example because introspection is disabled, isn't fatal.
+func validateAPIRequest(ctx context.Context, client api.Client, query string, vars map[string]interface{}) error {
+	cache := gqlschema.Cache{Dir: gqlschema.DefaultCacheDir(), VersionTTL: gqlschema.DefaultVersionTTL}
+	schema, err := cache.Load(ctx, client, cfg.Endpoint, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: not validating query: %s\n", err)
+		return nil
+	}
+
+	err = schema.Validate(query, vars)
+	if err != nil {
+		// The instance may have been upgraded since its version was last
+		// checked, so check again before rejecting the query.
+		cache.VersionTTL = 0
+		if schema, loadErr := cache.Load(ctx, client, cfg.Endpoint, false); loadErr == nil {
+			err = schema.Validate(query, vars)
+		}
+	}
+	if err != nil {
+		return cmderrors.ExitCode(cmderrors.GraphqlErrorsExitCode, errors.Newf("invalid query:\n%s", err))
+	}
+	return nil
+}
+
+func apiSchema(args []string) error {
+	usage := `
+Examples:
+
+  Print the schema of the Sourcegraph instance:
+
+    	$ src api schema
+
+  Print a single type and its fields:
+
+    	$ src api schema -type Repository
+
+  Download the schema again, for example after changing instance settings:
+
+    	$ src api schema -refresh
+`
+
+	flagSet := flag.NewFlagSet("schema", flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src api %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		typeFlag    = flagSet.String("type", "", "Print only the named type and its fields")
+		refreshFlag = flagSet.Bool("refresh", false, "Download the schema again, even if it is cached")
+		apiFlags    = api.NewFlags(flagSet)
+	)
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() > 0 {
+		return cmderrors.Usagef("unexpected arguments: %s", strings.Join(flagSet.Args(), " "))
+	}
+
+	cache := gqlschema.Cache{Dir: gqlschema.DefaultCacheDir()}
+	schema, err := cache.Load(context.Background(), cfg.apiClient(apiFlags, flagSet.Output()), cfg.Endpoint, *refreshFlag)
+	if err != nil {
+		return err
+	}
+
+	if *typeFlag == "" {
+		fmt.Print(schema.SDL())
+		return nil
+	}
+	def, ok := schema.Type(*typeFlag)
+	if !ok {
+		return errors.Newf("type %q is not defined by the schema", *typeFlag)
+	}
+	fmt.Print(def)
+	return nil
+}
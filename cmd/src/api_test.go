@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadVarsFile(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, tc := range map[string]struct {
+		filename string
+		contents string
+		want     map[string]interface{}
+		wantErr  bool
+	}{
+		"json": {
+			filename: "vars.json",
+			contents: `{"query": "repo:foo", "first": 10, "filters": {"archived": false}}`,
+			want:     map[string]interface{}{"query": "repo:foo", "first": 10, "filters": map[string]interface{}{"archived": false}},
+		},
+		"yaml": {
+			filename: "vars.yaml",
+			contents: "query: repo:foo\nfirst: 10\nfilters:\n  archived: false\n",
+			want:     map[string]interface{}{"query": "repo:foo", "first": 10, "filters": map[string]interface{}{"archived": false}},
+		},
+		"empty": {
+			filename: "vars.yaml",
+			want:     map[string]interface{}{},
+		},
+		"not an object": {
+			filename: "vars.json",
+			contents: `["foo"]`,
+			wantErr:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.filename)
+			if err := os.WriteFile(path, []byte(tc.contents), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			have, err := readVarsFile(path)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("unexpected nil error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("unexpected variables (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunJQ(t *testing.T) {
+	var response interface{}
+	if err := json.Unmarshal([]byte(`{
+		"data": {"repositories": {"nodes": [{"name": "foo"}, {"name": "bar"}]}},
+		"errors": [{"message": "oops"}]
+	}`), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		expr    string
+		want    []interface{}
+		wantErr bool
+	}{
+		"identity": {
+			expr: ".data.repositories.nodes[0]",
+			want: []interface{}{map[string]interface{}{"name": "foo"}},
+		},
+		"multiple outputs": {
+			expr: ".data.repositories.nodes[].name",
+			want: []interface{}{"foo", "bar"},
+		},
+		"errors": {
+			expr: "[.errors[].message]",
+			want: []interface{}{[]interface{}{"oops"}},
+		},
+		"runtime error": {
+			expr:    ".data.repositories.nodes[0].name | keys",
+			wantErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			filter, err := compileJQ(tc.expr)
+			if err != nil {
+				t.Fatalf("unexpected compile error: %s", err)
+			}
+
+			have, err := runJQ(filter, response)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("unexpected nil error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("unexpected outputs (-want +have):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := compileJQ(".data |"); err == nil {
+		t.Error("unexpected nil error compiling invalid expression")
+	}
+}
+
+func TestHasGraphQLErrors(t *testing.T) {
+	for _, tc := range []struct {
+		response string
+		want     bool
+	}{
+		{response: `{"data": {}}`, want: false},
+		{response: `{"data": {}, "errors": []}`, want: false},
+		{response: `{"data": null, "errors": [{"message": "oops"}]}`, want: true},
+	} {
+		var response interface{}
+		if err := json.Unmarshal([]byte(tc.response), &response); err != nil {
+			t.Fatal(err)
+		}
+		if have := hasGraphQLErrors(response); have != tc.want {
+			t.Errorf("hasGraphQLErrors(%s): have %v, want %v", tc.response, have, tc.want)
+		}
+	}
+}
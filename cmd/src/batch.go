@@ -18,6 +18,8 @@ The commands are:
 
 	apply                 applies a batch spec to create or update a batch
 	                      change
+	cache                 manages the local cache of step results and
+	                      repository archives
 	new                   creates a new batch spec YAML file
 	preview               creates a batch spec to be previewed or applied
 	remote                creates server side batch changes
@@ -45,7 +47,8 @@ Use "src batch [command] -h" for more information about a command.
 			"batch-changes",
 			"batches",
 		},
-		handler:   handler,
-		usageFunc: func() { fmt.Println(usage) },
+		subcommands: &batchCommands,
+		handler:     handler,
+		usageFunc:   func() { fmt.Println(usage) },
 	})
 }
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+var batchCacheCommands commander
+
+func init() {
+	usage := `'src batch cache' manages the local cache of step results and repository
+archives that is used when executing batch specs.
+
+Usage:
+
+	src batch cache command [command options]
+
+The commands are:
+
+	ls       lists the cache entries
+	stats    shows the disk usage of the cache
+	prune    removes cache entries by age or total size
+	clear    removes the cache entries of a batch spec, or all of them
+
+Use "src batch cache [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("cache", flag.ExitOnError)
+	handler := func(args []string) error {
+		batchCacheCommands.run(flagSet, "src batch cache", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	batchCommands = append(batchCommands, &command{
+		flagSet:     flagSet,
+		subcommands: &batchCacheCommands,
+		handler:     handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
+
+// newBatchCacheDirFlag registers the -cache flag of the 'src batch cache'
+// commands, which defaults to the cache directory of 'src batch preview' and
+// 'src batch apply'.
+func newBatchCacheDirFlag(flagSet *flag.FlagSet) *string {
+	return flagSet.String("cache", batchDefaultCacheDir(), "Directory for caching results and repository archives.")
+}
+
+func batchCacheUsageFunc(flagSet *flag.FlagSet, usage string) func() {
+	return func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src batch cache %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+}
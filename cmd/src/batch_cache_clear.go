@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/sourcegraph/sourcegraph/lib/batches/template"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches"
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/batches/service"
+)
+
+func init() {
+	usage := `
+'src batch cache clear' removes all entries from the cache, or with -f only the
+cached step results of a batch spec. Repository archives are shared by batch
+specs, so they're only removed when the whole cache is cleared.
+
+Examples:
+
+  Remove all cache entries:
+
+    	$ src batch cache clear
+
+  Remove the cached step results of a batch spec, so that all of its steps are
+  executed anew:
+
+    	$ src batch cache clear -f batch.spec.yaml
+`
+
+	flagSet := flag.NewFlagSet("clear", flag.ExitOnError)
+	var (
+		cacheDirFlag         = newBatchCacheDirFlag(flagSet)
+		fileFlag             = flagSet.String("f", "", "The batch spec file whose cached step results to remove, or - to read from standard input.")
+		allowUnsupportedFlag = flagSet.Bool("allow-unsupported", false, "Allow unsupported code hosts.")
+		allowIgnoredFlag     = flagSet.Bool("force-override-ignore", false, "Do not ignore repositories that have a .batchignore file.")
+		apiFlags             = api.NewFlags(flagSet)
+	)
+	flagSet.BoolVar(verbose, "v", false, "print the removed entries")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() != 0 {
+			return errAdditionalArguments
+		}
+
+		if *fileFlag == "" {
+			removed, err := executor.ClearCacheDir(*cacheDirFlag)
+			printBatchCacheRemoved("Removed", removed)
+			return err
+		}
+
+		ctx := context.Background()
+		svc := service.New(&service.Opts{
+			Client: cfg.apiClient(apiFlags, flagSet.Output()),
+		})
+
+		_, ffs, err := svc.DetermineLicenseAndFeatureFlags(ctx)
+		if err != nil {
+			return err
+		}
+		if err := validateSourcegraphVersionConstraint(ctx, ffs); err != nil {
+			return err
+		}
+
+		spec, batchSpecDir, _, err := parseBatchSpec(ctx, *fileFlag, svc)
+		if err != nil {
+			return err
+		}
+
+		workspaces, _, err := svc.ResolveWorkspacesForBatchSpec(ctx, spec, *allowUnsupportedFlag, *allowIgnoredFlag)
+		if err != nil {
+			if _, ok := err.(batches.UnsupportedRepoSet); ok {
+				// The workspaces of supported repositories are still
+				// returned, and those are the only ones that can be cached.
+			} else if _, ok := err.(batches.IgnoredRepoSet); ok {
+				// Likewise.
+			} else {
+				return errors.Wrap(err, "resolving repositories")
+			}
+		}
+
+		tasks := svc.BuildTasks(
+			&template.BatchChangeAttributes{
+				Name:        spec.Name,
+				Description: spec.Description,
+			},
+			spec.Steps,
+			workspaces,
+		)
+
+		// The disk cache doesn't report whether an entry existed, so we find
+		// the removed entries by listing the cache before and after.
+		before, err := executor.ListCacheEntries(*cacheDirFlag)
+		if err != nil {
+			return err
+		}
+		diskCache := &executor.ExecutionDiskCache{Dir: *cacheDirFlag}
+		if err := executor.ClearTasksCache(ctx, diskCache, tasks, os.Environ(), batchSpecDir); err != nil {
+			return err
+		}
+		after, err := executor.ListCacheEntries(*cacheDirFlag)
+		if err != nil {
+			return err
+		}
+
+		remaining := make(map[string]struct{}, len(after))
+		for _, e := range after {
+			remaining[e.Path] = struct{}{}
+		}
+		var removed []executor.CacheEntry
+		for _, e := range before {
+			if _, ok := remaining[e.Path]; !ok {
+				removed = append(removed, e)
+			}
+		}
+		printBatchCacheRemoved("Removed", removed)
+		return nil
+	}
+
+	// Register the command.
+	batchCacheCommands = append(batchCacheCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: batchCacheUsageFunc(flagSet, usage),
+	})
+}
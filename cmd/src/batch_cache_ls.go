@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  List the cache entries:
+
+    	$ src batch cache ls
+
+  List the step results of the repositories whose slug contains "src-cli":
+
+    	$ src batch cache ls -kind=result -repo=src-cli
+
+  List the cache entries as a table:
+
+    	$ src batch cache ls -o table
+`
+
+	flagSet := flag.NewFlagSet("ls", flag.ExitOnError)
+	var (
+		cacheDirFlag = newBatchCacheDirFlag(flagSet)
+		kindFlag     = flagSet.String("kind", "", `Only list the entries of this kind: "result" for step results, or "archive" for repository archives.`)
+		repoFlag     = flagSet.String("repo", "", "Only list the entries whose repository slug contains this string.")
+		formatFlag   = flagSet.String("f", "{{.Kind}} {{.Slug}}{{if ge .StepIndex 0}} step {{.StepIndex}}{{end}} ({{humanizeBytes .Size}}, used {{humanizeTime .LastUsed}})", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.Path}}" or "{{.|json}}")`)
+		outFlags     = newOutputFlags(flagSet, formatFlag, "Kind", "Slug", "StepIndex", "Size", "LastUsed")
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() != 0 {
+			return errAdditionalArguments
+		}
+		switch executor.CacheEntryKind(*kindFlag) {
+		case "", executor.CacheEntryResult, executor.CacheEntryArchive:
+		default:
+			return cmderrors.Usagef("invalid -kind %q: expected \"result\" or \"archive\"", *kindFlag)
+		}
+
+		out, err := outFlags.writer(true)
+		if err != nil {
+			return err
+		}
+
+		entries, err := executor.ListCacheEntries(*cacheDirFlag)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if *kindFlag != "" && string(e.Kind) != *kindFlag {
+				continue
+			}
+			if !strings.Contains(e.Slug, *repoFlag) {
+				continue
+			}
+			if err := out.Write(e); err != nil {
+				return err
+			}
+		}
+		return out.Close()
+	}
+
+	// Register the command.
+	batchCacheCommands = append(batchCacheCommands, &command{
+		flagSet:   flagSet,
+		aliases:   []string{"list"},
+		handler:   handler,
+		usageFunc: batchCacheUsageFunc(flagSet, usage),
+	})
+}
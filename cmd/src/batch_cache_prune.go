@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	humanize "github.com/dustin/go-humanize"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Remove the cache entries that haven't been used for a week:
+
+    	$ src batch cache prune -max-age=168h
+
+  Remove the least recently used cache entries until the cache takes up at
+  most 5GB:
+
+    	$ src batch cache prune -max-size=5GB
+
+  Show what would be removed, without removing anything:
+
+    	$ src batch cache prune -max-size=5GB -dry-run -v
+`
+
+	flagSet := flag.NewFlagSet("prune", flag.ExitOnError)
+	var (
+		cacheDirFlag = newBatchCacheDirFlag(flagSet)
+		maxAgeFlag   = flagSet.Duration("max-age", 0, "Remove the cache entries that haven't been used for longer than this. (e.g. \"72h\")")
+		maxSizeFlag  byteSize
+		dryRunFlag   = flagSet.Bool("dry-run", false, "If true, print what would be removed without removing anything.")
+	)
+	flagSet.Var(&maxSizeFlag, "max-size", `Remove the least recently used cache entries until the cache takes up at most this much disk space. (e.g. "5GB")`)
+	flagSet.BoolVar(verbose, "v", false, "print the removed entries")
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() != 0 {
+			return errAdditionalArguments
+		}
+		if *maxAgeFlag <= 0 && maxSizeFlag == 0 {
+			return cmderrors.Usage("provide -max-age or -max-size")
+		}
+
+		removed, err := executor.PruneCache(*cacheDirFlag, executor.CachePruneOpts{
+			MaxAge:  *maxAgeFlag,
+			MaxSize: int64(maxSizeFlag),
+			DryRun:  *dryRunFlag,
+		})
+		verb := "Removed"
+		if *dryRunFlag {
+			verb = "Would remove"
+		}
+		printBatchCacheRemoved(verb, removed)
+		return err
+	}
+
+	// Register the command.
+	batchCacheCommands = append(batchCacheCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: batchCacheUsageFunc(flagSet, usage),
+	})
+}
+
+// printBatchCacheRemoved prints how many cache entries were removed and how
+// much space they took up, and with -v the removed entries.
+func printBatchCacheRemoved(verb string, removed []executor.CacheEntry) {
+	var size int64
+	for _, e := range removed {
+		size += e.Size
+		if *verbose {
+			fmt.Println(e.Path)
+		}
+	}
+	noun := "entries"
+	if len(removed) == 1 {
+		noun = "entry"
+	}
+	fmt.Printf("%s %d cache %s (%s).\n", verb, len(removed), noun, humanize.IBytes(uint64(size)))
+}
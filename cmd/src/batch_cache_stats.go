@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"sort"
+
+	"github.com/sourcegraph/src-cli/internal/batches/executor"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Show the disk usage of the cache, and of the 10 repositories that take up
+  the most space in it:
+
+    	$ src batch cache stats
+
+  Show the disk usage of all repositories as JSON:
+
+    	$ src batch cache stats -repos=-1 -o json
+`
+
+	flagSet := flag.NewFlagSet("stats", flag.ExitOnError)
+	var (
+		cacheDirFlag = newBatchCacheDirFlag(flagSet)
+		reposFlag    = flagSet.Int("repos", 10, "Show the disk usage of the n repositories that take up the most space. (use -1 for all)")
+		formatFlag   = flagSet.String("f", batchCacheStatsTemplate, `Format for the output, using the syntax of Go package text/template. (e.g. "{{.Total.Size}}" or "{{.|json}}")`)
+		outFlags     = newOutputFlags(flagSet, formatFlag)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() != 0 {
+			return errAdditionalArguments
+		}
+
+		out, err := outFlags.writer(false)
+		if err != nil {
+			return err
+		}
+
+		entries, err := executor.ListCacheEntries(*cacheDirFlag)
+		if err != nil {
+			return err
+		}
+		if err := out.Write(newBatchCacheStats(*cacheDirFlag, entries, *reposFlag)); err != nil {
+			return err
+		}
+		return out.Close()
+	}
+
+	// Register the command.
+	batchCacheCommands = append(batchCacheCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: batchCacheUsageFunc(flagSet, usage),
+	})
+}
+
+const batchCacheStatsTemplate = `{{.Dir}}
+  step results:         {{.Results.Entries}} ({{humanizeBytes .Results.Size}})
+  repository archives:  {{.Archives.Entries}} ({{humanizeBytes .Archives.Size}})
+  total:                {{.Total.Entries}} ({{humanizeBytes .Total.Size}})
+{{- if .Repos}}
+
+Repositories:
+{{- range .Repos}}
+  {{.Slug}}: {{.Entries}} ({{humanizeBytes .Size}})
+{{- end}}
+{{- end}}`
+
+type batchCacheUsage struct {
+	Entries int
+	Size    int64
+}
+
+func (u *batchCacheUsage) add(e executor.CacheEntry) {
+	u.Entries++
+	u.Size += e.Size
+}
+
+type batchCacheRepoUsage struct {
+	Slug string
+	batchCacheUsage
+}
+
+type batchCacheStats struct {
+	Dir      string
+	Results  batchCacheUsage
+	Archives batchCacheUsage
+	Total    batchCacheUsage
+	// Repos are the repository slugs that take up the most space, largest
+	// first.
+	Repos []batchCacheRepoUsage
+}
+
+// newBatchCacheStats sums up the disk usage of the entries, of which the
+// maxRepos slugs that take up the most space are kept, or all if maxRepos is
+// negative.
+func newBatchCacheStats(dir string, entries []executor.CacheEntry, maxRepos int) batchCacheStats {
+	stats := batchCacheStats{Dir: dir}
+	repos := map[string]*batchCacheRepoUsage{}
+	for _, e := range entries {
+		stats.Total.add(e)
+		if e.Kind == executor.CacheEntryResult {
+			stats.Results.add(e)
+		} else {
+			stats.Archives.add(e)
+		}
+
+		repo, ok := repos[e.Slug]
+		if !ok {
+			repo = &batchCacheRepoUsage{Slug: e.Slug}
+			repos[e.Slug] = repo
+		}
+		repo.add(e)
+	}
+
+	for _, repo := range repos {
+		stats.Repos = append(stats.Repos, *repo)
+	}
+	sort.Slice(stats.Repos, func(i, j int) bool {
+		if stats.Repos[i].Size != stats.Repos[j].Size {
+			return stats.Repos[i].Size > stats.Repos[j].Size
+		}
+		return stats.Repos[i].Slug < stats.Repos[j].Slug
+	})
+	if maxRepos >= 0 && len(stats.Repos) > maxRepos {
+		stats.Repos = stats.Repos[:maxRepos]
+	}
+	return stats
+}
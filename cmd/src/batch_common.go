@@ -16,6 +16,7 @@ import (
 	"syscall"
 	"time"
 
+	humanize "github.com/dustin/go-humanize"
 	"github.com/mattn/go-isatty"
 
 	"github.com/sourcegraph/sourcegraph/lib/errors"
@@ -83,6 +84,10 @@ type batchExecuteFlags struct {
 
 	apply                    bool
 	cacheDir                 string
+	cacheURL                 string
+	cacheReadOnly            bool
+	cacheCompress            bool
+	cacheMaxSize             byteSize
 	disableCache             bool
 	tempDir                  string
 	file                     string
@@ -90,6 +95,8 @@ type batchExecuteFlags struct {
 	parallelism              int
 	timeout                  time.Duration
 	workspace                string
+	runtime                  string
+	dockerAPI                bool
 	cleanArchives            bool
 	skipErrors               bool
 	runAsRoot                bool
@@ -126,6 +133,26 @@ func newBatchExecuteFlags(flagSet *flag.FlagSet, cacheDir, tempDir string) *batc
 		"Directory for caching results and repository archives.",
 	)
 
+	flagSet.StringVar(
+		&caf.cacheURL, "cache-url", os.Getenv("SRC_BATCH_CACHE_URL"),
+		"URL of a remote execution cache that step results are shared through, in front of which the -cache directory is used: an http(s):// URL of a key-value store, or an s3://bucket/prefix URL. Setting it enables the execution cache. Can also be set with environment variable SRC_BATCH_CACHE_URL; if both are set, this flag will be used and not the environment variable.",
+	)
+
+	flagSet.BoolVar(
+		&caf.cacheReadOnly, "cache-read-only", false,
+		"If true, results are read from the remote execution cache, but not written to it, such as in CI.",
+	)
+
+	flagSet.BoolVar(
+		&caf.cacheCompress, "cache-compress", true,
+		"If true, compress the results that are written to the remote execution cache.",
+	)
+
+	flagSet.Var(
+		&caf.cacheMaxSize, "cache-max-size",
+		`If set, the least recently used results and repository archives are removed from the -cache directory at the end of the run, until it takes up at most this much disk space. (e.g. "5GB")`,
+	)
+
 	flagSet.StringVar(
 		&caf.tempDir, "tmp", tempDir,
 		"Directory for storing temporary data, such as log files. Default is /tmp. Can also be set with environment variable SRC_BATCH_TMP_DIR; if both are set, this flag will be used and not the environment variable.",
@@ -138,7 +165,7 @@ func newBatchExecuteFlags(flagSet *flag.FlagSet, cacheDir, tempDir string) *batc
 
 	flagSet.IntVar(
 		&caf.parallelism, "j", 0,
-		"The maximum number of parallel jobs. Default (or 0) is the number of CPU cores available to Docker.",
+		"The maximum number of parallel jobs. Default (or 0) is the number of CPU cores available to the container runtime, or of the host with -workspace native.",
 	)
 
 	flagSet.DurationVar(
@@ -158,7 +185,17 @@ func newBatchExecuteFlags(flagSet *flag.FlagSet, cacheDir, tempDir string) *batc
 
 	flagSet.StringVar(
 		&caf.workspace, "workspace", "auto",
-		`Workspace mode to use ("auto", "bind", or "volume")`,
+		`Workspace mode to use ("auto", "bind", "volume", or "native"). "native" runs the steps with a shell on the host instead of in Docker containers, ignores the step containers, and doesn't use the execution cache.`,
+	)
+
+	flagSet.StringVar(
+		&caf.runtime, "runtime", batchDefaultRuntime(),
+		`Container runtime to run steps with ("docker", "podman", or "nerdctl"). Can also be set with environment variable SRC_BATCH_RUNTIME; if both are set, this flag will be used and not the environment variable.`,
+	)
+
+	flagSet.BoolVar(
+		&caf.dockerAPI, "docker-api", true,
+		"If true, talk to the Docker Engine API over its unix socket instead of running the docker CLI for each container, if the socket is available.",
 	)
 
 	flagSet.BoolVar(verbose, "v", false, "print verbose output")
@@ -198,6 +235,35 @@ func batchDefaultCacheDir() string {
 	return dir
 }
 
+// byteSize is a flag.Value of a number of bytes, given like "500MB" or
+// "2GiB".
+type byteSize uint64
+
+func (b *byteSize) Set(s string) error {
+	n, err := humanize.ParseBytes(s)
+	if err != nil {
+		return err
+	}
+	*b = byteSize(n)
+	return nil
+}
+
+func (b *byteSize) String() string {
+	if b == nil || *b == 0 {
+		return ""
+	}
+	return humanize.IBytes(uint64(*b))
+}
+
+// batchDefaultRuntime returns the container runtime to use if -runtime isn't
+// given: the value of SRC_BATCH_RUNTIME if it's set, or Docker.
+func batchDefaultRuntime() string {
+	if rt := os.Getenv("SRC_BATCH_RUNTIME"); rt != "" {
+		return rt
+	}
+	return docker.RuntimeDocker.Name()
+}
+
 // batchDefaultTempDirPrefix returns the prefix to be passed to ioutil.TempFile.
 // If the environment variable SRC_BATCH_TMP_DIR is set, that is used as the prefix.
 // Otherwise we use "/tmp".
@@ -260,11 +326,11 @@ type executeBatchSpecOpts struct {
 	client api.Client
 }
 
-func createDockerWatchdog(ctx context.Context, execUI ui.ExecUI) *watchdog.WatchDog {
+func createDockerWatchdog(ctx context.Context, rt docker.Runtime, execUI ui.ExecUI) *watchdog.WatchDog {
 	return watchdog.New(dockerWatchDuration, func() {
-		_, err := docker.NCPU(ctx)
+		_, err := rt.NCPU(ctx)
 		if err != nil {
-			execUI.DockerWatchDogWarning(errors.Wrap(err, "docker watchdog"))
+			execUI.DockerWatchDogWarning(errors.Wrapf(err, "%s watchdog", rt.Name()))
 		}
 	})
 }
@@ -281,11 +347,31 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 		execUI = &ui.TUI{Out: out}
 	}
 
-	w := createDockerWatchdog(ctx, execUI)
-	go w.Start()
+	// Native workspaces run the steps on the host, so Docker isn't needed.
+	native := opts.flags.workspace == "native"
+	if native && opts.flags.runAsRoot {
+		return cmderrors.Usage("-run-as-root can't be used with -workspace native")
+	}
+
+	rt, err := docker.NewRuntime(opts.flags.runtime)
+	if err != nil {
+		return cmderrors.Usage(err.Error())
+	}
+	if !native && opts.flags.dockerAPI {
+		// Falls back to the CLI if the Engine API isn't available.
+		rt = docker.ConnectEngine(ctx, rt)
+	}
+
+	var w *watchdog.WatchDog
+	if !native {
+		w = createDockerWatchdog(ctx, rt, execUI)
+		go w.Start()
+	}
 
 	defer func() {
-		w.Stop()
+		if w != nil {
+			w.Stop()
+		}
 		if err != nil {
 			execUI.ExecutionError(err)
 		}
@@ -305,7 +391,7 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 		execUI = &ui.JSONLines{BinaryDiffs: true}
 	}
 
-	imageCache := docker.NewImageCache()
+	imageCache := docker.NewImageCache(rt)
 
 	if err := validateSourcegraphVersionConstraint(ctx, ffs); err != nil {
 		return err
@@ -318,11 +404,13 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 	// In the past, we relied on `getBatchParallelism` to ascertain if docker is running,
 	// however, we don't always check for the number of CPUs (especially when the -j parallelis)
 	// flag is passed. This is a more explicit check to confirm docker is working.
-	if err := docker.CheckVersion(ctx); err != nil {
-		return err
+	if !native {
+		if err := rt.CheckVersion(ctx); err != nil {
+			return err
+		}
 	}
 
-	parallelism, err := getBatchParallelism(ctx, opts.flags.parallelism)
+	parallelism, err := getBatchParallelism(ctx, rt, opts.flags.parallelism, native)
 	if err != nil {
 		return err
 	}
@@ -335,8 +423,8 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 	// points here, but that feels like overkill. Basically, if it's
 	// desktop-linux, we'll just assume the user has the default /home mount
 	// available and go from there.
-	if runtime.GOOS == "linux" && opts.flags.tempDir == batchDefaultTempDirPrefix() {
-		context, err := docker.CurrentContext(ctx)
+	if !native && runtime.GOOS == "linux" && opts.flags.tempDir == batchDefaultTempDirPrefix() {
+		context, err := rt.CurrentContext(ctx)
 		if err != nil {
 			return err
 		}
@@ -374,7 +462,12 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 
 	var workspaceCreator workspace.Creator
 
-	if len(batchSpec.Steps) > 0 {
+	if len(batchSpec.Steps) > 0 && native {
+		execUI.DeterminingWorkspaceCreatorType()
+		var typ workspace.CreatorType
+		workspaceCreator, typ = workspace.NewCreator(ctx, opts.flags.workspace, opts.flags.cacheDir, opts.flags.tempDir, rt, nil)
+		execUI.DeterminingWorkspaceCreatorTypeSuccess(typ)
+	} else if len(batchSpec.Steps) > 0 {
 		execUI.PreparingContainerImages()
 		images, err := svc.EnsureDockerImages(
 			ctx,
@@ -390,7 +483,7 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 
 		execUI.DeterminingWorkspaceCreatorType()
 		var typ workspace.CreatorType
-		workspaceCreator, typ = workspace.NewCreator(ctx, opts.flags.workspace, opts.flags.cacheDir, opts.flags.tempDir, images)
+		workspaceCreator, typ = workspace.NewCreator(ctx, opts.flags.workspace, opts.flags.cacheDir, opts.flags.tempDir, rt, images)
 		if typ == workspace.CreatorTypeVolume {
 			// This creator type requires an additional image, so let's ensure it exists.
 			_, err = imageCache.Ensure(ctx, workspace.DockerVolumeWorkspaceImage)
@@ -415,12 +508,41 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 		execUI.DeterminingWorkspacesSuccess(len(workspaces), len(repos), nil, nil)
 	}
 
+	if opts.flags.cacheMaxSize > 0 {
+		// Prune when we're done, whether or not the run succeeded, since
+		// failed runs fill the cache directory too. A failure to prune isn't
+		// worth failing the run over, though.
+		defer func() {
+			if _, err := executor.PruneCache(opts.flags.cacheDir, executor.CachePruneOpts{
+				MaxSize: int64(opts.flags.cacheMaxSize),
+			}); err != nil {
+				execUI.CachePruneWarning(err)
+			}
+		}()
+	}
+
 	archiveRegistry := repozip.NewArchiveRegistry(opts.client, opts.flags.cacheDir, opts.flags.cleanArchives)
 	logManager := log.NewDiskManager(opts.flags.tempDir, opts.flags.keepLogs)
 	var (
 		coordCache cache.Cache
 	)
-	if opts.flags.disableCache {
+	if native {
+		// Native steps run with whatever tools are installed on the host,
+		// which aren't part of the cache key, so their results can't be
+		// shared with container runs, or with other hosts.
+		coordCache = executor.ExecutionNoOpCache{}
+	} else if opts.flags.cacheURL != "" {
+		coordCache, err = executor.NewRemoteCache(ctx, executor.RemoteCacheOpts{
+			URL:      opts.flags.cacheURL,
+			Local:    executor.NewDiskCache(opts.flags.cacheDir),
+			ReadOnly: opts.flags.cacheReadOnly,
+			Compress: opts.flags.cacheCompress,
+			OnError:  execUI.RemoteCacheWarning,
+		})
+		if err != nil {
+			return errors.Wrap(err, "creating remote execution cache")
+		}
+	} else if opts.flags.disableCache {
 		coordCache = executor.ExecutionNoOpCache{}
 	} else {
 		coordCache = executor.NewDiskCache(opts.flags.cacheDir)
@@ -432,12 +554,14 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 				RepoArchiveRegistry: archiveRegistry,
 				Creator:             workspaceCreator,
 				EnsureImage:         imageCache.Ensure,
+				Runtime:             rt,
 				Parallelism:         parallelism,
 				WorkingDirectory:    batchSpecDir,
 				Timeout:             opts.flags.timeout,
 				TempDir:             opts.flags.tempDir,
 				GlobalEnv:           os.Environ(),
 				ForceRoot:           opts.flags.runAsRoot,
+				Native:              native,
 				BinaryDiffs:         ffs.BinaryDiffs,
 			},
 			Logger:      logManager,
@@ -461,11 +585,9 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 		uncachedTasks []*executor.Task
 	)
 	if opts.flags.clearCache {
-		fmt.Printf("Starting clear caches")
 		if err := coord.ClearCache(ctx, tasks); err != nil {
 			return err
 		}
-		fmt.Printf("cleared caches")
 		uncachedTasks = tasks
 	} else {
 		// Check the cache for completely cached executions.
@@ -474,7 +596,6 @@ func executeBatchSpec(ctx context.Context, opts executeBatchSpecOpts) (err error
 			return err
 		}
 	}
-	fmt.Printf("success")
 	execUI.CheckingCacheSuccess(len(specs), len(uncachedTasks))
 
 	taskExecUI := execUI.ExecutingTasks(*verbose, parallelism)
@@ -670,12 +791,15 @@ func contextCancelOnInterrupt(parent context.Context) (context.Context, func())
 	}
 }
 
-func getBatchParallelism(ctx context.Context, flag int) (int, error) {
+func getBatchParallelism(ctx context.Context, rt docker.Runtime, flag int, native bool) (int, error) {
 	if flag > 0 {
 		return flag, nil
 	}
+	if native {
+		return runtime.NumCPU(), nil
+	}
 
-	return docker.NCPU(ctx)
+	return rt.NCPU(ctx)
 }
 
 func validateSourcegraphVersionConstraint(ctx context.Context, ffs *batches.FeatureFlags) error {
@@ -174,7 +174,7 @@ func executeBatchSpecInWorkspaces(ctx context.Context, flags *executorModeFlags)
 		return errors.New("invalid execution, no steps to process")
 	}
 
-	imageCache := docker.NewImageCache()
+	imageCache := docker.NewImageCache(docker.RuntimeDocker)
 
 	ui.PreparingContainerImages()
 	_, err = service.New(&service.Opts{}).EnsureDockerImages(
@@ -204,6 +204,7 @@ func executeBatchSpecInWorkspaces(ctx context.Context, flags *executorModeFlags)
 		Logger:      &log.NoopTaskLogger{},
 		WC:          workspace.NewExecutorWorkspaceCreator(tempDir, repoDir),
 		EnsureImage: imageCache.Ensure,
+		Runtime:     docker.RuntimeDocker,
 		Task:        task,
 		// TODO: Should be slightly less than the executor timeout. Can we somehow read that?
 		Timeout:          flags.timeout,
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
+	"github.com/sourcegraph/src-cli/internal/bulk"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+// bulkFlags are the flags of commands that can run their mutation for each
+// record of an input file, instead of once.
+type bulkFlags struct {
+	fromFile    *string
+	inputFormat *string
+	concurrency *int
+	rate        *float64
+	resume      *string
+	report      *string
+
+	// fields are the fields of the input records, which correspond to flags
+	// of the command. The first is the field of the lines input format.
+	fields []string
+}
+
+// newBulkFlags registers the bulk flags on flagSet.
+func newBulkFlags(flagSet *flag.FlagSet, fields ...string) *bulkFlags {
+	return &bulkFlags{
+		fromFile:    flagSet.String("from-file", "", fmt.Sprintf("Run the command for each record of the file, or of stdin if \"-\". Records can set the fields %s, which default to the flags of the same name.", strings.Join(fields, ", "))),
+		inputFormat: flagSet.String("input-format", string(bulk.Auto), fmt.Sprintf("The format of -from-file: auto, lines (one %s per line), csv (with a header row) or ndjson.", fields[0])),
+		concurrency: flagSet.Int("concurrency", 4, "The maximum number of records of -from-file to process at once."),
+		rate:        flagSet.Float64("rate", 0, "The maximum number of records of -from-file to start per second. (default: no limit)"),
+		resume:      flagSet.String("resume", "", "Record the records of -from-file that succeeded in this file, and skip the records that it already lists, to retry a partial run."),
+		report:      flagSet.String("report", "", "Write a JSON summary of the run, including the records of -from-file that failed, to this file."),
+		fields:      fields,
+	}
+}
+
+// enabled returns true if the command should run in bulk.
+func (f *bulkFlags) enabled() bool {
+	return *f.fromFile != ""
+}
+
+// run calls fn for each record of the input file, printing the result of each
+// record to stdout and a summary to stderr. It returns an error if any record
+// failed.
+func (f *bulkFlags) run(ctx context.Context, fn bulk.Func) error {
+	format, err := bulk.ParseFormat(*f.inputFormat)
+	if err != nil {
+		return cmderrors.Usage(err.Error())
+	}
+
+	var r io.Reader = os.Stdin
+	if *f.fromFile != "-" {
+		file, err := os.Open(*f.fromFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	items, err := bulk.ReadItems(r, format, f.fields...)
+	if err != nil {
+		return errors.Wrapf(err, "reading %s", *f.fromFile)
+	}
+
+	start := time.Now()
+	report, err := bulk.Run(ctx, items, bulk.Options{
+		Concurrency: *f.concurrency,
+		Rate:        *f.rate,
+		ResumeFile:  *f.resume,
+		DryRun:      *dryRun,
+		Out:         os.Stdout,
+	}, fn)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, report.Summary(time.Since(start)))
+
+	if *f.report != "" {
+		if err := report.WriteFile(*f.report); err != nil {
+			return err
+		}
+	}
+	if report.Failed > 0 {
+		if *f.resume != "" {
+			fmt.Fprintf(os.Stderr, "Run the command again with -resume=%s to retry the records that failed.\n", *f.resume)
+		}
+		return cmderrors.ExitCode(1, nil)
+	}
+	return nil
+}
@@ -23,6 +23,14 @@ type command struct {
 	// handler is the function that is invoked to handle this command.
 	handler func(args []string) error
 
+	// subcommands of the command, if it is a group of commands. Only used to
+	// walk the command tree, e.g. for shell completion.
+	subcommands *commander
+
+	// rawArgs is true if the arguments are passed to the handler without
+	// parsing them as flags first, e.g. for plugins.
+	rawArgs bool
+
 	// flagSet.Usage function to invoke on e.g. -h flag. If nil, a default one is
 	// used.
 	usageFunc func()
@@ -89,12 +97,16 @@ func (c commander) run(flagSet *flag.FlagSet, cmdName, usageText string, args []
 
 		// Parse subcommand flags.
 		args := flagSet.Args()[1:]
-		if err := cmd.flagSet.Parse(args); err != nil {
+		if cmd.rawArgs {
+			// Leave the flags to the handler.
+		} else if err := cmd.flagSet.Parse(args); err != nil {
 			panic(fmt.Sprintf("all registered commands should use flag.ExitOnError: error: %s", err))
 		}
 
 		// Execute the subcommand.
-		if err := cmd.handler(flagSet.Args()[1:]); err != nil {
+		err = cmd.handler(flagSet.Args()[1:])
+		reportDryRun()
+		if err != nil {
 			if _, ok := err.(*cmderrors.UsageError); ok {
 				log.Printf("error: %s\n\n", err)
 				cmd.flagSet.Usage()
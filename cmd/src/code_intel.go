@@ -28,9 +28,10 @@ Use "src code-intel [command] -h" for more information about a command.
 
 	// Register the command.
 	commands = append(commands, &command{
-		flagSet: flagSet,
-		aliases: []string{"code-intel"},
-		handler: handler,
+		flagSet:     flagSet,
+		aliases:     []string{"code-intel"},
+		subcommands: &lsifCommands,
+		handler:     handler,
 		usageFunc: func() {
 			fmt.Println(usage)
 		},
@@ -90,10 +90,16 @@ func handleCodeIntelUpload(args []string) error {
 	if err != nil {
 		return handleUploadError(cfg.AccessToken, err)
 	}
+	// The upload options are given the access token, rather than the client.
+	if _, err := cfg.accessToken(); err != nil {
+		return err
+	}
 
 	client := api.NewClient(api.ClientOpts{
-		Out:   io.Discard,
-		Flags: codeintelUploadFlags.apiFlags,
+		Transport: cfg.transportOpts(),
+		Out:       io.Discard,
+		Flags:     codeintelUploadFlags.apiFlags,
+		DryRun:    dryRunLog,
 	})
 
 	uploadOptions := codeintelUploadOptions(out, isSCIPAvailable)
@@ -34,9 +34,10 @@ Use "src codeowners [command] -h" for more information about a command.
 
 	// Register the command.
 	commands = append(commands, &command{
-		flagSet: flagSet,
-		aliases: []string{"codeowner"},
-		handler: handler,
+		flagSet:     flagSet,
+		aliases:     []string{"codeowner"},
+		subcommands: &codeownersCommands,
+		handler:     handler,
 		usageFunc: func() {
 			fmt.Println(usage)
 		},
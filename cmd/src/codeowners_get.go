@@ -10,6 +10,7 @@ import (
 
 	"github.com/sourcegraph/src-cli/internal/api"
 	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/listoutput"
 )
 
 func init() {
@@ -28,8 +29,10 @@ Examples:
 		fmt.Println(usage)
 	}
 	var (
-		repoFlag = flagSet.String("repo", "", "The repository to attach the data to")
-		apiFlags = api.NewFlags(flagSet)
+		repoFlag   = flagSet.String("repo", "", "The repository to attach the data to")
+		formatFlag = flagSet.String("f", "", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.Repository.Name}}" or "{{.|json}}") (default: the contents of the file)`)
+		outFlags   = newOutputFlags(flagSet, formatFlag, "repository.name", "contents")
+		apiFlags   = api.NewFlags(flagSet)
 	)
 
 	handler := func(args []string) error {
@@ -41,6 +44,11 @@ Examples:
 			return errors.New("provide a repo name using -repo")
 		}
 
+		format, err := outFlags.resolve()
+		if err != nil {
+			return err
+		}
+
 		client := cfg.apiClient(apiFlags, flagSet.Output())
 
 		query := `query GetCodeownersFile(
@@ -73,9 +81,18 @@ Examples:
 			return cmderrors.ExitCode(2, errors.Newf("no codeowners data found for %q", *repoFlag))
 		}
 
-		fmt.Fprintf(os.Stdout, "%s", result.Repository.IngestedCodeowners.Contents)
-
-		return nil
+		if format == listoutput.Template && *formatFlag == "" {
+			fmt.Fprintf(os.Stdout, "%s", result.Repository.IngestedCodeowners.Contents)
+			return nil
+		}
+		out, err := outFlags.writer(false)
+		if err != nil {
+			return err
+		}
+		if err := out.Write(result.Repository.IngestedCodeowners); err != nil {
+			return err
+		}
+		return out.Close()
 	}
 
 	// Register the command.
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `'src completion' generates shell completion scripts for src.
+
+Usage:
+
+	src completion [-name NAME] bash|zsh|fish|powershell
+
+The scripts complete commands, including nested ones like 'src teams members
+add', and their flags. They also complete some values from the Sourcegraph
+instance, such as repository names for -repo-name, team names, and namespaces
+for -namespace, as well as context names.
+
+Examples:
+
+  Load completions for bash in the current shell, or for every new shell:
+
+    	$ source <(src completion bash)
+    	$ src completion bash > /etc/bash_completion.d/src
+
+  Load completions for zsh, from a directory in $fpath:
+
+    	$ src completion zsh > "${fpath[1]}/_src"
+
+  Load completions for fish:
+
+    	$ src completion fish > ~/.config/fish/completions/src.fish
+
+  Load completions for PowerShell, e.g. from your $PROFILE:
+
+    	PS> src completion powershell | Out-String | Invoke-Expression
+
+`
+
+	flagSet := flag.NewFlagSet("completion", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		nameFlag = flagSet.String("name", "src", "The name src is installed as, if it was renamed")
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() != 1 {
+			return cmderrors.Usage("expected exactly one argument: the shell to generate completions for")
+		}
+
+		script, ok := completionScripts[flagSet.Arg(0)]
+		if !ok {
+			return cmderrors.Usagef("unsupported shell %q: expected bash, zsh, fish or powershell", flagSet.Arg(0))
+		}
+		fmt.Print(strings.NewReplacer(
+			"{{name}}", *nameFlag,
+			"{{func}}", regexp.MustCompile(`\W`).ReplaceAllString(*nameFlag, "_"),
+		).Replace(script))
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+
+	// The completion scripts call back into src, which completes the words
+	// given after "--". The last word is the one being completed, and may be
+	// empty.
+	completeFlagSet := flag.NewFlagSet("__complete", flag.ExitOnError)
+	commands = append(commands, &command{
+		flagSet: completeFlagSet,
+		handler: func(args []string) error {
+			if err := completeFlagSet.Parse(args); err != nil {
+				return err
+			}
+			words := completeFlagSet.Args()
+			if len(words) == 0 {
+				words = []string{""}
+			}
+			for _, c := range complete(commands, flag.CommandLine, words, completeValues) {
+				fmt.Println(c)
+			}
+			return nil
+		},
+		usageFunc: func() {},
+	})
+}
+
+// complete returns the completions of the last of the words given on the
+// command line after the program name, by walking the command tree from
+// root. values is called to complete the value of a flag, or a positional
+// argument if the flag name is empty.
+func complete(root commander, global *flag.FlagSet, words []string, values func(path []string, flag, prefix string) []string) []string {
+	cur := words[len(words)-1]
+	words = words[:len(words)-1]
+
+	var (
+		path      []string
+		flagSet   = global
+		cmds      = root
+		valueFlag string
+	)
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if word == "--" {
+			// Only positional arguments follow.
+			cmds = nil
+			continue
+		}
+		if strings.HasPrefix(word, "-") && word != "-" {
+			// Flags can be given with one or two dashes.
+			name := strings.TrimPrefix(strings.TrimPrefix(word, "-"), "-")
+			if strings.Contains(name, "=") || !flagTakesValue(flagSet, name) {
+				continue
+			}
+			// The next word is the value of the flag.
+			if i+1 == len(words) {
+				valueFlag = name
+			}
+			i++
+			continue
+		}
+
+		if cmds == nil {
+			continue
+		}
+		cmd := cmds.find(word)
+		if cmd == nil {
+			// Unknown command: there is nothing more to complete.
+			return nil
+		}
+		path = append(path, cmd.flagSet.Name())
+		flagSet = cmd.flagSet
+		cmds = nil
+		if cmd.subcommands != nil {
+			cmds = *cmd.subcommands
+		}
+	}
+
+	var candidates []string
+	switch {
+	case valueFlag != "":
+		candidates = values(path, valueFlag, cur)
+
+	case strings.HasPrefix(cur, "-"):
+		name := strings.TrimLeft(cur, "-")
+		dashes := cur[:len(cur)-len(name)]
+		if name, value, ok := strings.Cut(name, "="); ok {
+			for _, v := range values(path, name, value) {
+				candidates = append(candidates, dashes+name+"="+v)
+			}
+			break
+		}
+		flagSet.VisitAll(func(f *flag.Flag) {
+			// Deprecated flags are undocumented.
+			if f.Usage != "" {
+				candidates = append(candidates, dashes+f.Name)
+			}
+		})
+
+	case cmds != nil:
+		for _, cmd := range cmds {
+			if name := cmd.flagSet.Name(); !hiddenCommands[strings.Join(append(path, name), " ")] {
+				candidates = append(candidates, name)
+			}
+		}
+
+	default:
+		candidates = values(path, "", cur)
+	}
+
+	seen := map[string]struct{}{}
+	var matches []string
+	for _, c := range candidates {
+		if _, ok := seen[c]; !ok && strings.HasPrefix(c, cur) {
+			seen[c] = struct{}{}
+			matches = append(matches, c)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// hiddenCommands are the commands that aren't completed: internal ones, and
+// the ones that only suggest the right command.
+var hiddenCommands = map[string]bool{
+	"__complete": true,
+	"doc":        true,
+	"publish":    true,
+}
+
+// find returns the command with the given name or alias, or nil.
+func (c commander) find(name string) *command {
+	for _, cmd := range c {
+		if cmd.matches(name) {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// flagTakesValue returns true if the flag is defined in the flag set and isn't
+// a boolean flag, which means that its value may be given as the next word.
+func flagTakesValue(flagSet *flag.FlagSet, name string) bool {
+	f := flagSet.Lookup(name)
+	if f == nil {
+		return false
+	}
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return !ok || !b.IsBoolFlag()
+}
+
+// valueCompletion completes the values of flags of a command, or its
+// positional arguments if flags contains an empty name.
+type valueCompletion struct {
+	// path is the command path, e.g. "teams members add". The empty path
+	// matches the global flags.
+	path     string
+	flags    []string
+	complete func(ctx context.Context, prefix string) ([]string, error)
+}
+
+var valueCompletions = []valueCompletion{
+	{path: "", flags: []string{"context"}, complete: completeContexts},
+	{path: "context use", flags: []string{""}, complete: completeContexts},
+	{path: "context remove", flags: []string{""}, complete: completeContexts},
+
+	{path: "repos get", flags: []string{"name"}, complete: completeRepositories},
+	{path: "repos add-metadata", flags: []string{"repo-name"}, complete: completeRepositories},
+	{path: "repos update-metadata", flags: []string{"repo-name"}, complete: completeRepositories},
+	{path: "repos delete-metadata", flags: []string{"repo-name"}, complete: completeRepositories},
+	{path: "codeowners create", flags: []string{"repo"}, complete: completeRepositories},
+	{path: "codeowners get", flags: []string{"repo"}, complete: completeRepositories},
+	{path: "codeowners update", flags: []string{"repo"}, complete: completeRepositories},
+	{path: "codeowners delete", flags: []string{"repo"}, complete: completeRepositories},
+
+	{path: "teams create", flags: []string{"parent-team"}, complete: completeTeams},
+	{path: "teams list", flags: []string{"parent-team"}, complete: completeTeams},
+	{path: "teams update", flags: []string{"name", "parent-team"}, complete: completeTeams},
+	{path: "teams delete", flags: []string{"name"}, complete: completeTeams},
+	{path: "teams members list", flags: []string{"name"}, complete: completeTeams},
+	{path: "teams members add", flags: []string{"team-name"}, complete: completeTeams},
+	{path: "teams members remove", flags: []string{"team-name"}, complete: completeTeams},
+
+	{path: "batch apply", flags: []string{"namespace", "n"}, complete: completeNamespaces},
+	{path: "batch preview", flags: []string{"namespace", "n"}, complete: completeNamespaces},
+	{path: "batch remote", flags: []string{"namespace", "n"}, complete: completeNamespaces},
+}
+
+// completionTimeout bounds the time spent on requests to the instance, so
+// that the shell doesn't hang on a slow or unreachable instance.
+const completionTimeout = 2 * time.Second
+
+// completeValues completes values with valueCompletions. Errors are ignored,
+// since there is nowhere to report them.
+func completeValues(path []string, flag, prefix string) []string {
+	p := strings.Join(path, " ")
+	for _, vc := range valueCompletions {
+		if vc.path != p {
+			continue
+		}
+		for _, f := range vc.flags {
+			if f != flag {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+			defer cancel()
+			values, _ := vc.complete(ctx, prefix)
+			return values
+		}
+	}
+	return nil
+}
+
+func completeContexts(ctx context.Context, prefix string) ([]string, error) {
+	f, err := loadConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	return f.contextNames(), nil
+}
+
+func completeRepositories(ctx context.Context, prefix string) ([]string, error) {
+	query := `query CompleteRepositories($query: String, $first: Int) {
+  repositories(query: $query, first: $first) {
+    nodes {
+      name
+    }
+  }
+}`
+	var result struct {
+		Repositories struct {
+			Nodes []struct{ Name string }
+		}
+	}
+	if ok, err := completionClient().NewRequest(query, map[string]interface{}{
+		"query": prefix,
+		"first": 100,
+	}).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(result.Repositories.Nodes))
+	for _, node := range result.Repositories.Nodes {
+		names = append(names, node.Name)
+	}
+	return names, nil
+}
+
+func completeTeams(ctx context.Context, prefix string) ([]string, error) {
+	query := `query CompleteTeams($search: String, $first: Int) {
+  teams(search: $search, first: $first) {
+    nodes {
+      name
+    }
+  }
+}`
+	var result struct {
+		Teams struct {
+			Nodes []struct{ Name string }
+		}
+	}
+	if ok, err := completionClient().NewRequest(query, map[string]interface{}{
+		"search": prefix,
+		"first":  100,
+	}).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(result.Teams.Nodes))
+	for _, node := range result.Teams.Nodes {
+		names = append(names, node.Name)
+	}
+	return names, nil
+}
+
+// completeNamespaces completes the namespaces the current user can usually
+// create batch changes in: their own, and those of their organizations.
+func completeNamespaces(ctx context.Context, prefix string) ([]string, error) {
+	query := `query CompleteNamespaces {
+  currentUser {
+    username
+    organizations {
+      nodes {
+        name
+      }
+    }
+  }
+}`
+	var result struct {
+		CurrentUser *struct {
+			Username      string
+			Organizations struct {
+				Nodes []struct{ Name string }
+			}
+		}
+	}
+	if ok, err := completionClient().NewQuery(query).Do(ctx, &result); err != nil || !ok {
+		return nil, err
+	}
+	if result.CurrentUser == nil {
+		return nil, nil
+	}
+
+	names := []string{result.CurrentUser.Username}
+	for _, org := range result.CurrentUser.Organizations.Nodes {
+		names = append(names, org.Name)
+	}
+	return names, nil
+}
+
+// completionClient returns a client that doesn't retry requests, since the
+// user is waiting for the completions.
+func completionClient() api.Client {
+	flagSet := flag.NewFlagSet("__complete", flag.ContinueOnError)
+	flags := api.NewFlags(flagSet)
+	_ = flagSet.Parse([]string{"-max-retries=0"})
+	return cfg.apiClient(flags, os.Stderr)
+}
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for {{name}}
+
+_{{func}}_completion() {
+	# Split the line ourselves, since COMP_WORDS is also split on "=".
+	local line="${COMP_LINE:0:COMP_POINT}"
+	local -a words
+	read -ra words <<< "$line"
+	if [[ -z "$line" || "$line" == *" " ]]; then
+		words+=("")
+	fi
+	local cur="${words[${#words[@]}-1]}"
+
+	local IFS=$'\n'
+	local -a candidates
+	candidates=($("${words[0]}" __complete -- "${words[@]:1}" 2>/dev/null))
+
+	# Bash only replaces the part of the word after "=".
+	if [[ "$cur" == *=* ]]; then
+		candidates=("${candidates[@]#*=}")
+	fi
+	COMPREPLY=("${candidates[@]}")
+}
+
+complete -o default -F _{{func}}_completion {{name}}
+`,
+
+	"zsh": `#compdef {{name}}
+
+# zsh completion for {{name}}
+
+_{{func}}() {
+	local -a candidates
+	candidates=("${(@f)$(${words[1]} __complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	if [[ ${#candidates} -eq 0 || -z "${candidates[1]}" ]]; then
+		_files
+		return
+	fi
+	compadd -Q -- "${candidates[@]}"
+}
+
+if [[ "$funcstack[1]" == "_{{func}}" ]]; then
+	_{{func}} "$@"
+else
+	compdef _{{func}} {{name}}
+fi
+`,
+
+	"fish": `# fish completion for {{name}}
+
+function __{{func}}_complete
+	set -l tokens (commandline -opc) (commandline -ct)
+	set -l candidates ($tokens[1] __complete -- $tokens[2..-1] 2>/dev/null)
+	if test (count $candidates) -eq 0
+		__fish_complete_path (commandline -ct)
+		return
+	end
+	printf '%s\n' $candidates
+end
+
+complete -c {{name}} -f -a '(__{{func}}_complete)'
+`,
+
+	"powershell": `# PowerShell completion for {{name}}
+
+Register-ArgumentCompleter -Native -CommandName '{{name}}' -ScriptBlock {
+	param($wordToComplete, $commandAst, $cursorPosition)
+
+	$words = @($commandAst.CommandElements |
+		Where-Object { $_.Extent.StartOffset -lt $cursorPosition } |
+		ForEach-Object { $_.ToString() })
+	$program = $words[0]
+	$arguments = @($words | Select-Object -Skip 1 | ForEach-Object { "'" + ($_ -replace "'", "''") + "'" })
+	if ($wordToComplete -eq '') {
+		# Pass the empty word being completed, which PowerShell drops unless
+		# arguments are passed the legacy way.
+		$arguments += '""'
+	}
+
+	$PSNativeCommandArgumentPassing = 'Legacy'
+	$candidates = Invoke-Expression "& '$program' __complete -- $($arguments -join ' ')" 2>$null
+
+	$candidates | Where-Object { $_ } | ForEach-Object {
+		[System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+	}
+}
+`,
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestComplete(t *testing.T) {
+	newFlagSet := func(name string) *flag.FlagSet {
+		return flag.NewFlagSet(name, flag.ContinueOnError)
+	}
+
+	global := newFlagSet("src")
+	global.Bool("v", false, "print verbose output")
+	global.String("context", "", "use the named connection profile")
+	global.String("endpoint", "", "")
+
+	add := newFlagSet("add")
+	add.String("team-name", "", "The team name")
+	add.Bool("skip-unmatched-members", false, "Skip unmatched members")
+	members := commander{{flagSet: add}, {flagSet: newFlagSet("remove")}}
+
+	teamsList := newFlagSet("list")
+	teamsList.Int("first", 0, "Number of teams")
+	teams := commander{
+		{flagSet: teamsList},
+		{flagSet: newFlagSet("members"), aliases: []string{"member"}, subcommands: &members},
+	}
+
+	root := commander{
+		{flagSet: newFlagSet("teams"), aliases: []string{"team"}, subcommands: &teams},
+		{flagSet: newFlagSet("search")},
+		{flagSet: newFlagSet("doc")},
+	}
+
+	values := func(path []string, flag, prefix string) []string {
+		// Echo what is being completed, so that the test can check it.
+		return []string{strings.Join(append(path, flag), "/") + ":" + prefix}
+	}
+
+	for name, tc := range map[string]struct {
+		words []string
+		want  []string
+	}{
+		"commands": {
+			words: []string{""},
+			want:  []string{"search", "teams"},
+		},
+		"command prefix": {
+			words: []string{"t"},
+			want:  []string{"teams"},
+		},
+		"nested commands through alias": {
+			words: []string{"team", "member", ""},
+			want:  []string{"add", "remove"},
+		},
+		"global flags": {
+			words: []string{"-"},
+			want:  []string{"-context", "-v"},
+		},
+		"flags with two dashes": {
+			words: []string{"teams", "members", "add", "--t"},
+			want:  []string{"--team-name"},
+		},
+		"flags after other flags": {
+			words: []string{"-v", "-context", "prod", "teams", "members", "add", "-skip-unmatched-members", "-"},
+			want:  []string{"-skip-unmatched-members", "-team-name"},
+		},
+		"flag value": {
+			words: []string{"teams", "members", "add", "-team-name", "ow"},
+			want:  nil,
+		},
+		"flag value matching prefix": {
+			words: []string{"teams", "members", "add", "-team-name", "teams"},
+			want:  []string{"teams/members/add/team-name:teams"},
+		},
+		"flag value after equals": {
+			words: []string{"-context="},
+			want:  []string{"-context=context:"},
+		},
+		"global flag value": {
+			words: []string{"-context", ""},
+			want:  []string{"context:"},
+		},
+		"positional argument": {
+			words: []string{"teams", "list", "-first", "10", ""},
+			want:  []string{"teams/list/:"},
+		},
+		"unknown command": {
+			words: []string{"nope", ""},
+			want:  nil,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			have := complete(root, global, tc.words, values)
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("unexpected completions (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 
 	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/listoutput"
 )
 
 func init() {
@@ -35,6 +37,7 @@ Examples:
 	var (
 		subjectFlag = flagSet.String("subject", "", "The ID of the settings subject whose settings to get. (default: authenticated user)")
 		formatFlag  = flagSet.String("f", "{{.|jsonIndent}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.|json}}")`)
+		outFlags    = newOutputFlags(flagSet, formatFlag)
 		apiFlags    = api.NewFlags(flagSet)
 	)
 
@@ -44,7 +47,11 @@ Examples:
 			return err
 		}
 
-		tmpl, err := parseTemplate(*formatFlag)
+		format, err := outFlags.resolve()
+		if err != nil {
+			return err
+		}
+		out, err := outFlags.writer(false)
 		if err != nil {
 			return err
 		}
@@ -78,7 +85,21 @@ Examples:
 		} else if result.SettingsSubject != nil {
 			final = result.SettingsSubject.SettingsCascade.Final
 		}
-		return execTemplate(tmpl, final)
+
+		// Templates are given the settings as JSON text, while the other
+		// formats print the settings themselves.
+		var v interface{} = final
+		if format != listoutput.Template {
+			data, err := jsonxToJSON(final)
+			if err != nil {
+				return err
+			}
+			v = json.RawMessage(data)
+		}
+		if err := out.Write(v); err != nil {
+			return err
+		}
+		return out.Close()
 	}
 
 	// Register the command.
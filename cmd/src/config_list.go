@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/listoutput"
 	"golang.org/x/net/context"
 )
 
@@ -31,6 +32,7 @@ Examples:
 	var (
 		subjectFlag = flagSet.String("subject", "", "The ID of the settings subject whose settings to list. (default: authenticated user)")
 		formatFlag  = flagSet.String("f", "", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.|json}}")`)
+		outFlags    = newOutputFlags(flagSet, formatFlag, "ID", "SettingsURL", "LatestSettings.CreatedAt")
 		apiFlags    = api.NewFlags(flagSet)
 	)
 
@@ -40,18 +42,22 @@ Examples:
 			return err
 		}
 
-		var formatStr string
-		if *formatFlag != "" {
-			formatStr = *formatFlag
-		} else {
+		format, err := outFlags.resolve()
+		if err != nil {
+			return err
+		}
+
+		if *formatFlag == "" {
 			// Set default here instead of in flagSet.String because it is very long and makes the usage message ugly.
-			formatStr = `{{range .Subjects -}}
+			*formatFlag = `{{range .Subjects -}}
 # {{.SettingsURL}}:{{with .LatestSettings}}
 {{.Contents}}
 {{- else}} (empty){{- end}}
 {{end}}`
 		}
-		tmpl, err := parseTemplate(formatStr)
+		// Templates are given the whole cascade, while the other formats
+		// print the settings of every subject in it.
+		out, err := outFlags.writer(format != listoutput.Template)
 		if err != nil {
 			return err
 		}
@@ -85,7 +91,18 @@ Examples:
 		} else if result.SettingsSubject != nil {
 			cascade = &result.SettingsSubject.SettingsCascade
 		}
-		return execTemplate(tmpl, cascade)
+		if format == listoutput.Template {
+			if err := out.Write(cascade); err != nil {
+				return err
+			}
+		} else if cascade != nil {
+			for _, subject := range cascade.Subjects {
+				if err := out.Write(subject); err != nil {
+					return err
+				}
+			}
+		}
+		return out.Close()
 	}
 
 	// Register the command.
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+var contextCommands commander
+
+func init() {
+	usage := `'src context' manages named connection profiles ("contexts") in the src config file.
+
+A context is an endpoint together with the access token, additional headers,
+and TLS and proxy settings to use with it. The current context is used by every
+command, unless another one is selected with the global -context flag:
+
+	$ src -context staging repos list
+
+The SRC_ENDPOINT and SRC_ACCESS_TOKEN environment variables, and the other
+environment variables listed in "src help", take precedence over the settings
+of the selected context.
+
+Usage:
+
+	src context command [command options]
+
+The commands are:
+
+	list      lists the contexts
+	use       sets the current context
+	add       adds a context
+	remove    removes a context
+
+Use "src context [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("context", flag.ExitOnError)
+	handler := func(args []string) error {
+		contextCommands.run(flagSet, "src context", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet:     flagSet,
+		subcommands: &contextCommands,
+		handler:     handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
+
+// configFile is the config file, as read from and written to disk by the
+// context commands. Unlike config, it preserves every top-level key of the
+// file, and doesn't apply any overrides from the environment or flags.
+type configFile struct {
+	Path           string
+	CurrentContext string
+	Contexts       map[string]*contextConfig
+
+	raw map[string]json.RawMessage
+}
+
+// loadConfigFile reads the config file. A missing file is treated as empty.
+func loadConfigFile() (*configFile, error) {
+	homeDir, err := userHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	path, _ := configFilePath(homeDir)
+
+	f := &configFile{
+		Path:     path,
+		Contexts: map[string]*contextConfig{},
+		raw:      map[string]json.RawMessage{},
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &f.raw); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", path)
+	}
+	if v, ok := f.raw["currentContext"]; ok {
+		if err := json.Unmarshal(v, &f.CurrentContext); err != nil {
+			return nil, errors.Wrapf(err, "parsing currentContext in %s", path)
+		}
+	}
+	if v, ok := f.raw["contexts"]; ok {
+		if err := json.Unmarshal(v, &f.Contexts); err != nil {
+			return nil, errors.Wrapf(err, "parsing contexts in %s", path)
+		}
+		if f.Contexts == nil {
+			f.Contexts = map[string]*contextConfig{}
+		}
+	}
+	return f, nil
+}
+
+// save writes the config file. Since it contains access tokens, it is only
+// readable by the current user.
+func (f *configFile) save() error {
+	if f.CurrentContext == "" {
+		delete(f.raw, "currentContext")
+	} else if err := f.setRaw("currentContext", f.CurrentContext); err != nil {
+		return err
+	}
+	if len(f.Contexts) == 0 {
+		delete(f.raw, "contexts")
+	} else if err := f.setRaw("contexts", f.Contexts); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(f.raw, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), ".src-config-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+func (f *configFile) setRaw(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f.raw[key] = data
+	return nil
+}
+
+// contextNames returns the names of the contexts in alphabetical order.
+func (f *configFile) contextNames() []string {
+	names := make([]string, 0, len(f.Contexts))
+	for name := range f.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
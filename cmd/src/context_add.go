@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Add a context named "staging":
+
+    	$ src context add -endpoint https://staging.sourcegraph.example.com -access-token $TOKEN staging
+
+  Add a context for an instance behind an auth proxy, and switch to it:
+
+    	$ src context add -endpoint https://sourcegraph.example.com -header 'X-Auth: secret' -use prod
+
+  Add a context for a local instance with a self-signed certificate:
+
+    	$ src context add -endpoint https://localhost:3443 -ca-bundle ~/dev/ca.pem dev
+
+To verify the endpoint and access token before saving them, use
+'src login -save-context NAME' instead.
+
+`
+
+	flagSet := flag.NewFlagSet("add", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src context %s' [flags] NAME:\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		endpointFlag    = flagSet.String("endpoint", "", `The URL of the Sourcegraph instance. (required)`)
+		accessTokenFlag = flagSet.String("access-token", "", `The access token to authenticate with.`)
+		caBundleFlag    = flagSet.String("ca-bundle", "", `Path to a PEM file of additional certificate authorities to trust.`)
+		clientCertFlag  = flagSet.String("client-cert", "", `Path to a PEM client certificate to present for mutual TLS.`)
+		clientKeyFlag   = flagSet.String("client-key", "", `Path to the PEM private key of the client certificate.`)
+		proxyFlag       = flagSet.String("proxy", "", `Proxy to connect through (http, https, socks5 or unix:///path/to/socket).`)
+		useFlag         = flagSet.Bool("use", false, `Make the context the current one.`)
+		forceFlag       = flagSet.Bool("force", false, `Replace the context if it already exists.`)
+		headersFlag     = headerFlags{}
+	)
+	flagSet.Var(headersFlag, "header", `An additional header to send with each request, as 'Name: value'. Can be given multiple times.`)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() != 1 {
+			return cmderrors.Usage("expected exactly one argument: the name of the context")
+		}
+		name := flagSet.Arg(0)
+		if *endpointFlag == "" {
+			return cmderrors.Usage("-endpoint must be specified")
+		}
+
+		c := &contextConfig{
+			Endpoint:          cleanEndpoint(*endpointFlag),
+			AccessToken:       *accessTokenFlag,
+			AdditionalHeaders: headersFlag,
+			CABundle:          *caBundleFlag,
+			ClientCert:        *clientCertFlag,
+			ClientKey:         *clientKeyFlag,
+			Proxy:             *proxyFlag,
+		}
+		if _, ok := c.AdditionalHeaders["authorization"]; ok && c.AccessToken != "" {
+			return cmderrors.Usage("an Authorization header and -access-token cannot be used together")
+		}
+		if err := (api.TransportOpts{
+			CABundlePath:   c.CABundle,
+			ClientCertPath: c.ClientCert,
+			ClientKeyPath:  c.ClientKey,
+			Proxy:          c.Proxy,
+		}).Validate(); err != nil {
+			return cmderrors.Usage(err.Error())
+		}
+
+		f, err := loadConfigFile()
+		if err != nil {
+			return err
+		}
+		if _, ok := f.Contexts[name]; ok && !*forceFlag {
+			return errors.Newf("context %q already exists in %s; use -force to replace it", name, f.Path)
+		}
+		f.Contexts[name] = c
+		if *useFlag {
+			f.CurrentContext = name
+		}
+		if err := f.save(); err != nil {
+			return err
+		}
+
+		if *useFlag {
+			fmt.Printf("Context %q added, and made the current context.\n", name)
+		} else {
+			fmt.Printf("Context %q added. Use it with 'src context use %s' or 'src -context %s'.\n", name, name, name)
+		}
+		return nil
+	}
+
+	// Register the command.
+	contextCommands = append(contextCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// headerFlags collects repeated 'Name: value' flags. Header names are
+// lowercased, like the ones given through the environment.
+type headerFlags map[string]string
+
+func (h headerFlags) String() string {
+	headers := make([]string, 0, len(h))
+	for k, v := range h {
+		headers = append(headers, k+": "+v)
+	}
+	sort.Strings(headers)
+	return strings.Join(headers, ", ")
+}
+
+func (h headerFlags) Set(s string) error {
+	k, v, ok := strings.Cut(s, ":")
+	k = strings.ToLower(strings.TrimSpace(k))
+	v = strings.TrimSpace(v)
+	if !ok || k == "" || v == "" {
+		return errors.Newf("expected 'Name: value', got %q", s)
+	}
+	h[k] = v
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  List the contexts, marking the current one with an asterisk:
+
+    	$ src context list
+
+`
+
+	flagSet := flag.NewFlagSet("list", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src context %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		f, err := loadConfigFile()
+		if err != nil {
+			return err
+		}
+		if len(f.Contexts) == 0 {
+			fmt.Printf("No contexts are defined in %s. Add one with 'src context add' or 'src login -save-context'.\n", f.Path)
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CURRENT\tNAME\tENDPOINT")
+		for _, name := range f.contextNames() {
+			current := ""
+			if name == cfg.Context {
+				current = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", current, name, f.Contexts[name].Endpoint)
+		}
+		return w.Flush()
+	}
+
+	// Register the command.
+	contextCommands = append(contextCommands, &command{
+		flagSet:   flagSet,
+		aliases:   []string{"ls"},
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
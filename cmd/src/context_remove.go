@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Remove the context named "staging":
+
+    	$ src context remove staging
+
+Removing the current context makes src fall back to the top-level settings of
+the config file.
+
+`
+
+	flagSet := flag.NewFlagSet("remove", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src context %s' NAME:\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() != 1 {
+			return cmderrors.Usage("expected exactly one argument: the name of the context")
+		}
+		name := flagSet.Arg(0)
+
+		f, err := loadConfigFile()
+		if err != nil {
+			return err
+		}
+		if _, ok := f.Contexts[name]; !ok {
+			return cmderrors.Usagef("context %q is not defined in %s", name, f.Path)
+		}
+		delete(f.Contexts, name)
+		if f.CurrentContext == name {
+			f.CurrentContext = ""
+		}
+		if err := f.save(); err != nil {
+			return err
+		}
+
+		fmt.Printf("Context %q removed.\n", name)
+		return nil
+	}
+
+	// Register the command.
+	contextCommands = append(contextCommands, &command{
+		flagSet:   flagSet,
+		aliases:   []string{"rm"},
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
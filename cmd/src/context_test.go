@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConfigFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	testHomeDir = tmpDir
+	t.Cleanup(func() { testHomeDir = "" })
+
+	path := filepath.Join(tmpDir, "src-config.json")
+	if err := os.WriteFile(path, []byte(`{"endpoint": "https://example.com", "accessToken": "deadbeef"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := loadConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Path != path {
+		t.Errorf("unexpected path: have %q, want %q", f.Path, path)
+	}
+	if len(f.Contexts) != 0 || f.CurrentContext != "" {
+		t.Fatalf("unexpected contexts: %+v", f)
+	}
+
+	f.Contexts["staging"] = &contextConfig{Endpoint: "https://staging.example.com", AccessToken: "abc"}
+	f.Contexts["dev"] = &contextConfig{Endpoint: "https://localhost:3443"}
+	f.CurrentContext = "staging"
+	if err := f.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0600 {
+		t.Errorf("unexpected permissions: %v", perm)
+	}
+
+	// The top-level settings must be preserved, and the contexts must be
+	// readable by readConfig.
+	cfg, err := readConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Context != "staging" || cfg.Endpoint != "https://staging.example.com" || cfg.AccessToken != "abc" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if raw["endpoint"] != "https://example.com" || raw["accessToken"] != "deadbeef" {
+		t.Errorf("top-level settings were not preserved: %s", data)
+	}
+
+	// Removing every context removes the keys altogether.
+	f, err = loadConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"dev", "staging"}, f.contextNames()); diff != "" {
+		t.Errorf("unexpected context names (-want +have):\n%s", diff)
+	}
+	f.Contexts = map[string]*contextConfig{}
+	f.CurrentContext = ""
+	if err := f.save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw = nil
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]interface{}{"endpoint": "https://example.com", "accessToken": "deadbeef"}, raw); diff != "" {
+		t.Errorf("unexpected file (-want +have):\n%s", diff)
+	}
+}
+
+func TestHeaderFlags(t *testing.T) {
+	h := headerFlags{}
+	for _, s := range []string{"X-Auth: secret", "Cookie:a=b: c"} {
+		if err := h.Set(s); err != nil {
+			t.Fatalf("unexpected error for %q: %s", s, err)
+		}
+	}
+	if diff := cmp.Diff(headerFlags{"x-auth": "secret", "cookie": "a=b: c"}, h); diff != "" {
+		t.Errorf("unexpected headers (-want +have):\n%s", diff)
+	}
+	if have, want := h.String(), "cookie: a=b: c, x-auth: secret"; have != want {
+		t.Errorf("unexpected string: have %q, want %q", have, want)
+	}
+
+	for _, s := range []string{"X-Auth", ": value", "X-Auth: "} {
+		if err := h.Set(s); err == nil {
+			t.Errorf("unexpected nil error for %q", s)
+		}
+	}
+}
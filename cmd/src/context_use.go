@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  Use the context named "staging" for every following command:
+
+    	$ src context use staging
+
+`
+
+	flagSet := flag.NewFlagSet("use", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src context %s' NAME:\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if flagSet.NArg() != 1 {
+			return cmderrors.Usage("expected exactly one argument: the name of the context")
+		}
+		name := flagSet.Arg(0)
+
+		f, err := loadConfigFile()
+		if err != nil {
+			return err
+		}
+		if _, ok := f.Contexts[name]; !ok {
+			return cmderrors.Usagef("context %q is not defined in %s", name, f.Path)
+		}
+		f.CurrentContext = name
+		if err := f.save(); err != nil {
+			return err
+		}
+
+		fmt.Printf("Switched to context %q.\n", name)
+		return nil
+	}
+
+	// Register the command.
+	contextCommands = append(contextCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
@@ -34,9 +34,10 @@ src debug has access to flags on src -- Ex: src -v kube -o foo.zip
 
 	// Register the command.
 	commands = append(commands, &command{
-		flagSet:   flagSet,
-		aliases:   []string{},
-		handler:   handler,
-		usageFunc: func() { fmt.Println(usage) },
+		flagSet:     flagSet,
+		aliases:     []string{},
+		subcommands: &debugCommands,
+		handler:     handler,
+		usageFunc:   func() { fmt.Println(usage) },
 	})
 }
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+// dryRunLog collects the mutations that weren't executed, if -dry-run is set.
+var dryRunLog *api.DryRun
+
+func dryRunFromEnv() bool {
+	v, _ := strconv.ParseBool(os.Getenv("SRC_DRY_RUN"))
+	return v
+}
+
+// reportDryRun prints the changes that would have been made by the command,
+// if -dry-run is set and any requests weren't sent. It's called once the
+// command is done.
+func reportDryRun() {
+	if dryRunLog == nil || len(dryRunLog.Operations()) == 0 {
+		return
+	}
+	_ = dryRunLog.WriteSummary(os.Stderr)
+
+	// Nested commands are run by their parent's handler, so make sure that
+	// the summary is only printed once.
+	dryRunLog = nil
+}
@@ -36,9 +36,10 @@ Use "src extsvc [command] -h" for more information about a command.
 
 	// Register the command.
 	commands = append(commands, &command{
-		flagSet: flagSet,
-		aliases: []string{"extsvc", "external-service"},
-		handler: handler,
+		flagSet:     flagSet,
+		aliases:     []string{"extsvc", "external-service"},
+		subcommands: &extsvcCommands,
+		handler:     handler,
 		usageFunc: func() {
 			fmt.Println(usage)
 		},
@@ -56,24 +57,21 @@ type externalService struct {
 var errServiceNotFound = errors.New("no such external service")
 
 func lookupExternalService(ctx context.Context, client api.Client, byID, byName string) (*externalService, error) {
-	var result struct {
-		ExternalServices struct {
-			Nodes []*externalService
+	var found *externalService
+	if err := api.Paginate(ctx, client, api.PaginatedQuery{
+		Query: externalServicesListQuery,
+		Path:  []string{"externalServices"},
+		Limit: -1,
+	}, func(svc *externalService) error {
+		if found == nil && ((byID != "" && svc.ID == byID) || (byName != "" && svc.DisplayName == byName)) {
+			found = svc
 		}
-	}
-	if ok, err := client.NewRequest(externalServicesListQuery, map[string]interface{}{
-		"first": 99999,
-	}).Do(ctx, &result); err != nil || !ok {
+		return nil
+	}); err != nil {
 		return nil, err
 	}
-
-	for _, svc := range result.ExternalServices.Nodes {
-		if byID != "" && svc.ID == byID {
-			return svc, nil
-		}
-		if byName != "" && svc.DisplayName == byName {
-			return svc, nil
-		}
+	if found != nil {
+		return found, nil
 	}
 	return nil, errServiceNotFound
 }
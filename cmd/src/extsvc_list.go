@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/listoutput"
 )
 
 func init() {
@@ -31,6 +32,7 @@ Examples:
 	var (
 		firstFlag  = flagSet.Int("first", -1, "Return only the first n external services. (use -1 for unlimited)")
 		formatFlag = flagSet.String("f", "", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.|json}}")`)
+		outFlags   = newOutputFlags(flagSet, formatFlag, "ID", "Kind", "DisplayName")
 		apiFlags   = api.NewFlags(flagSet)
 	)
 
@@ -39,9 +41,30 @@ Examples:
 			return err
 		}
 
-		first := *firstFlag
-		if first == -1 {
-			first = 9999999 // GraphQL API doesn't support negative for unlimited query
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		format, err := outFlags.resolve()
+		if err != nil {
+			return err
+		}
+		if format != listoutput.Template {
+			// Other formats than templates print the external services
+			// themselves, rather than the connection.
+			out, err := outFlags.writer(true)
+			if err != nil {
+				return err
+			}
+			if err := api.Paginate(ctx, client, api.PaginatedQuery{
+				Query: externalServicesListQuery,
+				Path:  []string{"externalServices"},
+				Limit: *firstFlag,
+			}, func(svc externalService) error {
+				return out.Write(svc)
+			}); err != nil {
+				return err
+			}
+			return out.Close()
 		}
 
 		var formatStr string
@@ -56,14 +79,17 @@ Examples:
 			return err
 		}
 
-		ctx := context.Background()
-		client := cfg.apiClient(apiFlags, flagSet.Output())
-
-		queryVars := map[string]interface{}{
-			"first": first,
-		}
 		var result externalServicesListResult
-		if ok, err := client.NewRequest(externalServicesListQuery, queryVars).Do(ctx, &result); err != nil || !ok {
+		if err := api.PaginatePages(ctx, client, api.PaginatedQuery{
+			Query: externalServicesListQuery,
+			Path:  []string{"externalServices"},
+			Limit: *firstFlag,
+		}, func(page *api.Connection[map[string]interface{}]) error {
+			result.ExternalServices.Nodes = append(result.ExternalServices.Nodes, page.Nodes...)
+			result.ExternalServices.TotalCount = page.TotalCount
+			result.ExternalServices.PageInfo.HasNextPage = page.PageInfo.HasNextPage
+			return nil
+		}); err != nil {
 			return err
 		}
 		return execTemplate(tmpl, result.ExternalServices)
@@ -78,8 +104,8 @@ Examples:
 }
 
 const externalServicesListQuery = `
-	query ($first: Int!) {
-		externalServices(first: $first) {
+	query ExternalServices($first: Int, $after: String) {
+		externalServices(first: $first, after: $after) {
 			nodes {
 				id
 				kind
@@ -90,6 +116,7 @@ const externalServicesListQuery = `
 			}
 			totalCount
 			pageInfo {
+				endCursor
 				hasNextPage
 			}
 		}
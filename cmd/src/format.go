@@ -80,6 +80,12 @@ func parseTemplate(text string) (*template.Template, error) {
 			}
 			return humanize.Time(t), nil
 		},
+		"humanizeTime": func(t time.Time) string {
+			return humanize.Time(t)
+		},
+		"humanizeBytes": func(n int64) string {
+			return humanize.IBytes(uint64(n))
+		},
 
 		// Register search-specific template functions
 		"searchSequentialLineNumber":        searchTemplateFuncs["searchSequentialLineNumber"],
@@ -5,11 +5,18 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
+
+	"github.com/pkg/browser"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/credentials"
 )
 
 func init() {
@@ -17,7 +24,7 @@ func init() {
 
 Usage:
 
-    src login SOURCEGRAPH_URL
+    src login [flags] SOURCEGRAPH_URL
 
 Examples:
 
@@ -28,6 +35,31 @@ Examples:
   Authenticate to Sourcegraph.com:
 
     $ src login https://sourcegraph.com
+
+  Authenticate to a Sourcegraph instance, and save the verified endpoint and
+  access token as the connection profile "prod" (see 'src context'):
+
+    $ src login -save-context prod https://sourcegraph.example.com
+
+  Authenticate to a Sourcegraph instance, and store the verified access token
+  in the OS keyring instead of the SRC_ACCESS_TOKEN environment variable:
+
+    $ SRC_ACCESS_TOKEN=sgp_... src login -keyring https://sourcegraph.example.com
+
+  Create a new access token in the browser, and save it as a connection profile
+  named after the instance:
+
+    $ src login -web https://sourcegraph.example.com
+
+  Create a new access token by signing in with a username and password, for
+  example on a machine without a browser. The password is read from the
+  terminal, or from stdin if it isn't a terminal:
+
+    $ src login -password -username alice https://sourcegraph.example.com
+
+Access tokens can also be looked up by an external credential helper, which is
+configured as the "credentialHelper" key of the src config file. See
+https://github.com/sourcegraph/src-cli#readme for more information.
 `
 
 	flagSet := flag.NewFlagSet("login", flag.ExitOnError)
@@ -37,7 +69,13 @@ Examples:
 	}
 
 	var (
-		apiFlags = api.NewFlags(flagSet)
+		saveContextFlag = flagSet.String("save-context", "", "Save the endpoint and access token as the named connection profile and make it the current one, once they are verified")
+		keyringFlag     = flagSet.Bool("keyring", false, "Store the access token in the OS keyring once it is verified, and look it up there from now on")
+		webFlag         = flagSet.Bool("web", false, "Create a new access token in the browser")
+		webTimeoutFlag  = flagSet.Duration("web-timeout", 5*time.Minute, "How long to wait for the access token to be created in the browser")
+		passwordFlag    = flagSet.Bool("password", false, "Create a new access token by signing in with a username and password")
+		usernameFlag    = flagSet.String("username", "", "The username or email to sign in with, when using -password")
+		apiFlags        = api.NewFlags(flagSet)
 	)
 
 	handler := func(args []string) error {
@@ -52,9 +90,73 @@ Examples:
 			return cmderrors.Usage("expected exactly one argument: the Sourcegraph URL, or SRC_ENDPOINT to be set")
 		}
 
+		ctx := context.Background()
+		if *webFlag && *passwordFlag {
+			return cmderrors.Usage("-web and -password cannot be used together")
+		}
+		if *passwordFlag && *usernameFlag == "" {
+			return cmderrors.Usage("-username must be specified when using -password")
+		}
+		if *webFlag || *passwordFlag {
+			var token string
+			if *webFlag {
+				ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+				defer cancel()
+				ctx, cancel = context.WithTimeout(ctx, *webTimeoutFlag)
+				defer cancel()
+
+				var err error
+				if token, err = loginWeb(ctx, endpoint, tokenNote(), os.Stdout, browser.OpenURL); err != nil {
+					return err
+				}
+			} else {
+				password, err := readPassword(os.Stdin, os.Stderr)
+				if err != nil {
+					return err
+				}
+				token, err = loginPassword(cfg.signInClient(apiFlags, io.Discard), endpoint, *usernameFlag, password, tokenNote())
+				if errors.Is(err, api.ErrDryRun) {
+					// The token wasn't created, so there's nothing to
+					// verify or save. The dry-run summary lists the
+					// mutation.
+					return nil
+				} else if err != nil {
+					return err
+				}
+			}
+
+			// The new token belongs to the given endpoint, regardless of the
+			// configured one, and has to be saved to be of any use.
+			cfg.Endpoint = cleanEndpoint(endpoint)
+			cfg.AccessToken = token
+			if *saveContextFlag == "" {
+				*saveContextFlag = defaultContextName(endpoint)
+			}
+		}
+
 		client := cfg.apiClient(apiFlags, io.Discard)
 
-		return loginCmd(context.Background(), cfg, client, endpoint, os.Stdout)
+		if err := loginCmd(ctx, cfg, client, endpoint, os.Stdout); err != nil {
+			return err
+		}
+
+		if *keyringFlag {
+			if err := credentials.KeyringSet(cleanEndpoint(endpoint), cfg.AccessToken); err != nil {
+				return err
+			}
+			fmt.Printf("Stored the access token for %s in the OS keyring.\n", cleanEndpoint(endpoint))
+		}
+		if *saveContextFlag != "" {
+			if err := saveLoginContext(*saveContextFlag, cfg, endpoint, *keyringFlag); err != nil {
+				return err
+			}
+			fmt.Printf("Saved connection profile %q and made it the current context.\n", *saveContextFlag)
+		} else if *keyringFlag && !cfg.UseKeyring {
+			if err := enableKeyring(); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
 	commands = append(commands, &command{
@@ -79,12 +181,16 @@ func loginCmd(ctx context.Context, cfg *config, client api.Client, endpointArg s
    To verify that it's working, run this command again.
 `, endpointArg, endpointArg)
 
-	if cfg.ConfigFilePath != "" {
+	if cfg.usesDeprecatedConfigFile() {
 		fmt.Fprintln(out)
 		fmt.Fprintf(out, "⚠️  Warning: Configuring src with a JSON file is deprecated. Please migrate to using the env vars SRC_ENDPOINT and SRC_ACCESS_TOKEN instead, and then remove %s. See https://github.com/sourcegraph/src-cli#readme for more information.\n", cfg.ConfigFilePath)
 	}
 
-	noToken := cfg.AccessToken == ""
+	token, err := cfg.accessToken()
+	if err != nil {
+		return err
+	}
+	noToken := token == ""
 	endpointConflict := endpointArg != cfg.Endpoint
 	if noToken || endpointConflict {
 		fmt.Fprintln(out)
@@ -125,3 +231,51 @@ func loginCmd(ctx context.Context, cfg *config, client api.Client, endpointArg s
 	fmt.Fprintln(out)
 	return nil
 }
+
+// saveLoginContext saves the verified connection settings of cfg as the
+// named connection profile, and makes it the current context. If useKeyring
+// is set, the access token is left to the OS keyring.
+func saveLoginContext(name string, cfg *config, endpoint string, useKeyring bool) error {
+	f, err := loadConfigFile()
+	if err != nil {
+		return err
+	}
+	c := &contextConfig{
+		Endpoint:          cleanEndpoint(endpoint),
+		AccessToken:       cfg.AccessToken,
+		AdditionalHeaders: cfg.AdditionalHeaders,
+		CABundle:          cfg.CABundle,
+		ClientCert:        cfg.ClientCert,
+		ClientKey:         cfg.ClientKey,
+		Proxy:             cfg.Proxy,
+		UseKeyring:        useKeyring,
+	}
+	if useKeyring {
+		c.AccessToken = ""
+	}
+	f.Contexts[name] = c
+	f.CurrentContext = name
+	return f.save()
+}
+
+// defaultContextName returns the name of the connection profile that new
+// access tokens for the endpoint are saved as: its host name.
+func defaultContextName(endpoint string) string {
+	if u, err := url.Parse(cleanEndpoint(endpoint)); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return cleanEndpoint(endpoint)
+}
+
+// enableKeyring makes src look up access tokens in the OS keyring for every
+// endpoint, by setting the top-level useKeyring key of the config file.
+func enableKeyring() error {
+	f, err := loadConfigFile()
+	if err != nil {
+		return err
+	}
+	if err := f.setRaw("useKeyring", true); err != nil {
+		return err
+	}
+	return f.save()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+	"golang.org/x/term"
+
+	"github.com/sourcegraph/src-cli/internal/users"
+)
+
+// passwordLoginScopes are the scopes of the access tokens created by
+// 'src login -password'.
+var passwordLoginScopes = []string{"user:all"}
+
+// loginPassword signs in to the endpoint with a username and password, and
+// creates a new access token for the user. Requests are sent with httpClient,
+// which is usually the API client of config.signInClient, so that they use the
+// configured TLS and proxy settings.
+func loginPassword(httpClient users.Doer, endpoint, username, password, note string) (string, error) {
+	client, err := users.SignIn(httpClient, cleanEndpoint(endpoint), username, password)
+	if err != nil {
+		return "", errors.Wrap(err, "signing in")
+	}
+	token, err := client.CreateAccessToken("", passwordLoginScopes, note)
+	if err != nil {
+		return "", errors.Wrap(err, "creating access token")
+	}
+	return token, nil
+}
+
+// readPassword reads a password from the terminal without echoing it, or
+// the first line of stdin if it isn't a terminal, for use in scripts.
+func readPassword(stdin *os.File, prompt io.Writer) (string, error) {
+	if isatty.IsTerminal(stdin.Fd()) {
+		fmt.Fprint(prompt, "Password: ")
+		password, err := term.ReadPassword(int(stdin.Fd()))
+		fmt.Fprintln(prompt)
+		if err != nil {
+			return "", errors.Wrap(err, "reading password")
+		}
+		return string(password), nil
+	}
+
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", errors.Wrap(err, "reading password")
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// tokenNote returns the note of the access tokens created by src login.
+func tokenNote() string {
+	if hostname, err := os.Hostname(); err == nil {
+		return fmt.Sprintf("src-cli (%s)", hostname)
+	}
+	return "src-cli"
+}
@@ -3,13 +3,24 @@ package main
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
 	"github.com/sourcegraph/src-cli/internal/cmderrors"
 )
 
@@ -93,3 +104,198 @@ func TestLogin(t *testing.T) {
 		}
 	})
 }
+
+func TestLoginWeb(t *testing.T) {
+	stateRe := regexp.MustCompile(`name="state" value="([0-9a-f]+)"`)
+
+	// browse plays the part of the user in the browser: it loads the page,
+	// and submits the token with the form, or with the given state.
+	browse := func(t *testing.T, loginURL, state, token string) int {
+		resp, err := http.Get(loginURL)
+		if err != nil {
+			t.Errorf("loading page: %s", err)
+			return 0
+		}
+		page, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if !strings.Contains(string(page), `href="https://sourcegraph.example.com/user/settings/tokens/new"`) {
+			t.Errorf("page doesn't link to the token creation page:\n%s", page)
+		}
+		if state == "" {
+			m := stateRe.FindSubmatch(page)
+			if m == nil {
+				t.Errorf("no state in page:\n%s", page)
+				return 0
+			}
+			state = string(m[1])
+		}
+
+		resp, err = http.PostForm(loginURL+"token", url.Values{"state": {state}, "token": {token}})
+		if err != nil {
+			t.Errorf("submitting token: %s", err)
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	t.Run("token", func(t *testing.T) {
+		openURL := func(loginURL string) error {
+			if !strings.HasPrefix(loginURL, "http://127.0.0.1:") {
+				t.Errorf("unexpected URL: %s", loginURL)
+			}
+			go func() {
+				if status := browse(t, loginURL, "", "sgp_new"); status != http.StatusOK {
+					t.Errorf("unexpected status code: %d", status)
+				}
+			}()
+			return nil
+		}
+
+		var out bytes.Buffer
+		token, err := loginWeb(context.Background(), "https://sourcegraph.example.com/", "src-cli (test)", &out, openURL)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if token != "sgp_new" {
+			t.Errorf("unexpected token: %q", token)
+		}
+		if !strings.Contains(out.String(), "Opening http://127.0.0.1:") {
+			t.Errorf("unexpected output: %q", out.String())
+		}
+	})
+
+	t.Run("wrong state", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		openURL := func(loginURL string) error {
+			if status := browse(t, loginURL, "forged", "sgp_forged"); status != http.StatusForbidden {
+				t.Errorf("unexpected status code: %d", status)
+			}
+
+			// Other host names that resolve to the loopback interface can't
+			// load the page.
+			req, _ := http.NewRequest("GET", loginURL, nil)
+			req.Host = "attacker.example.com"
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusForbidden {
+				t.Errorf("unexpected status code: %d", resp.StatusCode)
+			}
+			cancel()
+			return nil
+		}
+		var out bytes.Buffer
+		if _, err := loginWeb(ctx, "https://sourcegraph.example.com", "src-cli (test)", &out, openURL); !errors.Is(err, context.Canceled) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		openURL := func(string) error { return errors.New("no browser") }
+		var out bytes.Buffer
+		if _, err := loginWeb(ctx, "https://sourcegraph.example.com", "src-cli (test)", &out, openURL); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if !strings.Contains(out.String(), "Unable to open the browser (no browser)") {
+			t.Errorf("unexpected output: %q", out.String())
+		}
+	})
+}
+
+func TestLoginPassword(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprintln(w, "<html></html>")
+
+		case "/-/sign-in":
+			var body struct{ Email, Password string }
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("decoding sign-in request: %s", err)
+			}
+			if body.Email != "alice" || body.Password != "hunter2" {
+				http.Error(w, "invalid username or password", http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "sgs", Value: "session"})
+
+		case "/.api/graphql":
+			if c, err := r.Cookie("sgs"); err != nil || c.Value != "session" {
+				http.Error(w, "not signed in", http.StatusUnauthorized)
+				return
+			}
+			var body struct {
+				Query     string
+				Variables map[string]interface{}
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("decoding GraphQL request: %s", err)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if !strings.Contains(body.Query, "createAccessToken") {
+				fmt.Fprintln(w, `{"data": {"currentUser": {"id": "VXNlcjox"}}}`)
+				return
+			}
+			want := map[string]interface{}{"user": "VXNlcjox", "scopes": []interface{}{"user:all"}, "note": "src-cli (test)"}
+			if diff := cmp.Diff(want, body.Variables); diff != "" {
+				t.Errorf("unexpected createAccessToken variables (-want +have):\n%s", diff)
+			}
+			fmt.Fprintln(w, `{"data": {"createAccessToken": {"token": "sgp_new"}}}`)
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer s.Close()
+
+	client := api.NewClient(api.ClientOpts{Endpoint: s.URL, Out: io.Discard})
+	token, err := loginPassword(client, s.URL+"/", "alice", "hunter2", "src-cli (test)")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if token != "sgp_new" {
+		t.Errorf("unexpected token: %q", token)
+	}
+
+	if _, err := loginPassword(client, s.URL, "alice", "wrong", "src-cli (test)"); err == nil || !strings.Contains(err.Error(), "invalid username or password") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	t.Run("not recorded", func(t *testing.T) {
+		cassette := filepath.Join(t.TempDir(), "cassette.jsonl")
+		flagSet := flag.NewFlagSet("login", flag.ContinueOnError)
+		flags := api.NewFlags(flagSet)
+		if err := flagSet.Parse([]string{"-record", cassette}); err != nil {
+			t.Fatal(err)
+		}
+		cfg := &config{Endpoint: s.URL}
+
+		if _, err := loginPassword(cfg.signInClient(flags, io.Discard), s.URL, "alice", "hunter2", "src-cli (test)"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		// Other requests are still recorded. This one isn't signed in, which
+		// doesn't matter here.
+		_, _ = cfg.apiClient(flags, io.Discard).NewQuery("query CurrentUser { currentUser { id } }").Do(context.Background(), &struct{}{})
+
+		data, err := os.ReadFile(cassette)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), "CurrentUser") {
+			t.Errorf("expected the query to be recorded:\n%s", data)
+		}
+		for _, secret := range []string{"hunter2", "sign-in", "sgs", "sgp_new", "createAccessToken"} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("cassette contains %q:\n%s", secret, data)
+			}
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+var webLoginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><title>src login</title></head>
+<body>
+<h1>Sign in to {{.Endpoint}}</h1>
+<ol>
+<li><a href="{{.TokenURL}}" target="_blank" rel="noopener">Create a new access token</a> on {{.Endpoint}}, with the note <code>{{.Note}}</code>.</li>
+<li>Paste the new access token here, and send it to <code>src</code>:
+<form method="POST" action="/token">
+<input type="hidden" name="state" value="{{.State}}">
+<input type="password" name="token" autocomplete="off" required autofocus>
+<button type="submit">Send to src</button>
+</form>
+</li>
+</ol>
+</body>
+</html>
+`))
+
+const webLoginDonePage = `<!DOCTYPE html>
+<html>
+<head><title>src login</title></head>
+<body>
+<p>The access token was sent to <code>src</code>. You can close this window and return to the terminal.</p>
+</body>
+</html>
+`
+
+// loginWeb obtains a new access token for the endpoint through the browser.
+// Sourcegraph's token creation callback only knows about editor extensions, so
+// instead of asking the instance to redirect the new token to src, loginWeb
+// opens a page served on a loopback listener with openURL. The page links to
+// the token creation page of the instance, and sends the new token back with a
+// form. loginWeb waits for the token until ctx is done.
+func loginWeb(ctx context.Context, endpoint, note string, out io.Writer, openURL func(string) error) (string, error) {
+	state, err := webLoginState()
+	if err != nil {
+		return "", err
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", errors.Wrap(err, "listening for the access token")
+	}
+	addr := l.Addr().String()
+
+	tokens := make(chan string, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" || r.Method != http.MethodGet {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = webLoginPage.Execute(w, map[string]string{
+			"Endpoint": cleanEndpoint(endpoint),
+			"TokenURL": cleanEndpoint(endpoint) + "/user/settings/tokens/new",
+			"Note":     note,
+			"State":    state,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// Only the page served above knows the state, which keeps other
+		// pages in the browser from sending a token of their choosing.
+		if subtle.ConstantTimeCompare([]byte(r.PostFormValue("state")), []byte(state)) != 1 {
+			http.Error(w, "invalid state", http.StatusForbidden)
+			return
+		}
+		token := r.PostFormValue("token")
+		if token == "" {
+			http.Error(w, "missing token", http.StatusBadRequest)
+			return
+		}
+		select {
+		case tokens <- token:
+		default:
+			// Only the first token is used.
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, webLoginDonePage)
+	})
+
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Reject other host names that resolve to the loopback
+			// interface, so that no other site can read the page.
+			if r.Host != addr {
+				http.Error(w, "invalid host", http.StatusForbidden)
+				return
+			}
+			mux.ServeHTTP(w, r)
+		}),
+	}
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Close()
+
+	loginURL := "http://" + addr + "/"
+	fmt.Fprintf(out, "Opening %s in your browser to create an access token.\n", loginURL)
+	if err := openURL(loginURL); err != nil {
+		fmt.Fprintf(out, "⚠️  Unable to open the browser (%s). Open the URL above manually.\n", err)
+	}
+	fmt.Fprintln(out, "Waiting for the access token...")
+
+	select {
+	case token := <-tokens:
+		return token, nil
+	case <-ctx.Done():
+		return "", errors.Wrap(ctx.Err(), "waiting for the access token")
+	}
+}
+
+// webLoginState returns a random value that the token form has to include.
+func webLoginState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "generating state")
+	}
+	return hex.EncodeToString(b), nil
+}
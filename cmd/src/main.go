@@ -1,18 +1,22 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/user"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/sourcegraph/sourcegraph/lib/errors"
 
 	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/credentials"
 )
 
 const usageText = `src is a tool that provides access to Sourcegraph instances.
@@ -25,22 +29,34 @@ Usage:
 Environment variables
 	SRC_ACCESS_TOKEN  Sourcegraph access token
 	SRC_ENDPOINT      endpoint to use, if unset will default to "https://sourcegraph.com"
+	SRC_CA_BUNDLE     path to a PEM file of additional certificate authorities to trust
+	SRC_CLIENT_CERT   path to a PEM client certificate to present for mutual TLS
+	SRC_CLIENT_KEY    path to the PEM private key of the client certificate
+	SRC_PROXY         proxy to connect through (http, https, socks5 or unix:///path/to/socket)
+	SRC_DRY_RUN       set to "true" to enable -dry-run
 
 The options are:
 
 	-v                               print verbose output
+	-context NAME                    use the named connection profile for this invocation (see "src context")
+	-dry-run                         run queries, but print mutations and other changes instead of making them
+	-o FORMAT                        output format of list and get commands: json, ndjson, yaml, csv, table or template
+	-columns COLUMNS                 comma-separated columns to print with -o csv and -o table
 
 The commands are:
 
 	api             interacts with the Sourcegraph GraphQL API
 	batch           manages batch changes
 	code-intel      manages code intelligence data
+	completion      generates shell completion scripts
 	config          manages global, org, and user settings
+	context         manages named connection profiles
 	extensions,ext  manages extensions (experimental)
 	extsvc          manages external services
 	login           authenticate to a Sourcegraph instance with your user credentials
 	lsif            manages LSIF data (deprecated: use 'code-intel')
 	orgs,org        manages organizations
+	plugin          manages plugins that provide additional commands
 	teams,team      manages teams
 	repos,repo      manages repositories
 	search          search for results on Sourcegraph
@@ -49,12 +65,19 @@ The commands are:
 	codeowners      manages code ownership information
 	version         display and compare the src-cli version against the recommended version for your instance
 
-Use "src [command] -h" for more information about a command.
+Use "src [command] -h" for more information about a command. Executables named
+src-<command> on the PATH provide additional commands (see "src plugin").
 
 `
 
 var (
-	verbose = flag.Bool("v", false, "print verbose output")
+	verbose     = flag.Bool("v", false, "print verbose output")
+	contextName = flag.String("context", "", "use the named connection profile for this invocation")
+
+	dryRun = flag.Bool("dry-run", dryRunFromEnv(), "run queries, but print mutations instead of executing them")
+
+	outputFormat  = flag.String("o", "", "output format of commands that print resources: json, ndjson, yaml, csv, table or template")
+	outputColumns = flag.String("columns", "", "comma-separated columns to print with -o csv and -o table")
 
 	// The following arguments are deprecated which is why they are no longer documented
 	configPath = flag.String("config", "", "")
@@ -72,7 +95,25 @@ func main() {
 	log.SetFlags(0)
 	log.SetPrefix("")
 
-	commands.run(flag.CommandLine, "src", usageText, os.Args[1:])
+	// Parse the global flags before running the command, to look for a plugin
+	// if the command isn't built in. The plugins are only listed in the usage
+	// text when it's printed, since finding them reads every directory on the
+	// PATH.
+	flag.CommandLine.Usage = func() {
+		_, _ = fmt.Fprint(flag.CommandLine.Output(), usageText+pluginUsage())
+	}
+	_ = flag.CommandLine.Parse(os.Args[1:])
+	if *dryRun {
+		dryRunLog = api.NewDryRun()
+	}
+	usage := usageText
+	if flag.NArg() == 0 || flag.Arg(0) == "help" {
+		usage += pluginUsage()
+	} else {
+		registerPlugin(flag.Arg(0))
+	}
+
+	commands.run(flag.CommandLine, "src", usage, os.Args[1:])
 }
 
 var cfg *config
@@ -82,8 +123,41 @@ type config struct {
 	Endpoint          string            `json:"endpoint"`
 	AccessToken       string            `json:"accessToken"`
 	AdditionalHeaders map[string]string `json:"additionalHeaders"`
-
+	CABundle          string            `json:"caBundle,omitempty"`
+	ClientCert        string            `json:"clientCert,omitempty"`
+	ClientKey         string            `json:"clientKey,omitempty"`
+	Proxy             string            `json:"proxy,omitempty"`
+
+	// UseKeyring and CredentialHelper configure where to look up the access
+	// token if it isn't given in the environment or the file.
+	UseKeyring       bool   `json:"useKeyring,omitempty"`
+	CredentialHelper string `json:"credentialHelper,omitempty"`
+
+	// CurrentContext is the name of the connection profile in Contexts that
+	// is used by default. The top-level settings above are used if it's empty.
+	CurrentContext string                    `json:"currentContext,omitempty"`
+	Contexts       map[string]*contextConfig `json:"contexts,omitempty"`
+
+	// Context is the name of the connection profile in use, if any.
+	Context        string `json:"-"`
 	ConfigFilePath string
+
+	// lookupToken looks up the access token in the OS keyring or with the
+	// credential helper, if it wasn't given in the environment or the file.
+	lookupToken func() (string, error)
+}
+
+// contextConfig is a named connection profile in the config file.
+type contextConfig struct {
+	Endpoint          string            `json:"endpoint"`
+	AccessToken       string            `json:"accessToken,omitempty"`
+	AdditionalHeaders map[string]string `json:"additionalHeaders,omitempty"`
+	CABundle          string            `json:"caBundle,omitempty"`
+	ClientCert        string            `json:"clientCert,omitempty"`
+	ClientKey         string            `json:"clientKey,omitempty"`
+	Proxy             string            `json:"proxy,omitempty"`
+	UseKeyring        bool              `json:"useKeyring,omitempty"`
+	CredentialHelper  string            `json:"credentialHelper,omitempty"`
 }
 
 // apiClient returns an api.Client built from the configuration.
@@ -91,36 +165,98 @@ func (c *config) apiClient(flags *api.Flags, out io.Writer) api.Client {
 	return api.NewClient(api.ClientOpts{
 		Endpoint:          c.Endpoint,
 		AccessToken:       c.AccessToken,
+		AccessTokenFunc:   c.accessToken,
 		AdditionalHeaders: c.AdditionalHeaders,
+		Transport:         c.transportOpts(),
 		Flags:             flags,
 		Out:               out,
+		DryRun:            dryRunLog,
 	})
 }
 
-var testHomeDir string // used by tests to mock the user's $HOME
-
-// readConfig reads the config file from the given path.
-func readConfig() (*config, error) {
-	cfgPath := *configPath
-	userSpecified := *configPath != ""
+// signInClient returns an api.Client to sign in with a password. Its requests
+// are never recorded or replayed, since they carry the password, the session
+// cookie and the new access token.
+func (c *config) signInClient(flags *api.Flags, out io.Writer) api.Client {
+	return api.NewClient(api.ClientOpts{
+		Endpoint:   c.Endpoint,
+		Transport:  c.transportOpts(),
+		Flags:      flags,
+		Out:        out,
+		DryRun:     dryRunLog,
+		NoCassette: true,
+	})
+}
 
-	var homeDir string
-	if testHomeDir != "" {
-		homeDir = testHomeDir
-	} else {
-		u, err := user.Current()
+// accessToken returns the access token. If it wasn't given in the environment
+// or the file, it's looked up in the OS keyring or with the credential helper
+// the first time it's needed, so that commands that don't talk to Sourcegraph
+// don't depend on them.
+func (c *config) accessToken() (string, error) {
+	if c.AccessToken == "" && c.lookupToken != nil {
+		token, err := c.lookupToken()
 		if err != nil {
-			return nil, err
+			return "", err
 		}
-		homeDir = u.HomeDir
+		c.AccessToken = token
+	}
+	return c.AccessToken, nil
+}
+
+// transportOpts returns the TLS and proxy settings from the configuration.
+func (c *config) transportOpts() api.TransportOpts {
+	return api.TransportOpts{
+		CABundlePath:   c.CABundle,
+		ClientCertPath: c.ClientCert,
+		ClientKeyPath:  c.ClientKey,
+		Proxy:          c.Proxy,
 	}
+}
+
+// usesDeprecatedConfigFile returns true if the connection settings come from
+// the top-level keys of a config file, rather than from a context or through
+// the OS keyring or a credential helper.
+func (c *config) usesDeprecatedConfigFile() bool {
+	return c.ConfigFilePath != "" && c.Context == "" && !c.UseKeyring && c.CredentialHelper == ""
+}
+
+var testHomeDir string // used by tests to mock the user's $HOME
+
+// userHomeDir returns the home directory of the current user.
+func userHomeDir() (string, error) {
+	if testHomeDir != "" {
+		return testHomeDir, nil
+	}
+	u, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return u.HomeDir, nil
+}
+
+// configFilePath returns the path of the config file, and whether it was
+// given explicitly with -config.
+func configFilePath(homeDir string) (path string, userSpecified bool) {
+	path = *configPath
+	userSpecified = path != ""
 
 	if !userSpecified {
-		cfgPath = filepath.Join(homeDir, "src-config.json")
-	} else if strings.HasPrefix(cfgPath, "~/") {
-		cfgPath = filepath.Join(homeDir, cfgPath[2:])
+		path = filepath.Join(homeDir, "src-config.json")
+	} else if strings.HasPrefix(path, "~/") {
+		path = filepath.Join(homeDir, path[2:])
+	}
+	return os.ExpandEnv(path), userSpecified
+}
+
+// readConfig reads the config file from the given path.
+func readConfig() (*config, error) {
+	homeDir, err := userHomeDir()
+	if err != nil {
+		return nil, err
 	}
-	data, err := os.ReadFile(os.ExpandEnv(cfgPath))
+
+	cfgPath, userSpecified := configFilePath(homeDir)
+	data, err := os.ReadFile(cfgPath)
 	if err != nil && (!os.IsNotExist(err) || userSpecified) {
 		return nil, err
 	}
@@ -132,6 +268,32 @@ func readConfig() (*config, error) {
 		}
 	}
 
+	// A connection profile replaces the top-level settings of the file. The
+	// -context flag selects a profile for this invocation only.
+	var contextHeaders map[string]string
+	name := cfg.CurrentContext
+	if contextName != nil && *contextName != "" {
+		name = *contextName
+	}
+	if name != "" {
+		c, ok := cfg.Contexts[name]
+		if !ok {
+			return nil, errors.Newf("context %q is not defined in %s", name, cfgPath)
+		}
+		cfg.Context = name
+		cfg.Endpoint = c.Endpoint
+		cfg.AccessToken = c.AccessToken
+		cfg.CABundle = c.CABundle
+		cfg.ClientCert = c.ClientCert
+		cfg.ClientKey = c.ClientKey
+		cfg.Proxy = c.Proxy
+		cfg.UseKeyring = cfg.UseKeyring || c.UseKeyring
+		if c.CredentialHelper != "" {
+			cfg.CredentialHelper = c.CredentialHelper
+		}
+		contextHeaders = c.AdditionalHeaders
+	}
+
 	envToken := os.Getenv("SRC_ACCESS_TOKEN")
 	envEndpoint := os.Getenv("SRC_ENDPOINT")
 
@@ -158,12 +320,41 @@ func readConfig() (*config, error) {
 	}
 
 	cfg.AdditionalHeaders = parseAdditionalHeaders()
+	for k, v := range contextHeaders {
+		// Headers from the environment take precedence.
+		if _, ok := cfg.AdditionalHeaders[strings.ToLower(k)]; !ok {
+			cfg.AdditionalHeaders[strings.ToLower(k)] = v
+		}
+	}
 	// Ensure that we're not clashing additonal headers
 	_, hasAuthorizationAdditonalHeader := cfg.AdditionalHeaders["authorization"]
 	if cfg.AccessToken != "" && hasAuthorizationAdditonalHeader {
 		return nil, errConfigAuthorizationConflict
 	}
 
+	// TLS and proxy settings can be set individually through the environment.
+	for _, override := range []struct {
+		env   string
+		value *string
+	}{
+		{"SRC_CA_BUNDLE", &cfg.CABundle},
+		{"SRC_CLIENT_CERT", &cfg.ClientCert},
+		{"SRC_CLIENT_KEY", &cfg.ClientKey},
+		{"SRC_PROXY", &cfg.Proxy},
+	} {
+		if v := os.Getenv(override.env); v != "" {
+			*override.value = v
+		}
+	}
+	for _, path := range []*string{&cfg.CABundle, &cfg.ClientCert, &cfg.ClientKey} {
+		if strings.HasPrefix(*path, "~/") {
+			*path = filepath.Join(homeDir, (*path)[2:])
+		}
+	}
+	if err := cfg.transportOpts().Validate(); err != nil {
+		return nil, err
+	}
+
 	// Lastly, apply endpoint flag if set
 	if endpoint != nil && *endpoint != "" {
 		cfg.Endpoint = *endpoint
@@ -171,9 +362,41 @@ func readConfig() (*config, error) {
 
 	cfg.Endpoint = cleanEndpoint(cfg.Endpoint)
 
+	// The access token of the endpoint is looked up when it's needed, if it
+	// wasn't given in the environment or the file.
+	if cfg.AccessToken == "" && !hasAuthorizationAdditonalHeader {
+		endpoint, useKeyring, helper := cfg.Endpoint, cfg.UseKeyring, cfg.CredentialHelper
+		cfg.lookupToken = sync.OnceValues(func() (string, error) {
+			return lookupAccessToken(endpoint, useKeyring, helper)
+		})
+	}
+
 	return &cfg, nil
 }
 
+// lookupAccessToken returns the access token of the endpoint from the OS
+// keyring, if useKeyring is set, or else from the credential helper, if one is
+// configured. It returns an empty token if neither knows the endpoint.
+func lookupAccessToken(endpoint string, useKeyring bool, helper string) (string, error) {
+	if useKeyring {
+		token, err := credentials.KeyringGet(endpoint)
+		if err == nil {
+			return token, nil
+		} else if !errors.Is(err, credentials.ErrNotFound) {
+			return "", err
+		}
+	}
+	if helper != "" {
+		token, err := credentials.HelperGet(context.Background(), helper, endpoint)
+		if err == nil {
+			return token, nil
+		} else if !errors.Is(err, credentials.ErrNotFound) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
 func cleanEndpoint(urlStr string) string {
 	return strings.TrimSuffix(urlStr, "/")
 }
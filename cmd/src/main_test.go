@@ -2,11 +2,17 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/zalando/go-keyring"
+
+	"github.com/sourcegraph/src-cli/internal/credentials"
 )
 
 func TestReadConfig(t *testing.T) {
@@ -17,7 +23,10 @@ func TestReadConfig(t *testing.T) {
 		envFooHeader string
 		envHeaders   string
 		envEndpoint  string
+		envProxy     string
+		envCABundle  string
 		flagEndpoint string
+		flagContext  string
 		want         *config
 		wantErr      string
 	}{
@@ -158,6 +167,115 @@ func TestReadConfig(t *testing.T) {
 			envHeaders:  "Authorization:Bearer",
 			wantErr:     errConfigAuthorizationConflict.Error(),
 		},
+		{
+			name: "config file, TLS and proxy settings",
+			fileContents: &config{
+				Endpoint:   "https://example.com",
+				CABundle:   "~/ca.pem",
+				ClientCert: "/etc/src/client.pem",
+				ClientKey:  "/etc/src/client.key",
+				Proxy:      "socks5://localhost:1080",
+			},
+			want: &config{
+				Endpoint:          "https://example.com",
+				AdditionalHeaders: map[string]string{},
+				CABundle:          filepath.Join("$HOME", "ca.pem"),
+				ClientCert:        "/etc/src/client.pem",
+				ClientKey:         "/etc/src/client.key",
+				Proxy:             "socks5://localhost:1080",
+			},
+		},
+		{
+			name: "environment overrides TLS and proxy settings",
+			fileContents: &config{
+				Endpoint: "https://example.com",
+				CABundle: "/etc/src/ca.pem",
+				Proxy:    "socks5://localhost:1080",
+			},
+			envCABundle: "/tmp/ca.pem",
+			envProxy:    "unix:///var/run/proxy.sock",
+			want: &config{
+				Endpoint:          "https://example.com",
+				AdditionalHeaders: map[string]string{},
+				CABundle:          "/tmp/ca.pem",
+				Proxy:             "unix:///var/run/proxy.sock",
+			},
+		},
+		{
+			name: "client certificate without key",
+			fileContents: &config{
+				Endpoint:   "https://example.com",
+				ClientCert: "/etc/src/client.pem",
+			},
+			wantErr: "a client certificate and a client key must be configured together",
+		},
+		{
+			name: "current context",
+			fileContents: &config{
+				Endpoint:       "https://example.com",
+				AccessToken:    "deadbeef",
+				CurrentContext: "staging",
+				Contexts:       testContexts,
+			},
+			want: &config{
+				Endpoint:          "https://staging.example.com",
+				AccessToken:       "staging-token",
+				AdditionalHeaders: map[string]string{"x-auth": "staging"},
+				Proxy:             "socks5://localhost:1080",
+				CurrentContext:    "staging",
+				Contexts:          testContexts,
+				Context:           "staging",
+			},
+		},
+		{
+			name: "context flag overrides current context",
+			fileContents: &config{
+				CurrentContext: "staging",
+				Contexts:       testContexts,
+			},
+			flagContext: "dev",
+			want: &config{
+				Endpoint:          "https://localhost:3443",
+				AdditionalHeaders: map[string]string{},
+				CABundle:          filepath.Join("$HOME", "dev", "ca.pem"),
+				CurrentContext:    "staging",
+				Contexts:          testContexts,
+				Context:           "dev",
+			},
+		},
+		{
+			name: "environment overrides context",
+			fileContents: &config{
+				CurrentContext: "staging",
+				Contexts:       testContexts,
+			},
+			envEndpoint:  "https://override.com",
+			envToken:     "abc",
+			envFooHeader: "bar",
+			envProxy:     "http://proxy.example.com",
+			want: &config{
+				Endpoint:          "https://override.com",
+				AccessToken:       "abc",
+				AdditionalHeaders: map[string]string{"foo": "bar", "x-auth": "staging"},
+				Proxy:             "http://proxy.example.com",
+				CurrentContext:    "staging",
+				Contexts:          testContexts,
+				Context:           "staging",
+			},
+		},
+		{
+			name: "undefined context",
+			fileContents: &config{
+				Contexts: testContexts,
+			},
+			flagContext: "prod",
+			wantErr:     `context "prod" is not defined in $HOME/config.json`,
+		},
+		{
+			name:     "unsupported proxy scheme",
+			envProxy: "ftp://proxy.example.com",
+			wantErr:  `unsupported proxy scheme "ftp": expected http, https, socks5, socks5h or unix`,
+		},
 	}
 
 	for _, test := range tests {
@@ -171,9 +289,15 @@ func TestReadConfig(t *testing.T) {
 			}
 			setEnv("SRC_ACCESS_TOKEN", test.envToken)
 			setEnv("SRC_ENDPOINT", test.envEndpoint)
+			setEnv("SRC_PROXY", test.envProxy)
+			setEnv("SRC_CA_BUNDLE", test.envCABundle)
 
 			tmpDir := t.TempDir()
 			testHomeDir = tmpDir
+			if test.want != nil {
+				test.want.CABundle = strings.Replace(test.want.CABundle, "$HOME", tmpDir, 1)
+			}
+			test.wantErr = strings.Replace(test.wantErr, "$HOME", tmpDir, 1)
 
 			if test.flagEndpoint != "" {
 				val := test.flagEndpoint
@@ -181,6 +305,12 @@ func TestReadConfig(t *testing.T) {
 				t.Cleanup(func() { endpoint = nil })
 			}
 
+			if test.flagContext != "" {
+				val := test.flagContext
+				contextName = &val
+				t.Cleanup(func() { contextName = nil })
+			}
+
 			if test.fileContents != nil {
 				oldConfigPath := *configPath
 				t.Cleanup(func() { *configPath = oldConfigPath })
@@ -205,8 +335,8 @@ func TestReadConfig(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			config, err := readConfig()
-			if diff := cmp.Diff(test.want, config); diff != "" {
+			have, err := readConfig()
+			if diff := cmp.Diff(test.want, have, cmpopts.IgnoreUnexported(config{})); diff != "" {
 				t.Errorf("config: %v", diff)
 			}
 			var errMsg string
@@ -219,3 +349,159 @@ func TestReadConfig(t *testing.T) {
 		})
 	}
 }
+
+var testContexts = map[string]*contextConfig{
+	"staging": {
+		Endpoint:          "https://staging.example.com/",
+		AccessToken:       "staging-token",
+		AdditionalHeaders: map[string]string{"X-Auth": "staging"},
+		Proxy:             "socks5://localhost:1080",
+	},
+	"dev": {
+		Endpoint: "https://localhost:3443",
+		CABundle: "~/dev/ca.pem",
+	},
+}
+
+func TestReadConfig_AccessTokenLookup(t *testing.T) {
+	keyring.MockInit()
+	if err := credentials.KeyringSet("https://keyring.example.com", "keyring-token"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name     string
+		file     *config
+		envToken string
+		want     string
+	}{
+		{
+			name: "keyring",
+			file: &config{Endpoint: "https://keyring.example.com/", UseKeyring: true},
+			want: "keyring-token",
+		},
+		{
+			name: "keyring without token for the endpoint",
+			file: &config{Endpoint: "https://example.com", UseKeyring: true},
+			want: "",
+		},
+		{
+			name: "credential helper",
+			file: &config{Endpoint: "https://example.com", CredentialHelper: "f() { echo password=helper-$1; }; f"},
+			want: "helper-get",
+		},
+		{
+			name: "keyring before credential helper",
+			file: &config{Endpoint: "https://keyring.example.com", UseKeyring: true, CredentialHelper: "exit 1"},
+			want: "keyring-token",
+		},
+		{
+			name: "access token in file before keyring",
+			file: &config{Endpoint: "https://keyring.example.com", AccessToken: "file-token", UseKeyring: true},
+			want: "file-token",
+		},
+		{
+			name: "context with keyring",
+			file: &config{
+				CredentialHelper: "exit 1",
+				CurrentContext:   "prod",
+				Contexts: map[string]*contextConfig{
+					"prod": {Endpoint: "https://keyring.example.com", UseKeyring: true},
+				},
+			},
+			want: "keyring-token",
+		},
+		{
+			name:     "environment before keyring and credential helper",
+			file:     &config{Endpoint: "https://keyring.example.com", UseKeyring: true, CredentialHelper: "exit 1"},
+			envToken: "env-token",
+			want:     "env-token",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			testHomeDir = tmpDir
+			t.Cleanup(func() { testHomeDir = "" })
+			t.Setenv("SRC_ACCESS_TOKEN", test.envToken)
+			t.Setenv("SRC_ENDPOINT", "")
+			t.Setenv("SRC_HEADERS", "")
+
+			data, err := json.Marshal(test.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(tmpDir, "src-config.json"), data, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := readConfig()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			token, err := cfg.accessToken()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if token != test.want {
+				t.Errorf("unexpected access token: have %q, want %q", token, test.want)
+			}
+		})
+	}
+
+	t.Run("failing credential helper", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		testHomeDir = tmpDir
+		t.Cleanup(func() { testHomeDir = "" })
+		t.Setenv("SRC_ACCESS_TOKEN", "")
+		t.Setenv("SRC_ENDPOINT", "")
+
+		data := `{"endpoint": "https://example.com", "credentialHelper": "echo vault is sealed >&2; exit 1"}`
+		if err := os.WriteFile(filepath.Join(tmpDir, "src-config.json"), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		// The helper isn't run until the token is needed.
+		cfg, err := readConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := cfg.accessToken(); err == nil || !strings.Contains(err.Error(), "vault is sealed") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("credential helper is only run when the token is needed", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		testHomeDir = tmpDir
+		t.Cleanup(func() { testHomeDir = "" })
+		t.Setenv("SRC_ACCESS_TOKEN", "")
+		t.Setenv("SRC_ENDPOINT", "")
+
+		marker := filepath.Join(tmpDir, "runs")
+		data, err := json.Marshal(&config{
+			Endpoint:         "https://example.com",
+			CredentialHelper: fmt.Sprintf("f() { echo run >> %q; echo password=helper-token; }; f", marker),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, "src-config.json"), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := readConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if _, err := os.Stat(marker); !os.IsNotExist(err) {
+			t.Fatalf("credential helper was run by readConfig")
+		}
+		for i := 0; i < 2; i++ {
+			if token, err := cfg.accessToken(); err != nil || token != "helper-token" {
+				t.Fatalf("unexpected token %q and error %v", token, err)
+			}
+		}
+		if runs, _ := os.ReadFile(marker); string(runs) != "run\n" {
+			t.Errorf("unexpected credential helper runs: %q", runs)
+		}
+	})
+}
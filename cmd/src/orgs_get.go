@@ -31,6 +31,7 @@ Examples:
 	var (
 		nameFlag   = flagSet.String("name", "", `Look up organization by name. (e.g. "abc-org")`)
 		formatFlag = flagSet.String("f", "{{.|json}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Name}} ({{.DisplayName}})")`)
+		outFlags   = newOutputFlags(flagSet, formatFlag, "ID", "Name", "DisplayName")
 		apiFlags   = api.NewFlags(flagSet)
 	)
 
@@ -41,7 +42,7 @@ Examples:
 
 		client := cfg.apiClient(apiFlags, flagSet.Output())
 
-		tmpl, err := parseTemplate(*formatFlag)
+		out, err := outFlags.writer(false)
 		if err != nil {
 			return err
 		}
@@ -65,7 +66,10 @@ Examples:
 			return err
 		}
 
-		return execTemplate(tmpl, result.Organization)
+		if err := out.Write(result.Organization); err != nil {
+			return err
+		}
+		return out.Close()
 	}
 
 	// Register the command.
@@ -36,6 +36,7 @@ Examples:
 		firstFlag  = flagSet.Int("first", 1000, "Returns the first n organizations from the list. (use -1 for unlimited)")
 		queryFlag  = flagSet.String("query", "", `Returns organizations whose names match the query. (e.g. "alice")`)
 		formatFlag = flagSet.String("f", "{{.Name}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Name}} ({{.DisplayName}})" or "{{.|json}}")`)
+		outFlags   = newOutputFlags(flagSet, formatFlag, "ID", "Name", "DisplayName")
 		apiFlags   = api.NewFlags(flagSet)
 	)
 
@@ -46,43 +47,44 @@ Examples:
 
 		client := cfg.apiClient(apiFlags, flagSet.Output())
 
-		tmpl, err := parseTemplate(*formatFlag)
+		out, err := outFlags.writer(true)
 		if err != nil {
 			return err
 		}
 
 		query := `query Organizations(
   $first: Int,
+  $after: String,
   $query: String,
 ) {
   organizations(
     first: $first,
+    after: $after,
     query: $query,
   ) {
     nodes {
       ...OrgFields
     }
+    pageInfo {
+      endCursor
+      hasNextPage
+    }
   }
 }` + orgFragment
 
-		var result struct {
-			Organizations struct {
-				Nodes []Org
-			}
-		}
-		if ok, err := client.NewRequest(query, map[string]interface{}{
-			"first": api.NullInt(*firstFlag),
-			"query": api.NullString(*queryFlag),
-		}).Do(context.Background(), &result); err != nil || !ok {
+		if err := api.Paginate(context.Background(), client, api.PaginatedQuery{
+			Query: query,
+			Vars: map[string]interface{}{
+				"query": api.NullString(*queryFlag),
+			},
+			Path:  []string{"organizations"},
+			Limit: *firstFlag,
+		}, func(org Org) error {
+			return out.Write(org)
+		}); err != nil {
 			return err
 		}
-
-		for _, org := range result.Organizations.Nodes {
-			if err := execTemplate(tmpl, org); err != nil {
-				return err
-			}
-		}
-		return nil
+		return out.Close()
 	}
 
 	// Register the command.
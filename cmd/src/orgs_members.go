@@ -30,9 +30,10 @@ Use "src orgs members [command] -h" for more information about a command.
 
 	// Register the command.
 	orgsCommands = append(orgsCommands, &command{
-		flagSet: flagSet,
-		aliases: []string{"member"},
-		handler: handler,
+		flagSet:     flagSet,
+		aliases:     []string{"member"},
+		subcommands: &orgsMembersCommands,
+		handler:     handler,
 		usageFunc: func() {
 			fmt.Println(usage)
 		},
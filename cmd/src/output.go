@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/listoutput"
+)
+
+// outputFlags are the -o and -columns flags of commands that print
+// resources. They take precedence over the global -o and -columns flags.
+type outputFlags struct {
+	flagSet        *flag.FlagSet
+	format         *string
+	columns        *string
+	template       *string
+	defaultColumns []string
+}
+
+// newOutputFlags registers the output flags on flagSet. templateFlag is the
+// -f flag of the command, which is used by the template output format, and
+// defaultColumns are the columns printed by -o csv and -o table.
+func newOutputFlags(flagSet *flag.FlagSet, templateFlag *string, defaultColumns ...string) *outputFlags {
+	columnsUsage := "Comma-separated columns to print with -o csv and -o table, as paths into the JSON output. (e.g. \"ID,Emails.0.Email\")"
+	if len(defaultColumns) > 0 {
+		columnsUsage += fmt.Sprintf(" (default %q)", strings.Join(defaultColumns, ","))
+	}
+	return &outputFlags{
+		flagSet:        flagSet,
+		format:         flagSet.String("o", "", "Output format: json, ndjson, yaml, csv, table or template. (default: template, using -f)"),
+		columns:        flagSet.String("columns", "", columnsUsage),
+		template:       templateFlag,
+		defaultColumns: defaultColumns,
+	}
+}
+
+// resolve returns the selected output format: the -o flag of the command, or
+// else the template format if -f is given, or else the global -o flag.
+func (f *outputFlags) resolve() (listoutput.Format, error) {
+	templateSet := false
+	f.flagSet.Visit(func(fl *flag.Flag) {
+		if fl.Name == "f" {
+			templateSet = true
+		}
+	})
+
+	name := *f.format
+	if name == "" && !templateSet && outputFormat != nil {
+		name = *outputFormat
+	}
+	if name == "" {
+		return listoutput.Template, nil
+	}
+	format, err := listoutput.ParseFormat(name)
+	if err != nil {
+		return "", cmderrors.Usage(err.Error())
+	}
+	if templateSet && format != listoutput.Template {
+		return "", cmderrors.Usagef("-f cannot be used with -o %s", format)
+	}
+	return format, nil
+}
+
+// writer returns a writer of the selected output format to stdout. list is
+// true if the command prints a list of resources.
+func (f *outputFlags) writer(list bool) (listoutput.Writer, error) {
+	format, err := f.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := listoutput.Options{Format: format, List: list, Columns: f.defaultColumns}
+	if *f.columns != "" {
+		opts.Columns = listoutput.ParseColumns(*f.columns)
+	} else if outputColumns != nil && *outputColumns != "" {
+		opts.Columns = listoutput.ParseColumns(*outputColumns)
+	}
+	if format == listoutput.Template {
+		if opts.Template, err = parseTemplate(*f.template); err != nil {
+			return nil, err
+		}
+	}
+	return listoutput.NewWriter(os.Stdout, opts)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/sourcegraph/src-cli/internal/listoutput"
+)
+
+func TestOutputFlags(t *testing.T) {
+	for name, tc := range map[string]struct {
+		global  string
+		args    []string
+		want    listoutput.Format
+		wantErr bool
+	}{
+		"default":                       {want: listoutput.Template},
+		"command flag":                  {args: []string{"-o", "yaml"}, want: listoutput.YAML},
+		"global flag":                   {global: "csv", want: listoutput.CSV},
+		"command flag overrides global": {global: "csv", args: []string{"-o", "ndjson"}, want: listoutput.NDJSON},
+		"template overrides global":     {global: "csv", args: []string{"-f", "{{.ID}}"}, want: listoutput.Template},
+		"template with -o template":     {args: []string{"-o", "template", "-f", "{{.ID}}"}, want: listoutput.Template},
+		"template with other format":    {args: []string{"-o", "json", "-f", "{{.ID}}"}, wantErr: true},
+		"unknown format":                {args: []string{"-o", "xml"}, wantErr: true},
+		"unknown global format":         {global: "xml", wantErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			old := *outputFormat
+			*outputFormat = tc.global
+			t.Cleanup(func() { *outputFormat = old })
+
+			flagSet := flag.NewFlagSet("list", flag.ContinueOnError)
+			formatFlag := flagSet.String("f", "{{.Name}}", "")
+			outFlags := newOutputFlags(flagSet, formatFlag, "ID", "Name")
+			if err := flagSet.Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+
+			have, err := outFlags.resolve()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got format %q", have)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have != tc.want {
+				t.Errorf("wrong format: want %q, have %q", tc.want, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	srcexec "github.com/sourcegraph/src-cli/internal/exec"
+)
+
+var pluginCommands commander
+
+func init() {
+	usage := `'src plugin' manages plugins: executables that provide additional src commands.
+
+Running 'src foo' runs the first executable named 'src-foo' on the PATH, if
+src has no built-in command of that name. The remaining arguments are passed to
+the plugin unchanged, and the plugin is given the connection settings of src
+in these environment variables:
+
+	SRC_ENDPOINT      the Sourcegraph endpoint
+	SRC_ACCESS_TOKEN  the access token, if any
+	SRC_HEADERS       the additional headers, as newline-separated "Name: value" pairs
+	SRC_CA_BUNDLE, SRC_CLIENT_CERT, SRC_CLIENT_KEY and SRC_PROXY
+	                  the TLS and proxy settings, if any
+	SRC_CONTEXT       the name of the connection profile in use, if any
+	SRC_VERBOSE       "true" if src was run with -v
+	SRC_DRY_RUN       "true" if src was run with -dry-run, in which case the
+	                  plugin must not make any changes
+
+Apart from SRC_CONTEXT and SRC_VERBOSE, these are the variables that src reads
+itself, so a plugin can also run src commands with the same settings.
+
+Usage:
+
+	src plugin command [command options]
+
+The commands are:
+
+	list      lists the plugins found on the PATH
+
+Use "src plugin [command] -h" for more information about a command.
+`
+
+	flagSet := flag.NewFlagSet("plugin", flag.ExitOnError)
+	handler := func(args []string) error {
+		pluginCommands.run(flagSet, "src plugin", usage, args)
+		return nil
+	}
+
+	// Register the command.
+	commands = append(commands, &command{
+		flagSet:     flagSet,
+		aliases:     []string{"plugins"},
+		subcommands: &pluginCommands,
+		handler:     handler,
+		usageFunc: func() {
+			fmt.Println(usage)
+		},
+	})
+}
+
+const pluginPrefix = "src-"
+
+// plugin is an executable on the PATH that provides a src command.
+type plugin struct {
+	Name string
+	Path string
+
+	// ShadowedBy is the path of the plugin of the same name that is earlier
+	// on the PATH, if any. BuiltIn is true if the plugin has the same name as
+	// a built-in command. In either case, the plugin is never run.
+	ShadowedBy string
+	BuiltIn    bool
+}
+
+// findPlugins returns the plugins in the directories of the PATH, in order of
+// their names. Plugins that can't be run are included, so that they can be
+// reported.
+func findPlugins() []plugin {
+	var plugins []plugin
+	found := map[string]string{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := pluginName(entry)
+			if !ok {
+				continue
+			}
+			p := plugin{Name: name, Path: filepath.Join(dir, entry.Name())}
+			if path, ok := found[name]; ok {
+				p.ShadowedBy = path
+			} else {
+				found[name] = p.Path
+			}
+			p.BuiltIn = commands.find(name) != nil
+			plugins = append(plugins, p)
+		}
+	}
+	sort.SliceStable(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// pluginName returns the name of the command provided by the directory entry,
+// if it is a plugin.
+func pluginName(entry fs.DirEntry) (string, bool) {
+	name := entry.Name()
+	if !strings.HasPrefix(name, pluginPrefix) || entry.IsDir() {
+		return "", false
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return "", false
+	}
+
+	if runtime.GOOS == "windows" {
+		ext := filepath.Ext(name)
+		if !isWindowsExecutableExt(ext) {
+			return "", false
+		}
+		name = strings.TrimSuffix(name, ext)
+	} else if info.Mode()&0111 == 0 {
+		return "", false
+	}
+
+	name = strings.TrimPrefix(name, pluginPrefix)
+	return name, name != ""
+}
+
+func isWindowsExecutableExt(ext string) bool {
+	pathExt := os.Getenv("PATHEXT")
+	if pathExt == "" {
+		pathExt = ".com;.exe;.bat;.cmd"
+	}
+	for _, e := range strings.Split(pathExt, ";") {
+		if e != "" && strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookPlugin returns the path of the plugin that provides the command, if any.
+func lookPlugin(name string) (string, bool) {
+	if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+	if commands.find(name) != nil {
+		return "", false
+	}
+	path, err := exec.LookPath(pluginPrefix + name)
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// registerPlugin registers the command name if it is provided by a plugin.
+func registerPlugin(name string) {
+	path, ok := lookPlugin(name)
+	if !ok {
+		return
+	}
+	commands = append(commands, &command{
+		flagSet: flag.NewFlagSet(name, flag.ExitOnError),
+		rawArgs: true,
+		handler: func(args []string) error {
+			return runPlugin(path, args)
+		},
+	})
+}
+
+// runPlugin runs the plugin with the given arguments, and the connection
+// settings of the configuration in its environment.
+func runPlugin(path string, args []string) error {
+	cmd := srcexec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// Plugins are given the access token, so it has to be looked up now.
+	if _, err := cfg.accessToken(); err != nil {
+		return err
+	}
+	cmd.Env = append(os.Environ(), pluginEnv(cfg, *verbose, *dryRun)...)
+
+	// The plugin is responsible for honouring SRC_DRY_RUN, and for reporting
+	// what it didn't do.
+	dryRunLog = nil
+
+	// Interrupts are sent to the whole process group, so it's up to the
+	// plugin to handle them.
+	signal.Ignore(os.Interrupt)
+	defer signal.Reset(os.Interrupt)
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code := exitErr.ExitCode()
+			if code < 0 {
+				code = 1
+			}
+			return cmderrors.ExitCode(code, nil)
+		}
+		return errors.Wrapf(err, "running plugin %s", path)
+	}
+	return nil
+}
+
+// pluginEnv returns the environment variables that give a plugin the
+// connection settings of the configuration.
+func pluginEnv(c *config, verbose, dryRun bool) []string {
+	env := []string{"SRC_ENDPOINT=" + c.Endpoint}
+	for _, v := range []struct {
+		name, value string
+	}{
+		{"SRC_ACCESS_TOKEN", c.AccessToken},
+		{"SRC_CA_BUNDLE", c.CABundle},
+		{"SRC_CLIENT_CERT", c.ClientCert},
+		{"SRC_CLIENT_KEY", c.ClientKey},
+		{"SRC_PROXY", c.Proxy},
+		{"SRC_CONTEXT", c.Context},
+	} {
+		if v.value != "" {
+			env = append(env, v.name+"="+v.value)
+		}
+	}
+
+	if len(c.AdditionalHeaders) > 0 {
+		headers := make([]string, 0, len(c.AdditionalHeaders))
+		for k, v := range c.AdditionalHeaders {
+			headers = append(headers, k+": "+v)
+		}
+		sort.Strings(headers)
+		env = append(env, additionalHeadersKey+"="+strings.Join(headers, "\n"))
+	}
+
+	if verbose {
+		env = append(env, "SRC_VERBOSE=true")
+	}
+	if dryRun {
+		env = append(env, "SRC_DRY_RUN=true")
+	}
+	return env
+}
+
+// pluginUsage returns the list of plugins for the usage text of src, or an
+// empty string if there are none.
+func pluginUsage() string {
+	var b strings.Builder
+	for _, p := range findPlugins() {
+		if p.ShadowedBy == "" && !p.BuiltIn {
+			fmt.Fprintf(&b, "\t%-15s %s\n", p.Name, p.Path)
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "The plugins are:\n\n" + b.String() + "\n"
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+func init() {
+	usage := `
+Examples:
+
+  List the plugins found on the PATH:
+
+    	$ src plugin list
+
+  Plugins that can't be run, because a plugin of the same name comes earlier
+  on the PATH or src has a built-in command of that name, are reported on
+  standard error.
+
+`
+
+	flagSet := flag.NewFlagSet("list", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src plugin %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		plugins := findPlugins()
+		if len(plugins) == 0 {
+			fmt.Printf("No plugins were found on the PATH. Plugins are executables named %s<command>.\n", pluginPrefix)
+			return nil
+		}
+
+		var warnings []string
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tPATH")
+		for _, p := range plugins {
+			switch {
+			case p.BuiltIn:
+				warnings = append(warnings, fmt.Sprintf("%s is ignored, because src has a built-in %q command", p.Path, p.Name))
+			case p.ShadowedBy != "":
+				warnings = append(warnings, fmt.Sprintf("%s is shadowed by %s", p.Path, p.ShadowedBy))
+			default:
+				fmt.Fprintf(w, "%s\t%s\n", p.Name, p.Path)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		for _, warning := range warnings {
+			fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+		}
+		return nil
+	}
+
+	// Register the command.
+	pluginCommands = append(pluginCommands, &command{
+		flagSet:   flagSet,
+		aliases:   []string{"ls"},
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are found by their file extension on Windows")
+	}
+
+	first, second := t.TempDir(), t.TempDir()
+	for _, f := range []struct {
+		dir, name string
+		mode      os.FileMode
+	}{
+		{first, "src-hello", 0755},
+		{first, "src-repos", 0755},
+		{first, "src-not-executable", 0644},
+		{first, "other-tool", 0755},
+		{second, "src-hello", 0755},
+		{second, "src-bye", 0755},
+	} {
+		if err := os.WriteFile(filepath.Join(f.dir, f.name), []byte("#!/bin/sh\n"), f.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(first, "src-dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", first+string(os.PathListSeparator)+second)
+
+	want := []plugin{
+		{Name: "bye", Path: filepath.Join(second, "src-bye")},
+		{Name: "hello", Path: filepath.Join(first, "src-hello")},
+		{Name: "hello", Path: filepath.Join(second, "src-hello"), ShadowedBy: filepath.Join(first, "src-hello")},
+		{Name: "repos", Path: filepath.Join(first, "src-repos"), BuiltIn: true},
+	}
+	if diff := cmp.Diff(want, findPlugins()); diff != "" {
+		t.Errorf("unexpected plugins (-want +have):\n%s", diff)
+	}
+
+	for name, want := range map[string]string{
+		"hello":   filepath.Join(first, "src-hello"),
+		"bye":     filepath.Join(second, "src-bye"),
+		"repos":   "",
+		"missing": "",
+		"../bye":  "",
+	} {
+		have, _ := lookPlugin(name)
+		if have != want {
+			t.Errorf("lookPlugin(%q): want %q, have %q", name, want, have)
+		}
+	}
+}
+
+func TestPluginEnv(t *testing.T) {
+	c := &config{
+		Endpoint:          "https://sourcegraph.example.com",
+		AccessToken:       "token",
+		AdditionalHeaders: map[string]string{"x-foo": "bar", "x-baz": "qux"},
+		Proxy:             "socks5://localhost:1080",
+		Context:           "prod",
+	}
+	want := []string{
+		"SRC_ENDPOINT=https://sourcegraph.example.com",
+		"SRC_ACCESS_TOKEN=token",
+		"SRC_PROXY=socks5://localhost:1080",
+		"SRC_CONTEXT=prod",
+		"SRC_HEADERS=x-baz: qux\nx-foo: bar",
+		"SRC_VERBOSE=true",
+		"SRC_DRY_RUN=true",
+	}
+	env := pluginEnv(c, true, true)
+	if diff := cmp.Diff(want, env); diff != "" {
+		t.Errorf("unexpected environment (-want +have):\n%s", diff)
+	}
+
+	// The headers must be read back the same way by src itself, so that
+	// plugins can run src.
+	if diff := cmp.Diff(c.AdditionalHeaders, parseAdditionalHeadersFromEnviron(env)); diff != "" {
+		t.Errorf("unexpected headers (-want +have):\n%s", diff)
+	}
+}
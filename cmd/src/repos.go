@@ -40,9 +40,10 @@ Use "src repos [command] -h" for more information about a command.
 
 	// Register the command.
 	commands = append(commands, &command{
-		flagSet: flagSet,
-		aliases: []string{"repo"},
-		handler: handler,
+		flagSet:     flagSet,
+		aliases:     []string{"repo"},
+		subcommands: &reposCommands,
+		handler:     handler,
 		usageFunc: func() {
 			fmt.Println(usage)
 		},
@@ -8,6 +8,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/lib/errors"
 
 	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/bulk"
 )
 
 func init() {
@@ -20,6 +21,15 @@ Examples:
 
   Omitting -value will create a tag (a key with a null value).
 
+  Add the metadata listed in a CSV file with "repo-name", "key" and "value"
+  columns, where an empty value creates a tag:
+
+    	$ src repos add-metadata -from-file=metadata.csv
+
+  Add a tag "archived" to the repositories listed in a file, one name per line:
+
+    	$ src repos add-metadata -from-file=repos.txt -key=archived
+
   [DEPRECATED] Note that 'add-kvp' is deprecated and will be removed in future release. Use 'add-metadata' instead.
 `
 
@@ -34,9 +44,37 @@ Examples:
 		repoNameFlag = flagSet.String("repo-name", "", `The name of the repo to add the key-value pair metadata to (required if -repo is not specified)`)
 		keyFlag      = flagSet.String("key", "", `The name of the  metadata key to add (required)`)
 		valueFlag    = flagSet.String("value", "", `The  metadata value associated with the  metadata key. Defaults to null.`)
+		bulkFlags    = newBulkFlags(flagSet, "repo-name", "repo", "key", "value")
 		apiFlags     = api.NewFlags(flagSet)
 	)
 
+	addMetadata := func(ctx context.Context, client api.Client, repo, repoName, key string, value *string) (bool, error) {
+		repoID, err := getRepoIdOrError(ctx, client, &repo, &repoName)
+		if err != nil {
+			return false, err
+		}
+
+		query := `mutation addRepoMetadata(
+  $repo: ID!,
+  $key: String!,
+  $value: String,
+) {
+  addRepoKeyValuePair(
+    repo: $repo,
+    key: $key,
+    value: $value,
+  ) {
+    alwaysNil
+  }
+}`
+
+		return client.NewRequest(query, map[string]interface{}{
+			"repo":  *repoID,
+			"key":   key,
+			"value": value,
+		}).Do(ctx, nil)
+	}
+
 	handler := func(args []string) error {
 		if err := flagSet.Parse(args); err != nil {
 			return err
@@ -55,36 +93,36 @@ Examples:
 				valueFlag = &value
 			}
 		})
-		if keyFlag == nil {
-			return errors.New("error: key is required")
-		}
-
 		client := cfg.apiClient(apiFlags, flagSet.Output())
 		ctx := context.Background()
-		repoID, err := getRepoIdOrError(ctx, client, repoFlag, repoNameFlag)
-		if err != nil {
-			return err
-		}
 
-		query := `mutation addRepoMetadata(
-  $repo: ID!,
-  $key: String!,
-  $value: String,
-) {
-  addRepoKeyValuePair(
-    repo: $repo,
-    key: $key,
-    value: $value,
-  ) {
-    alwaysNil
-  }
-}`
+		if bulkFlags.enabled() {
+			return bulkFlags.run(ctx, func(ctx context.Context, item bulk.Item) error {
+				repo, repoName := *repoFlag, *repoNameFlag
+				if item.Has("repo") || item.Has("repo-name") {
+					repo, repoName = item.Get("repo", ""), item.Get("repo-name", "")
+				}
+				var key string
+				if keyFlag != nil {
+					key = *keyFlag
+				}
+				if key = item.Get("key", key); key == "" {
+					return errors.New("error: key is required")
+				}
+				value := valueFlag
+				if item.Has("value") {
+					v := item.Get("value", "")
+					value = &v
+				}
+				_, err := addMetadata(ctx, client, repo, repoName, key, value)
+				return err
+			})
+		}
 
-		if ok, err := client.NewRequest(query, map[string]interface{}{
-			"repo":  *repoID,
-			"key":   *keyFlag,
-			"value": valueFlag,
-		}).Do(ctx, nil); err != nil || !ok {
+		if keyFlag == nil {
+			return errors.New("error: key is required")
+		}
+		if ok, err := addMetadata(ctx, client, *repoFlag, *repoNameFlag, *keyFlag, valueFlag); err != nil || !ok {
 			return err
 		}
 
@@ -8,10 +8,13 @@ import (
 	"github.com/sourcegraph/sourcegraph/lib/errors"
 
 	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/bulk"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
 )
 
 func init() {
 	flagSet := flag.NewFlagSet("delete", flag.ExitOnError)
+	bulkFlags := newBulkFlags(flagSet, "name")
 	apiFlags := api.NewFlags(flagSet)
 
 	printUsage := func() {
@@ -25,14 +28,19 @@ Examples:
    Delete one or more repositories:
 
     	$ src repos delete github.com/my/repo github.com/my/repo2
+
+   Delete the repositories listed in a file, one name per line, at most 2 per
+   second:
+
+    	$ src repos delete -from-file=repos.txt -rate=2
 `
 		fmt.Fprint(flag.CommandLine.Output(), examples)
 	}
 
-	deleteRepository := func(ctx context.Context, client api.Client, repoName string) error {
+	deleteRepository := func(ctx context.Context, client api.Client, repoName string) (bool, error) {
 		repoID, err := fetchRepositoryID(ctx, client, repoName)
 		if err != nil {
-			return err
+			return false, err
 		}
 
 		query := `mutation DeleteRepository($repoID: ID!){
@@ -41,14 +49,9 @@ Examples:
 			}
 		}`
 		var result struct{}
-		if ok, err := client.NewRequest(query, map[string]interface{}{
+		return client.NewRequest(query, map[string]interface{}{
 			"repoID": repoID,
-		}).Do(ctx, &result); err != nil || !ok {
-			return err
-		}
-
-		fmt.Fprintf(flag.CommandLine.Output(), "Repository %q deleted\n", repoName)
-		return nil
+		}).Do(ctx, &result)
 	}
 
 	deleteRepositories := func(args []string) error {
@@ -59,12 +62,24 @@ Examples:
 		ctx := context.Background()
 		client := cfg.apiClient(apiFlags, flagSet.Output())
 
+		if bulkFlags.enabled() {
+			if flagSet.NArg() > 0 {
+				return cmderrors.Usage("repository names cannot be given as arguments with -from-file")
+			}
+			return bulkFlags.run(ctx, func(ctx context.Context, item bulk.Item) error {
+				_, err := deleteRepository(ctx, client, item.Get("name", ""))
+				return err
+			})
+		}
+
 		var errs errors.MultiError
 		for _, repoName := range flagSet.Args() {
-			err := deleteRepository(ctx, client, repoName)
+			ok, err := deleteRepository(ctx, client, repoName)
 			if err != nil {
 				err = errors.Wrapf(err, "Failed to delete repository %q", repoName)
 				errs = errors.Append(errs, err)
+			} else if ok {
+				fmt.Fprintf(flag.CommandLine.Output(), "Repository %q deleted\n", repoName)
 			}
 		}
 		return errs
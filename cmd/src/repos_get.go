@@ -27,6 +27,7 @@ Examples:
 	var (
 		nameFlag   = flagSet.String("name", "", "The name of the repository. (required)")
 		formatFlag = flagSet.String("f", "{{.ID}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Name}}") or "{{.|json}}")`)
+		outFlags   = newOutputFlags(flagSet, formatFlag, "id", "name", "url", "createdAt")
 		apiFlags   = api.NewFlags(flagSet)
 	)
 
@@ -37,7 +38,7 @@ Examples:
 
 		client := cfg.apiClient(apiFlags, flagSet.Output())
 
-		tmpl, err := parseTemplate(*formatFlag)
+		out, err := outFlags.writer(false)
 		if err != nil {
 			return err
 		}
@@ -62,7 +63,10 @@ Examples:
 			return err
 		}
 
-		return execTemplate(tmpl, result.Repository)
+		if err := out.Write(result.Repository); err != nil {
+			return err
+		}
+		return out.Close()
 	}
 
 	// Register the command.
@@ -47,8 +47,9 @@ Examples:
 		notIndexedFlag       = flagSet.Bool("not-indexed", true, "Include repositories that do not have a text search index.")
 		orderByFlag          = flagSet.String("order-by", "name", `How to order the results; possible choices are: "name", "created-at"`)
 		descendingFlag       = flagSet.Bool("descending", false, "Whether or not results should be in descending order.")
-		namesWithoutHostFlag = flagSet.Bool("names-without-host", false, "Whether or not repository names should be printed without the hostname (or other first path component). If set, -f is ignored.")
+		namesWithoutHostFlag = flagSet.Bool("names-without-host", false, "Whether or not repository names should be printed without the hostname (or other first path component). If set, -f and -o are ignored.")
 		formatFlag           = flagSet.String("f", "{{.Name}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Name}}") or "{{.|json}}")`)
+		outFlags             = newOutputFlags(flagSet, formatFlag, "id", "name", "url", "createdAt")
 		apiFlags             = api.NewFlags(flagSet)
 	)
 
@@ -59,13 +60,14 @@ Examples:
 
 		client := cfg.apiClient(apiFlags, flagSet.Output())
 
-		tmpl, err := parseTemplate(*formatFlag)
+		out, err := outFlags.writer(true)
 		if err != nil {
 			return err
 		}
 
 		query := `query Repositories(
   $first: Int,
+  $after: String,
   $query: String,
   $cloned: Boolean,
   $notCloned: Boolean,
@@ -76,6 +78,7 @@ Examples:
 ) {
   repositories(
     first: $first,
+    after: $after,
     query: $query,
     cloned: $cloned,
     notCloned: $notCloned,
@@ -87,6 +90,10 @@ Examples:
     nodes {
       ...RepositoryFields
     }
+    pageInfo {
+      endCursor
+      hasNextPage
+    }
   }
 }
 ` + repositoryFragment
@@ -101,36 +108,33 @@ Examples:
 			return fmt.Errorf("invalid -order-by flag value: %q", *orderByFlag)
 		}
 
-		var result struct {
-			Repositories struct {
-				Nodes []Repository
-			}
-		}
-		if ok, err := client.NewRequest(query, map[string]interface{}{
-			"first":      api.NullInt(*firstFlag),
-			"query":      api.NullString(*queryFlag),
-			"cloned":     *clonedFlag,
-			"notCloned":  *notClonedFlag,
-			"indexed":    *indexedFlag,
-			"notIndexed": *notIndexedFlag,
-			"orderBy":    orderBy,
-			"descending": *descendingFlag,
-		}).Do(context.Background(), &result); err != nil || !ok {
-			return err
-		}
-
-		for _, repo := range result.Repositories.Nodes {
+		if err := api.Paginate(context.Background(), client, api.PaginatedQuery{
+			Query: query,
+			Vars: map[string]interface{}{
+				"query":      api.NullString(*queryFlag),
+				"cloned":     *clonedFlag,
+				"notCloned":  *notClonedFlag,
+				"indexed":    *indexedFlag,
+				"notIndexed": *notIndexedFlag,
+				"orderBy":    orderBy,
+				"descending": *descendingFlag,
+			},
+			Path:  []string{"repositories"},
+			Limit: *firstFlag,
+		}, func(repo Repository) error {
 			if *namesWithoutHostFlag {
 				firstSlash := strings.Index(repo.Name, "/")
 				fmt.Println(repo.Name[firstSlash+len("/"):])
-				continue
-			}
-
-			if err := execTemplate(tmpl, repo); err != nil {
-				return err
+				return nil
 			}
+			return out.Write(repo)
+		}); err != nil {
+			return err
+		}
+		if *namesWithoutHostFlag {
+			return nil
 		}
-		return nil
+		return out.Close()
 	}
 
 	// Register the command.
@@ -33,9 +33,10 @@ func init() {
 	}
 
 	commands = append(commands, &command{
-		flagSet: flagSet,
-		aliases: []string{"scout"},
-		handler: handler,
+		flagSet:     flagSet,
+		aliases:     []string{"scout"},
+		subcommands: &scoutCommands,
+		handler:     handler,
 		usageFunc: func() {
 			fmt.Println(usage)
 		},
@@ -9,6 +9,8 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"os/signal"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -37,6 +39,24 @@ Examples:
 
     	$ src search -json 'repogroup:sample error'
 
+  Search with a regular expression, with 2 lines of context around matches:
+
+    	$ src search -pattern-type=regexp -context-lines=2 'repo:^github\.com/my/repo$ New\w+Client'
+
+  Perform a search with the GraphQL API instead of streaming the results, for
+  scripts that rely on its output:
+
+    	$ src search -legacy -json 'repogroup:sample error'
+
+  Perform a search and report the matches as SARIF, e.g. to upload
+  them to GitHub code scanning:
+
+    	$ src search -format=sarif 'repo:^github\.com/my/repo$ lang:go ioutil\.' > results.sarif
+
+  Annotate matches in a GitHub Actions workflow run:
+
+    	$ src search -format=gh-annotations 'repo:^github\.com/my/repo$ lang:go ioutil\.'
+
 Other tips:
 
   Make 'type:diff' searches have colored diffs by installing https://colordiff.org
@@ -50,6 +70,22 @@ Other tips:
 
   Query syntax: https://docs.sourcegraph.com/code_search/reference/queries
 
+  Check the number of matches of search queries in CI:
+
+    	$ src search assert -policy search-policy.yaml
+
+  Download every file that matches a query, with a manifest:
+
+    	$ src search export -o DIR 'query'
+
+  Report new matches of a query every 10 minutes:
+
+    	$ src search watch -state FILE 'query'
+
+  See "src search assert -h", "src search export -h" and "src search watch -h"
+  for more information. To search for the word "assert", "export" or "watch"
+  itself, use -- before it.
+
   Be careful with search strings including negation: a search with an initial
   negated term may be parsed as a flag rather than as a search string. You can
   use -- to ensure that src parses this correctly, eg:
@@ -59,27 +95,31 @@ Other tips:
 
 	flagSet := flag.NewFlagSet("search", flag.ExitOnError)
 	var (
-		jsonFlag        = flagSet.Bool("json", false, "Whether or not to output results as JSON.")
-		explainJSONFlag = flagSet.Bool("explain-json", false, "Explain the JSON output schema and exit.")
-		apiFlags        = api.NewFlags(flagSet)
-		lessFlag        = flagSet.Bool("less", true, "Pipe output to 'less -R' (only if stdout is terminal, and not json flag).")
-		streamFlag      = flagSet.Bool("stream", false, "Consume results as stream. Streaming search only supports a subset of flags and parameters: trace, insecure-skip-verify, display, json.")
-		display         = flagSet.Int("display", -1, "Limit the number of results that are displayed. Only supported together with stream flag. Statistics continue to report all results.")
+		jsonFlag          = flagSet.Bool("json", false, "Whether or not to output results as JSON.")
+		explainJSONFlag   = flagSet.Bool("explain-json", false, "Explain the JSON output schema of -legacy -json and exit.")
+		apiFlags          = api.NewFlags(flagSet)
+		lessFlag          = flagSet.Bool("less", true, "Pipe output to 'less -R' (only if stdout is terminal, and not json flag). Only supported together with legacy flag.")
+		legacyFlag        = flagSet.Bool("legacy", false, "Run the search with the GraphQL API, which returns all results at once, instead of streaming them. Only supports the json, less, pattern-type, search-version and search-context flags.")
+		streamFlag        = flagSet.Bool("stream", true, "Consume results as stream. This is the default, -stream=false is the same as -legacy.")
+		display           = flagSet.Int("display", -1, "Limit the number of results that are displayed. Statistics continue to report all results.")
+		timeoutFlag       = flagSet.Duration("timeout", 0, "The maximum duration of the search, including reconnects. (default: no limit)")
+		formatFlag        = flagSet.String("format", "", "Output format: text, json, sarif, csv or gh-annotations. (default: text, or json with -json)")
+		patternTypeFlag   = flagSet.String("pattern-type", "", "How to interpret the query, e.g. standard, literal, regexp, keyword or structural. Patterns in the query, such as patterntype:regexp, take precedence. (default: the default of the instance)")
+		versionFlag       = flagSet.String("search-version", "", "The version of the query syntax, e.g. V3. (default: the default of the instance)")
+		contextLinesFlag  = flagSet.Int("context-lines", -1, "The number of lines of context to return around each match. (default: the default of the instance)")
+		maxLineLenFlag    = flagSet.Int("max-line-len", 0, "Truncate matched lines longer than this many characters. (default: no limit)")
+		searchContextFlag = flagSet.String("search-context", "", "The search context to search, e.g. global or @user/context, as if the query started with context:NAME.")
 	)
 
 	handler := func(args []string) error {
-		if err := flagSet.Parse(args); err != nil {
-			return err
+		// Subcommands such as 'src search assert' have to come first, any
+		// other arguments are a query.
+		if len(args) > 0 && searchCommands.find(args[0]) != nil {
+			searchCommands.run(flag.NewFlagSet("search", flag.ExitOnError), "src search", usage, args)
 		}
 
-		if *streamFlag {
-			opts := streaming.Opts{
-				Display: *display,
-				Trace:   apiFlags.Trace(),
-				Json:    *jsonFlag,
-			}
-			client := cfg.apiClient(apiFlags, flagSet.Output())
-			return streamSearch(flagSet.Arg(0), opts, client, os.Stdout)
+		if err := flagSet.Parse(args); err != nil {
+			return err
 		}
 
 		if *explainJSONFlag {
@@ -90,7 +130,53 @@ Other tips:
 		if flagSet.NArg() != 1 {
 			return cmderrors.Usage("expected exactly one argument: the search query")
 		}
-		queryString := flagSet.Arg(0)
+
+		legacy := *legacyFlag || !*streamFlag
+		if legacy {
+			streamingOnly := map[string]bool{"display": true, "timeout": true, "format": true, "context-lines": true, "max-line-len": true}
+			var err error
+			flagSet.Visit(func(f *flag.Flag) {
+				if err == nil && streamingOnly[f.Name] {
+					err = cmderrors.Usagef("-%s is not supported together with -legacy", f.Name)
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if *formatFlag != "" {
+			if *jsonFlag && *formatFlag != streamFormatJSON {
+				return cmderrors.Usagef("-json cannot be used with -format %s", *formatFlag)
+			}
+			if !slices.Contains(streamFormats, *formatFlag) {
+				return cmderrors.Usagef("unknown format %q, expected one of %s", *formatFlag, strings.Join(streamFormats, ", "))
+			}
+		}
+
+		if !legacy {
+			opts := streaming.Opts{
+				Display:       *display,
+				Trace:         apiFlags.Trace(),
+				Json:          *jsonFlag,
+				Format:        *formatFlag,
+				Timeout:       *timeoutFlag,
+				PatternType:   *patternTypeFlag,
+				Version:       *versionFlag,
+				MaxLineLen:    *maxLineLenFlag,
+				SearchContext: *searchContextFlag,
+			}
+			if *contextLinesFlag >= 0 {
+				opts.ContextLines = contextLinesFlag
+			}
+			// Ctrl-C stops the search, but still prints the results so far.
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+			client := cfg.apiClient(apiFlags, flagSet.Output())
+			return streamSearch(ctx, flagSet.Arg(0), opts, client, os.Stdout)
+		}
+
+		queryString := streaming.QueryWithSearchContext(flagSet.Arg(0), *searchContextFlag)
 
 		// For pagination, pipe our own output to 'less -R'
 		if *lessFlag && !*jsonFlag {
@@ -131,6 +217,23 @@ Other tips:
 
 		client := cfg.apiClient(apiFlags, flagSet.Output())
 
+		// The version and pattern type are only passed if they are set, so that
+		// the defaults of the instance apply otherwise.
+		vars := map[string]interface{}{
+			"query": api.NullString(queryString),
+		}
+		var searchArgs struct{ variables, args string }
+		if *versionFlag != "" {
+			vars["version"] = *versionFlag
+			searchArgs.variables += ", $version: SearchVersion!"
+			searchArgs.args += ", version: $version"
+		}
+		if *patternTypeFlag != "" {
+			vars["patternType"] = *patternTypeFlag
+			searchArgs.variables += ", $patternType: SearchPatternType!"
+			searchArgs.args += ", patternType: $patternType"
+		}
+
 		query := `fragment FileMatchFields on FileMatch {
 				repository {
 					name
@@ -214,11 +317,11 @@ Other tips:
 			}
 		  }
 
-		  query ($query: String!) {
+		  query ($query: String!` + searchArgs.variables + `) {
 			site {
 				buildVersion
 			}
-			search(query: $query) {
+			search(query: $query` + searchArgs.args + `) {
 			  results {
 				results{
 				  __typename
@@ -259,9 +362,7 @@ Other tips:
 			}
 		}
 
-		if ok, err := client.NewRequest(query, map[string]interface{}{
-			"query": api.NullString(queryString),
-		}).Do(context.Background(), &result); err != nil || !ok {
+		if ok, err := client.NewRequest(query, vars).Do(context.Background(), &result); err != nil || !ok {
 			return err
 		}
 
@@ -291,8 +392,9 @@ Other tips:
 
 	// Register the command.
 	commands = append(commands, &command{
-		flagSet: flagSet,
-		handler: handler,
+		flagSet:     flagSet,
+		subcommands: &searchCommands,
+		handler:     handler,
 		usageFunc: func() {
 			fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src %s':\n", flagSet.Name())
 			flagSet.PrintDefaults()
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/streaming"
+)
+
+var searchCommands commander
+
+// searchUsage returns a usage error of the 'src search NAME' subcommand. Since
+// subcommands take precedence over queries that are the same word, it also
+// tells users that meant to search for NAME how to do so.
+func searchUsage(name, msg string) error {
+	return cmderrors.Usagef("%s\n\nTo search for %q instead, use: src search -- %s", msg, name, name)
+}
+
+func init() {
+	usage := `'src search assert' runs the search queries of a policy file, and fails if
+the number of matches of any of them is outside its bounds. It is meant to be
+used as a gate in CI.
+
+Usage:
+
+	src search assert -policy FILE [-junit FILE]
+
+The policy is a YAML file with a list of checks, each with a query and a
+maximum and/or minimum number of matches:
+
+	checks:
+	  - name: no-ioutil
+	    query: 'repo:^github\.com/my/repo$ lang:go ioutil\.'
+	    max: 0
+	  - name: license
+	    query: 'repo:^github\.com/my/repo$ file:^LICENSE$ type:path'
+	    min: 1
+
+A check fails if its search is incomplete, because it hit a limit or timed out,
+or the Sourcegraph instance reported an error or alert, unless the matches that
+were found already break or satisfy the bounds.
+
+The command exits with status 1 if any check failed.
+
+Examples:
+
+  Run the checks of a policy, and write a JUnit XML report:
+
+    	$ src search assert -policy search-policy.yaml -junit search-policy.xml
+`
+
+	flagSet := flag.NewFlagSet("assert", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src search %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		policyFlag   = flagSet.String("policy", "", "The YAML policy file with the checks to run. (required)")
+		junitFlag    = flagSet.String("junit", "", "Write a JUnit XML report of the checks to this file.")
+		examplesFlag = flagSet.Int("examples", 5, "The maximum number of matches to list for each failed check.")
+		apiFlags     = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if *policyFlag == "" {
+			return searchUsage("assert", "-policy is required")
+		}
+		if flagSet.NArg() != 0 {
+			return searchUsage("assert", "unexpected arguments")
+		}
+
+		policy, err := readSearchPolicy(*policyFlag)
+		if err != nil {
+			return err
+		}
+
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+		results := make([]searchCheckResult, 0, len(policy.Checks))
+		for _, check := range policy.Checks {
+			result := runSearchCheck(client, check, *examplesFlag, apiFlags.Trace())
+			results = append(results, result)
+			fmt.Println(result.summary())
+			if result.Failure != "" {
+				for _, example := range result.Examples {
+					fmt.Println("    " + example)
+				}
+			}
+		}
+
+		if *junitFlag != "" {
+			var buf bytes.Buffer
+			if err := writeSearchJUnit(&buf, *policyFlag, results); err != nil {
+				return err
+			}
+			if err := os.WriteFile(*junitFlag, buf.Bytes(), 0o644); err != nil {
+				return errors.Wrap(err, "writing JUnit report")
+			}
+		}
+
+		failed := 0
+		for _, result := range results {
+			if result.Failure != "" {
+				failed++
+			}
+		}
+		if failed > 0 {
+			return cmderrors.ExitCode(1, errors.Newf("%d of %d checks failed", failed, len(results)))
+		}
+		return nil
+	}
+
+	// Register the command.
+	searchCommands = append(searchCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// searchPolicy is the policy file of 'src search assert'.
+type searchPolicy struct {
+	Checks []searchCheck `yaml:"checks"`
+}
+
+// searchCheck is a query of a search policy, and the bounds of its number of
+// matches.
+type searchCheck struct {
+	Name  string `yaml:"name"`
+	Query string `yaml:"query"`
+	Max   *int   `yaml:"max"`
+	Min   *int   `yaml:"min"`
+}
+
+func readSearchPolicy(path string) (*searchPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseSearchPolicy(data)
+}
+
+func parseSearchPolicy(data []byte) (*searchPolicy, error) {
+	var policy searchPolicy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&policy); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "parsing policy")
+	}
+	if len(policy.Checks) == 0 {
+		return nil, errors.New("the policy has no checks")
+	}
+
+	names := map[string]bool{}
+	for i := range policy.Checks {
+		check := &policy.Checks[i]
+		if check.Query == "" {
+			return nil, errors.Newf("check %d has no query", i+1)
+		}
+		if check.Name == "" {
+			check.Name = check.Query
+		}
+		if names[check.Name] {
+			return nil, errors.Newf("there is more than one check named %q", check.Name)
+		}
+		names[check.Name] = true
+		if check.Max == nil && check.Min == nil {
+			return nil, errors.Newf("check %q needs a max or min number of matches", check.Name)
+		}
+		if check.Max != nil && check.Min != nil && *check.Min > *check.Max {
+			return nil, errors.Newf("check %q has a min greater than its max", check.Name)
+		}
+	}
+	return &policy, nil
+}
+
+// searchCheckResult is the outcome of a check.
+type searchCheckResult struct {
+	Check    searchCheck
+	Duration time.Duration
+
+	// Matches is the number of matches, which is a lower bound if Incomplete
+	// is non-empty.
+	Matches int
+	// Incomplete lists the reasons why not all matches were found, such as
+	// timeouts, limits, errors and alerts.
+	Incomplete []string
+	// Examples are a few of the matches.
+	Examples []string
+
+	// Failure is empty if the check passed.
+	Failure string
+}
+
+func (r searchCheckResult) summary() string {
+	status := "PASS"
+	if r.Failure != "" {
+		status = "FAIL"
+	}
+	s := fmt.Sprintf("%s %s: %s", status, r.Check.Name, r.matchCount())
+	if r.Failure != "" {
+		s += ": " + r.Failure
+	}
+	return s
+}
+
+func (r searchCheckResult) matchCount() string {
+	plus := ""
+	if len(r.Incomplete) > 0 {
+		plus = "+"
+	}
+	if r.Matches == 1 && plus == "" {
+		return "1 match"
+	}
+	return fmt.Sprintf("%d%s matches", r.Matches, plus)
+}
+
+// runSearchCheck runs the query of the check with the streaming search API,
+// and evaluates its bounds.
+func runSearchCheck(client api.Client, check searchCheck, maxExamples int, trace bool) searchCheckResult {
+	result := searchCheckResult{Check: check}
+	var progress *streaming.Progress
+	decoder := streaming.Decoder{
+		OnProgress: func(p *streaming.Progress) {
+			progress = p
+		},
+		OnMatches: func(matches []streaming.EventMatch) {
+			for _, match := range matches {
+				if len(result.Examples) >= maxExamples {
+					return
+				}
+				result.Examples = append(result.Examples, describeSearchMatch(match))
+			}
+		},
+		OnAlert: func(alert *streaming.EventAlert) {
+			result.Incomplete = append(result.Incomplete, "alert: "+alert.Title)
+		},
+		OnError: func(eventError *streaming.EventError) {
+			result.Incomplete = append(result.Incomplete, "error: "+eventError.Message)
+		},
+	}
+
+	start := time.Now()
+	err := streaming.Search(check.Query, streaming.Opts{Display: maxExamples, Trace: trace}, client, decoder)
+	result.Duration = time.Since(start)
+	if err != nil {
+		result.Incomplete = append(result.Incomplete, err.Error())
+	}
+	if progress == nil || !progress.Done {
+		result.Incomplete = append(result.Incomplete, "the search did not finish")
+	}
+	if progress != nil {
+		result.Matches = progress.MatchCount
+		for _, skipped := range progress.Skipped {
+			if skipped.Reason.Incomplete() {
+				result.Incomplete = append(result.Incomplete, skipped.Title)
+			}
+		}
+	}
+
+	result.Failure = evaluateSearchCheck(check, result.Matches, len(result.Incomplete) > 0)
+	if result.Failure != "" && len(result.Incomplete) > 0 {
+		result.Failure += fmt.Sprintf(" (incomplete: %s)", strings.Join(result.Incomplete, "; "))
+	}
+	return result
+}
+
+// evaluateSearchCheck returns why the number of matches breaks the bounds of
+// the check, or an empty string if it doesn't. If the search was incomplete,
+// matches is a lower bound, so the check only passes if it would pass with
+// any number of additional matches.
+func evaluateSearchCheck(check searchCheck, matches int, incomplete bool) string {
+	if check.Max != nil && matches > *check.Max {
+		return fmt.Sprintf("expected at most %d", *check.Max)
+	}
+	if check.Min != nil && matches < *check.Min {
+		if incomplete {
+			return fmt.Sprintf("expected at least %d, but the search was incomplete", *check.Min)
+		}
+		return fmt.Sprintf("expected at least %d", *check.Min)
+	}
+	if incomplete && check.Max != nil {
+		return fmt.Sprintf("expected at most %d, but the search was incomplete", *check.Max)
+	}
+	return ""
+}
+
+// describeSearchMatch returns a one-line description of a match for reports.
+func describeSearchMatch(match streaming.EventMatch) string {
+	if locations := matchLocations(match); len(locations) > 0 {
+		loc := locations[0]
+		if loc.Line == 0 {
+			return loc.Repository + " › " + loc.Path
+		}
+		return fmt.Sprintf("%s › %s:%d:%d: %s", loc.Repository, loc.Path, loc.Line, loc.Column, strings.TrimSpace(loc.Preview))
+	}
+	switch match := match.(type) {
+	case *streaming.EventRepoMatch:
+		return match.Repository
+	case *streaming.EventSymbolMatch:
+		return match.Repository + " › " + match.Path
+	case *streaming.EventCommitMatch:
+		return match.URL
+	}
+	return ""
+}
+
+// JUnit XML, as understood by CI systems. See
+// https://github.com/testmoapp/junitxml.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut *junitOutput  `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",cdata"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+// writeSearchJUnit writes the results of the checks of the policy file as a
+// JUnit XML report.
+func writeSearchJUnit(w io.Writer, policyPath string, results []searchCheckResult) error {
+	suite := junitTestSuite{Name: policyPath, Tests: len(results)}
+	var total time.Duration
+	for _, result := range results {
+		total += result.Duration
+		tc := junitTestCase{
+			Name:      result.Check.Name,
+			ClassName: "src search assert",
+			Time:      junitSeconds(result.Duration),
+			SystemOut: &junitOutput{Text: fmt.Sprintf("query: %s\n%s\n", result.Check.Query, result.matchCount())},
+		}
+		if result.Failure != "" {
+			suite.Failures++
+			text := fmt.Sprintf("query: %s\n%s, %s\n", result.Check.Query, result.matchCount(), result.Failure)
+			if len(result.Examples) > 0 {
+				text += "\nmatches:\n  " + strings.Join(result.Examples, "\n  ") + "\n"
+			}
+			tc.Failure = &junitFailure{
+				Message: result.matchCount() + ", " + result.Failure,
+				Text:    text,
+			}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	suite.Time = junitSeconds(total)
+
+	suites := junitTestSuites{
+		Name:     "src search assert",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/streaming"
+)
+
+func TestParseSearchPolicy(t *testing.T) {
+	for name, tc := range map[string]struct {
+		policy  string
+		wantErr string
+	}{
+		"valid": {
+			policy: "checks:\n  - name: a\n    query: foo\n    max: 0\n  - query: bar\n    min: 1\n    max: 3\n",
+		},
+		"empty":         {policy: "", wantErr: "no checks"},
+		"unknown field": {policy: "checks:\n  - query: foo\n    maximum: 1\n", wantErr: "maximum"},
+		"no query":      {policy: "checks:\n  - name: a\n    max: 0\n", wantErr: "check 1 has no query"},
+		"no bounds":     {policy: "checks:\n  - query: foo\n", wantErr: `check "foo" needs a max or min`},
+		"min over max":  {policy: "checks:\n  - query: foo\n    min: 2\n    max: 1\n", wantErr: "min greater than its max"},
+		"duplicate":     {policy: "checks:\n  - query: foo\n    max: 1\n  - query: foo\n    min: 1\n", wantErr: "more than one check"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			policy, err := parseSearchPolicy([]byte(tc.policy))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have := policy.Checks[1].Name; have != "bar" {
+				t.Errorf("expected the name to default to the query, got %q", have)
+			}
+		})
+	}
+}
+
+func TestEvaluateSearchCheck(t *testing.T) {
+	zero, two := 0, 2
+	for name, tc := range map[string]struct {
+		check      searchCheck
+		matches    int
+		incomplete bool
+		want       string
+	}{
+		"under max":                 {check: searchCheck{Max: &zero}, matches: 0},
+		"over max":                  {check: searchCheck{Max: &zero}, matches: 1, want: "expected at most 0"},
+		"incomplete under max":      {check: searchCheck{Max: &zero}, matches: 0, incomplete: true, want: "expected at most 0, but the search was incomplete"},
+		"incomplete over max":       {check: searchCheck{Max: &zero}, matches: 3, incomplete: true, want: "expected at most 0"},
+		"over min":                  {check: searchCheck{Min: &two}, matches: 2},
+		"under min":                 {check: searchCheck{Min: &two}, matches: 1, want: "expected at least 2"},
+		"incomplete over min":       {check: searchCheck{Min: &two}, matches: 5, incomplete: true},
+		"incomplete under min":      {check: searchCheck{Min: &two}, matches: 1, incomplete: true, want: "expected at least 2, but the search was incomplete"},
+		"between min and max":       {check: searchCheck{Min: &zero, Max: &two}, matches: 1},
+		"incomplete within min max": {check: searchCheck{Min: &zero, Max: &two}, matches: 1, incomplete: true, want: "expected at most 2, but the search was incomplete"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if have := evaluateSearchCheck(tc.check, tc.matches, tc.incomplete); have != tc.want {
+				t.Errorf("want %q, have %q", tc.want, have)
+			}
+		})
+	}
+}
+
+func TestRunSearchCheck(t *testing.T) {
+	repositories := 1
+	progress := map[string]streaming.Progress{
+		"ioutil": {Done: true, MatchCount: 1, RepositoriesCount: &repositories},
+		"timeout": {Done: true, Skipped: []streaming.Skipped{
+			{Reason: streaming.ShardTimeout, Title: "1 repository timed out"},
+		}},
+		"forks": {Done: true, Skipped: []streaming.Skipped{
+			{Reason: streaming.ExcludedFork, Title: "1 fork excluded"},
+		}},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("q")
+		writer, _ := streaming.NewWriter(w)
+		if query == "ioutil" {
+			writer.Event("matches", []streaming.EventMatch{&streaming.EventContentMatch{
+				Type:       streaming.ContentMatchType,
+				Path:       "main.go",
+				Repository: "github.com/org/repo",
+				ChunkMatches: []streaming.ChunkMatch{{
+					Content:      `import "io/ioutil"`,
+					ContentStart: streaming.Location{Line: 2},
+					Ranges: []streaming.Range{{
+						Start: streaming.Location{Line: 2, Column: 11},
+						End:   streaming.Location{Line: 2, Column: 17},
+					}},
+				}},
+			}})
+		}
+		if p, ok := progress[query]; ok {
+			writer.Event("progress", p)
+		}
+		writer.Event("done", nil)
+	}))
+	defer ts.Close()
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: flagSet.Output(), Flags: api.NewFlags(flagSet)})
+
+	zero := 0
+	for _, tc := range []struct {
+		query          string
+		wantMatches    int
+		wantIncomplete []string
+		wantFailure    string
+		wantExamples   []string
+	}{
+		{
+			query:        "ioutil",
+			wantMatches:  1,
+			wantFailure:  "expected at most 0",
+			wantExamples: []string{"github.com/org/repo › main.go:3:12: import \"io/ioutil\""},
+		},
+		{
+			query:          "timeout",
+			wantIncomplete: []string{"1 repository timed out"},
+			wantFailure:    "expected at most 0, but the search was incomplete (incomplete: 1 repository timed out)",
+		},
+		{
+			query: "forks",
+		},
+		{
+			query:          "unfinished",
+			wantIncomplete: []string{"the search did not finish"},
+			wantFailure:    "expected at most 0, but the search was incomplete (incomplete: the search did not finish)",
+		},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			have := runSearchCheck(client, searchCheck{Name: tc.query, Query: tc.query, Max: &zero}, 5, false)
+			if have.Matches != tc.wantMatches {
+				t.Errorf("wrong number of matches: want %d, have %d", tc.wantMatches, have.Matches)
+			}
+			if diff := cmp.Diff(tc.wantIncomplete, have.Incomplete); diff != "" {
+				t.Errorf("unexpected incomplete reasons (-want +have):\n%s", diff)
+			}
+			if have.Failure != tc.wantFailure {
+				t.Errorf("wrong failure: want %q, have %q", tc.wantFailure, have.Failure)
+			}
+			if diff := cmp.Diff(tc.wantExamples, have.Examples); diff != "" {
+				t.Errorf("unexpected examples (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteSearchJUnit(t *testing.T) {
+	zero, one := 0, 1
+	results := []searchCheckResult{
+		{
+			Check:    searchCheck{Name: "no-ioutil", Query: "lang:go ioutil", Max: &zero},
+			Duration: 1500 * time.Millisecond,
+			Matches:  2,
+			Examples: []string{"github.com/org/repo › main.go:3:12: import \"io/ioutil\""},
+			Failure:  "expected at most 0",
+		},
+		{
+			Check:    searchCheck{Name: "license", Query: "file:^LICENSE$ type:path", Min: &one},
+			Duration: 250 * time.Millisecond,
+			Matches:  1,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeSearchJUnit(&buf, "policy.yaml", results); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="src search assert" tests="2" failures="1" time="1.750">
+  <testsuite name="policy.yaml" tests="2" failures="1" time="1.750">
+    <testcase name="no-ioutil" classname="src search assert" time="1.500">
+      <failure message="2 matches, expected at most 0"><![CDATA[query: lang:go ioutil
+2 matches, expected at most 0
+
+matches:
+  github.com/org/repo › main.go:3:12: import "io/ioutil"
+]]></failure>
+      <system-out><![CDATA[query: lang:go ioutil
+2 matches
+]]></system-out>
+    </testcase>
+    <testcase name="license" classname="src search assert" time="0.250">
+      <system-out><![CDATA[query: file:^LICENSE$ type:path
+1 match
+]]></system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Errorf("unexpected report (-want +have):\n%s", diff)
+	}
+}
+
+func TestSearchSubcommandUsage(t *testing.T) {
+	// 'src search export' used to search for "export", so the subcommands
+	// explain how to do that when they're run without their arguments.
+	for _, name := range []string{"assert", "export", "watch"} {
+		cmd := searchCommands.find(name)
+		if cmd == nil {
+			t.Fatalf("no %s command", name)
+		}
+		err := cmd.handler(nil)
+		if _, ok := err.(*cmderrors.UsageError); !ok {
+			t.Errorf("%s: expected a usage error, got %v", name, err)
+		} else if want := "use: src search -- " + name; !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected %q in %q", name, want, err.Error())
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/conc/pool"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/batches/repozip"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/streaming"
+)
+
+// searchExportManifest is the name of the manifest file that 'src search
+// export' writes to the output directory.
+const searchExportManifest = "manifest.json"
+
+func init() {
+	usage := `'src search export' downloads every file that matches a search query into a
+local directory, for offline review.
+
+Usage:
+
+	src search export -o DIR [options] 'query'
+
+For every distinct repository, commit and path of the content, path and symbol
+matches of the query, the whole file is downloaded to DIR/<repository>/<path>.
+If the same path of a repository matches at more than one commit, the other
+commits are downloaded to DIR/<repository>@<commit>/<path>.
+
+DIR/manifest.json lists the files with the repository, commit and path they
+were downloaded from, their location in DIR, their size and SHA-256 checksum,
+and the error for files that couldn't be downloaded.
+
+Searches return at most 500 results by default. Add count:all to the query to
+export every matching file.
+
+Examples:
+
+  Export all YAML files that configure a deprecated option:
+
+    	$ src search export -o review 'file:\.ya?ml$ allowInsecure: true count:all'
+`
+
+	flagSet := flag.NewFlagSet("export", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src search %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		outFlag         = flagSet.String("o", "", "The directory to download the files to. (required)")
+		concurrencyFlag = flagSet.Int("concurrency", 8, "The maximum number of files to download at once.")
+		apiFlags        = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if *outFlag == "" {
+			return searchUsage("export", "-o is required")
+		}
+		if flagSet.NArg() != 1 {
+			return searchUsage("export", "expected exactly one argument: the search query")
+		}
+		query := flagSet.Arg(0)
+
+		if err := os.MkdirAll(*outFlag, 0o755); err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+		exporter := newSearchExporter(client, *outFlag, *concurrencyFlag, os.Stdout)
+
+		decoder := streaming.Decoder{
+			OnMatches: func(matches []streaming.EventMatch) {
+				for _, match := range matches {
+					exporter.add(ctx, match)
+				}
+			},
+			OnAlert: func(alert *streaming.EventAlert) {
+				logError(fmt.Sprintf("%s: %s\n", alert.Title, alert.Description))
+			},
+			OnError: func(eventError *streaming.EventError) {
+				logError(eventError.Message + "\n")
+			},
+		}
+		searchErr := streaming.Search(query, streaming.Opts{Display: -1, Trace: apiFlags.Trace()}, client, decoder)
+		files := exporter.wait()
+
+		manifest := searchExportManifestFile{
+			Query:      query,
+			Endpoint:   cfg.Endpoint,
+			ExportedAt: time.Now().UTC().Truncate(time.Second),
+			Files:      files,
+		}
+		if err := manifest.write(filepath.Join(*outFlag, searchExportManifest)); err != nil {
+			return err
+		}
+		if searchErr != nil {
+			return searchErr
+		}
+
+		failed := 0
+		for _, f := range files {
+			if f.Error != "" {
+				failed++
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d files to %s.\n", len(files)-failed, *outFlag)
+		if failed > 0 {
+			return cmderrors.ExitCode(1, errors.Newf("%d files could not be downloaded, see %s", failed, filepath.Join(*outFlag, searchExportManifest)))
+		}
+		return nil
+	}
+
+	// Register the command.
+	searchCommands = append(searchCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// searchExportManifestFile is the manifest of an export.
+type searchExportManifestFile struct {
+	Query      string              `json:"query"`
+	Endpoint   string              `json:"endpoint"`
+	ExportedAt time.Time           `json:"exportedAt"`
+	Files      []*searchExportFile `json:"files"`
+}
+
+func (m *searchExportManifestFile) write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(os.WriteFile(path, append(data, '\n'), 0o644), "writing manifest")
+}
+
+// searchExportFile is a file of an export.
+type searchExportFile struct {
+	Repository string `json:"repository"`
+	Commit     string `json:"commit,omitempty"`
+	Path       string `json:"path"`
+
+	// File is the location of the downloaded file, relative to the output
+	// directory and with forward slashes.
+	File   string `json:"file,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// searchExporter downloads the files of search matches in parallel, once per
+// repository, commit and path.
+type searchExporter struct {
+	client repozip.HTTPClient
+	dir    string
+	out    io.Writer
+	pool   *pool.Pool
+
+	mu    sync.Mutex
+	files map[repozip.RepoRevision]map[string]*searchExportFile
+	// commits are the commits that are downloaded to DIR/<repo>/<path>, by
+	// the repository and path.
+	commits map[[2]string]string
+}
+
+func newSearchExporter(client repozip.HTTPClient, dir string, concurrency int, out io.Writer) *searchExporter {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &searchExporter{
+		client:  client,
+		dir:     dir,
+		out:     out,
+		pool:    pool.New().WithMaxGoroutines(concurrency),
+		files:   map[repozip.RepoRevision]map[string]*searchExportFile{},
+		commits: map[[2]string]string{},
+	}
+}
+
+// add starts downloading the file of the match, unless the match is not in a
+// file, or the file is already being downloaded.
+func (e *searchExporter) add(ctx context.Context, match streaming.EventMatch) {
+	var repo repozip.RepoRevision
+	var path string
+	switch match := match.(type) {
+	case *streaming.EventContentMatch:
+		repo, path = repozip.RepoRevision{RepoName: match.Repository, Commit: match.Commit}, match.Path
+	case *streaming.EventPathMatch:
+		repo, path = repozip.RepoRevision{RepoName: match.Repository, Commit: match.Commit}, match.Path
+	case *streaming.EventSymbolMatch:
+		repo, path = repozip.RepoRevision{RepoName: match.Repository, Commit: match.Commit}, match.Path
+	default:
+		return
+	}
+
+	f, dest, ok := e.claim(repo, path)
+	if !ok {
+		return
+	}
+
+	// Go blocks while the maximum number of files are being downloaded, so it
+	// must not be called with the mutex held.
+	e.pool.Go(func() {
+		err := e.download(ctx, repo, path, dest, f)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if err != nil {
+			f.Error = err.Error()
+			logError(fmt.Sprintf("%s › %s: %s\n", repo.RepoName, path, err))
+			return
+		}
+		fmt.Fprintln(e.out, filepath.Join(e.dir, filepath.FromSlash(f.File)))
+	})
+}
+
+// claim records the file of the repository, and returns where to download it
+// to. It returns false if the file was already claimed, or can't be
+// downloaded.
+func (e *searchExporter) claim(repo repozip.RepoRevision, path string) (*searchExportFile, string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.files[repo][path]; ok {
+		return nil, "", false
+	}
+	if e.files[repo] == nil {
+		e.files[repo] = map[string]*searchExportFile{}
+	}
+	f := &searchExportFile{Repository: repo.RepoName, Commit: repo.Commit, Path: path}
+	e.files[repo][path] = f
+
+	// The first commit of a path is downloaded to DIR/<repo>/<path>, others to
+	// DIR/<repo>@<commit>/<path>.
+	dirName := repo.RepoName
+	key := [2]string{repo.RepoName, path}
+	if commit, ok := e.commits[key]; !ok {
+		e.commits[key] = repo.Commit
+	} else if commit != repo.Commit {
+		dirName += "@" + repo.Commit
+	}
+
+	dest, err := exportDestination(e.dir, dirName, path)
+	if err != nil {
+		f.Error = err.Error()
+		logError(fmt.Sprintf("%s › %s: %s\n", repo.RepoName, path, err))
+		return nil, "", false
+	}
+	return f, dest, true
+}
+
+func (e *searchExporter) download(ctx context.Context, repo repozip.RepoRevision, path, dest string, f *searchExportFile) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	ok, err := repozip.FetchFile(ctx, e.client, repo, path, dest)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("file not found")
+	}
+
+	file, err := os.Open(dest)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(e.dir, dest)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	f.File, f.Size, f.SHA256 = filepath.ToSlash(rel), size, hex.EncodeToString(h.Sum(nil))
+	e.mu.Unlock()
+	return nil
+}
+
+// wait waits for the downloads to finish, and returns the files in order of
+// their repository, path and commit.
+func (e *searchExporter) wait() []*searchExportFile {
+	e.pool.Wait()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	files := []*searchExportFile{}
+	for _, paths := range e.files {
+		for _, f := range paths {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if a.Repository != b.Repository {
+			return a.Repository < b.Repository
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Commit < b.Commit
+	})
+	return files
+}
+
+// exportDestination returns the local path of a file of a repository, which
+// must be inside dir. Repository names and paths come from the Sourcegraph
+// instance, so they are checked rather than trusted.
+func exportDestination(dir, repoDir, path string) (string, error) {
+	for _, part := range append(strings.Split(repoDir, "/"), strings.Split(path, "/")...) {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `\`) {
+			return "", errors.Newf("refusing to export to unsafe path %q", repoDir+"/"+path)
+		}
+	}
+	return filepath.Join(dir, filepath.FromSlash(repoDir), filepath.FromSlash(path)), nil
+}
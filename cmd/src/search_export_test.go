@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/streaming"
+)
+
+func TestSearchExporter(t *testing.T) {
+	files := map[string]string{
+		"/github.com/org/a@c1/-/raw/main.go":     "package main\n",
+		"/github.com/org/a@c2/-/raw/main.go":     "package main // v2\n",
+		"/github.com/org/a@c1/-/raw/lib/util.go": "package lib\n",
+	}
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		content, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer ts.Close()
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: flagSet.Output(), Flags: api.NewFlags(flagSet)})
+
+	dir := t.TempDir()
+	var out bytes.Buffer
+	exporter := newSearchExporter(client, dir, 2, &out)
+	for _, match := range []streaming.EventMatch{
+		&streaming.EventContentMatch{Type: streaming.ContentMatchType, Repository: "github.com/org/a", Commit: "c1", Path: "main.go"},
+		&streaming.EventSymbolMatch{Type: streaming.SymbolMatchType, Repository: "github.com/org/a", Commit: "c1", Path: "main.go"},
+		&streaming.EventPathMatch{Type: streaming.PathMatchType, Repository: "github.com/org/a", Commit: "c1", Path: "lib/util.go"},
+		&streaming.EventContentMatch{Type: streaming.ContentMatchType, Repository: "github.com/org/a", Commit: "c2", Path: "main.go"},
+		&streaming.EventContentMatch{Type: streaming.ContentMatchType, Repository: "github.com/org/a", Commit: "c1", Path: "missing.go"},
+		&streaming.EventContentMatch{Type: streaming.ContentMatchType, Repository: "github.com/org/a", Commit: "c1", Path: "../escape.go"},
+		&streaming.EventRepoMatch{Type: streaming.RepoMatchType, Repository: "github.com/org/a"},
+	} {
+		exporter.add(context.Background(), match)
+	}
+	have := exporter.wait()
+
+	checksum := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	want := []*searchExportFile{
+		{Repository: "github.com/org/a", Commit: "c1", Path: "../escape.go", Error: `refusing to export to unsafe path "github.com/org/a/../escape.go"`},
+		{Repository: "github.com/org/a", Commit: "c1", Path: "lib/util.go", File: "github.com/org/a/lib/util.go", Size: 12, SHA256: checksum("package lib\n")},
+		{Repository: "github.com/org/a", Commit: "c1", Path: "main.go", File: "github.com/org/a/main.go", Size: 13, SHA256: checksum("package main\n")},
+		{Repository: "github.com/org/a", Commit: "c2", Path: "main.go", File: "github.com/org/a@c2/main.go", Size: 19, SHA256: checksum("package main // v2\n")},
+		{Repository: "github.com/org/a", Commit: "c1", Path: "missing.go", Error: "file not found"},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("unexpected files (-want +have):\n%s", diff)
+	}
+	if have := atomic.LoadInt32(&requests); have != 4 {
+		t.Errorf("expected 4 downloads, have %d", have)
+	}
+
+	for _, f := range want {
+		if f.File == "" {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f.File)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := checksum(string(content)); have != f.SHA256 {
+			t.Errorf("wrong content of %s", f.File)
+		}
+	}
+	if have := strings.Count(out.String(), "\n"); have != 3 {
+		t.Errorf("expected 3 downloaded files to be printed, have %d:\n%s", have, out.String())
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,6 +13,7 @@ import (
 	"text/template"
 
 	"github.com/grafana/regexp"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 	"github.com/sourcegraph/src-cli/internal/streaming"
@@ -19,18 +21,47 @@ import (
 
 var labelRegexp = regexp.MustCompile(`(?:\[)(.*?)(?:])`)
 
-func streamSearch(query string, opts streaming.Opts, client api.Client, w io.Writer) error {
-	var d streaming.Decoder
-	if opts.Json {
-		d = jsonDecoder(w)
-	} else {
+func streamSearch(ctx context.Context, query string, opts streaming.Opts, client api.Client, w io.Writer) error {
+	format := opts.Format
+	if format == "" {
+		format = streamFormatText
+		if opts.Json {
+			format = streamFormatJSON
+		}
+	}
+
+	var (
+		d     streaming.Decoder
+		flush func() error
+	)
+	switch format {
+	case streamFormatText:
 		t, err := parseTemplate(streamingTemplate)
 		if err != nil {
 			return err
 		}
 		d = textDecoder(query, t, w)
+	case streamFormatJSON:
+		d = jsonDecoder(w)
+	case streamFormatSARIF:
+		d, flush = sarifDecoder(query, w)
+	case streamFormatCSV:
+		d, flush = csvDecoder(w)
+	case streamFormatGHAnnotations:
+		d = ghAnnotationsDecoder(query, w)
+	default:
+		return errors.Newf("unknown format %q, expected one of %s", format, strings.Join(streamFormats, ", "))
+	}
+
+	err := streaming.SearchContext(ctx, query, opts, client, d)
+	if flush != nil {
+		// Write what was found so far, even if the search was interrupted,
+		// timed out or failed.
+		if flushErr := flush(); err == nil {
+			err = flushErr
+		}
 	}
-	return streaming.Search(query, opts, client, d)
+	return err
 }
 
 // jsonDecoder streams results as JSON to w.
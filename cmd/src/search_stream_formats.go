@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/sourcegraph/src-cli/internal/streaming"
+	"github.com/sourcegraph/src-cli/internal/version"
+)
+
+// The output formats of streaming search.
+const (
+	streamFormatText          = "text"
+	streamFormatJSON          = "json"
+	streamFormatSARIF         = "sarif"
+	streamFormatCSV           = "csv"
+	streamFormatGHAnnotations = "gh-annotations"
+)
+
+var streamFormats = []string{streamFormatText, streamFormatJSON, streamFormatSARIF, streamFormatCSV, streamFormatGHAnnotations}
+
+// matchLocation is a location in a file that matched a search, as printed by
+// the SARIF, CSV and GitHub annotation formats. Lines and columns start at 1,
+// and the end is exclusive.
+type matchLocation struct {
+	Repository string
+	Commit     string
+	Path       string
+
+	// Line is 0 for path matches, which match the file as a whole.
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+
+	// Preview is the line of the file that contains the start of the match.
+	Preview string
+}
+
+// matchLocations returns a location for each range of a content match, and
+// one for a path match. Other matches have no location in a file.
+func matchLocations(match streaming.EventMatch) []matchLocation {
+	switch match := match.(type) {
+	case *streaming.EventContentMatch:
+		var locations []matchLocation
+		for _, chunk := range match.ChunkMatches {
+			lines := strings.Split(chunk.Content, "\n")
+			for _, r := range chunk.Ranges {
+				var preview string
+				if i := r.Start.Line - chunk.ContentStart.Line; i >= 0 && i < len(lines) {
+					preview = strings.TrimRight(lines[i], "\r")
+				}
+				locations = append(locations, matchLocation{
+					Repository: match.Repository,
+					Commit:     match.Commit,
+					Path:       match.Path,
+					Line:       r.Start.Line + 1,
+					Column:     r.Start.Column + 1,
+					EndLine:    r.End.Line + 1,
+					EndColumn:  r.End.Column + 1,
+					Preview:    preview,
+				})
+			}
+		}
+		return locations
+
+	case *streaming.EventPathMatch:
+		return []matchLocation{{
+			Repository: match.Repository,
+			Commit:     match.Commit,
+			Path:       match.Path,
+		}}
+	}
+	return nil
+}
+
+// locationDecoder returns a decoder that calls write for each match location.
+// Errors and alerts are written to stderr.
+func locationDecoder(write func(matchLocation) error) streaming.Decoder {
+	return streaming.Decoder{
+		OnMatches: func(matches []streaming.EventMatch) {
+			for _, match := range matches {
+				for _, loc := range matchLocations(match) {
+					if err := write(loc); err != nil {
+						logError(err.Error() + "\n")
+						return
+					}
+				}
+			}
+		},
+		OnAlert: func(alert *streaming.EventAlert) {
+			logError(fmt.Sprintf("%s: %s\n", alert.Title, alert.Description))
+		},
+		OnError: func(eventError *streaming.EventError) {
+			logError(eventError.Message + "\n")
+		},
+	}
+}
+
+// csvDecoder streams the locations of matches to w as CSV, with a header row.
+func csvDecoder(w io.Writer) (streaming.Decoder, func() error) {
+	cw := csv.NewWriter(w)
+	headerWritten := false
+	d := locationDecoder(func(loc matchLocation) error {
+		if !headerWritten {
+			headerWritten = true
+			if err := cw.Write([]string{"repository", "commit", "path", "line", "column", "end_line", "end_column", "preview"}); err != nil {
+				return err
+			}
+		}
+		return cw.Write([]string{
+			loc.Repository,
+			loc.Commit,
+			loc.Path,
+			formatLocationNumber(loc.Line),
+			formatLocationNumber(loc.Column),
+			formatLocationNumber(loc.EndLine),
+			formatLocationNumber(loc.EndColumn),
+			loc.Preview,
+		})
+	})
+	return d, func() error {
+		cw.Flush()
+		return cw.Error()
+	}
+}
+
+func formatLocationNumber(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// ghAnnotationsDecoder streams the locations of matches to w as GitHub Actions
+// workflow commands, which annotate the matching lines as warnings.
+//
+// See https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions#setting-a-warning-message.
+func ghAnnotationsDecoder(query string, w io.Writer) streaming.Decoder {
+	return locationDecoder(func(loc matchLocation) error {
+		props := []string{"file=" + escapeGHProperty(loc.Path)}
+		if loc.Line > 0 {
+			props = append(props, "line="+strconv.Itoa(loc.Line), "col="+strconv.Itoa(loc.Column))
+			props = append(props, "endLine="+strconv.Itoa(loc.EndLine), "endColumn="+strconv.Itoa(loc.EndColumn))
+		}
+		props = append(props, "title="+escapeGHProperty(loc.Repository))
+
+		message := fmt.Sprintf("Match for %q", query)
+		if preview := strings.TrimSpace(loc.Preview); preview != "" {
+			message += ": " + preview
+		}
+		_, err := fmt.Fprintf(w, "::warning %s::%s\n", strings.Join(props, ","), escapeGHData(message))
+		return err
+	})
+}
+
+func escapeGHData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeGHProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// sarifRuleID is the ID of the single rule of SARIF logs: matching the query.
+const sarifRuleID = "sourcegraph-search"
+
+// sarifDecoder collects the locations of matches, and writes them to w as a
+// SARIF 2.1.0 log once the search is done.
+//
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+func sarifDecoder(query string, w io.Writer) (streaming.Decoder, func() error) {
+	results := []sarifResult{}
+	d := locationDecoder(func(loc matchLocation) error {
+		result := sarifResult{
+			RuleID:  sarifRuleID,
+			Level:   "warning",
+			Message: sarifMessage{Text: fmt.Sprintf("Match for %q in %s", query, loc.Repository)},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: loc.Path},
+				},
+			}},
+			Properties: map[string]string{"repository": loc.Repository},
+		}
+		if loc.Commit != "" {
+			result.Properties["commit"] = loc.Commit
+		}
+		if loc.Line > 0 {
+			result.Locations[0].PhysicalLocation.Region = &sarifRegion{
+				StartLine:   loc.Line,
+				StartColumn: loc.Column,
+				EndLine:     loc.EndLine,
+				EndColumn:   loc.EndColumn,
+			}
+			if loc.Preview != "" {
+				result.Locations[0].PhysicalLocation.Region.Snippet = &sarifMessage{Text: loc.Preview}
+			}
+		}
+		results = append(results, result)
+		return nil
+	})
+
+	return d, func() error {
+		log := sarifLog{
+			Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+			Version: "2.1.0",
+			Runs: []sarifRun{{
+				Tool: sarifTool{Driver: sarifDriver{
+					Name:           "src-cli",
+					InformationURI: "https://github.com/sourcegraph/src-cli",
+					Version:        version.BuildTag,
+					Rules: []sarifRule{{
+						ID:               sarifRuleID,
+						ShortDescription: sarifMessage{Text: "Sourcegraph search match"},
+						FullDescription:  sarifMessage{Text: fmt.Sprintf("Code that matches the Sourcegraph search query %q.", query)},
+					}},
+				}},
+				Results: results,
+			}},
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(log)
+	}
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Version        string      `json:"version"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int           `json:"startLine"`
+	StartColumn int           `json:"startColumn"`
+	EndLine     int           `json:"endLine"`
+	EndColumn   int           `json:"endColumn"`
+	Snippet     *sarifMessage `json:"snippet,omitempty"`
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hexops/autogold"
 
@@ -157,7 +161,7 @@ func TestSearchStream(t *testing.T) {
 			flagSet := flag.NewFlagSet("test", flag.ExitOnError)
 			flags := api.NewFlags(flagSet)
 			client := cfg.apiClient(flags, flagSet.Output())
-			err = streamSearch("", c.opts, client, w)
+			err = streamSearch(context.Background(), "", c.opts, client, w)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -175,3 +179,104 @@ func TestSearchStream(t *testing.T) {
 	}
 
 }
+
+func TestSearchStreamFormats(t *testing.T) {
+	matches := []streaming.EventMatch{
+		&streaming.EventContentMatch{
+			Type:       streaming.ContentMatchType,
+			Path:       "cmd/main.go",
+			Repository: "github.com/org/repo",
+			Commit:     "deadbeef",
+			ChunkMatches: []streaming.ChunkMatch{
+				{
+					Content:      "import (\n\t\"io/ioutil\"\n)",
+					ContentStart: streaming.Location{Offset: 100, Line: 9},
+					Ranges: []streaming.Range{
+						{
+							Start: streaming.Location{Offset: 111, Line: 10, Column: 2},
+							End:   streaming.Location{Offset: 120, Line: 10, Column: 11},
+						},
+					},
+				},
+				{
+					Content:      "\tdata, err := ioutil.ReadAll(r)",
+					ContentStart: streaming.Location{Offset: 400, Line: 41},
+					Ranges: []streaming.Range{
+						{
+							Start: streaming.Location{Offset: 414, Line: 41, Column: 14},
+							End:   streaming.Location{Offset: 420, Line: 41, Column: 20},
+						},
+					},
+				},
+			},
+		},
+		&streaming.EventPathMatch{
+			Type:       streaming.PathMatchType,
+			Path:       "docs/ioutil, deprecated.md",
+			Repository: "github.com/org/repo",
+		},
+		&streaming.EventRepoMatch{
+			Type:       streaming.RepoMatchType,
+			Repository: "github.com/org/ioutil",
+		},
+	}
+	s := testServer(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writer, _ := streaming.NewWriter(w)
+		writer.Event("matches", matches)
+		writer.Event("done", nil)
+	}))
+	defer s.Close()
+
+	cfg = &config{
+		Endpoint: s.URL,
+	}
+	defer func() { cfg = nil }()
+
+	for _, format := range []string{streamFormatSARIF, streamFormatCSV, streamFormatGHAnnotations} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+			client := cfg.apiClient(api.NewFlags(flagSet), flagSet.Output())
+			if err := streamSearch(context.Background(), "ioutil", streaming.Opts{Format: format}, client, &buf); err != nil {
+				t.Fatal(err)
+			}
+			autogold.Equal(t, autogold.Raw(buf.String()))
+		})
+	}
+}
+
+func TestSearchStreamFormats_Interrupted(t *testing.T) {
+	// The search never finishes, so it times out after the first match.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer, _ := streaming.NewWriter(w)
+		writer.Event("matches", []streaming.EventMatch{
+			&streaming.EventPathMatch{
+				Type:       streaming.PathMatchType,
+				Path:       "docs/ioutil.md",
+				Repository: "github.com/org/repo",
+			},
+		})
+		<-r.Context().Done()
+	}))
+	defer s.Close()
+
+	cfg = &config{
+		Endpoint: s.URL,
+	}
+	defer func() { cfg = nil }()
+
+	for _, format := range []string{streamFormatSARIF, streamFormatCSV} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			flagSet := flag.NewFlagSet("test", flag.ExitOnError)
+			client := cfg.apiClient(api.NewFlags(flagSet), flagSet.Output())
+			opts := streaming.Opts{Format: format, Timeout: 200 * time.Millisecond}
+			if err := streamSearch(context.Background(), "ioutil", opts, client, &buf); err == nil {
+				t.Fatal("expected an error for the timed out search")
+			}
+			if !strings.Contains(buf.String(), "docs/ioutil.md") {
+				t.Errorf("the match found before the timeout wasn't written:\n%s", buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/cmderrors"
+	"github.com/sourcegraph/src-cli/internal/streaming"
+)
+
+func init() {
+	usage := `'src search watch' re-runs a search query on a schedule, and reports the
+matches that it hasn't seen before.
+
+Usage:
+
+	src search watch -state FILE [options] 'query'
+
+The fingerprints of the matches of each run are kept in the state file. A
+content match is new if one of its matching lines wasn't seen before, whether or
+not the line moved, and path, symbol, repository and commit matches are new if
+their path, symbols, repository or commit weren't seen before. Matches that go
+away and come back are reported again, unless the search that didn't find them
+was incomplete.
+
+The first run, without a state file, records the matches of the query as the
+baseline without reporting them, unless -initial is set.
+
+New matches are printed to stdout as a JSON object per run, or POSTed as that
+JSON object to the URL of -webhook:
+
+	{"query": "...", "time": "...", "matches": [...]}
+
+Searches return at most 500 results by default. Add count:all to the query to
+watch every match.
+
+Examples:
+
+  Report new uses of a deprecated function every 10 minutes:
+
+    	$ src search watch -state ioutil.json 'lang:go ioutil\.ReadAll count:all'
+
+  Check once, for example from cron, and POST new matches to a webhook:
+
+    	$ src search watch -once -state ioutil.json -webhook https://example.com/hook 'lang:go ioutil\.ReadAll'
+`
+
+	flagSet := flag.NewFlagSet("watch", flag.ExitOnError)
+	usageFunc := func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of 'src search %s':\n", flagSet.Name())
+		flagSet.PrintDefaults()
+		fmt.Println(usage)
+	}
+	var (
+		intervalFlag = flagSet.Duration("interval", 10*time.Minute, "How often to run the search.")
+		stateFlag    = flagSet.String("state", "", "The file to keep the fingerprints of the matches that were seen in. (required)")
+		webhookFlag  = flagSet.String("webhook", "", "POST new matches as JSON to this URL, instead of printing them to stdout.")
+		onceFlag     = flagSet.Bool("once", false, "Run the search once and exit, instead of on a schedule.")
+		initialFlag  = flagSet.Bool("initial", false, "Report the matches of the first run, instead of recording them as the baseline.")
+		apiFlags     = api.NewFlags(flagSet)
+	)
+
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+		if *stateFlag == "" {
+			return searchUsage("watch", "-state is required")
+		}
+		if *intervalFlag <= 0 {
+			return cmderrors.Usage("-interval must be positive")
+		}
+		if flagSet.NArg() != 1 {
+			return searchUsage("watch", "expected exactly one argument: the search query")
+		}
+
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+		watcher := &searchWatcher{
+			client:    client,
+			query:     flagSet.Arg(0),
+			statePath: *stateFlag,
+			initial:   *initialFlag,
+			trace:     apiFlags.Trace(),
+			emit: func(ctx context.Context, event *searchWatchEvent) error {
+				return json.NewEncoder(os.Stdout).Encode(event)
+			},
+		}
+		if *webhookFlag != "" {
+			webhookClient, err := api.NewHTTPClient(cfg.transportOpts())
+			if err != nil {
+				return errors.Wrap(err, "configuring webhook client")
+			}
+			watcher.emit = func(ctx context.Context, event *searchWatchEvent) error {
+				return postSearchWatchEvent(ctx, webhookClient, *webhookFlag, event)
+			}
+		}
+
+		ctx := context.Background()
+		ticker := time.NewTicker(*intervalFlag)
+		defer ticker.Stop()
+		for {
+			_, err := watcher.run(ctx)
+			if *onceFlag {
+				return err
+			}
+			if err != nil {
+				// A failed run leaves the state alone, so that the next run
+				// reports its new matches.
+				logError(fmt.Sprintf("%s: %s\n", time.Now().Format(time.RFC3339), err))
+			}
+			<-ticker.C
+		}
+	}
+
+	// Register the command.
+	searchCommands = append(searchCommands, &command{
+		flagSet:   flagSet,
+		handler:   handler,
+		usageFunc: usageFunc,
+	})
+}
+
+// searchWatchEvent is the JSON object that 'src search watch' reports new
+// matches with.
+type searchWatchEvent struct {
+	Query   string                 `json:"query"`
+	Time    time.Time              `json:"time"`
+	Matches []streaming.EventMatch `json:"matches"`
+}
+
+// searchWatchState is the state file of 'src search watch'.
+type searchWatchState struct {
+	Query     string    `json:"query"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Fingerprints are the sorted fingerprints of the matches that were seen.
+	Fingerprints []string `json:"fingerprints"`
+}
+
+// readSearchWatchState reads the state file at path. It returns nil if the
+// file doesn't exist yet.
+func readSearchWatchState(path string) (*searchWatchState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var state searchWatchState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, errors.Wrapf(err, "parsing state file %s", path)
+	}
+	return &state, nil
+}
+
+// write atomically replaces the state file at path, so that an interrupted
+// write doesn't lose the matches that were seen.
+func (s *searchWatchState) write(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return errors.Wrap(os.Rename(f.Name(), path), "writing state file")
+}
+
+// searchWatcher runs a search, and reports the matches that weren't seen in
+// its previous runs.
+type searchWatcher struct {
+	client    api.Client
+	query     string
+	statePath string
+	initial   bool
+	trace     bool
+	emit      func(context.Context, *searchWatchEvent) error
+}
+
+// run runs the search once, reports the new matches, and updates the state
+// file. It returns the number of new matches that were reported. The state
+// file is only updated if the new matches were reported successfully.
+func (w *searchWatcher) run(ctx context.Context) (int, error) {
+	state, err := readSearchWatchState(w.statePath)
+	if err != nil {
+		return 0, err
+	}
+	if state != nil && state.Query != w.query {
+		return 0, errors.Newf("state file %s is for the query %q, use a different state file for each query", w.statePath, state.Query)
+	}
+	seen := map[string]bool{}
+	if state != nil {
+		for _, fp := range state.Fingerprints {
+			seen[fp] = true
+		}
+	}
+
+	var (
+		matches    []streaming.EventMatch
+		progress   *streaming.Progress
+		incomplete bool
+	)
+	decoder := streaming.Decoder{
+		OnProgress: func(p *streaming.Progress) {
+			progress = p
+		},
+		OnMatches: func(m []streaming.EventMatch) {
+			matches = append(matches, m...)
+		},
+		OnAlert: func(alert *streaming.EventAlert) {
+			incomplete = true
+			logError(fmt.Sprintf("%s: %s\n", alert.Title, alert.Description))
+		},
+		OnError: func(eventError *streaming.EventError) {
+			incomplete = true
+			logError(eventError.Message + "\n")
+		},
+	}
+	start := time.Now().UTC()
+	if err := streaming.Search(w.query, streaming.Opts{Display: -1, Trace: w.trace}, w.client, decoder); err != nil {
+		return 0, err
+	}
+	if progress == nil || !progress.Complete {
+		incomplete = true
+	}
+
+	current := map[string]bool{}
+	var newMatches []streaming.EventMatch
+	for _, match := range matches {
+		isNew := false
+		for _, fp := range searchMatchFingerprints(match) {
+			if !seen[fp] && !current[fp] {
+				isNew = true
+			}
+			current[fp] = true
+		}
+		if isNew {
+			newMatches = append(newMatches, match)
+		}
+	}
+	// Matches that an incomplete search didn't find may still be there, so
+	// they stay seen.
+	if incomplete {
+		for fp := range seen {
+			current[fp] = true
+		}
+	}
+
+	if state == nil && !w.initial {
+		fmt.Fprintf(os.Stderr, "Recorded %d matches as the baseline, later runs report new matches only.\n", len(matches))
+		newMatches = nil
+	}
+	if len(newMatches) > 0 {
+		if err := w.emit(ctx, &searchWatchEvent{Query: w.query, Time: start, Matches: newMatches}); err != nil {
+			return 0, err
+		}
+	}
+
+	fingerprints := make([]string, 0, len(current))
+	for fp := range current {
+		fingerprints = append(fingerprints, fp)
+	}
+	sort.Strings(fingerprints)
+	newState := &searchWatchState{Query: w.query, UpdatedAt: start, Fingerprints: fingerprints}
+	if err := newState.write(w.statePath); err != nil {
+		return 0, err
+	}
+	return len(newMatches), nil
+}
+
+// searchMatchFingerprints returns the fingerprints of a match. They don't
+// depend on the commit or on line numbers, so that a match isn't new just
+// because its file changed elsewhere.
+func searchMatchFingerprints(match streaming.EventMatch) []string {
+	var parts [][]string
+	switch match := match.(type) {
+	case *streaming.EventContentMatch:
+		for _, loc := range matchLocations(match) {
+			parts = append(parts, []string{"content", match.Repository, match.Path, strings.TrimSpace(loc.Preview)})
+		}
+	case *streaming.EventPathMatch:
+		parts = append(parts, []string{"path", match.Repository, match.Path})
+	case *streaming.EventSymbolMatch:
+		for _, symbol := range match.Symbols {
+			parts = append(parts, []string{"symbol", match.Repository, match.Path, symbol.Kind, symbol.ContainerName, symbol.Name})
+		}
+	case *streaming.EventRepoMatch:
+		parts = append(parts, []string{"repo", match.Repository})
+	case *streaming.EventCommitMatch:
+		parts = append(parts, []string{"commit", match.URL})
+	}
+
+	fingerprints := make([]string, 0, len(parts))
+	for _, p := range parts {
+		sum := sha256.Sum256([]byte(strings.Join(p, "\x00")))
+		fingerprints = append(fingerprints, hex.EncodeToString(sum[:]))
+	}
+	return fingerprints
+}
+
+// postSearchWatchEvent POSTs the event as JSON to the webhook URL with client,
+// which should have the TLS and proxy settings of the configuration.
+func postSearchWatchEvent(ctx context.Context, client *http.Client, url string, event *searchWatchEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "posting to webhook")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Newf("webhook returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
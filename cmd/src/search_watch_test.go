@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/streaming"
+)
+
+func TestSearchWatcher(t *testing.T) {
+	contentMatch := func(lines ...string) *streaming.EventContentMatch {
+		m := &streaming.EventContentMatch{Type: streaming.ContentMatchType, Repository: "github.com/org/repo", Path: "main.go"}
+		for i, line := range lines {
+			m.ChunkMatches = append(m.ChunkMatches, streaming.ChunkMatch{
+				Content:      line,
+				ContentStart: streaming.Location{Line: i * 10},
+				Ranges: []streaming.Range{{
+					Start: streaming.Location{Line: i * 10},
+					End:   streaming.Location{Line: i * 10, Column: len(line)},
+				}},
+			})
+		}
+		return m
+	}
+	pathMatch := &streaming.EventPathMatch{Type: streaming.PathMatchType, Repository: "github.com/org/repo", Path: "ioutil.go"}
+
+	// Each run of the search gets the next matches.
+	type run struct {
+		matches []streaming.EventMatch
+		skipped []streaming.Skipped
+	}
+	var runs []run
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := runs[0]
+		runs = runs[1:]
+		writer, _ := streaming.NewWriter(w)
+		writer.Event("matches", current.matches)
+		writer.Event("progress", streaming.Progress{Done: true, Skipped: current.skipped})
+		writer.Event("done", nil)
+	}))
+	defer ts.Close()
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: flagSet.Output(), Flags: api.NewFlags(flagSet)})
+
+	var emitted []*searchWatchEvent
+	watcher := &searchWatcher{
+		client:    client,
+		query:     "ioutil",
+		statePath: filepath.Join(t.TempDir(), "state.json"),
+		emit: func(ctx context.Context, event *searchWatchEvent) error {
+			emitted = append(emitted, event)
+			return nil
+		},
+	}
+
+	for _, tc := range []struct {
+		name string
+		run  run
+		want []streaming.EventMatch
+	}{
+		{
+			name: "baseline",
+			run:  run{matches: []streaming.EventMatch{contentMatch("ioutil.ReadAll(r)")}},
+		},
+		{
+			name: "no change",
+			run:  run{matches: []streaming.EventMatch{contentMatch("ioutil.ReadAll(r)")}},
+		},
+		{
+			name: "new line and path",
+			run:  run{matches: []streaming.EventMatch{contentMatch("  ioutil.ReadAll(r)", "ioutil.ReadFile(f)"), pathMatch}},
+			want: []streaming.EventMatch{contentMatch("  ioutil.ReadAll(r)", "ioutil.ReadFile(f)"), pathMatch},
+		},
+		{
+			name: "incomplete",
+			run: run{
+				matches: []streaming.EventMatch{pathMatch},
+				skipped: []streaming.Skipped{{Reason: streaming.ShardTimeout, Title: "timed out"}},
+			},
+		},
+		{
+			name: "still seen after incomplete",
+			run:  run{matches: []streaming.EventMatch{contentMatch("ioutil.ReadAll(r)", "ioutil.ReadFile(f)")}},
+		},
+		{
+			name: "reported again after going away",
+			run:  run{matches: []streaming.EventMatch{pathMatch}},
+			want: []streaming.EventMatch{pathMatch},
+		},
+	} {
+		runs = append(runs, tc.run)
+		emitted = nil
+		n, err := watcher.run(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if n != len(tc.want) {
+			t.Errorf("%s: expected %d new matches, have %d", tc.name, len(tc.want), n)
+		}
+		var have []streaming.EventMatch
+		for _, event := range emitted {
+			if event.Query != "ioutil" {
+				t.Errorf("%s: wrong query %q", tc.name, event.Query)
+			}
+			have = append(have, event.Matches...)
+		}
+		if diff := cmp.Diff(tc.want, have); diff != "" {
+			t.Errorf("%s: unexpected new matches (-want +have):\n%s", tc.name, diff)
+		}
+	}
+
+	watcher.query = "other"
+	if _, err := watcher.run(context.Background()); err == nil || !strings.Contains(err.Error(), `is for the query "ioutil"`) {
+		t.Errorf("expected an error for a state file of another query, got %v", err)
+	}
+}
+
+func TestPostSearchWatchEvent(t *testing.T) {
+	status := http.StatusNoContent
+	var body []byte
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if have := r.Header.Get("Content-Type"); have != "application/json" {
+			t.Errorf("wrong content type %q", have)
+		}
+		if have := r.Header.Get("Authorization"); have != "" {
+			t.Errorf("the access token was sent to the webhook: %q", have)
+		}
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	// The webhook is only trusted through the configured CA bundle.
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := api.NewHTTPClient(api.TransportOpts{CABundlePath: caPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := &searchWatchEvent{
+		Query:   "ioutil",
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Matches: []streaming.EventMatch{&streaming.EventPathMatch{Type: streaming.PathMatchType, Repository: "github.com/org/repo", Path: "ioutil.go"}},
+	}
+	if err := postSearchWatchEvent(context.Background(), client, ts.URL, event); err != nil {
+		t.Fatal(err)
+	}
+	var have map[string]any
+	if err := json.Unmarshal(body, &have); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"query": "ioutil",
+		"time":  "2024-01-02T03:04:05Z",
+		"matches": []any{
+			map[string]any{"type": "path", "repository": "github.com/org/repo", "path": "ioutil.go"},
+		},
+	}
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("unexpected body (-want +have):\n%s", diff)
+	}
+
+	status = http.StatusInternalServerError
+	if err := postSearchWatchEvent(context.Background(), client, ts.URL, event); err == nil || !strings.Contains(err.Error(), "HTTP 500") {
+		t.Errorf("expected an error for HTTP 500, got %v", err)
+	}
+}
@@ -21,7 +21,8 @@ COMMANDS
 	flagSet := flag.NewFlagSet("snapshot", flag.ExitOnError)
 
 	commands = append(commands, &command{
-		flagSet: flagSet,
+		flagSet:     flagSet,
+		subcommands: &snapshotCommands,
 		handler: func(args []string) error {
 			snapshotCommands.run(flagSet, "src snapshot", usage, args)
 			return nil
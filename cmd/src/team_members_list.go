@@ -2,10 +2,8 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"os"
 
 	"github.com/sourcegraph/sourcegraph/lib/errors"
 
@@ -33,10 +31,11 @@ Examples:
 	}
 	var (
 		nameFlag   = flagSet.String("name", "", "The team of which to return members")
-		firstFlag  = flagSet.Int("first", 1000, "Returns the first n teams from the list")
+		firstFlag  = flagSet.Int("first", 1000, "Returns the first n team members from the list. (use -1 for unlimited)")
 		queryFlag  = flagSet.String("query", "", `Returns teams whose name or displayname match the query. (e.g. "engineering")`)
 		formatFlag = flagSet.String("f", "{{.Username}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.Name}}: {{.DisplayName}}" or "{{.|json}}")`)
-		jsonFlag   = flagSet.Bool("json", false, `Format for the output as json`)
+		jsonFlag   = flagSet.Bool("json", false, `Format for the output as json (deprecated: use -o json)`)
+		outFlags   = newOutputFlags(flagSet, formatFlag, "ID", "Username")
 		apiFlags   = api.NewFlags(flagSet)
 	)
 
@@ -49,7 +48,10 @@ Examples:
 			return errors.New("must provide -name")
 		}
 
-		tmpl, err := parseTemplate(*formatFlag)
+		if *jsonFlag && *outFlags.format == "" {
+			*outFlags.format = "json"
+		}
+		out, err := outFlags.writer(true)
 		if err != nil {
 			return err
 		}
@@ -59,47 +61,41 @@ Examples:
 		query := `query TeamMembers(
 	$name: String!,
 	$first: Int,
+	$after: String,
 	$search: String
 ) {
 	team(name: $name) {
 		members (
 			first: $first,
+			after: $after,
 			search: $search
 		) {
 			nodes {
 				...TeamMemberFields
 			}
+			pageInfo {
+				endCursor
+				hasNextPage
+			}
 		}
 	}
 }
 ` + teamMemberFragment
 
-		var result struct {
-			Team struct {
-				Members struct {
-					Nodes []TeamMember
-				}
-			}
-		}
-		if ok, err := client.NewRequest(query, map[string]interface{}{
-			"name":   *nameFlag,
-			"first":  api.NullInt(*firstFlag),
-			"search": api.NullString(*queryFlag),
-		}).Do(context.Background(), &result); err != nil || !ok {
+		if err := api.Paginate(context.Background(), client, api.PaginatedQuery{
+			Query: query,
+			Vars: map[string]interface{}{
+				"name":   *nameFlag,
+				"search": api.NullString(*queryFlag),
+			},
+			Path:  []string{"team", "members"},
+			Limit: *firstFlag,
+		}, func(t TeamMember) error {
+			return out.Write(t)
+		}); err != nil {
 			return err
 		}
-
-		if jsonFlag != nil && *jsonFlag {
-			json.NewEncoder(os.Stdout).Encode(result.Team.Members.Nodes)
-			return nil
-		}
-
-		for _, t := range result.Team.Members.Nodes {
-			if err := execTemplate(tmpl, t); err != nil {
-				return err
-			}
-		}
-		return nil
+		return out.Close()
 	}
 
 	// Register the command.
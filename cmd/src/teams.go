@@ -33,9 +33,10 @@ Use "src teams [command] -h" for more information about a command.
 
 	// Register the command.
 	commands = append(commands, &command{
-		flagSet: flagSet,
-		aliases: []string{"team"},
-		handler: handler,
+		flagSet:     flagSet,
+		aliases:     []string{"team"},
+		subcommands: &teamsCommands,
+		handler:     handler,
 		usageFunc: func() {
 			fmt.Println(usage)
 		},
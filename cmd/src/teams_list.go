@@ -2,10 +2,8 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"os"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 )
@@ -30,11 +28,12 @@ Examples:
 		fmt.Println(usage)
 	}
 	var (
-		firstFlag      = flagSet.Int("first", 1000, "Returns the first n teams from the list")
+		firstFlag      = flagSet.Int("first", 1000, "Returns the first n teams from the list. (use -1 for unlimited)")
 		parentTeamFlag = flagSet.String("parent-team", "", "Get child teams of the given team")
 		queryFlag      = flagSet.String("query", "", `Returns teams whose name or displayname match the query. (e.g. "engineering")`)
 		formatFlag     = flagSet.String("f", "{{.Name}} {{if .DisplayName}}({{.DisplayName}}){{end}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.Name}}: {{.DisplayName}}" or "{{.|json}}")`)
-		jsonFlag       = flagSet.Bool("json", false, `Format for the output as json`)
+		jsonFlag       = flagSet.Bool("json", false, `Format for the output as json (deprecated: use -o json)`)
+		outFlags       = newOutputFlags(flagSet, formatFlag, "ID", "Name", "DisplayName", "Readonly")
 		apiFlags       = api.NewFlags(flagSet)
 	)
 
@@ -43,7 +42,10 @@ Examples:
 			return err
 		}
 
-		tmpl, err := parseTemplate(*formatFlag)
+		if *jsonFlag && *outFlags.format == "" {
+			*outFlags.format = "json"
+		}
+		out, err := outFlags.writer(true)
 		if err != nil {
 			return err
 		}
@@ -52,73 +54,65 @@ Examples:
 
 		query := `query Teams(
 	$first: Int,
+	$after: String,
 	$search: String
 ) {
 	teams(
 		first: $first,
+		after: $after,
 		search: $search
 	) {
 		nodes {
 			...TeamFields
 		}
-		
+		pageInfo {
+			endCursor
+			hasNextPage
+		}
 	}
 }` + teamFragment
+		path := []string{"teams"}
 
 		if *parentTeamFlag != "" {
 			query = `query ChildTeams(
 		$first: Int,
+		$after: String,
 		$search: String,
 		$parentTeamName: String!
 	) {
 		team(name: $parentTeamName) {
 			childTeams(
 				first: $first,
+				after: $after,
 				search: $search
 			) {
 				nodes {
 					...TeamFields
 				}
-				
+				pageInfo {
+					endCursor
+					hasNextPage
+				}
 			}
 		}
 	}` + teamFragment
+			path = []string{"team", "childTeams"}
 		}
 
-		var result struct {
-			Teams struct {
-				Nodes []Team
-			}
-			Team struct {
-				ChildTeams struct {
-					Nodes []Team
-				}
-			}
-		}
-		if ok, err := client.NewRequest(query, map[string]interface{}{
-			"first":          api.NullInt(*firstFlag),
-			"search":         api.NullString(*queryFlag),
-			"parentTeamName": api.NullString(*parentTeamFlag),
-		}).Do(context.Background(), &result); err != nil || !ok {
+		if err := api.Paginate(context.Background(), client, api.PaginatedQuery{
+			Query: query,
+			Vars: map[string]interface{}{
+				"search":         api.NullString(*queryFlag),
+				"parentTeamName": api.NullString(*parentTeamFlag),
+			},
+			Path:  path,
+			Limit: *firstFlag,
+		}, func(t Team) error {
+			return out.Write(t)
+		}); err != nil {
 			return err
 		}
-
-		nodes := result.Teams.Nodes
-		if *parentTeamFlag != "" {
-			nodes = result.Team.ChildTeams.Nodes
-		}
-
-		if jsonFlag != nil && *jsonFlag {
-			json.NewEncoder(os.Stdout).Encode(nodes)
-			return nil
-		}
-
-		for _, t := range nodes {
-			if err := execTemplate(tmpl, t); err != nil {
-				return err
-			}
-		}
-		return nil
+		return out.Close()
 	}
 
 	// Register the command.
@@ -31,9 +31,10 @@ Use "src team members [command] -h" for more information about a command.
 
 	// Register the command.
 	teamsCommands = append(teamsCommands, &command{
-		flagSet: flagSet,
-		aliases: []string{"member"},
-		handler: handler,
+		flagSet:     flagSet,
+		aliases:     []string{"member"},
+		subcommands: &teamMembersCommands,
+		handler:     handler,
 		usageFunc: func() {
 			fmt.Println(usage)
 		},
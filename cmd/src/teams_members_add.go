@@ -8,6 +8,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/lib/errors"
 
 	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/bulk"
 )
 
 func init() {
@@ -18,6 +19,14 @@ Examples:
 
     	$ src teams members add -team-name='engineering' [-email='alice@sourcegraph.com'] [-username='alice'] [-id='VXNlcjox'] [-external-account-service-id='https://github.com/' -external-account-service-type='github' [-external-account-account-id='123123123'] [-external-account-login='alice']]
 
+  Add the users listed in a file, one username per line, to a team:
+
+    	$ src teams members add -team-name='engineering' -from-file=usernames.txt
+
+  Add the team members listed in a CSV file with "team-name" and "email" columns:
+
+    	$ src teams members add -from-file=members.csv -resume=members.done
+
 `
 
 	flagSet := flag.NewFlagSet("add", flag.ExitOnError)
@@ -36,6 +45,7 @@ Examples:
 		externalAccountServiceTypeFlag = flagSet.String("external-account-service-type", "", "External account service type to match the user by, must specify all of externalAccount*")
 		externalAccountAccountIDFlag   = flagSet.String("external-account-account-id", "", "External account account ID to match the user by, must specify all of externalAccount*")
 		externalAccountLoginFlag       = flagSet.String("external-account-login", "", "External account login ID to match the user by, must specify all of externalAccount*")
+		bulkFlags                      = newBulkFlags(flagSet, "username", "team-name", "email", "id", "external-account-service-id", "external-account-service-type", "external-account-account-id", "external-account-login")
 		apiFlags                       = api.NewFlags(flagSet)
 	)
 
@@ -44,13 +54,31 @@ Examples:
 			return err
 		}
 
-		if *teamNameFlag == "" {
+		if *teamNameFlag == "" && !bulkFlags.enabled() {
 			return errors.New("provide a team name")
 		}
 
 		client := cfg.apiClient(apiFlags, flagSet.Output())
 
-		query := `mutation AddTeamMember(
+		// fields are the flags that can be overridden by the records of
+		// -from-file.
+		fields := map[string]string{
+			"team-name":                     *teamNameFlag,
+			"id":                            *idFlag,
+			"email":                         *emailFlag,
+			"username":                      *usernameFlag,
+			"external-account-service-id":   *externalAccountServiceIDFlag,
+			"external-account-service-type": *externalAccountServiceTypeFlag,
+			"external-account-account-id":   *externalAccountAccountIDFlag,
+			"external-account-login":        *externalAccountLoginFlag,
+		}
+
+		addMember := func(ctx context.Context, fields map[string]string) (bool, error) {
+			if fields["team-name"] == "" {
+				return false, errors.New("provide a team name")
+			}
+
+			query := `mutation AddTeamMember(
 	$teamName: String!
 	$id: ID,
 	$email: String,
@@ -79,24 +107,35 @@ Examples:
 }
 ` + teamFragment
 
-		var result struct {
-			AddTeamMembers Team
+			var result struct {
+				AddTeamMembers Team
+			}
+			return client.NewRequest(query, map[string]interface{}{
+				"teamName":                   fields["team-name"],
+				"skipUnmatchedMembers":       *skipUnmatchedMembersFlag,
+				"id":                         api.NullString(fields["id"]),
+				"email":                      api.NullString(fields["email"]),
+				"username":                   api.NullString(fields["username"]),
+				"externalAccountServiceID":   api.NullString(fields["external-account-service-id"]),
+				"externalAccountServiceType": api.NullString(fields["external-account-service-type"]),
+				"externalAccountAccountID":   api.NullString(fields["external-account-account-id"]),
+				"externalAccountLogin":       api.NullString(fields["external-account-login"]),
+			}).Do(ctx, &result)
 		}
-		if ok, err := client.NewRequest(query, map[string]interface{}{
-			"teamName":                   *teamNameFlag,
-			"skipUnmatchedMembers":       *skipUnmatchedMembersFlag,
-			"id":                         api.NullString(*idFlag),
-			"email":                      api.NullString(*emailFlag),
-			"username":                   api.NullString(*usernameFlag),
-			"externalAccountServiceID":   api.NullString(*externalAccountServiceIDFlag),
-			"externalAccountServiceType": api.NullString(*externalAccountServiceTypeFlag),
-			"externalAccountAccountID":   api.NullString(*externalAccountAccountIDFlag),
-			"externalAccountLogin":       api.NullString(*externalAccountLoginFlag),
-		}).Do(context.Background(), &result); err != nil || !ok {
-			return err
+
+		if bulkFlags.enabled() {
+			return bulkFlags.run(context.Background(), func(ctx context.Context, item bulk.Item) error {
+				itemFields := make(map[string]string, len(fields))
+				for name, value := range fields {
+					itemFields[name] = item.Get(name, value)
+				}
+				_, err := addMember(ctx, itemFields)
+				return err
+			})
 		}
 
-		return nil
+		_, err := addMember(context.Background(), fields)
+		return err
 	}
 
 	// Register the command.
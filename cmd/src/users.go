@@ -34,9 +34,10 @@ Use "src users [command] -h" for more information about a command.
 
 	// Register the command.
 	commands = append(commands, &command{
-		flagSet: flagSet,
-		aliases: []string{"user"},
-		handler: handler,
+		flagSet:     flagSet,
+		aliases:     []string{"user"},
+		subcommands: &usersCommands,
+		handler:     handler,
 		usageFunc: func() {
 			fmt.Println(usage)
 		},
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/bulk"
 )
 
 func init() {
@@ -26,7 +27,12 @@ Examples:
 
   Delete all user accounts that match the query:
 
-    	$ src users list -f='{{.ID}}' -query=alice | xargs -n 1 -I USERID src users delete -id=USERID
+    	$ src users list -f='{{.ID}}' -query=alice | src users delete -from-file=-
+
+  Delete the user accounts listed in a file, one ID per line, 8 at a time.
+  Running the same command again retries the ones that failed:
+
+    	$ src users delete -from-file=users.txt -concurrency=8 -resume=users.done
 
 `
 
@@ -38,9 +44,29 @@ Examples:
 	}
 	var (
 		userIDFlag = flagSet.String("id", "", `The ID of the user to delete.`)
+		bulkFlags  = newBulkFlags(flagSet, "id")
 		apiFlags   = api.NewFlags(flagSet)
 	)
 
+	deleteUser := func(ctx context.Context, client api.Client, userID string) (bool, error) {
+		query := `mutation DeleteUser(
+  $user: ID!
+) {
+  deleteUser(
+    user: $user
+  ) {
+    alwaysNil
+  }
+}`
+
+		var result struct {
+			DeleteUser struct{}
+		}
+		return client.NewRequest(query, map[string]interface{}{
+			"user": userID,
+		}).Do(ctx, &result)
+	}
+
 	handler := func(args []string) error {
 		if err := flagSet.Parse(args); err != nil {
 			return err
@@ -48,6 +74,13 @@ Examples:
 
 		client := cfg.apiClient(apiFlags, flagSet.Output())
 
+		if bulkFlags.enabled() {
+			return bulkFlags.run(context.Background(), func(ctx context.Context, item bulk.Item) error {
+				_, err := deleteUser(ctx, client, item.Get("id", *userIDFlag))
+				return err
+			})
+		}
+
 		if *userIDFlag == "" {
 			query := `query UsersTotalCountCountUsers { users { totalCount } }`
 
@@ -79,22 +112,7 @@ Examples:
 			}
 		}
 
-		query := `mutation DeleteUser(
-  $user: ID!
-) {
-  deleteUser(
-    user: $user
-  ) {
-    alwaysNil
-  }
-}`
-
-		var result struct {
-			DeleteUser struct{}
-		}
-		if ok, err := client.NewRequest(query, map[string]interface{}{
-			"user": *userIDFlag,
-		}).Do(context.Background(), &result); err != nil || !ok {
+		if ok, err := deleteUser(context.Background(), client, *userIDFlag); err != nil || !ok {
 			return err
 		}
 
@@ -30,6 +30,7 @@ Examples:
 		usernameFlag = flagSet.String("username", "", `Look up user by username. (e.g. "alice")`)
 		emailFlag    = flagSet.String("email", "", `Look up user by email. (e.g. "alice@sourcegraph.com")`)
 		formatFlag   = flagSet.String("f", "{{.|json}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Username}} ({{.DisplayName}})")`)
+		outFlags     = newOutputFlags(flagSet, formatFlag, "ID", "Username", "DisplayName", "SiteAdmin")
 		apiFlags     = api.NewFlags(flagSet)
 	)
 
@@ -44,7 +45,7 @@ Examples:
 			return errors.New("cannot specify both email and username")
 		}
 
-		tmpl, err := parseTemplate(*formatFlag)
+		out, err := outFlags.writer(false)
 		if err != nil {
 			return err
 		}
@@ -71,7 +72,10 @@ Examples:
 			return err
 		}
 
-		return execTemplate(tmpl, result.User)
+		if err := out.Write(result.User); err != nil {
+			return err
+		}
+		return out.Close()
 	}
 
 	// Register the command.
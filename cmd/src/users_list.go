@@ -41,6 +41,7 @@ Examples:
 		queryFlag  = flagSet.String("query", "", `Returns users whose names match the query. (e.g. "alice")`)
 		tagFlag    = flagSet.String("tag", "", `Returns users with the given tag.`)
 		formatFlag = flagSet.String("f", "{{.Username}}", `Format for the output, using the syntax of Go package text/template. (e.g. "{{.ID}}: {{.Username}} ({{.DisplayName}})" or "{{.|json}}")`)
+		outFlags   = newOutputFlags(flagSet, formatFlag, "ID", "Username", "DisplayName", "SiteAdmin")
 		apiFlags   = api.NewFlags(flagSet)
 	)
 
@@ -52,12 +53,11 @@ Examples:
 		ctx := context.Background()
 		client := cfg.apiClient(apiFlags, flagSet.Output())
 
-		tmpl, err := parseTemplate(*formatFlag)
+		out, err := outFlags.writer(true)
 		if err != nil {
 			return err
 		}
 		vars := map[string]interface{}{
-			"first": api.NullInt(*firstFlag),
 			"query": api.NullString(*queryFlag),
 			"tag":   api.NullString(*tagFlag),
 		}
@@ -69,35 +69,37 @@ Examples:
 		}
 		query := `query Users(
   $first: Int,
+  $after: String,
   $query: String,
 ` + queryTagVar + `
 ) {
   users(
-first: $first,
+    first: $first,
+    after: $after,
     query: $query,
 ` + queryTag + `
   ) {
     nodes {
       ...UserFields
     }
+    pageInfo {
+      endCursor
+      hasNextPage
+    }
   }
 }` + userFragment
 
-		var result struct {
-			Users struct {
-				Nodes []User
-			}
-		}
-		if ok, err := client.NewRequest(query, vars).Do(ctx, &result); err != nil || !ok {
+		if err := api.Paginate(ctx, client, api.PaginatedQuery{
+			Query: query,
+			Vars:  vars,
+			Path:  []string{"users"},
+			Limit: *firstFlag,
+		}, func(user User) error {
+			return out.Write(user)
+		}); err != nil {
 			return err
 		}
-
-		for _, user := range result.Users.Nodes {
-			if err := execTemplate(tmpl, user); err != nil {
-				return err
-			}
-		}
-		return nil
+		return out.Close()
 	}
 
 	// Register the command.
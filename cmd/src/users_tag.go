@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/sourcegraph/src-cli/internal/api"
+	"github.com/sourcegraph/src-cli/internal/bulk"
 )
 
 func init() {
@@ -20,6 +21,14 @@ Examples:
 
     	$ src users tag -user-id=$(src users get -f '{{.ID}}' -username=alice) -remove -tag=foo
 
+  Add a tag "foo" to all users that match the query:
+
+    	$ src users list -f='{{.ID}}' -query=alice | src users tag -from-file=- -tag=foo
+
+  Add the tags listed in a CSV file with "user-id" and "tag" columns:
+
+    	$ src users tag -from-file=tags.csv -report=report.json
+
 Related examples:
 
   List all users with the "foo" tag:
@@ -38,16 +47,11 @@ Related examples:
 		userIDFlag = flagSet.String("user-id", "", `The ID of the user to tag. (required)`)
 		tagFlag    = flagSet.String("tag", "", `The tag to set on the user. (required)`)
 		removeFlag = flagSet.Bool("remove", false, `Remove the tag. (default: add the tag`)
+		bulkFlags  = newBulkFlags(flagSet, "user-id", "tag")
 		apiFlags   = api.NewFlags(flagSet)
 	)
 
-	handler := func(args []string) error {
-		if err := flagSet.Parse(args); err != nil {
-			return err
-		}
-
-		client := cfg.apiClient(apiFlags, flagSet.Output())
-
+	setTag := func(ctx context.Context, client api.Client, userID, tag string) error {
 		query := `mutation SetUserTag(
   $user: ID!,
   $tag: String!,
@@ -63,13 +67,29 @@ Related examples:
 }`
 
 		_, err := client.NewRequest(query, map[string]interface{}{
-			"user":    *userIDFlag,
-			"tag":     *tagFlag,
+			"user":    userID,
+			"tag":     tag,
 			"present": !*removeFlag,
-		}).Do(context.Background(), &struct{}{})
+		}).Do(ctx, &struct{}{})
 		return err
 	}
 
+	handler := func(args []string) error {
+		if err := flagSet.Parse(args); err != nil {
+			return err
+		}
+
+		client := cfg.apiClient(apiFlags, flagSet.Output())
+
+		if bulkFlags.enabled() {
+			return bulkFlags.run(context.Background(), func(ctx context.Context, item bulk.Item) error {
+				return setTag(ctx, client, item.Get("user-id", *userIDFlag), item.Get("tag", *tagFlag))
+			})
+		}
+
+		return setTag(context.Background(), client, *userIDFlag, *tagFlag)
+	}
+
 	// Register the command.
 	usersCommands = append(usersCommands, &command{
 		flagSet:   flagSet,
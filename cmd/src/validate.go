@@ -34,9 +34,10 @@ Use "src validate [command] -h" for more information about a command.
 
 	// Register the command
 	commands = append(commands, &command{
-		flagSet: flagSet,
-		aliases: []string{"validate"},
-		handler: handler,
+		flagSet:     flagSet,
+		aliases:     []string{"validate"},
+		subcommands: &validateCommands,
+		handler:     handler,
 		usageFunc: func() {
 			fmt.Println(usage)
 		},
@@ -4,7 +4,6 @@ package api
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -17,6 +16,8 @@ import (
 	"github.com/kballard/go-shellquote"
 	"github.com/mattn/go-isatty"
 
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
 	"github.com/sourcegraph/src-cli/internal/version"
 )
 
@@ -45,7 +46,8 @@ type Request interface {
 	// transmitted and the response is unmarshalled into result.
 	//
 	// If no data was available to be unmarshalled — for example, due to the
-	// -get-curl flag being set — then ok will return false.
+	// -get-curl flag being set, or a mutation not being executed in dry-run
+	// mode — then ok will return false.
 	Do(ctx context.Context, result interface{}) (ok bool, err error)
 
 	// DoRaw has the same behaviour as Do, with one exception: the result will
@@ -59,6 +61,10 @@ type Request interface {
 type client struct {
 	opts       ClientOpts
 	httpClient *http.Client
+
+	// transportErr is set if the transport described by the options could
+	// not be built, and is returned by every request.
+	transportErr error
 }
 
 // request is the internal concrete type implementing Request.
@@ -74,6 +80,16 @@ type ClientOpts struct {
 	AccessToken       string
 	AdditionalHeaders map[string]string
 
+	// AccessTokenFunc, if set, returns the access token if AccessToken is
+	// empty. It's called for every request, so that the token is only looked
+	// up once it's needed, and should cache its result. Its error is returned
+	// by the request.
+	AccessTokenFunc func() (string, error)
+
+	// Transport configures the TLS and proxy settings used to connect to
+	// Sourcegraph.
+	Transport TransportOpts
+
 	// Flags are the standard API client flags provided by NewFlags. If nil,
 	// default values will be used.
 	Flags *Flags
@@ -81,6 +97,16 @@ type ClientOpts struct {
 	// Out is the writer that will be used when outputting diagnostics, such as
 	// curl commands when -get-curl is enabled.
 	Out io.Writer
+
+	// DryRun, if set, makes the client collect mutations and other requests
+	// that could change data, and print them to Out instead of sending them.
+	// Queries are sent as usual.
+	DryRun *DryRun
+
+	// NoCassette keeps the requests of the client out of the -record and
+	// -replay cassettes, for requests that carry credentials that can't be
+	// redacted, such as signing in with a password.
+	NoCassette bool
 }
 
 // NewClient creates a new API client.
@@ -94,22 +120,33 @@ func NewClient(opts ClientOpts) Client {
 		flags = defaultFlags()
 	}
 
-	httpClient := http.DefaultClient
-	if flags.insecureSkipVerify != nil && *flags.insecureSkipVerify {
-		httpClient = &http.Client{
-			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
-		}
+	insecureSkipVerify := flags.insecureSkipVerify != nil && *flags.insecureSkipVerify
+	transport, err := newTransport(opts.Transport, insecureSkipVerify)
+	if err != nil {
+		err = errors.Wrap(err, "configuring connection to Sourcegraph")
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	httpClient := &http.Client{
+		Transport: newRetryTransport(transport, flags.RetryPolicy()),
+	}
+
+	if record, replay := flags.Cassette(); err == nil && !opts.NoCassette && (record != "" || replay != "") {
+		httpClient.Transport, err = newCassetteTransport(httpClient.Transport, record, replay, opts.AdditionalHeaders)
 	}
 
 	return &client{
 		opts: ClientOpts{
 			Endpoint:          opts.Endpoint,
 			AccessToken:       opts.AccessToken,
+			AccessTokenFunc:   opts.AccessTokenFunc,
 			AdditionalHeaders: opts.AdditionalHeaders,
+			Transport:         opts.Transport,
 			Flags:             flags,
 			Out:               opts.Out,
+			DryRun:            opts.DryRun,
 		},
-		httpClient: httpClient,
+		httpClient:   httpClient,
+		transportErr: err,
 	}
 }
 
@@ -126,6 +163,16 @@ func (c *client) NewRequest(query string, vars map[string]interface{}) Request {
 }
 
 func (c *client) Do(req *http.Request) (*http.Response, error) {
+	if c.transportErr != nil {
+		return nil, c.transportErr
+	}
+	if c.opts.DryRun != nil {
+		if intercepted, err := c.opts.DryRun.interceptHTTPRequest(c.opts.Out, req); err != nil {
+			return nil, err
+		} else if intercepted {
+			return nil, ErrDryRun
+		}
+	}
 	return c.httpClient.Do(req)
 }
 
@@ -138,7 +185,18 @@ func (c *client) NewHTTPRequest(ctx context.Context, method, p string, body io.R
 	return req, nil
 }
 
+// accessToken returns the access token to authenticate requests with, if any.
+func (c *client) accessToken() (string, error) {
+	if c.opts.AccessToken == "" && c.opts.AccessTokenFunc != nil {
+		return c.opts.AccessTokenFunc()
+	}
+	return c.opts.AccessToken, nil
+}
+
 func (c *client) createHTTPRequest(ctx context.Context, method, p string, body io.Reader) (*http.Request, error) {
+	if c.transportErr != nil {
+		return nil, c.transportErr
+	}
 	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.opts.Endpoint, "/")+"/"+p, body)
 	if err != nil {
 		return nil, err
@@ -148,8 +206,12 @@ func (c *client) createHTTPRequest(ctx context.Context, method, p string, body i
 	} else {
 		req.Header.Set("User-Agent", "src-cli/"+version.BuildTag)
 	}
-	if c.opts.AccessToken != "" {
-		req.Header.Set("Authorization", "token "+c.opts.AccessToken)
+	token, err := c.accessToken()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
 	}
 	if *c.opts.Flags.trace {
 		req.Header.Set("X-Sourcegraph-Should-Trace", "true")
@@ -171,6 +233,11 @@ func (r *request) do(ctx context.Context, result interface{}) (bool, error) {
 		return false, err
 	}
 
+	// In dry-run mode, mutations aren't executed, and there is no result.
+	if r.client.opts.DryRun != nil && isMutation(r.query) {
+		return false, r.client.opts.DryRun.interceptMutation(r.client.opts.Out, r.query, r.vars)
+	}
+
 	if *r.client.opts.Flags.dump {
 		fmt.Fprintf(r.client.opts.Out, "<-- query:\n%s\n\n", r.query)
 		if len(r.vars) > 0 {
@@ -195,11 +262,22 @@ func (r *request) do(ctx context.Context, result interface{}) (bool, error) {
 		return false, err
 	}
 
-	var bufBody io.Reader = bytes.NewBuffer(reqBody)
-	bufBody = gzipReader(bufBody)
+	// Compress the body up front, rather than streaming it, so that it can
+	// be sent again if the request has to be retried.
+	var bufBody bytes.Buffer
+	if _, err := io.Copy(&bufBody, gzipReader(bytes.NewReader(reqBody))); err != nil {
+		return false, err
+	}
+
+	// Queries can always be retried, but mutations may only be retried when
+	// we know that the server didn't process them.
+	safety := retrySafe
+	if isMutation(r.query) {
+		safety = retryUnsafe
+	}
 
 	// Create the HTTP request.
-	req, err := r.client.NewHTTPRequest(ctx, "POST", ".api/graphql", bufBody)
+	req, err := r.client.NewHTTPRequest(withRetrySafety(ctx, safety), "POST", ".api/graphql", bytes.NewReader(bufBody.Bytes()))
 	if err != nil {
 		return false, err
 	}
@@ -299,13 +377,28 @@ func (r *request) curlCmd() (string, error) {
 		return "", err
 	}
 
+	token, err := r.client.accessToken()
+	if err != nil {
+		return "", err
+	}
+
 	s := "curl \\\n"
-	if r.client.opts.AccessToken != "" {
-		s += fmt.Sprintf("   %s \\\n", shellquote.Join("-H", "Authorization: token "+r.client.opts.AccessToken))
+	if token != "" {
+		s += fmt.Sprintf("   %s \\\n", shellquote.Join("-H", "Authorization: token "+token))
 	}
 	for k, v := range r.client.opts.AdditionalHeaders {
 		s += fmt.Sprintf("   %s \\\n", shellquote.Join("-H", k+": "+v))
 	}
+	transport := r.client.opts.Transport
+	if transport.CABundlePath != "" {
+		s += fmt.Sprintf("   %s \\\n", shellquote.Join("--cacert", transport.CABundlePath))
+	}
+	if transport.ClientCertPath != "" {
+		s += fmt.Sprintf("   %s \\\n", shellquote.Join("--cert", transport.ClientCertPath, "--key", transport.ClientKeyPath))
+	}
+	if transport.Proxy != "" && !strings.HasPrefix(transport.Proxy, "unix:") {
+		s += fmt.Sprintf("   %s \\\n", shellquote.Join("--proxy", transport.Proxy))
+	}
 	s += fmt.Sprintf("   %s \\\n", shellquote.Join("-d", string(data)))
 	s += fmt.Sprintf("   %s", shellquote.Join(r.client.opts.Endpoint+"/.api/graphql"))
 	return s, nil
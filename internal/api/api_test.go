@@ -1,3 +1,66 @@
 package api
 
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
 // TODO: implement a super basic GraphQL server that can return canned results.
+
+func TestNewClient_AccessTokenFunc(t *testing.T) {
+	var authorization string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"data": {}}`))
+	}))
+	defer ts.Close()
+
+	var calls int
+	client := NewClient(ClientOpts{
+		Endpoint: ts.URL,
+		AccessTokenFunc: func() (string, error) {
+			calls++
+			return "looked-up", nil
+		},
+		Out: io.Discard,
+	})
+	if calls != 0 {
+		t.Fatalf("access token looked up before a request was made")
+	}
+	if _, err := client.NewQuery("query { currentUser { username } }").Do(context.Background(), &struct{}{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if authorization != "token looked-up" {
+		t.Errorf("unexpected Authorization header: %q", authorization)
+	}
+
+	client = NewClient(ClientOpts{
+		Endpoint:    ts.URL,
+		AccessToken: "given",
+		AccessTokenFunc: func() (string, error) {
+			t.Error("unexpected access token lookup")
+			return "", nil
+		},
+		Out: io.Discard,
+	})
+	if _, err := client.NewQuery("query { currentUser { username } }").Do(context.Background(), &struct{}{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if authorization != "token given" {
+		t.Errorf("unexpected Authorization header: %q", authorization)
+	}
+
+	client = NewClient(ClientOpts{
+		Endpoint:        ts.URL,
+		AccessTokenFunc: func() (string, error) { return "", errors.New("vault is sealed") },
+		Out:             io.Discard,
+	})
+	if _, err := client.NewQuery("query { currentUser { username } }").Do(context.Background(), &struct{}{}); err == nil || err.Error() != "vault is sealed" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+// redacted replaces the values of sensitive headers in cassettes.
+const redacted = "REDACTED"
+
+// interaction is a single request and response pair stored in a cassette.
+// Cassettes are JSON lines files with one interaction per line, so that
+// several src invocations can append to the same cassette.
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Header http.Header `json:"header,omitempty"`
+
+	// GraphQL requests are recorded decoded, and matched on their operation
+	// name and variables.
+	Operation string          `json:"operation,omitempty"`
+	Query     string          `json:"query,omitempty"`
+	Variables json.RawMessage `json:"variables,omitempty"`
+
+	// Body is only recorded for requests that aren't GraphQL requests.
+	Body recordedBody `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	StatusCode int          `json:"statusCode"`
+	Header     http.Header  `json:"header,omitempty"`
+	Body       recordedBody `json:"body,omitempty"`
+}
+
+// recordedBody is stored as a plain string when it is valid UTF-8, which is
+// the case for GraphQL and streaming search responses, to keep cassettes
+// readable. Other bodies are base64 encoded.
+type recordedBody []byte
+
+type binaryBody struct {
+	Base64 []byte `json:"base64"`
+}
+
+func (b recordedBody) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(binaryBody{Base64: b})
+}
+
+func (b *recordedBody) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = recordedBody(s)
+		return nil
+	}
+	var bin binaryBody
+	if err := json.Unmarshal(data, &bin); err != nil {
+		return err
+	}
+	*b = bin.Base64
+	return nil
+}
+
+// key returns the value that requests are matched on during replay.
+func (r *recordedRequest) key() string {
+	if r.Query != "" {
+		op := r.Operation
+		if op == "" {
+			// Anonymous operations can only be told apart by their text.
+			op = strings.Join(strings.Fields(r.Query), " ")
+		}
+		return "graphql " + op + " " + string(r.Variables)
+	}
+	return r.Method + " " + r.Path
+}
+
+// newRecordedRequest captures req, decoding GraphQL requests and redacting
+// the headers that may carry credentials.
+func newRecordedRequest(req *http.Request, sensitiveHeaders map[string]struct{}) (*recordedRequest, error) {
+	rec := &recordedRequest{
+		Method: req.Method,
+		Path:   strings.TrimPrefix(req.URL.RequestURI(), "/"),
+		Header: req.Header.Clone(),
+	}
+	for name := range rec.Header {
+		if _, ok := sensitiveHeaders[strings.ToLower(name)]; ok {
+			rec.Header.Set(name, redacted)
+		}
+	}
+
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(req.URL.Path, "/.api/graphql") {
+		rec.Body = body
+		return rec, nil
+	}
+
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, errors.Wrap(err, "decompressing GraphQL request")
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return nil, errors.Wrap(err, "decompressing GraphQL request")
+		}
+	}
+	var gql struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	if err := json.Unmarshal(body, &gql); err != nil {
+		return nil, errors.Wrap(err, "decoding GraphQL request")
+	}
+
+	rec.Query = gql.Query
+	rec.Operation = parseOperation(gql.Query).Name
+	if len(gql.Variables) > 0 {
+		// Marshalling a map sorts its keys, which gives us a stable key.
+		if rec.Variables, err = json.Marshal(gql.Variables); err != nil {
+			return nil, err
+		}
+	}
+	return rec, nil
+}
+
+// readRequestBody returns a copy of the body of req without consuming it.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		// Read the body and put it back for the next transport.
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		return body, nil
+	}
+
+	rc, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// newCassetteTransport wraps base to record requests to the cassette at
+// record, or replaces it to replay requests from the cassette at replay.
+func newCassetteTransport(base http.RoundTripper, record, replay string, additionalHeaders map[string]string) (http.RoundTripper, error) {
+	switch {
+	case record != "" && replay != "":
+		return base, errors.New("requests cannot be recorded and replayed at the same time")
+	case replay != "":
+		rt, err := newReplayTransport(replay)
+		if err != nil {
+			return base, errors.Wrap(err, "loading requests to replay")
+		}
+		return rt, nil
+	default:
+		return &recordingTransport{
+			base:             base,
+			path:             record,
+			sensitiveHeaders: sensitiveHeaders(additionalHeaders),
+		}, nil
+	}
+}
+
+// sensitiveHeaders returns the lower cased names of the headers that are
+// redacted in cassettes: the access token, and any additional headers, since
+// those are typically used to authenticate with a proxy in front of
+// Sourcegraph.
+func sensitiveHeaders(additionalHeaders map[string]string) map[string]struct{} {
+	headers := map[string]struct{}{"authorization": {}}
+	for name := range additionalHeaders {
+		headers[strings.ToLower(name)] = struct{}{}
+	}
+	return headers
+}
+
+// recordingTransport appends every request and the response to it to a
+// cassette.
+type recordingTransport struct {
+	base             http.RoundTripper
+	path             string
+	sensitiveHeaders map[string]struct{}
+
+	mu sync.Mutex
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec, err := newRecordedRequest(req, t.sensitiveHeaders)
+	if err != nil {
+		return nil, errors.Wrap(err, "recording request")
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Responses, and streaming search responses in particular, are recorded
+	// once they have been fully read, so that the caller still sees them
+	// as they arrive.
+	resp.Body = &recordingBody{
+		ReadCloser: resp.Body,
+		done: func(body []byte) error {
+			return t.append(interaction{
+				Request: *rec,
+				Response: recordedResponse{
+					StatusCode: resp.StatusCode,
+					Header:     resp.Header.Clone(),
+					Body:       body,
+				},
+			})
+		},
+	}
+	return resp, nil
+}
+
+func (t *recordingTransport) append(i interaction) error {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "opening cassette")
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return errors.Wrap(err, "writing cassette")
+	}
+	return f.Close()
+}
+
+// recordingBody buffers a response body as it is read, and hands it to done
+// when the body is closed.
+type recordingBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	done func([]byte) error
+	once sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	// Callers that stop reading early still get the rest of the response
+	// recorded, so that replaying it behaves the same.
+	_, _ = io.Copy(&b.buf, b.ReadCloser)
+	err := b.ReadCloser.Close()
+
+	var doneErr error
+	b.once.Do(func() { doneErr = b.done(b.buf.Bytes()) })
+	if err != nil {
+		return err
+	}
+	return doneErr
+}
+
+// replayTransport answers requests from a cassette without touching the
+// network. Requests with the same key are answered in the order they were
+// recorded, and the last matching response is repeated once they run out,
+// which keeps polling loops working.
+type replayTransport struct {
+	mu           sync.Mutex
+	interactions map[string][]*interaction
+}
+
+func newReplayTransport(path string) (*replayTransport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening cassette")
+	}
+	defer f.Close()
+
+	t := &replayTransport{interactions: map[string][]*interaction{}}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 256*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var i interaction
+		if err := json.Unmarshal(scanner.Bytes(), &i); err != nil {
+			return nil, errors.Wrapf(err, "parsing cassette %s, line %d", path, line)
+		}
+		key := i.Request.key()
+		t.interactions[key] = append(t.interactions[key], &i)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading cassette")
+	}
+	return t, nil
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Headers aren't matched on, so there's nothing to redact.
+	rec, err := newRecordedRequest(req, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "replaying request")
+	}
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	key := rec.key()
+
+	t.mu.Lock()
+	candidates := t.interactions[key]
+	if len(candidates) > 1 {
+		t.interactions[key] = candidates[1:]
+	}
+	t.mu.Unlock()
+
+	if len(candidates) == 0 {
+		return nil, errors.Newf("no recorded response for %s", key)
+	}
+	recorded := candidates[0].Response
+
+	return &http.Response{
+		Status:        http.StatusText(recorded.StatusCode),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassette(t *testing.T) {
+	const (
+		token       = "s3cr3t-t0k3n"
+		proxySecret = "pr0xy-s3cr3t"
+		query       = `query Repo($name: String!) { repository(name: $name) { id } }`
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.api/graphql" {
+			fmt.Fprintf(w, "raw %s", r.URL.RequestURI())
+			return
+		}
+
+		var body struct {
+			Variables map[string]string `json:"variables"`
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("decompressing request: %s", err)
+			return
+		}
+		if err := json.NewDecoder(zr).Decode(&body); err != nil {
+			t.Errorf("decoding request: %s", err)
+		}
+		fmt.Fprintf(w, `{"data": {"repository": {"id": "id-%s"}}}`, body.Variables["name"])
+	}))
+
+	cassette := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	newClient := func(t *testing.T, args ...string) Client {
+		t.Helper()
+
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags := NewFlags(flagSet)
+		if err := flagSet.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return NewClient(ClientOpts{
+			Endpoint:          ts.URL,
+			AccessToken:       token,
+			AdditionalHeaders: map[string]string{"X-Proxy-Auth": proxySecret},
+			Flags:             flags,
+			Out:               io.Discard,
+		})
+	}
+
+	repoID := func(t *testing.T, client Client, name string) (string, error) {
+		t.Helper()
+
+		var result struct {
+			Repository struct{ ID string }
+		}
+		_, err := client.NewRequest(query, map[string]interface{}{"name": name}).Do(context.Background(), &result)
+		return result.Repository.ID, err
+	}
+
+	raw := func(t *testing.T, client Client, path string) (string, error) {
+		t.Helper()
+
+		req, err := client.NewHTTPRequest(context.Background(), "GET", path, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	t.Run("record", func(t *testing.T) {
+		client := newClient(t, "-record", cassette)
+		for _, name := range []string{"foo", "bar"} {
+			if _, err := repoID(t, client, name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := raw(t, client, ".api/src-cli/version"); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(cassette)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := strings.Count(string(data), "\n"); have != 3 {
+			t.Errorf("unexpected number of recorded interactions: have %d, want 3", have)
+		}
+		for _, secret := range []string{token, proxySecret} {
+			if strings.Contains(string(data), secret) {
+				t.Errorf("cassette contains unredacted secret %q", secret)
+			}
+		}
+	})
+
+	// Everything after this point must be answered from the cassette.
+	ts.Close()
+
+	t.Run("replay", func(t *testing.T) {
+		client := newClient(t, "-replay", cassette)
+		for _, name := range []string{"bar", "foo", "bar"} {
+			id, err := repoID(t, client, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "id-" + name; id != want {
+				t.Errorf("unexpected repository ID: have %q, want %q", id, want)
+			}
+		}
+
+		body, err := raw(t, client, ".api/src-cli/version")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "raw /.api/src-cli/version"; body != want {
+			t.Errorf("unexpected body: have %q, want %q", body, want)
+		}
+	})
+
+	t.Run("replay without a recording", func(t *testing.T) {
+		client := newClient(t, "-replay", cassette)
+		_, err := repoID(t, client, "baz")
+		if err == nil || !strings.Contains(err.Error(), `no recorded response for graphql Repo {"name":"baz"}`) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("record and replay", func(t *testing.T) {
+		client := newClient(t, "-record", cassette, "-replay", cassette)
+		_, err := repoID(t, client, "foo")
+		if err == nil || !strings.Contains(err.Error(), "cannot be recorded and replayed at the same time") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
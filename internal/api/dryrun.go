@@ -0,0 +1,232 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/jsonx"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+// DryRun collects the requests that weren't sent because the client is in
+// dry-run mode. In dry-run mode, GraphQL queries are sent as usual, but
+// mutations and other requests that could change data on the Sourcegraph
+// instance are not.
+type DryRun struct {
+	mu         sync.Mutex
+	operations []DryRunOperation
+}
+
+// DryRunOperation is a request that wasn't sent in dry-run mode.
+type DryRunOperation struct {
+	// Name is the name of the GraphQL mutation, or the method and path of
+	// other HTTP requests.
+	Name string
+	// Variables are the variables of the mutation, with secrets redacted.
+	Variables map[string]interface{}
+}
+
+func (op DryRunOperation) String() string {
+	if op.Variables == nil {
+		return op.Name
+	}
+	data, err := json.Marshal(op.Variables)
+	if err != nil {
+		return op.Name
+	}
+	return op.Name + " " + string(data)
+}
+
+// ErrDryRun is returned for HTTP requests that weren't sent in dry-run mode.
+var ErrDryRun = errors.New("request was not sent in dry-run mode")
+
+// NewDryRun returns a DryRun to pass to NewClient in ClientOpts.
+func NewDryRun() *DryRun {
+	return &DryRun{}
+}
+
+// Operations returns the requests that weren't sent, in order.
+func (d *DryRun) Operations() []DryRunOperation {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]DryRunOperation(nil), d.operations...)
+}
+
+// WriteSummary writes the list of requests that weren't sent.
+func (d *DryRun) WriteSummary(w io.Writer) error {
+	ops := d.Operations()
+	if len(ops) == 0 {
+		_, err := fmt.Fprintln(w, "Dry run: no changes would have been made.")
+		return err
+	}
+
+	noun := "changes"
+	if len(ops) == 1 {
+		noun = "change"
+	}
+	if _, err := fmt.Fprintf(w, "Dry run: %d %s would have been made:\n\n", len(ops), noun); err != nil {
+		return err
+	}
+	for i, op := range ops {
+		if _, err := fmt.Fprintf(w, "  %d. %s\n", i+1, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DryRun) record(op DryRunOperation) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.operations = append(d.operations, op)
+}
+
+// interceptMutation records the mutation and prints it to out, instead of
+// sending it.
+func (d *DryRun) interceptMutation(out io.Writer, query string, vars map[string]interface{}) error {
+	name := parseOperation(query).Name
+	if name == "" {
+		name = "anonymous mutation"
+	}
+	op := DryRunOperation{Name: name, Variables: redactVariables(vars)}
+	d.record(op)
+	_, err := fmt.Fprintf(out, "dry run: not executing %s\n", op)
+	return err
+}
+
+// interceptHTTPRequest records requests that could change data, and prints
+// them to out instead of sending them. It returns false for requests that can
+// be sent. GraphQL requests that are sent with Do, rather than with a Request,
+// are only intercepted if they're mutations.
+func (d *DryRun) interceptHTTPRequest(out io.Writer, req *http.Request) (bool, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false, nil
+	}
+	if strings.HasSuffix(req.URL.Path, signInPath) {
+		// Signing in doesn't change data, and the requests made on behalf of
+		// the signed in user can't be previewed without it.
+		return false, nil
+	}
+	if strings.HasSuffix(req.URL.Path, "/.api/graphql") {
+		query, vars, ok, err := readGraphQLRequest(req)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			if !isMutation(query) {
+				return false, nil
+			}
+			return true, d.interceptMutation(out, query, vars)
+		}
+	}
+
+	op := DryRunOperation{Name: req.Method + " " + req.URL.Path}
+	d.record(op)
+	_, err := fmt.Fprintf(out, "dry run: not sending %s\n", op)
+	return true, err
+}
+
+// signInPath is the path of the endpoint that signs in with a password, below
+// the path of the instance, if any.
+const signInPath = "/-/sign-in"
+
+// readGraphQLRequest returns the query and variables of the GraphQL request
+// req, and leaves its body to be read again. ok is false if the body isn't a
+// GraphQL request.
+func readGraphQLRequest(req *http.Request) (query string, vars map[string]interface{}, ok bool, err error) {
+	if req.Body == nil {
+		return "", nil, false, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return "", nil, false, errors.Wrap(err, "reading request body")
+	}
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	var body struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	if err := json.Unmarshal(data, &body); err != nil || body.Query == "" {
+		return "", nil, false, nil
+	}
+	return body.Query, body.Variables, true, nil
+}
+
+// secretName matches the names of variables and fields whose values are
+// redacted when mutations are printed in dry-run mode.
+var secretName = regexp.MustCompile(`(?i)token|password|passphrase|secret|credential|private.?key|api.?key|authorization`)
+
+// redactVariables returns a copy of the variables with the values of secrets
+// redacted. Strings that contain JSON objects, such as the configuration of
+// external services, are redacted too.
+func redactVariables(vars map[string]interface{}) map[string]interface{} {
+	if vars == nil {
+		return nil
+	}
+
+	// Round trip through JSON, so that variables of any type can be
+	// redacted, and the original variables aren't modified.
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return map[string]interface{}{"error": "variables could not be encoded: " + err.Error()}
+	}
+	var copied map[string]interface{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return map[string]interface{}{"error": "variables could not be decoded: " + err.Error()}
+	}
+	return redactValue(copied).(map[string]interface{})
+}
+
+func redactValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, field := range value {
+			if secretName.MatchString(k) && field != nil {
+				value[k] = redacted
+			} else {
+				value[k] = redactValue(field)
+			}
+		}
+		return value
+
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactValue(item)
+		}
+		return value
+
+	case string:
+		if !strings.HasPrefix(strings.TrimSpace(value), "{") {
+			return value
+		}
+		// Configuration is typically JSON with comments and trailing commas.
+		// Strings that look like objects, but can't be parsed, are redacted
+		// entirely, to be on the safe side.
+		data, errs := jsonx.Parse(value, jsonx.ParseOptions{Comments: true, TrailingCommas: true})
+		if len(errs) > 0 {
+			return redacted
+		}
+		var obj map[string]interface{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return redacted
+		}
+		redactedData, err := json.Marshal(redactValue(obj))
+		if err != nil {
+			return redacted
+		}
+		return string(redactedData)
+	}
+	return v
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+func TestDryRun(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":{"currentUser":{"username":"alice"}}}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	var out bytes.Buffer
+	dryRun := NewDryRun()
+	client := NewClient(ClientOpts{Endpoint: ts.URL, Out: &out, DryRun: dryRun})
+	ctx := context.Background()
+
+	// Queries are sent.
+	var result struct {
+		CurrentUser struct{ Username string }
+	}
+	if ok, err := client.NewQuery(`query CurrentUser { currentUser { username } }`).Do(ctx, &result); err != nil || !ok {
+		t.Fatalf("query: ok=%v, err=%v", ok, err)
+	}
+	if result.CurrentUser.Username != "alice" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	// Mutations aren't.
+	for _, vars := range []map[string]interface{}{
+		{"user": "VXNlcjox"},
+		{"input": map[string]interface{}{
+			"id":     "RXh0ZXJuYWxTZXJ2aWNlOjE=",
+			"config": `{"url": "https://github.com", "token": "secret", /* comment */ "repos": ["a/b"],}`,
+		}},
+	} {
+		ok, err := client.NewRequest(`mutation DeleteUser($user: ID!) { deleteUser(user: $user) { alwaysNil } }`, vars).Do(ctx, &result)
+		if err != nil || ok {
+			t.Fatalf("mutation: ok=%v, err=%v", ok, err)
+		}
+	}
+	ok, err := client.NewRequest(`mutation { createAccessToken(password: "hunter2") { token } }`, map[string]interface{}{"password": "hunter2"}).Do(ctx, nil)
+	if err != nil || ok {
+		t.Fatalf("mutation: ok=%v, err=%v", ok, err)
+	}
+
+	// Neither are other requests that could change data.
+	req, err := client.NewHTTPRequest(ctx, "POST", ".api/lsif/upload", strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(req); !errors.Is(err, ErrDryRun) {
+		t.Errorf("expected ErrDryRun, got %v", err)
+	}
+
+	// GraphQL requests that are sent with Do, and signing in, are only
+	// intercepted if they're mutations.
+	for _, tc := range []struct {
+		path, body  string
+		intercepted bool
+	}{
+		{path: ".api/graphql?CurrentUser", body: `{"query":"query CurrentUser { currentUser { id } }"}`},
+		{path: ".api/graphql?createAccessToken", body: `{"query":"mutation createAccessToken($note: String!) { createAccessToken(note: $note) { token } }","variables":{"note":"src-cli"}}`, intercepted: true},
+		{path: "-/sign-in", body: `{"email":"alice","password":"hunter2"}`},
+		// Instances can be served below a path prefix.
+		{path: "sourcegraph/-/sign-in", body: `{"email":"alice","password":"hunter2"}`},
+	} {
+		req, err := http.NewRequestWithContext(ctx, "POST", ts.URL+"/"+tc.path, strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Do(req); tc.intercepted != errors.Is(err, ErrDryRun) {
+			t.Errorf("%s: unexpected error %v", tc.path, err)
+		}
+	}
+
+	if requests != 4 {
+		t.Errorf("expected only the queries and sign-ins to be sent, got %d requests", requests)
+	}
+
+	want := []DryRunOperation{
+		{Name: "DeleteUser", Variables: map[string]interface{}{"user": "VXNlcjox"}},
+		{Name: "DeleteUser", Variables: map[string]interface{}{"input": map[string]interface{}{
+			"id":     "RXh0ZXJuYWxTZXJ2aWNlOjE=",
+			"config": `{"repos":["a/b"],"token":"REDACTED","url":"https://github.com"}`,
+		}}},
+		{Name: "anonymous mutation", Variables: map[string]interface{}{"password": "REDACTED"}},
+		{Name: "POST /.api/lsif/upload"},
+		{Name: "createAccessToken", Variables: map[string]interface{}{"note": "src-cli"}},
+	}
+	if diff := cmp.Diff(want, dryRun.Operations()); diff != "" {
+		t.Errorf("unexpected operations (-want +have):\n%s", diff)
+	}
+	if strings.Contains(out.String(), "hunter2") || strings.Contains(out.String(), `"secret"`) {
+		t.Errorf("secrets were printed:\n%s", out.String())
+	}
+
+	var summary bytes.Buffer
+	if err := dryRun.WriteSummary(&summary); err != nil {
+		t.Fatal(err)
+	}
+	wantSummary := `Dry run: 5 changes would have been made:
+
+  1. DeleteUser {"user":"VXNlcjox"}
+  2. DeleteUser {"input":{"config":"{\"repos\":[\"a/b\"],\"token\":\"REDACTED\",\"url\":\"https://github.com\"}","id":"RXh0ZXJuYWxTZXJ2aWNlOjE="}}
+  3. anonymous mutation {"password":"REDACTED"}
+  4. POST /.api/lsif/upload
+  5. createAccessToken {"note":"src-cli"}
+`
+	if diff := cmp.Diff(wantSummary, summary.String()); diff != "" {
+		t.Errorf("unexpected summary (-want +have):\n%s", diff)
+	}
+}
+
+func TestRedactVariables(t *testing.T) {
+	vars := map[string]interface{}{
+		"accessToken": "abc",
+		"nested":      []interface{}{map[string]interface{}{"clientSecret": "def", "name": "x"}},
+		"config":      `{"token": "abc"`,
+		"missing":     nil,
+		"text":        "not { json",
+	}
+	want := map[string]interface{}{
+		"accessToken": "REDACTED",
+		"nested":      []interface{}{map[string]interface{}{"clientSecret": "REDACTED", "name": "x"}},
+		"config":      "REDACTED",
+		"missing":     nil,
+		"text":        "not { json",
+	}
+	if diff := cmp.Diff(want, redactVariables(vars)); diff != "" {
+		t.Errorf("unexpected variables (-want +have):\n%s", diff)
+	}
+	if vars["accessToken"] != "abc" {
+		t.Error("the original variables were modified")
+	}
+}
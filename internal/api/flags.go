@@ -3,6 +3,8 @@ package api
 import (
 	"flag"
 	"os"
+	"strconv"
+	"time"
 )
 
 // Flags encapsulates the standard flags that should be added to all commands
@@ -13,6 +15,27 @@ type Flags struct {
 	trace              *bool
 	insecureSkipVerify *bool
 	userAgentTelemetry *bool
+	maxRetries         *int
+	retryMaxDelay      *time.Duration
+	record             *string
+	replay             *string
+}
+
+// GetCurl returns true if requests should be printed as curl commands
+// instead of being sent.
+func (f *Flags) GetCurl() bool {
+	if f.getCurl == nil {
+		return false
+	}
+	return *(f.getCurl)
+}
+
+// DumpRequests returns true if requests and responses should be logged.
+func (f *Flags) DumpRequests() bool {
+	if f.dump == nil {
+		return false
+	}
+	return *(f.dump)
 }
 
 func (f *Flags) Trace() bool {
@@ -29,6 +52,38 @@ func (f *Flags) UserAgentTelemetry() bool {
 	return *(f.userAgentTelemetry)
 }
 
+// RetryPolicy returns the policy used to retry requests that failed with a
+// transient error.
+func (f *Flags) RetryPolicy() RetryPolicy {
+	policy := RetryPolicy{
+		MaxRetries: defaultMaxRetries(),
+		MinDelay:   defaultRetryMinDelay,
+		MaxDelay:   defaultRetryMaxDelay(),
+	}
+	if f.maxRetries != nil {
+		policy.MaxRetries = *f.maxRetries
+	}
+	if f.retryMaxDelay != nil {
+		policy.MaxDelay = *f.retryMaxDelay
+	}
+	if policy.MaxRetries < 0 {
+		policy.MaxRetries = 0
+	}
+	return policy
+}
+
+// Cassette returns the cassette that requests and responses should be
+// recorded to or replayed from, if any.
+func (f *Flags) Cassette() (record, replay string) {
+	if f.record != nil {
+		record = *f.record
+	}
+	if f.replay != nil {
+		replay = *f.replay
+	}
+	return record, replay
+}
+
 // NewFlags instantiates a new Flags structure and attaches flags to the given
 // flag set.
 func NewFlags(flagSet *flag.FlagSet) *Flags {
@@ -38,21 +93,49 @@ func NewFlags(flagSet *flag.FlagSet) *Flags {
 		trace:              flagSet.Bool("trace", false, "Log the trace ID for requests. See https://docs.sourcegraph.com/admin/observability/tracing"),
 		insecureSkipVerify: flagSet.Bool("insecure-skip-verify", false, "Skip validation of TLS certificates against trusted chains"),
 		userAgentTelemetry: flagSet.Bool("user-agent-telemetry", defaultUserAgentTelemetry(), "Include the operating system and architecture in the User-Agent sent with requests to Sourcegraph"),
+		maxRetries:         flagSet.Int("max-retries", defaultMaxRetries(), "Maximum number of times to retry requests that failed with a transient error (can also be set with SRC_MAX_RETRIES)"),
+		retryMaxDelay:      flagSet.Duration("retry-max-delay", defaultRetryMaxDelay(), "Maximum time to wait between retries, including waits requested by the server with Retry-After (can also be set with SRC_RETRY_MAX_DELAY)"),
+		record:             flagSet.String("record", os.Getenv("SRC_RECORD"), "Append all requests to Sourcegraph and their responses to the given cassette file, with credentials redacted (can also be set with SRC_RECORD)"),
+		replay:             flagSet.String("replay", os.Getenv("SRC_REPLAY"), "Answer requests from the given cassette file instead of contacting Sourcegraph (can also be set with SRC_REPLAY)"),
 	}
 }
 
 func defaultFlags() *Flags {
 	telemetry := defaultUserAgentTelemetry()
 	d := false
+	maxRetries := defaultMaxRetries()
+	retryMaxDelay := defaultRetryMaxDelay()
+	record := os.Getenv("SRC_RECORD")
+	replay := os.Getenv("SRC_REPLAY")
 	return &Flags{
 		dump:               &d,
 		getCurl:            &d,
 		trace:              &d,
 		insecureSkipVerify: &d,
 		userAgentTelemetry: &telemetry,
+		maxRetries:         &maxRetries,
+		retryMaxDelay:      &retryMaxDelay,
+		record:             &record,
+		replay:             &replay,
 	}
 }
 
 func defaultUserAgentTelemetry() bool {
 	return os.Getenv("SRC_DISABLE_USER_AGENT_TELEMETRY") == ""
 }
+
+const defaultRetryMinDelay = 500 * time.Millisecond
+
+func defaultMaxRetries() int {
+	if n, err := strconv.Atoi(os.Getenv("SRC_MAX_RETRIES")); err == nil {
+		return n
+	}
+	return 3
+}
+
+func defaultRetryMaxDelay() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SRC_RETRY_MAX_DELAY")); err == nil {
+		return d
+	}
+	return 30 * time.Second
+}
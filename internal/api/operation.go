@@ -0,0 +1,135 @@
+package api
+
+import "strings"
+
+// operation describes the first executable operation in a GraphQL document.
+type operation struct {
+	// Type is one of "query", "mutation" or "subscription".
+	Type string
+	// Name is the operation name, which is empty for anonymous operations.
+	Name string
+}
+
+// parseOperation does just enough lexing of a GraphQL document to find the
+// type and name of its first operation. Fragment definitions, comments and
+// string literals are skipped. Documents that can't be understood are
+// treated as anonymous queries.
+func parseOperation(document string) operation {
+	l := lexer{src: document}
+	for {
+		tok := l.next()
+		switch tok {
+		case "":
+			return operation{Type: "query"}
+		case "{":
+			// Shorthand query syntax.
+			return operation{Type: "query"}
+		case "fragment":
+			l.skipDefinition()
+		case "query", "mutation", "subscription":
+			op := operation{Type: tok}
+			if name := l.next(); isName(name) {
+				op.Name = name
+			}
+			return op
+		}
+	}
+}
+
+// isMutation returns true if the first operation in document is a mutation.
+func isMutation(document string) bool {
+	return parseOperation(document).Type == "mutation"
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+// next returns the next token, which is either a name or a single punctuator
+// character. An empty string is returned at the end of the input.
+func (l *lexer) next() string {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '#':
+			if i := strings.IndexByte(l.src[l.pos:], '\n'); i >= 0 {
+				l.pos += i + 1
+			} else {
+				l.pos = len(l.src)
+			}
+		case c == '"':
+			l.skipString()
+		case c == ',' || c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			// Commas are insignificant in GraphQL.
+			l.pos++
+		case c == '_' || isLetter(c):
+			start := l.pos
+			for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+				l.pos++
+			}
+			return l.src[start:l.pos]
+		default:
+			l.pos++
+			return string(c)
+		}
+	}
+	return ""
+}
+
+// skipString skips over a string or block string literal.
+func (l *lexer) skipString() {
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		l.pos += 3
+		for l.pos < len(l.src) {
+			if strings.HasPrefix(l.src[l.pos:], `\"""`) {
+				l.pos += 4
+			} else if strings.HasPrefix(l.src[l.pos:], `"""`) {
+				l.pos += 3
+				return
+			} else {
+				l.pos++
+			}
+		}
+		return
+	}
+
+	l.pos++
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '\\':
+			l.pos += 2
+		case '"', '\n':
+			l.pos++
+			return
+		default:
+			l.pos++
+		}
+	}
+}
+
+// skipDefinition skips to the end of the selection set of the current
+// definition.
+func (l *lexer) skipDefinition() {
+	depth := 0
+	for {
+		switch l.next() {
+		case "":
+			return
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth <= 0 {
+				return
+			}
+		}
+	}
+}
+
+func isName(tok string) bool {
+	return tok != "" && (tok[0] == '_' || isLetter(tok[0]))
+}
+
+func isLetter(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
@@ -0,0 +1,68 @@
+package api
+
+import "testing"
+
+func TestParseOperation(t *testing.T) {
+	for name, tc := range map[string]struct {
+		document string
+		want     operation
+	}{
+		"empty": {
+			document: "",
+			want:     operation{Type: "query"},
+		},
+		"shorthand query": {
+			document: `{ currentUser { username } }`,
+			want:     operation{Type: "query"},
+		},
+		"anonymous query": {
+			document: `query { currentUser { username } }`,
+			want:     operation{Type: "query"},
+		},
+		"named query": {
+			document: `query CurrentUser { currentUser { username } }`,
+			want:     operation{Type: "query", Name: "CurrentUser"},
+		},
+		"named query with variables": {
+			document: `query Repos($first: Int) { repositories(first: $first) { nodes { name } } }`,
+			want:     operation{Type: "query", Name: "Repos"},
+		},
+		"anonymous mutation with variables": {
+			document: `mutation($user: ID!) { deleteUser(user: $user) { alwaysNil } }`,
+			want:     operation{Type: "mutation"},
+		},
+		"mutation after fragment": {
+			document: `
+				fragment UserFields on User {
+					id
+					username
+					# mutation Fake { }
+					query
+				}
+
+				mutation CreateUser($username: String!) {
+					createUser(username: $username) { user { ...UserFields } }
+				}`,
+			want: operation{Type: "mutation", Name: "CreateUser"},
+		},
+		"comments and strings": {
+			document: `
+				# query Commented { }
+				"""
+				Block string with "quotes" and mutation keyword
+				"""
+				mutation Tagged { addTag(tag: "query {") { alwaysNil } }`,
+			want: operation{Type: "mutation", Name: "Tagged"},
+		},
+		"subscription": {
+			document: `subscription OnEvent { event }`,
+			want:     operation{Type: "subscription", Name: "OnEvent"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if have := parseOperation(tc.document); have != tc.want {
+				t.Errorf("unexpected operation: have %+v, want %+v", have, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+// DefaultPageSize is the number of nodes requested per page when paginating
+// through a connection.
+const DefaultPageSize = 500
+
+// PageInfo is the pageInfo of a GraphQL connection.
+type PageInfo struct {
+	EndCursor   *string `json:"endCursor"`
+	HasNextPage bool    `json:"hasNextPage"`
+}
+
+// Connection is a single page of a GraphQL connection. TotalCount is only
+// set if the query selects it.
+type Connection[T any] struct {
+	Nodes      []T      `json:"nodes"`
+	TotalCount int      `json:"totalCount"`
+	PageInfo   PageInfo `json:"pageInfo"`
+}
+
+// PaginatedQuery describes a query over a GraphQL connection.
+//
+// The query must declare `$first: Int` and `$after: String` variables, pass
+// them to the connection, and select `pageInfo { endCursor hasNextPage }` on
+// it. The first and after variables are managed by the paginator.
+type PaginatedQuery struct {
+	Query string
+	Vars  map[string]interface{}
+
+	// Path is the path to the connection in the result, such as
+	// []string{"team", "members"}. A null anywhere along the path is treated
+	// as an empty connection.
+	Path []string
+
+	// Limit is the maximum number of nodes to return. -1 returns every node.
+	Limit int
+
+	// PageSize is the number of nodes requested at a time. It defaults to
+	// DefaultPageSize.
+	PageSize int
+}
+
+// Paginate calls fn for every node of the connection described by q, fetching
+// one page at a time.
+func Paginate[T any](ctx context.Context, client Client, q PaginatedQuery, fn func(T) error) error {
+	return PaginatePages(ctx, client, q, func(page *Connection[T]) error {
+		for _, node := range page.Nodes {
+			if err := fn(node); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// PaginatePages calls fn for every page of the connection described by q.
+// The nodes of the last page are truncated to q.Limit.
+func PaginatePages[T any](ctx context.Context, client Client, q PaginatedQuery, fn func(*Connection[T]) error) error {
+	pageSize := q.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	vars := make(map[string]interface{}, len(q.Vars)+2)
+	for k, v := range q.Vars {
+		vars[k] = v
+	}
+
+	remaining := q.Limit
+	var after *string
+	for remaining != 0 {
+		first := pageSize
+		if remaining > 0 && remaining < first {
+			first = remaining
+		}
+		vars["first"] = first
+		vars["after"] = after
+
+		var result json.RawMessage
+		if ok, err := client.NewRequest(q.Query, vars).Do(ctx, &result); err != nil || !ok {
+			// Not ok means that no request was sent, for example because
+			// -get-curl is set, so there are no further pages either.
+			return err
+		}
+
+		page, err := decodeConnection[T](result, q.Path)
+		if err != nil {
+			return err
+		}
+		if remaining > 0 {
+			if len(page.Nodes) > remaining {
+				page.Nodes = page.Nodes[:remaining]
+			}
+			remaining -= len(page.Nodes)
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+
+		if !page.PageInfo.HasNextPage || len(page.Nodes) == 0 {
+			return nil
+		}
+		if page.PageInfo.EndCursor == nil {
+			return errors.Newf("connection %q has a next page, but no end cursor", q.Path)
+		}
+		after = page.PageInfo.EndCursor
+	}
+	return nil
+}
+
+func decodeConnection[T any](result json.RawMessage, path []string) (*Connection[T], error) {
+	var page Connection[T]
+	for _, key := range path {
+		if isNull(result) {
+			return &page, nil
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(result, &fields); err != nil {
+			return nil, errors.Wrapf(err, "decoding %q", key)
+		}
+		result = fields[key]
+	}
+	if isNull(result) {
+		return &page, nil
+	}
+
+	if err := json.Unmarshal(result, &page); err != nil {
+		return nil, errors.Wrapf(err, "decoding connection %q", path)
+	}
+	return &page, nil
+}
+
+func isNull(data json.RawMessage) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) == 0 || bytes.Equal(data, []byte("null"))
+}
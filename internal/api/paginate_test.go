@@ -0,0 +1,165 @@
+package api
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPaginate(t *testing.T) {
+	const query = `query TeamMembers($first: Int, $after: String) {
+	team(name: "owners") { members(first: $first, after: $after) { nodes { id } pageInfo { endCursor hasNextPage } } }
+}`
+
+	// The server pages through members 0 to 9, using the index of the next
+	// member as the cursor.
+	const numMembers = 10
+
+	newServer := func(t *testing.T, requests *[]map[string]interface{}) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Errorf("decompressing request: %s", err)
+				return
+			}
+			var body struct {
+				Variables map[string]interface{} `json:"variables"`
+			}
+			if err := json.NewDecoder(zr).Decode(&body); err != nil {
+				t.Errorf("decoding request: %s", err)
+				return
+			}
+			*requests = append(*requests, body.Variables)
+
+			start := 0
+			if after, ok := body.Variables["after"].(string); ok {
+				start, _ = strconv.Atoi(after)
+			}
+			end := start + int(body.Variables["first"].(float64))
+			if end > numMembers {
+				end = numMembers
+			}
+
+			var conn Connection[map[string]string]
+			for i := start; i < end; i++ {
+				conn.Nodes = append(conn.Nodes, map[string]string{"id": strconv.Itoa(i)})
+			}
+			cursor := strconv.Itoa(end)
+			conn.PageInfo = PageInfo{EndCursor: &cursor, HasNextPage: end < numMembers}
+
+			data, _ := json.Marshal(conn)
+			fmt.Fprintf(w, `{"data": {"team": {"members": %s}}}`, data)
+		}))
+	}
+
+	for name, tc := range map[string]struct {
+		limit       int
+		wantIDs     []string
+		wantAfters  []interface{}
+		wantFirsts  []interface{}
+		wantPageLen []int
+	}{
+		"all": {
+			limit:       -1,
+			wantIDs:     []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"},
+			wantAfters:  []interface{}{nil, "4", "8"},
+			wantFirsts:  []interface{}{4.0, 4.0, 4.0},
+			wantPageLen: []int{4, 4, 2},
+		},
+		"limit on page boundary": {
+			limit:       8,
+			wantIDs:     []string{"0", "1", "2", "3", "4", "5", "6", "7"},
+			wantAfters:  []interface{}{nil, "4"},
+			wantFirsts:  []interface{}{4.0, 4.0},
+			wantPageLen: []int{4, 4},
+		},
+		"limit within page": {
+			limit:       5,
+			wantIDs:     []string{"0", "1", "2", "3", "4"},
+			wantAfters:  []interface{}{nil, "4"},
+			wantFirsts:  []interface{}{4.0, 1.0},
+			wantPageLen: []int{4, 1},
+		},
+		"limit larger than connection": {
+			limit:       100,
+			wantIDs:     []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"},
+			wantAfters:  []interface{}{nil, "4", "8"},
+			wantFirsts:  []interface{}{4.0, 4.0, 4.0},
+			wantPageLen: []int{4, 4, 2},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var requests []map[string]interface{}
+			ts := newServer(t, &requests)
+			defer ts.Close()
+
+			client := NewClient(ClientOpts{Endpoint: ts.URL, Out: io.Discard})
+
+			var ids []string
+			var pageLens []int
+			err := PaginatePages(context.Background(), client, PaginatedQuery{
+				Query:    query,
+				Path:     []string{"team", "members"},
+				Limit:    tc.limit,
+				PageSize: 4,
+			}, func(page *Connection[struct{ ID string }]) error {
+				pageLens = append(pageLens, len(page.Nodes))
+				for _, node := range page.Nodes {
+					ids = append(ids, node.ID)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if diff := cmp.Diff(tc.wantIDs, ids); diff != "" {
+				t.Errorf("unexpected nodes (-want +have):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantPageLen, pageLens); diff != "" {
+				t.Errorf("unexpected page lengths (-want +have):\n%s", diff)
+			}
+
+			var afters, firsts []interface{}
+			for _, vars := range requests {
+				afters = append(afters, vars["after"])
+				firsts = append(firsts, vars["first"])
+			}
+			if diff := cmp.Diff(tc.wantAfters, afters); diff != "" {
+				t.Errorf("unexpected after variables (-want +have):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantFirsts, firsts); diff != "" {
+				t.Errorf("unexpected first variables (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPaginate_NullPath(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": {"team": null}}`))
+	}))
+	defer ts.Close()
+
+	client := NewClient(ClientOpts{Endpoint: ts.URL, Out: io.Discard})
+
+	err := Paginate(context.Background(), client, PaginatedQuery{
+		Query: `query { team(name: "missing") { members { nodes { id } } } }`,
+		Path:  []string{"team", "members"},
+		Limit: -1,
+	}, func(node struct{ ID string }) error {
+		t.Errorf("unexpected node: %+v", node)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+// RetryPolicy configures how transient failures are retried by the transport
+// shared by all API requests.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries after the initial attempt.
+	// Zero disables retries.
+	MaxRetries int
+
+	// MinDelay is the base delay used for the first retry. Subsequent retries
+	// double the delay, up to MaxDelay.
+	MinDelay time.Duration
+
+	// MaxDelay caps the backoff between attempts. Retry-After values larger
+	// than MaxDelay are not honoured, and the response is returned as-is.
+	MaxDelay time.Duration
+}
+
+// retrySafety describes whether a request can be repeated without risking
+// duplicate side effects on the server.
+type retrySafety int
+
+const (
+	// retryUnsafe requests may have side effects, so they are only retried
+	// when the server is known not to have processed them.
+	retryUnsafe retrySafety = iota
+	// retrySafe requests are idempotent and can be retried on any transient
+	// failure.
+	retrySafe
+)
+
+type retrySafetyKey struct{}
+
+// withRetrySafety annotates ctx so that requests created with it are retried
+// according to safety, regardless of their HTTP method.
+func withRetrySafety(ctx context.Context, safety retrySafety) context.Context {
+	return context.WithValue(ctx, retrySafetyKey{}, safety)
+}
+
+// requestRetrySafety returns the retry safety of req. GraphQL requests are
+// annotated explicitly; anything else is considered safe only if its method is
+// idempotent.
+func requestRetrySafety(req *http.Request) retrySafety {
+	if safety, ok := req.Context().Value(retrySafetyKey{}).(retrySafety); ok {
+		return safety
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return retrySafe
+	}
+	return retryUnsafe
+}
+
+// retryTransport is an http.RoundTripper that retries transient failures
+// with jittered exponential backoff.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+
+	// sleep waits for d or until ctx is done. It is overridden in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newRetryTransport(base http.RoundTripper, policy RetryPolicy) *retryTransport {
+	return &retryTransport{
+		base:   base,
+		policy: policy,
+		sleep:  sleepContext,
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	safety := requestRetrySafety(req)
+
+	// A request body can only be sent again if we know how to recreate it.
+	canReplay := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, errors.Wrap(err, "recreating request body for retry")
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if !canReplay || attempt >= t.policy.MaxRetries {
+			return resp, err
+		}
+
+		delay, retry := t.shouldRetry(safety, attempt, resp, err)
+		if !retry {
+			return resp, err
+		}
+		if resp != nil {
+			// Drain the body so the connection can be reused.
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if err := t.sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// shouldRetry determines whether the outcome of an attempt warrants another
+// one, and if so how long to wait first.
+func (t *retryTransport) shouldRetry(safety retrySafety, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if err != nil {
+		if isConnectError(err) || (safety == retrySafe && isTransientNetworkError(err)) {
+			return t.backoff(attempt), true
+		}
+		return 0, false
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		// The server rejected the request outright, so even mutations are
+		// safe to repeat, but only if we were told when to do so.
+		retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok || retryAfter > t.policy.MaxDelay {
+			return 0, false
+		}
+		return retryAfter, true
+
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if safety != retrySafe {
+			return 0, false
+		}
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok && retryAfter <= t.policy.MaxDelay {
+			return retryAfter, true
+		}
+		return t.backoff(attempt), true
+	}
+
+	return 0, false
+}
+
+// backoff returns the jittered delay to wait before the retry following the
+// given attempt.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.policy.MinDelay
+	for i := 0; i < attempt && d < t.policy.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > t.policy.MaxDelay {
+		d = t.policy.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	// Equal jitter: wait at least half of the delay, and a random amount of
+	// the other half, so that concurrent clients don't retry in lockstep.
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// isConnectError returns true if err occurred while establishing a
+// connection, which means that the request never reached the server.
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// isTransientNetworkError returns true if err is a network failure that may
+// have happened after the request was sent, such as a connection reset by a
+// load balancer that is being rolled.
+func isTransientNetworkError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
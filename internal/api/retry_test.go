@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	const (
+		query    = `query CurrentUser { currentUser { username } }`
+		mutation = `mutation DeleteUser($user: ID!) { deleteUser(user: $user) { alwaysNil } }`
+	)
+
+	reset := func(w http.ResponseWriter) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			panic(err)
+		}
+		conn.Close()
+	}
+
+	for name, tc := range map[string]struct {
+		query        string
+		failures     func(w http.ResponseWriter)
+		numFailures  int
+		wantAttempts int
+		wantErr      bool
+	}{
+		"query retried on 503": {
+			query:        query,
+			failures:     func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+			numFailures:  2,
+			wantAttempts: 3,
+		},
+		"query retried on connection reset": {
+			query:        query,
+			failures:     reset,
+			numFailures:  1,
+			wantAttempts: 2,
+		},
+		"query gives up after max retries": {
+			query:        query,
+			failures:     func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+			numFailures:  10,
+			wantAttempts: 4,
+			wantErr:      true,
+		},
+		"mutation not retried on 503": {
+			query:        mutation,
+			failures:     func(w http.ResponseWriter) { w.WriteHeader(http.StatusServiceUnavailable) },
+			numFailures:  1,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		"mutation not retried on connection reset": {
+			query:        mutation,
+			failures:     reset,
+			numFailures:  1,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		"mutation retried on 429 with Retry-After": {
+			query: mutation,
+			failures: func(w http.ResponseWriter) {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			numFailures:  1,
+			wantAttempts: 2,
+		},
+		"429 without Retry-After not retried": {
+			query:        query,
+			failures:     func(w http.ResponseWriter) { w.WriteHeader(http.StatusTooManyRequests) },
+			numFailures:  1,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+		"429 with Retry-After beyond max delay not retried": {
+			query: query,
+			failures: func(w http.ResponseWriter) {
+				w.Header().Set("Retry-After", "3600")
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+			numFailures:  1,
+			wantAttempts: 1,
+			wantErr:      true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var attempts int32
+			var firstBody []byte
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Errorf("reading request body: %s", err)
+				}
+				if n == 1 {
+					firstBody = body
+				} else if !bytes.Equal(body, firstBody) {
+					t.Errorf("retried request has a different body")
+				}
+
+				if int(n) <= tc.numFailures {
+					tc.failures(w)
+					return
+				}
+				_, _ = w.Write([]byte(`{"data": {}}`))
+			}))
+			defer ts.Close()
+
+			flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags := NewFlags(flagSet)
+			if err := flagSet.Parse([]string{"-max-retries=3", "-retry-max-delay=10ms"}); err != nil {
+				t.Fatal(err)
+			}
+
+			client := NewClient(ClientOpts{
+				Endpoint: ts.URL,
+				Flags:    flags,
+				Out:      io.Discard,
+			})
+
+			var result struct{}
+			_, err := client.NewRequest(tc.query, map[string]interface{}{"user": "VXNlcjox"}).Do(context.Background(), &result)
+			if tc.wantErr && err == nil {
+				t.Error("unexpected nil error")
+			} else if !tc.wantErr && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			if have := int(atomic.LoadInt32(&attempts)); have != tc.wantAttempts {
+				t.Errorf("unexpected number of attempts: have %d, want %d", have, tc.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryTransport_Backoff(t *testing.T) {
+	transport := newRetryTransport(http.DefaultTransport, RetryPolicy{
+		MaxRetries: 10,
+		MinDelay:   100 * time.Millisecond,
+		MaxDelay:   time.Second,
+	})
+
+	for attempt, want := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		for i := 0; i < 20; i++ {
+			if have := transport.backoff(attempt); have < want/2 || have > want {
+				t.Errorf("attempt %d: backoff %s not in range [%s, %s]", attempt, have, want/2, want)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for value, tc := range map[string]struct {
+		want   time.Duration
+		wantOK bool
+	}{
+		"":                              {wantOK: false},
+		"soon":                          {wantOK: false},
+		"-1":                            {wantOK: false},
+		"0":                             {want: 0, wantOK: true},
+		"120":                           {want: 2 * time.Minute, wantOK: true},
+		"Wed, 01 May 2024 12:00:30 GMT": {want: 30 * time.Second, wantOK: true},
+		"Wed, 01 May 2024 11:00:00 GMT": {want: 0, wantOK: true},
+	} {
+		have, ok := parseRetryAfter(value, now)
+		if ok != tc.wantOK || have != tc.want {
+			t.Errorf("parseRetryAfter(%q): have (%s, %t), want (%s, %t)", value, have, ok, tc.want, tc.wantOK)
+		}
+	}
+}
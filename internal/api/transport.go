@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+// TransportOpts configures how the API client connects to Sourcegraph. The
+// zero value uses the system certificate pool and the proxy settings from the
+// standard HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+type TransportOpts struct {
+	// CABundlePath is the path to a PEM file of additional certificate
+	// authorities to trust, on top of the system pool.
+	CABundlePath string
+
+	// ClientCertPath and ClientKeyPath are paths to a PEM encoded certificate
+	// and private key to present to the server or gateway for mutual TLS.
+	ClientCertPath string
+	ClientKeyPath  string
+
+	// Proxy is the URL of the proxy to route all requests through. The
+	// http, https, socks5 and socks5h schemes are supported, as well as
+	// unix:///path/to/socket for an HTTP proxy listening on a unix socket.
+	Proxy string
+}
+
+// Validate checks that the options are consistent, without loading any
+// files.
+func (o TransportOpts) Validate() error {
+	if (o.ClientCertPath == "") != (o.ClientKeyPath == "") {
+		return errors.New("a client certificate and a client key must be configured together")
+	}
+	if o.Proxy != "" {
+		if _, err := parseProxyURL(o.Proxy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewHTTPClient returns a plain HTTP client with the TLS and proxy settings of
+// opts, for requests to services other than Sourcegraph, such as webhooks.
+// Unlike the API client, it doesn't retry requests, record or replay them, or
+// hold them back with -dry-run.
+func NewHTTPClient(opts TransportOpts) (*http.Client, error) {
+	transport, err := newTransport(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// newTransport builds the base transport used by the API client.
+func newTransport(opts TransportOpts, insecureSkipVerify bool) (*http.Transport, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.InsecureSkipVerify = insecureSkipVerify
+
+	if opts.CABundlePath != "" {
+		pool, err := loadCABundle(opts.CABundlePath)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	if opts.ClientCertPath != "" {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCertPath, opts.ClientKeyPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading client certificate")
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if opts.Proxy != "" {
+		proxyURL, err := parseProxyURL(opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+
+		if proxyURL.Scheme == "unix" {
+			// The transport only dials the proxy once one is configured, so
+			// every connection can go to the socket. The proxy URL itself is
+			// only used to decide how to talk to the proxy.
+			socket := proxyURL.Path
+			dialer := &net.Dialer{}
+			transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			}
+			proxyURL = &url.URL{Scheme: "http", Host: "unix-socket-proxy"}
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return transport, nil
+}
+
+// loadCABundle returns the system certificate pool extended with the
+// certificates in the PEM file at path.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading CA bundle")
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.Newf("no PEM encoded certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
+
+func parseProxyURL(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing proxy URL")
+	}
+
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		if u.Host == "" {
+			return nil, errors.Newf("proxy URL %q has no host", proxy)
+		}
+	case "unix":
+		// Accept both unix:///path and unix:/path.
+		if u.Path == "" {
+			return nil, errors.Newf("proxy URL %q has no socket path", proxy)
+		}
+	default:
+		return nil, errors.Newf("unsupported proxy scheme %q: expected http, https, socks5, socks5h or unix", u.Scheme)
+	}
+	return u, nil
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransportOpts_Validate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts    TransportOpts
+		wantErr string
+	}{
+		"zero value": {},
+		"client certificate and key": {
+			opts: TransportOpts{ClientCertPath: "cert.pem", ClientKeyPath: "key.pem"},
+		},
+		"client certificate without key": {
+			opts:    TransportOpts{ClientCertPath: "cert.pem"},
+			wantErr: "a client certificate and a client key must be configured together",
+		},
+		"client key without certificate": {
+			opts:    TransportOpts{ClientKeyPath: "key.pem"},
+			wantErr: "a client certificate and a client key must be configured together",
+		},
+		"socks5 proxy": {
+			opts: TransportOpts{Proxy: "socks5://localhost:1080"},
+		},
+		"unix socket proxy": {
+			opts: TransportOpts{Proxy: "unix:///var/run/proxy.sock"},
+		},
+		"unix socket proxy without path": {
+			opts:    TransportOpts{Proxy: "unix://"},
+			wantErr: `proxy URL "unix://" has no socket path`,
+		},
+		"http proxy without host": {
+			opts:    TransportOpts{Proxy: "http://"},
+			wantErr: `proxy URL "http://" has no host`,
+		},
+		"unsupported proxy scheme": {
+			opts:    TransportOpts{Proxy: "ftp://proxy"},
+			wantErr: `unsupported proxy scheme "ftp": expected http, https, socks5, socks5h or unix`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tc.opts.Validate()
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr) {
+				t.Errorf("unexpected error: have %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewClient_CABundle(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": {}}`))
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	caPath := writePEM(t, dir, "ca.pem", "CERTIFICATE", ts.Certificate().Raw)
+
+	t.Run("untrusted", func(t *testing.T) {
+		client := NewClient(ClientOpts{Endpoint: ts.URL, Out: io.Discard})
+		if _, err := client.NewQuery("query { currentUser { username } }").Do(context.Background(), &struct{}{}); err == nil {
+			t.Fatal("unexpected nil error")
+		}
+	})
+
+	t.Run("trusted", func(t *testing.T) {
+		client := NewClient(ClientOpts{
+			Endpoint:  ts.URL,
+			Transport: TransportOpts{CABundlePath: caPath},
+			Out:       io.Discard,
+		})
+		if _, err := client.NewQuery("query { currentUser { username } }").Do(context.Background(), &struct{}{}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("missing bundle", func(t *testing.T) {
+		client := NewClient(ClientOpts{
+			Endpoint:  ts.URL,
+			Transport: TransportOpts{CABundlePath: filepath.Join(dir, "missing.pem")},
+			Out:       io.Discard,
+		})
+		_, err := client.NewQuery("query { currentUser { username } }").Do(context.Background(), &struct{}{})
+		if err == nil || !strings.Contains(err.Error(), "reading CA bundle") {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	client, err := NewHTTPClient(TransportOpts{CABundlePath: writePEM(t, dir, "ca.pem", "CERTIFICATE", ts.Certificate().Raw)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Post(ts.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+
+	if _, err := NewHTTPClient(TransportOpts{CABundlePath: filepath.Join(dir, "missing.pem")}); err == nil || !strings.Contains(err.Error(), "reading CA bundle") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNewClient_ClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath, clientCert := generateClientCertificate(t, dir)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(clientCert)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": {}}`))
+	}))
+	ts.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	caPath := writePEM(t, dir, "ca.pem", "CERTIFICATE", ts.Certificate().Raw)
+
+	client := NewClient(ClientOpts{
+		Endpoint: ts.URL,
+		Transport: TransportOpts{
+			CABundlePath:   caPath,
+			ClientCertPath: certPath,
+			ClientKeyPath:  keyPath,
+		},
+		Out: io.Discard,
+	})
+	if _, err := client.NewQuery("query { currentUser { username } }").Do(context.Background(), &struct{}{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestNewClient_UnixSocketProxy(t *testing.T) {
+	// Unix socket paths are limited in length, so avoid the potentially long
+	// t.TempDir().
+	dir, err := os.MkdirTemp("", "src-proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "proxy.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A plain HTTP proxy receives requests with absolute URLs.
+	var proxiedURL string
+	proxy := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+		_, _ = w.Write([]byte(`{"data": {}}`))
+	})}
+	go func() { _ = proxy.Serve(listener) }()
+	defer proxy.Close()
+
+	client := NewClient(ClientOpts{
+		Endpoint:  "http://sourcegraph.test",
+		Transport: TransportOpts{Proxy: "unix://" + socket},
+		Out:       io.Discard,
+	})
+	if _, err := client.NewQuery("query { currentUser { username } }").Do(context.Background(), &struct{}{}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := "http://sourcegraph.test/.api/graphql"; proxiedURL != want {
+		t.Errorf("unexpected proxied URL: have %q, want %q", proxiedURL, want)
+	}
+}
+
+func generateClientCertificate(t *testing.T, dir string) (certPath, keyPath string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "src-cli test client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath = writePEM(t, dir, "client.pem", "CERTIFICATE", der)
+	keyPath = writePEM(t, dir, "client.key", "EC PRIVATE KEY", keyDER)
+	return certPath, keyPath, cert
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -12,16 +12,19 @@ type ImageCache interface {
 	Ensure(ctx context.Context, name string) (Image, error)
 }
 
-// imageCache is a cache of metadata about Docker images, indexed by name.
+// imageCache is a cache of metadata about the images of a container runtime,
+// indexed by name.
 type imageCache struct {
+	runtime  Runtime
 	images   map[string]Image
 	imagesMu sync.Mutex
 }
 
-// NewImageCache creates a new image cache.
-func NewImageCache() ImageCache {
+// NewImageCache creates a new image cache for the images of the given runtime.
+func NewImageCache(runtime Runtime) ImageCache {
 	return &imageCache{
-		images: make(map[string]Image),
+		runtime: runtime,
+		images:  make(map[string]Image),
 	}
 }
 
@@ -36,7 +39,7 @@ func (ic *imageCache) Get(name string) Image {
 		return image
 	}
 
-	image := &image{name: name}
+	image := &image{name: name, runtime: ic.runtime}
 	ic.images[name] = image
 	return image
 }
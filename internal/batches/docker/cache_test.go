@@ -3,7 +3,7 @@ package docker
 import "testing"
 
 func TestImageCache(t *testing.T) {
-	cache := NewImageCache()
+	cache := NewImageCache(RuntimeDocker)
 	if cache == nil {
 		t.Error("unexpected nil cache")
 	}
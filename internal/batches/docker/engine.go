@@ -0,0 +1,630 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+// engineAPIVersion is the version of the Docker Engine API that Engine uses.
+// It's supported by Docker 20.10 and later.
+const engineAPIVersion = "v1.41"
+
+// defaultEngineHost is where the Docker daemon listens if neither DOCKER_HOST
+// nor the current Docker context say otherwise.
+const defaultEngineHost = "unix:///var/run/docker.sock"
+
+// Engine is a client for the Docker Engine API, which it talks to over the
+// unix socket of the Docker daemon. Running containers and inspecting images
+// with it is a lot cheaper than spawning a docker CLI process for each of
+// them, so it's used instead of the CLI if the socket is available.
+type Engine struct {
+	client *http.Client
+}
+
+// NewEngine returns a client for the Docker daemon at host, which must be a
+// unix:// URL.
+func NewEngine(host string) (*Engine, error) {
+	socket, ok := strings.CutPrefix(host, "unix://")
+	if !ok {
+		return nil, errors.Newf("unsupported Docker host %q: only unix sockets are supported", host)
+	}
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &Engine{client: &http.Client{Transport: transport}}, nil
+}
+
+// engineError is an error response of the Engine API.
+type engineError struct {
+	status  int
+	message string
+}
+
+func (e *engineError) Error() string {
+	return fmt.Sprintf("Docker Engine API returned HTTP %d: %s", e.status, e.message)
+}
+
+func (e *Engine) newRequest(ctx context.Context, method, path string, query url.Values, body any) (*http.Request, error) {
+	var r io.Reader
+	contentType := ""
+	switch body := body.(type) {
+	case nil:
+	case io.Reader:
+		r = body
+		contentType = "application/x-tar"
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	u := "http://docker/" + engineAPIVersion + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
+
+// do sends a request to the Engine API. A body that is an io.Reader is sent as
+// a tar archive, and any other body as JSON. Error responses are returned as
+// an *engineError.
+func (e *Engine) do(req *http.Request) (*http.Response, error) {
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		var body struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if err := json.Unmarshal(data, &body); err != nil || body.Message == "" {
+			body.Message = strings.TrimSpace(string(data))
+		}
+		return nil, &engineError{status: resp.StatusCode, message: body.Message}
+	}
+	return resp, nil
+}
+
+// call sends a request to the Engine API, and decodes the JSON response into
+// out, unless out is nil.
+func (e *Engine) call(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	req, err := e.newRequest(ctx, method, path, query, in)
+	if err != nil {
+		return err
+	}
+	resp, err := e.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// callFast is call with the timeout of commands that are expected to be fast,
+// so that an unresponsive daemon is reported like an unresponsive CLI. args
+// describe the call in the error.
+func (e *Engine) callFast(ctx context.Context, args []string, method, path string, out any) error {
+	dctx, cancel, err := withFastCommandContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	err = e.call(dctx, method, path, nil, nil, out)
+	if errors.IsDeadlineExceeded(err) || errors.IsDeadlineExceeded(dctx.Err()) {
+		return newFastCommandTimeoutError(dctx, "docker", args...)
+	}
+	return err
+}
+
+// Ping returns an error if the Docker daemon doesn't respond.
+func (e *Engine) Ping(ctx context.Context) error {
+	return e.callFast(ctx, []string{"version"}, "GET", "/_ping", nil)
+}
+
+// NCPU returns the number of CPU cores available to Docker.
+func (e *Engine) NCPU(ctx context.Context) (int, error) {
+	var info Info
+	if err := e.callFast(ctx, []string{"info"}, "GET", "/info", &info); err != nil {
+		return 0, err
+	}
+	return info.NCPU, nil
+}
+
+// ImageID returns the content digest of the image with the given name. It
+// returns an error if the image hasn't been pulled.
+func (e *Engine) ImageID(ctx context.Context, name string) (string, error) {
+	var image struct {
+		ID string `json:"Id"`
+	}
+	args := []string{"image", "inspect", name}
+	if err := e.callFast(ctx, args, "GET", "/images/"+name+"/json", &image); err != nil {
+		return "", err
+	}
+	return image.ID, nil
+}
+
+// CreateVolume creates a new, empty volume, and returns its name.
+func (e *Engine) CreateVolume(ctx context.Context) (string, error) {
+	var volume struct {
+		Name string
+	}
+	if err := e.call(ctx, "POST", "/volumes/create", nil, struct{}{}, &volume); err != nil {
+		return "", err
+	}
+	return volume.Name, nil
+}
+
+// RemoveVolume removes the volume with the given name.
+func (e *Engine) RemoveVolume(ctx context.Context, name string) error {
+	return e.call(ctx, "DELETE", "/volumes/"+url.PathEscape(name), nil, nil, nil)
+}
+
+// ExitError is returned by Engine.Run if the container exits with a non-zero
+// status.
+type ExitError struct {
+	Code int
+	// OOMKilled is true if the container ran out of memory.
+	OOMKilled bool
+}
+
+func (e *ExitError) Error() string {
+	if e.OOMKilled {
+		return fmt.Sprintf("exit status %d: the container ran out of memory", e.Code)
+	}
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode returns the exit status of the container, like
+// os/exec.ExitError.ExitCode.
+func (e *ExitError) ExitCode() int { return e.Code }
+
+// Run runs a container like `docker run` with the given arguments would, and
+// copies its output to stdout and stderr, which may be nil. The container is
+// removed when Run returns, and ctx being done stops it.
+//
+// args are the arguments of the docker CLI, starting with "run". Only the
+// options that src uses are supported; see parseRunArgs.
+func (e *Engine) Run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	config, err := parseRunArgs(args)
+	if err != nil {
+		return err
+	}
+	config.AttachStdout, config.AttachStderr = true, true
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+
+	id, err := e.createContainer(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer e.removeContainer(ctx, id)
+
+	// Events are watched from before the container starts, so that the
+	// events of a container that exits right away aren't missed.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := e.containerEvents(ctx, id, "oom", "die")
+	if err != nil {
+		return errors.Wrap(err, "watching container events")
+	}
+
+	stream, err := e.attach(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "attaching to container")
+	}
+	defer stream.Close()
+	go func() {
+		// Unblock reading the output if ctx is done while the container
+		// is still running.
+		<-ctx.Done()
+		stream.Close()
+	}()
+
+	if err := e.call(ctx, "POST", "/containers/"+id+"/start", nil, nil, nil); err != nil {
+		return errors.Wrap(err, "starting container")
+	}
+
+	if err := demuxStream(stream, stdout, stderr); err != nil && ctx.Err() == nil {
+		return errors.Wrap(err, "reading container output")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// The kernel reports running out of memory before the container dies.
+	oomKilled := false
+	for event := range events {
+		switch event.Action {
+		case "oom":
+			oomKilled = true
+		case "die":
+			code, err := strconv.Atoi(event.Actor.Attributes["exitCode"])
+			if err != nil {
+				return errors.Wrapf(err, "parsing exit code of container")
+			}
+			if code != 0 {
+				return &ExitError{Code: code, OOMKilled: oomKilled}
+			}
+			return nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// The event stream ended before the container died, so we have to ask.
+	var status struct {
+		StatusCode int
+	}
+	if err := e.call(ctx, "POST", "/containers/"+id+"/wait", nil, nil, &status); err != nil {
+		return errors.Wrap(err, "waiting for container")
+	}
+	if status.StatusCode != 0 {
+		return &ExitError{Code: status.StatusCode, OOMKilled: oomKilled}
+	}
+	return nil
+}
+
+// CopyToVolume extracts the tar archive into the volume at target, without
+// running a container. image is the image of the container that the volume is
+// mounted in to do so, which has to have been pulled.
+func (e *Engine) CopyToVolume(ctx context.Context, image, volume, target string, archive io.Reader) error {
+	id, err := e.createContainer(ctx, &containerConfig{
+		Image: image,
+		HostConfig: hostConfig{
+			Mounts: []engineMount{{Type: "volume", Source: volume, Target: target}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer e.removeContainer(ctx, id)
+
+	req, err := e.newRequest(ctx, "PUT", "/containers/"+id+"/archive", url.Values{"path": {target}}, archive)
+	if err != nil {
+		return err
+	}
+	resp, err := e.do(req)
+	if err != nil {
+		return errors.Wrap(err, "copying archive into volume")
+	}
+	return resp.Body.Close()
+}
+
+func (e *Engine) createContainer(ctx context.Context, config *containerConfig) (string, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := e.call(ctx, "POST", "/containers/create", nil, config, &created); err != nil {
+		return "", errors.Wrap(err, "creating container")
+	}
+	return created.ID, nil
+}
+
+// removeContainer removes the container, even if ctx is done.
+func (e *Engine) removeContainer(ctx context.Context, id string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	_ = e.call(ctx, "DELETE", "/containers/"+id, url.Values{"force": {"1"}}, nil, nil)
+}
+
+// attach returns the multiplexed stdout and stderr stream of the container.
+func (e *Engine) attach(ctx context.Context, id string) (io.ReadCloser, error) {
+	query := url.Values{"stream": {"1"}, "stdout": {"1"}, "stderr": {"1"}}
+	req, err := e.newRequest(ctx, "POST", "/containers/"+id+"/attach", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Docker hijacks the connection for the stream, which net/http supports
+	// as a protocol upgrade.
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	resp, err := e.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body.Close()
+		return nil, errors.Newf("unexpected HTTP status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// demuxStream copies the stdout and stderr frames of a multiplexed container
+// output stream to stdout and stderr until the stream ends.
+func demuxStream(r io.Reader, stdout, stderr io.Writer) error {
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		var w io.Writer
+		switch header[0] {
+		case 0, 1:
+			w = stdout
+		case 2:
+			w = stderr
+		default:
+			return errors.Newf("invalid stream type %d", header[0])
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, r, size); err != nil {
+			return err
+		}
+	}
+}
+
+// containerEvent is an event of the Engine API.
+type containerEvent struct {
+	Action string
+	Actor  struct {
+		ID         string
+		Attributes map[string]string
+	}
+}
+
+// containerEvents returns a channel of the events of the container with the
+// given actions. It's closed when the event stream ends, or ctx is done.
+func (e *Engine) containerEvents(ctx context.Context, id string, actions ...string) (<-chan containerEvent, error) {
+	filters, err := json.Marshal(map[string][]string{
+		"type":      {"container"},
+		"container": {id},
+		"event":     actions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := e.newRequest(ctx, "GET", "/events", url.Values{"filters": {string(filters)}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan containerEvent)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var event containerEvent
+			if err := dec.Decode(&event); err != nil {
+				return
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// containerConfig is the body of a request to create a container.
+type containerConfig struct {
+	Image        string
+	Entrypoint   []string `json:",omitempty"`
+	Cmd          []string `json:",omitempty"`
+	Env          []string `json:",omitempty"`
+	WorkingDir   string   `json:",omitempty"`
+	User         string   `json:",omitempty"`
+	AttachStdout bool     `json:",omitempty"`
+	AttachStderr bool     `json:",omitempty"`
+	HostConfig   hostConfig
+}
+
+type hostConfig struct {
+	Init       bool          `json:",omitempty"`
+	UsernsMode string        `json:",omitempty"`
+	Mounts     []engineMount `json:",omitempty"`
+}
+
+type engineMount struct {
+	Type     string
+	Source   string
+	Target   string
+	ReadOnly bool `json:",omitempty"`
+}
+
+// parseRunArgs parses the arguments of `docker run` into the configuration of
+// a container. It supports the options that src runs containers with, so that
+// callers can build the same arguments for the CLI and the Engine API.
+//
+// The container is always removed after it's run, so --rm is implied. The
+// container ID isn't written to a file, so --cidfile is ignored.
+func parseRunArgs(args []string) (*containerConfig, error) {
+	if len(args) == 0 || args[0] != "run" {
+		return nil, errors.Newf("expected arguments of docker run, got %q", args)
+	}
+	config := &containerConfig{}
+
+	rest := args[1:]
+	for len(rest) > 0 {
+		arg := rest[0]
+		rest = rest[1:]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			if arg == "--" {
+				if len(rest) == 0 {
+					return nil, errors.New("no image given after --")
+				}
+				arg, rest = rest[0], rest[1:]
+			}
+			config.Image = arg
+			if len(rest) > 0 {
+				config.Cmd = rest
+			}
+			return config, nil
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--rm":
+			continue
+		case "--init":
+			config.HostConfig.Init = true
+			continue
+		}
+
+		if !hasValue {
+			if len(rest) == 0 {
+				return nil, errors.Newf("no value given for %s", name)
+			}
+			value, rest = rest[0], rest[1:]
+		}
+		switch name {
+		case "--cidfile":
+		case "--workdir", "-w":
+			config.WorkingDir = value
+		case "--user", "-u":
+			config.User = value
+		case "--userns":
+			config.HostConfig.UsernsMode = value
+		case "--env", "-e":
+			config.Env = append(config.Env, value)
+		case "--entrypoint":
+			config.Entrypoint = []string{value}
+		case "--mount":
+			mount, err := parseMount(value)
+			if err != nil {
+				return nil, err
+			}
+			config.HostConfig.Mounts = append(config.HostConfig.Mounts, mount)
+		default:
+			return nil, errors.Newf("unsupported docker run option %s", name)
+		}
+	}
+	return nil, errors.New("no image given")
+}
+
+// parseMount parses the value of a --mount option, such as
+// type=bind,source=/a,target=/b,ro.
+func parseMount(value string) (engineMount, error) {
+	mount := engineMount{Type: "volume"}
+	for _, field := range strings.Split(value, ",") {
+		k, v, _ := strings.Cut(field, "=")
+		switch k {
+		case "type":
+			mount.Type = v
+		case "source", "src":
+			mount.Source = v
+		case "target", "destination", "dst":
+			mount.Target = v
+		case "ro", "readonly":
+			mount.ReadOnly = v == "" || v == "true" || v == "1"
+		default:
+			return engineMount{}, errors.Newf("unsupported mount option %q", field)
+		}
+	}
+	if mount.Target == "" {
+		return engineMount{}, errors.Newf("mount %q has no target", value)
+	}
+	return mount, nil
+}
+
+// engineHost returns the address of the Docker daemon that the docker CLI
+// talks to.
+func engineHost(ctx context.Context) string {
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		return host
+	}
+	out, err := executeFastCommand(ctx, "docker", "context", "inspect", "--format", "{{ .Endpoints.docker.Host }}")
+	if host := strings.TrimSpace(string(out)); err == nil && host != "" {
+		return host
+	}
+	return defaultEngineHost
+}
+
+// engineRuntime is Docker, using the Engine API instead of the CLI where it
+// can.
+type engineRuntime struct {
+	*dockerRuntime
+	engine *Engine
+}
+
+// ConnectEngine returns a runtime that uses the Engine API of the Docker
+// daemon, if rt is Docker and the daemon listens on a unix socket. Otherwise,
+// or if the daemon doesn't respond, rt is returned and the CLI is used.
+func ConnectEngine(ctx context.Context, rt Runtime) Runtime {
+	d, ok := rt.(*dockerRuntime)
+	if !ok {
+		return rt
+	}
+	engine, err := NewEngine(engineHost(ctx))
+	if err != nil {
+		return rt
+	}
+	if err := engine.Ping(ctx); err != nil {
+		return rt
+	}
+	return &engineRuntime{dockerRuntime: d, engine: engine}
+}
+
+func (rt *engineRuntime) Engine() *Engine { return rt.engine }
+
+func (rt *engineRuntime) CheckVersion(ctx context.Context) error {
+	if err := rt.engine.Ping(ctx); err != nil {
+		return errors.Newf("failed to reach the Docker daemon:\n\t%s\n\n'src batch' requires Docker to be available.", err)
+	}
+	return nil
+}
+
+func (rt *engineRuntime) NCPU(ctx context.Context) (int, error) {
+	return rt.engine.NCPU(ctx)
+}
+
+func (rt *engineRuntime) CreateVolume(ctx context.Context) (string, error) {
+	return rt.engine.CreateVolume(ctx)
+}
+
+func (rt *engineRuntime) RemoveVolume(ctx context.Context, name string) error {
+	return rt.engine.RemoveVolume(ctx, name)
+}
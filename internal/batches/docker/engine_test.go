@@ -0,0 +1,544 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRunArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		args    []string
+		want    *containerConfig
+		wantErr string
+	}{
+		{
+			name: "step",
+			args: []string{
+				"run", "--rm", "--init", "--cidfile", "/tmp/cid",
+				"--workdir", "/work/sub",
+				"--mount", "type=bind,source=/tmp/script,target=/tmp/run.sh",
+				"--user", "1000:1000",
+				"--mount", "type=volume,source=vol,target=/work",
+				"--mount", "type=bind,source=/data/,target=/data,ro",
+				"-e", "FOO=bar=baz",
+				"--entrypoint", "/bin/sh",
+				"--", "sha256:abc", "/tmp/run.sh",
+			},
+			want: &containerConfig{
+				Image:      "sha256:abc",
+				Entrypoint: []string{"/bin/sh"},
+				Cmd:        []string{"/tmp/run.sh"},
+				Env:        []string{"FOO=bar=baz"},
+				WorkingDir: "/work/sub",
+				User:       "1000:1000",
+				HostConfig: hostConfig{
+					Init: true,
+					Mounts: []engineMount{
+						{Type: "bind", Source: "/tmp/script", Target: "/tmp/run.sh"},
+						{Type: "volume", Source: "vol", Target: "/work"},
+						{Type: "bind", Source: "/data/", Target: "/data", ReadOnly: true},
+					},
+				},
+			},
+		},
+		{
+			name: "image without separator",
+			args: []string{"run", "--rm", "--entrypoint", "/bin/sh", "alpine", "-c", "id -u; id -g"},
+			want: &containerConfig{
+				Image:      "alpine",
+				Entrypoint: []string{"/bin/sh"},
+				Cmd:        []string{"-c", "id -u; id -g"},
+			},
+		},
+		{
+			name: "values after equals signs",
+			args: []string{"run", "--user=0:0", "--userns=host", "alpine"},
+			want: &containerConfig{
+				Image:      "alpine",
+				User:       "0:0",
+				HostConfig: hostConfig{UsernsMode: "host"},
+			},
+		},
+		{
+			name:    "not run",
+			args:    []string{"image", "inspect", "alpine"},
+			wantErr: "expected arguments of docker run",
+		},
+		{
+			name:    "unsupported option",
+			args:    []string{"run", "--privileged", "alpine"},
+			wantErr: "unsupported docker run option --privileged",
+		},
+		{
+			name:    "missing value",
+			args:    []string{"run", "--workdir"},
+			wantErr: "no value given for --workdir",
+		},
+		{
+			name:    "missing image",
+			args:    []string{"run", "--rm"},
+			wantErr: "no image given",
+		},
+		{
+			name:    "mount without target",
+			args:    []string{"run", "--mount", "type=bind,source=/a", "alpine"},
+			wantErr: "has no target",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			have, err := parseRunArgs(tc.args)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("unexpected config (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEngine_Run(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name       string
+		result     fakeRunResult
+		wantStdout string
+		wantStderr string
+		wantErr    error
+	}{
+		{
+			name:       "success",
+			result:     fakeRunResult{stdout: []string{"hello ", "world\n"}, stderr: []string{"warning\n"}},
+			wantStdout: "hello world\n",
+			wantStderr: "warning\n",
+		},
+		{
+			name:       "failure",
+			result:     fakeRunResult{stderr: []string{"oops\n"}, exitCode: 3},
+			wantStderr: "oops\n",
+			wantErr:    &ExitError{Code: 3},
+		},
+		{
+			name:    "out of memory",
+			result:  fakeRunResult{exitCode: 137, oom: true},
+			wantErr: &ExitError{Code: 137, OOMKilled: true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newFakeEngine(t)
+			fake.run = func(*containerConfig) fakeRunResult { return tc.result }
+
+			var stdout, stderr bytes.Buffer
+			args := []string{
+				"run", "--rm", "--init",
+				"--mount", "type=volume,source=vol,target=/work",
+				"-e", "FOO=bar",
+				"--entrypoint", "/bin/sh",
+				"--", "alpine", "/tmp/run.sh",
+			}
+			err := fake.engine.Run(ctx, args, &stdout, &stderr)
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantStdout, stdout.String())
+			assert.Equal(t, tc.wantStderr, stderr.String())
+
+			require.Len(t, fake.containers, 1)
+			c := fake.containers["c1"]
+			assert.True(t, c.removed, "container not removed")
+			assert.Equal(t, &containerConfig{
+				Image:        "alpine",
+				Entrypoint:   []string{"/bin/sh"},
+				Cmd:          []string{"/tmp/run.sh"},
+				Env:          []string{"FOO=bar"},
+				AttachStdout: true,
+				AttachStderr: true,
+				HostConfig: hostConfig{
+					Init:   true,
+					Mounts: []engineMount{{Type: "volume", Source: "vol", Target: "/work"}},
+				},
+			}, c.config)
+		})
+	}
+
+	t.Run("container create fails", func(t *testing.T) {
+		fake := newFakeEngine(t)
+
+		err := fake.engine.Run(ctx, []string{"run", "missing"}, nil, nil)
+		assert.ErrorContains(t, err, "No such image: missing")
+		assert.Empty(t, fake.containers)
+	})
+}
+
+func TestEngine_CopyToVolume(t *testing.T) {
+	fake := newFakeEngine(t)
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "README.md", Mode: 0644, Size: 2, Uid: 1000}))
+	_, err := tw.Write([]byte("hi"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	want := archive.Bytes()
+
+	err = fake.engine.CopyToVolume(context.Background(), "alpine", "vol", "/work", bytes.NewReader(want))
+	require.NoError(t, err)
+
+	require.Len(t, fake.containers, 1)
+	c := fake.containers["c1"]
+	assert.Equal(t, []engineMount{{Type: "volume", Source: "vol", Target: "/work"}}, c.config.HostConfig.Mounts)
+	assert.False(t, c.started, "container started")
+	assert.True(t, c.removed, "container not removed")
+	assert.Equal(t, map[string][]byte{"/work": want}, c.archives)
+}
+
+func TestEngine(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeEngine(t)
+
+	assert.NoError(t, fake.engine.Ping(ctx))
+
+	ncpu, err := fake.engine.NCPU(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, ncpu)
+
+	id, err := fake.engine.ImageID(ctx, "sourcegraph/alpine:3")
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256:a1", id)
+
+	_, err = fake.engine.ImageID(ctx, "missing")
+	var engineErr *engineError
+	if assert.ErrorAs(t, err, &engineErr) {
+		assert.Equal(t, http.StatusNotFound, engineErr.status)
+	}
+
+	volume, err := fake.engine.CreateVolume(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", volume)
+	assert.NoError(t, fake.engine.RemoveVolume(ctx, volume))
+	assert.Empty(t, fake.volumes)
+	assert.Error(t, fake.engine.RemoveVolume(ctx, volume))
+}
+
+func TestConnectEngine(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("docker with socket", func(t *testing.T) {
+		fake := newFakeEngine(t)
+		t.Setenv("DOCKER_HOST", "unix://"+fake.socket)
+
+		rt := ConnectEngine(ctx, RuntimeDocker)
+		assert.NotNil(t, rt.Engine())
+		assert.Equal(t, "docker", rt.Name())
+		assert.NoError(t, rt.CheckVersion(ctx))
+
+		ncpu, err := rt.NCPU(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, ncpu)
+	})
+
+	t.Run("socket not listening", func(t *testing.T) {
+		t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "docker.sock"))
+
+		assert.Equal(t, RuntimeDocker, ConnectEngine(ctx, RuntimeDocker))
+	})
+
+	t.Run("tcp host", func(t *testing.T) {
+		t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:2375")
+
+		assert.Equal(t, RuntimeDocker, ConnectEngine(ctx, RuntimeDocker))
+	})
+
+	t.Run("other runtime", func(t *testing.T) {
+		fake := newFakeEngine(t)
+		t.Setenv("DOCKER_HOST", "unix://"+fake.socket)
+
+		rt := ConnectEngine(ctx, RuntimePodman)
+		assert.Equal(t, RuntimePodman, rt)
+		assert.Nil(t, rt.Engine())
+	})
+}
+
+// fakeEngine is a Docker daemon that serves the parts of the Engine API that
+// Engine uses on a unix socket.
+type fakeEngine struct {
+	socket string
+	engine *Engine
+
+	// run returns the result of running a container.
+	run func(config *containerConfig) fakeRunResult
+
+	mu         sync.Mutex
+	volumes    map[string]bool
+	containers map[string]*fakeContainer
+}
+
+type fakeRunResult struct {
+	stdout, stderr []string
+	exitCode       int
+	oom            bool
+}
+
+type fakeContainer struct {
+	config   *containerConfig
+	archives map[string][]byte
+	started  bool
+	removed  bool
+
+	// start is closed when the container is started, and exited when its
+	// output has been written.
+	start  chan struct{}
+	exited chan struct{}
+	result fakeRunResult
+}
+
+var fakeImages = map[string]string{
+	"alpine":               "sha256:a0",
+	"sourcegraph/alpine:3": "sha256:a1",
+}
+
+func newFakeEngine(t *testing.T) *fakeEngine {
+	t.Helper()
+
+	// Socket paths are limited to around 100 characters, which the
+	// directories of nested tests can exceed.
+	dir, err := os.MkdirTemp("", "engine")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	fake := &fakeEngine{
+		socket:     filepath.Join(dir, "docker.sock"),
+		run:        func(*containerConfig) fakeRunResult { return fakeRunResult{} },
+		volumes:    map[string]bool{},
+		containers: map[string]*fakeContainer{},
+	}
+
+	l, err := net.Listen("unix", fake.socket)
+	require.NoError(t, err)
+	srv := &http.Server{Handler: fake.handler()}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	fake.engine, err = NewEngine("unix://" + fake.socket)
+	require.NoError(t, err)
+	return fake
+}
+
+func (f *fakeEngine) container(w http.ResponseWriter, r *http.Request) *fakeContainer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	c, ok := f.containers[r.PathValue("id")]
+	if !ok || c.removed {
+		writeEngineError(w, http.StatusNotFound, "No such container: "+r.PathValue("id"))
+		return nil
+	}
+	return c
+}
+
+func (f *fakeEngine) handler() http.Handler {
+	mux := http.NewServeMux()
+	v := "/" + engineAPIVersion
+
+	mux.HandleFunc("GET "+v+"/_ping", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "OK")
+	})
+
+	mux.HandleFunc("GET "+v+"/info", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"NCPU": 4})
+	})
+
+	mux.HandleFunc("GET "+v+"/images/", func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, v+"/images/"), "/json")
+		id, ok := fakeImages[name]
+		if !ok {
+			writeEngineError(w, http.StatusNotFound, "No such image: "+name)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"Id": id})
+	})
+
+	mux.HandleFunc("POST "+v+"/volumes/create", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		name := fmt.Sprintf("v%d", len(f.volumes)+1)
+		f.volumes[name] = true
+		json.NewEncoder(w).Encode(map[string]any{"Name": name})
+	})
+
+	mux.HandleFunc("DELETE "+v+"/volumes/{name}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if !f.volumes[r.PathValue("name")] {
+			writeEngineError(w, http.StatusNotFound, "no such volume")
+			return
+		}
+		delete(f.volumes, r.PathValue("name"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("POST "+v+"/containers/create", func(w http.ResponseWriter, r *http.Request) {
+		var config containerConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			writeEngineError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if _, ok := fakeImages[config.Image]; !ok {
+			writeEngineError(w, http.StatusNotFound, "No such image: "+config.Image)
+			return
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		id := fmt.Sprintf("c%d", len(f.containers)+1)
+		f.containers[id] = &fakeContainer{
+			config:   &config,
+			archives: map[string][]byte{},
+			start:    make(chan struct{}),
+			exited:   make(chan struct{}),
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{"Id": id})
+	})
+
+	mux.HandleFunc("POST "+v+"/containers/{id}/attach", func(w http.ResponseWriter, r *http.Request) {
+		c := f.container(w, r)
+		if c == nil {
+			return
+		}
+
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(buf, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		buf.Flush()
+
+		<-c.start
+		writeFrame := func(stream byte, data string) {
+			header := [8]byte{stream}
+			binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+			buf.Write(header[:])
+			buf.WriteString(data)
+		}
+		for _, s := range c.result.stdout {
+			writeFrame(1, s)
+		}
+		for _, s := range c.result.stderr {
+			writeFrame(2, s)
+		}
+		buf.Flush()
+		close(c.exited)
+	})
+
+	mux.HandleFunc("POST "+v+"/containers/{id}/start", func(w http.ResponseWriter, r *http.Request) {
+		c := f.container(w, r)
+		if c == nil {
+			return
+		}
+		f.mu.Lock()
+		c.started = true
+		c.result = f.run(c.config)
+		f.mu.Unlock()
+		close(c.start)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("POST "+v+"/containers/{id}/wait", func(w http.ResponseWriter, r *http.Request) {
+		c := f.container(w, r)
+		if c == nil {
+			return
+		}
+		<-c.exited
+		json.NewEncoder(w).Encode(map[string]any{"StatusCode": c.result.exitCode})
+	})
+
+	mux.HandleFunc("PUT "+v+"/containers/{id}/archive", func(w http.ResponseWriter, r *http.Request) {
+		c := f.container(w, r)
+		if c == nil {
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		c.archives[r.URL.Query().Get("path")] = data
+		f.mu.Unlock()
+	})
+
+	mux.HandleFunc("DELETE "+v+"/containers/{id}", func(w http.ResponseWriter, r *http.Request) {
+		c := f.container(w, r)
+		if c == nil {
+			return
+		}
+		f.mu.Lock()
+		c.removed = true
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	mux.HandleFunc("GET "+v+"/events", func(w http.ResponseWriter, r *http.Request) {
+		var filters map[string][]string
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters); err != nil || len(filters["container"]) != 1 {
+			writeEngineError(w, http.StatusBadRequest, "expected a container filter")
+			return
+		}
+		f.mu.Lock()
+		c := f.containers[filters["container"][0]]
+		f.mu.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-c.exited:
+		case <-r.Context().Done():
+			return
+		}
+		enc := json.NewEncoder(w)
+		event := func(action string, attributes map[string]string) {
+			enc.Encode(map[string]any{
+				"Type":   "container",
+				"Action": action,
+				"Actor":  map[string]any{"ID": filters["container"][0], "Attributes": attributes},
+			})
+		}
+		if c.result.oom {
+			event("oom", nil)
+		}
+		event("die", map[string]string{"exitCode": fmt.Sprint(c.result.exitCode)})
+		w.(http.Flusher).Flush()
+
+		// Like Docker, keep streaming until the client goes away.
+		<-r.Context().Done()
+	})
+
+	return mux
+}
+
+func writeEngineError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
@@ -9,8 +9,6 @@ import (
 	"sync"
 
 	"github.com/sourcegraph/sourcegraph/lib/errors"
-
-	"github.com/sourcegraph/src-cli/internal/exec"
 )
 
 // UIDGID represents a UID:GID pair.
@@ -34,7 +32,8 @@ type Image interface {
 }
 
 type image struct {
-	name string
+	name    string
+	runtime Runtime
 
 	// There are lots of once fields below: basically, we're going to try fairly
 	// hard to prevent performing the same operations on the same image over and
@@ -61,7 +60,7 @@ func (image *image) Digest(ctx context.Context) (string, error) {
 	return image.digest, ensureErr
 }
 
-// Ensure ensures that the image has been pulled by Docker. Note that it does
+// Ensure ensures that the image has been pulled by the runtime. Note that it does
 // not attempt to pull a newer version of the image if it exists locally.
 func (image *image) Ensure(ctx context.Context) error {
 	image.ensureOnce.Do(func() {
@@ -81,6 +80,10 @@ func (image *image) Ensure(ctx context.Context) error {
 				// Desktop VMs running out of memory, whereupon the Linux
 				// kernel's OOM killer sometimes chooses to kill components of
 				// Docker instead of processes within containers.
+				if engine := image.runtime.Engine(); engine != nil {
+					return engine.ImageID(ctx, image.name)
+				}
+
 				dctx, cancel, err := withFastCommandContext(ctx)
 				if err != nil {
 					return "", err
@@ -88,11 +91,11 @@ func (image *image) Ensure(ctx context.Context) error {
 				defer cancel()
 
 				args := []string{"image", "inspect", "--format", "{{ .Id }}", image.name}
-				out, err := exec.CommandContext(dctx, "docker", args...).Output()
-				id := string(bytes.TrimSpace(out))
+				out, err := image.runtime.Command(dctx, args...).Output()
+				id := image.runtime.parseImageID(out)
 
 				if errors.IsDeadlineExceeded(err) || errors.IsDeadlineExceeded(dctx.Err()) {
-					return "", newFastCommandTimeoutError(dctx, args...)
+					return "", newFastCommandTimeoutError(dctx, image.runtime.Name(), args...)
 				} else if err != nil {
 					return "", err
 				}
@@ -108,14 +111,16 @@ func (image *image) Ensure(ctx context.Context) error {
 				// trying to tell an unresponsive Docker to pull.
 				return err
 			} else if err != nil {
-				// Let's try pulling the image.
-				pullCmd := exec.CommandContext(ctx, "docker", "image", "pull", image.name)
+				// Let's try pulling the image. This always uses the command
+				// line, even with the Engine API, since the CLI knows the
+				// credentials for private registries.
+				pullCmd := image.runtime.Command(ctx, "image", "pull", image.name)
 				var stderr bytes.Buffer
 				pullCmd.Stderr = &stderr
 				if err := pullCmd.Run(); err != nil {
 					exitErr := &goexec.ExitError{}
 					if errors.As(err, &exitErr) {
-						return errors.Newf("failed to pull image: %s\ndocker pull exited with code %d", stderr.String(), exitErr.ExitCode())
+						return errors.Newf("failed to pull image: %s\n%s pull exited with code %d", stderr.String(), image.runtime.Name(), exitErr.ExitCode())
 					}
 					return errors.Wrap(err, "pulling image")
 				}
@@ -127,7 +132,7 @@ func (image *image) Ensure(ctx context.Context) error {
 			}
 
 			if digest == "" {
-				return errors.Errorf("unexpected empty docker image content ID for %q", image.name)
+				return errors.Errorf("unexpected empty %s image content ID for %q", image.runtime.Name(), image.name)
 			}
 
 			image.digest = digest
@@ -155,13 +160,17 @@ func (image *image) UIDGID(ctx context.Context) (UIDGID, error) {
 				"run",
 				"--rm",
 				"--entrypoint", "/bin/sh",
-				digest,
+				image.runtime.ImageRef(image.name, digest),
 				"-c", "id -u; id -g",
 			}
-			cmd := exec.CommandContext(ctx, "docker", args...)
-			cmd.Stdout = stdout
-
-			if err := cmd.Run(); err != nil {
+			if engine := image.runtime.Engine(); engine != nil {
+				err = engine.Run(ctx, args, stdout, nil)
+			} else {
+				cmd := image.runtime.Command(ctx, args...)
+				cmd.Stdout = stdout
+				err = cmd.Run()
+			}
+			if err != nil {
 				return UIDGID{}, errors.Wrap(err, "running id")
 			}
 
@@ -22,14 +22,14 @@ func TestImage_Digest(t *testing.T) {
 	}{
 		"success": {
 			expectations: []*expect.Expectation{inspectSuccess("foo", "digest")},
-			image:        &image{name: "foo"},
+			image:        &image{name: "foo", runtime: RuntimeDocker},
 			want:         "digest",
 		},
 		"inspect invalid output": {
 			expectations: []*expect.Expectation{
 				inspectSuccess("foo", ""),
 			},
-			image:   &image{name: "foo"},
+			image:   &image{name: "foo", runtime: RuntimeDocker},
 			wantErr: true,
 		},
 		"inspect failure first attempt": {
@@ -38,7 +38,7 @@ func TestImage_Digest(t *testing.T) {
 				pullSuccess("foo"),
 				inspectSuccess("foo", "digest"),
 			},
-			image: &image{name: "foo"},
+			image: &image{name: "foo", runtime: RuntimeDocker},
 			want:  "digest",
 		},
 		"pull failure": {
@@ -46,7 +46,7 @@ func TestImage_Digest(t *testing.T) {
 				inspectFailure("foo"),
 				pullFailure("foo"),
 			},
-			image:   &image{name: "foo"},
+			image:   &image{name: "foo", runtime: RuntimeDocker},
 			wantErr: true,
 		},
 	} {
@@ -89,7 +89,7 @@ func TestImage_Digest(t *testing.T) {
 		t.Cleanup(cancel)
 
 		expect.Commands(t, inspectSuccess("foo", ""))
-		image := &image{name: "foo"}
+		image := &image{name: "foo", runtime: RuntimeDocker}
 
 		digest, err := image.Digest(ctx)
 		assert.Empty(t, digest)
@@ -111,7 +111,7 @@ func TestImage_Ensure(t *testing.T) {
 	}{
 		"no pull required": {
 			expectations: []*expect.Expectation{inspectSuccess("foo", "digest")},
-			image:        &image{name: "foo"},
+			image:        &image{name: "foo", runtime: RuntimeDocker},
 			wantErr:      false,
 		},
 		"pull required": {
@@ -120,7 +120,7 @@ func TestImage_Ensure(t *testing.T) {
 				pullSuccess("foo"),
 				inspectSuccess("foo", "digest"),
 			},
-			image:   &image{name: "foo"},
+			image:   &image{name: "foo", runtime: RuntimeDocker},
 			wantErr: false,
 		},
 		"pull failed": {
@@ -128,7 +128,7 @@ func TestImage_Ensure(t *testing.T) {
 				inspectFailure("foo"),
 				pullFailure("foo"),
 			},
-			image:   &image{name: "foo"},
+			image:   &image{name: "foo", runtime: RuntimeDocker},
 			wantErr: true,
 		},
 	} {
@@ -172,7 +172,7 @@ func TestImage_UIDGID(t *testing.T) {
 				inspectSuccess("foo", "bar"),
 				uidGid("bar", expect.Behaviour{Stdout: []byte("1000\n2000\n")}),
 			},
-			image: &image{name: "foo"},
+			image: &image{name: "foo", runtime: RuntimeDocker},
 			want:  UIDGID{UID: 1000, GID: 2000},
 		},
 		// We should also make sure 0 works. Sometimes it's easy to miss. Just
@@ -182,7 +182,7 @@ func TestImage_UIDGID(t *testing.T) {
 				inspectSuccess("foo", "bar"),
 				uidGid("bar", expect.Behaviour{Stdout: []byte("0\n0\n")}),
 			},
-			image: &image{name: "foo"},
+			image: &image{name: "foo", runtime: RuntimeDocker},
 			want:  UIDGID{UID: 0, GID: 0},
 		},
 		// This is technically valid, because POSIX basically punts on the
@@ -193,7 +193,7 @@ func TestImage_UIDGID(t *testing.T) {
 				inspectSuccess("foo", "bar"),
 				uidGid("bar", expect.Behaviour{Stdout: []byte("-1000\n-2000\n")}),
 			},
-			image: &image{name: "foo"},
+			image: &image{name: "foo", runtime: RuntimeDocker},
 			want:  UIDGID{UID: -1000, GID: -2000},
 		},
 		// This is technically invalid, but should still succeed. Postel's Law
@@ -203,7 +203,7 @@ func TestImage_UIDGID(t *testing.T) {
 				inspectSuccess("foo", "bar"),
 				uidGid("bar", expect.Behaviour{Stdout: []byte("1000\n2000")}),
 			},
-			image: &image{name: "foo"},
+			image: &image{name: "foo", runtime: RuntimeDocker},
 			want:  UIDGID{UID: 1000, GID: 2000},
 		},
 		// As above, this is invalid, but we should still handle it.
@@ -212,7 +212,7 @@ func TestImage_UIDGID(t *testing.T) {
 				inspectSuccess("foo", "bar"),
 				uidGid("bar", expect.Behaviour{Stdout: []byte("1000\n2000\n3000\n")}),
 			},
-			image: &image{name: "foo"},
+			image: &image{name: "foo", runtime: RuntimeDocker},
 			want:  UIDGID{UID: 1000, GID: 2000},
 		},
 		// Now for some interesting failure cases.
@@ -221,7 +221,7 @@ func TestImage_UIDGID(t *testing.T) {
 				inspectSuccess("foo", "bar"),
 				uidGid("bar", expect.Behaviour{Stdout: []byte("")}),
 			},
-			image:   &image{name: "foo"},
+			image:   &image{name: "foo", runtime: RuntimeDocker},
 			wantErr: true,
 		},
 		// This is ripped from the headlines^WDocker.
@@ -232,7 +232,7 @@ func TestImage_UIDGID(t *testing.T) {
 					ExitCode: 127,
 					Stderr:   []byte("sh: id: not found")}),
 			},
-			image:   &image{name: "foo"},
+			image:   &image{name: "foo", runtime: RuntimeDocker},
 			wantErr: true,
 		},
 		// POSIX might allow negative IDs because, well, honestly, it was
@@ -243,7 +243,7 @@ func TestImage_UIDGID(t *testing.T) {
 				inspectSuccess("foo", "bar"),
 				uidGid("bar", expect.Behaviour{Stdout: []byte("X\n2000\n")}),
 			},
-			image:   &image{name: "foo"},
+			image:   &image{name: "foo", runtime: RuntimeDocker},
 			wantErr: true,
 		},
 		"string gid": {
@@ -251,7 +251,7 @@ func TestImage_UIDGID(t *testing.T) {
 				inspectSuccess("foo", "bar"),
 				uidGid("bar", expect.Behaviour{Stdout: []byte("1000\nX\n")}),
 			},
-			image:   &image{name: "foo"},
+			image:   &image{name: "foo", runtime: RuntimeDocker},
 			wantErr: true,
 		},
 		// Now for some more run of the mill failures.
@@ -260,7 +260,7 @@ func TestImage_UIDGID(t *testing.T) {
 				inspectSuccess("foo", "bar"),
 				uidGid("bar", expect.Behaviour{ExitCode: 1}),
 			},
-			image:   &image{name: "foo"},
+			image:   &image{name: "foo", runtime: RuntimeDocker},
 			wantErr: true,
 		},
 		"inspect and pull failure": {
@@ -268,7 +268,7 @@ func TestImage_UIDGID(t *testing.T) {
 				inspectFailure("foo"),
 				pullFailure("foo"),
 			},
-			image:   &image{name: "foo"},
+			image:   &image{name: "foo", runtime: RuntimeDocker},
 			wantErr: true,
 		},
 	} {
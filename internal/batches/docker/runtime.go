@@ -0,0 +1,280 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	goexec "os/exec"
+	"strings"
+	"sync"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
+	"github.com/sourcegraph/src-cli/internal/exec"
+)
+
+// Runtime is a container runtime with a Docker compatible command line, such
+// as Docker itself, Podman or nerdctl. Batch steps are run in containers of a
+// runtime, and the implementations take care of the differences between the
+// command lines.
+type Runtime interface {
+	// Name returns the name of the runtime, which is also the name of its
+	// executable.
+	Name() string
+
+	// Command returns the command to run the runtime's executable with the
+	// given arguments.
+	Command(ctx context.Context, args ...string) *goexec.Cmd
+
+	// CheckVersion returns an error if the runtime isn't available.
+	CheckVersion(ctx context.Context) error
+
+	// CurrentContext returns the name of the current Docker context (not to
+	// be confused with a Go context), or an empty string if the runtime
+	// doesn't have contexts.
+	CurrentContext(ctx context.Context) (string, error)
+
+	// NCPU returns the number of CPU cores available to the runtime.
+	NCPU(ctx context.Context) (int, error)
+
+	// ImageRef returns the reference to run an image with, given its name and
+	// the content digest that Image.Digest returns.
+	ImageRef(name, digest string) string
+
+	// UserArgs returns the arguments to `run` a container as the given user.
+	UserArgs(ug UIDGID) []string
+
+	// BindMountUserArgs returns the arguments to `run` a container of the
+	// image as its own user, such that the files the container creates in
+	// bind mounts are owned by the user running src. It returns nil if the
+	// runtime doesn't need any.
+	BindMountUserArgs(ctx context.Context, image Image) ([]string, error)
+
+	// CreateVolume creates a new, empty volume, and returns its name.
+	CreateVolume(ctx context.Context) (string, error)
+
+	// RemoveVolume removes the volume with the given name.
+	RemoveVolume(ctx context.Context, name string) error
+
+	// Engine returns the Engine API client to use instead of the command line,
+	// or nil if the command line has to be used. See ConnectEngine.
+	Engine() *Engine
+
+	// parseImageID returns the content digest from the output of `image
+	// inspect --format {{ .Id }}`.
+	parseImageID(out []byte) string
+}
+
+// The runtimes that src can run batch steps with.
+var (
+	RuntimeDocker  Runtime = &dockerRuntime{cliRuntime{name: "docker"}}
+	RuntimePodman  Runtime = &podmanRuntime{cliRuntime: cliRuntime{name: "podman"}}
+	RuntimeNerdctl Runtime = &nerdctlRuntime{cliRuntime{name: "nerdctl"}}
+)
+
+// Runtimes are the runtimes that NewRuntime knows, by name.
+var Runtimes = map[string]Runtime{
+	RuntimeDocker.Name():  RuntimeDocker,
+	RuntimePodman.Name():  RuntimePodman,
+	RuntimeNerdctl.Name(): RuntimeNerdctl,
+}
+
+// NewRuntime returns the runtime with the given name. An empty name means
+// Docker.
+func NewRuntime(name string) (Runtime, error) {
+	if name == "" {
+		return RuntimeDocker, nil
+	}
+	if rt, ok := Runtimes[name]; ok {
+		return rt, nil
+	}
+	return nil, errors.Newf("unknown container runtime %q, expected one of docker, podman or nerdctl", name)
+}
+
+// cliRuntime implements the parts of Runtime that are the same for every
+// runtime. They use Docker's command line.
+type cliRuntime struct {
+	name string
+}
+
+func (rt *cliRuntime) Name() string { return rt.name }
+
+func (rt *cliRuntime) Command(ctx context.Context, args ...string) *goexec.Cmd {
+	return exec.CommandContext(ctx, rt.name, args...)
+}
+
+// CheckVersion is used to check if the runtime is running. We use this method
+// instead of checkExecutable (https://sourcegraph.com/github.com/sourcegraph/src-cli@main/-/blob/cmd/src/batch_common.go?L547%3A6=&popover=pinned)
+// to prevent a case where runtime commands take too long and results in
+// `src-cli` freezing for some users.
+func (rt *cliRuntime) CheckVersion(ctx context.Context) error {
+	_, err := executeFastCommand(ctx, rt.name, "version")
+	if err != nil {
+		return errors.Newf(
+			"failed to execute \"%s version\":\n\t%s\n\n'src batch' requires \"%s\" to be available.",
+			rt.name, err, rt.name,
+		)
+	}
+
+	return nil
+}
+
+// CurrentContext returns an empty string, since only Docker has contexts.
+func (rt *cliRuntime) CurrentContext(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+type Info struct {
+	Host struct {
+		CPUs int `json:"cpus"`
+	} `json:"host"` // Podman engine
+	NCPU int `json:"NCPU"` // Docker Engine and nerdctl
+}
+
+func (rt *cliRuntime) NCPU(ctx context.Context) (int, error) {
+	out, err := executeFastCommand(ctx, rt.name, "info", "--format", "{{ json .}}")
+	if err != nil {
+		return 0, err
+	}
+
+	var info Info
+	if err := json.Unmarshal(out, &info); err != nil {
+		return 0, err
+	}
+	if info.NCPU > 0 {
+		return info.NCPU, nil
+	}
+	return info.Host.CPUs, nil
+}
+
+func (rt *cliRuntime) ImageRef(name, digest string) string { return digest }
+
+func (rt *cliRuntime) UserArgs(ug UIDGID) []string {
+	return []string{"--user", ug.String()}
+}
+
+// BindMountUserArgs returns nil, since Docker and nerdctl run containers in
+// the user namespace of the host, where the container user is the same user
+// as on the host.
+func (rt *cliRuntime) BindMountUserArgs(ctx context.Context, image Image) ([]string, error) {
+	return nil, nil
+}
+
+func (rt *cliRuntime) CreateVolume(ctx context.Context) (string, error) {
+	out, err := rt.Command(ctx, "volume", "create").CombinedOutput()
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes.TrimSpace(out)), nil
+}
+
+func (rt *cliRuntime) RemoveVolume(ctx context.Context, name string) error {
+	return rt.Command(ctx, "volume", "rm", name).Run()
+}
+
+func (rt *cliRuntime) Engine() *Engine { return nil }
+
+func (rt *cliRuntime) parseImageID(out []byte) string {
+	return string(bytes.TrimSpace(out))
+}
+
+type dockerRuntime struct{ cliRuntime }
+
+func (rt *dockerRuntime) CurrentContext(ctx context.Context) (string, error) {
+	out, err := executeFastCommand(ctx, rt.name, "context", "inspect", "--format", "{{ .Name }}")
+	if err != nil {
+		return "", err
+	}
+
+	name := string(bytes.TrimSpace(out))
+	if name == "" {
+		return "", errors.New("no context returned from Docker")
+	}
+
+	return name, nil
+}
+
+type podmanRuntime struct {
+	cliRuntime
+
+	// rootless is whether Podman is rootless, once rootlessKnown is set.
+	rootlessMu    sync.Mutex
+	rootlessKnown bool
+	rootless      bool
+}
+
+// BindMountUserArgs maps the user of the image to the user running src when
+// Podman is rootless. Rootless Podman maps root in the container to the user
+// already, but every other user to a subordinate UID that the user running src
+// can't remove the files of. Mapping the user to a UID other than 0 requires
+// Podman 4.3 or later.
+func (rt *podmanRuntime) BindMountUserArgs(ctx context.Context, image Image) ([]string, error) {
+	rootless, err := rt.isRootless()
+	if err != nil || !rootless {
+		return nil, err
+	}
+	ug, err := image.UIDGID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ug == Root {
+		return nil, nil
+	}
+	return append(rt.UserArgs(ug), fmt.Sprintf("--userns=keep-id:uid=%d,gid=%d", ug.UID, ug.GID)), nil
+}
+
+// isRootless returns whether Podman runs containers without root privileges,
+// which is the default unless it's run by root. The answer is the same for
+// every task, so it's checked independently of the context of the task that
+// asks first, and remembered once it's known. Errors aren't remembered, so
+// that the next task checks again.
+func (rt *podmanRuntime) isRootless() (bool, error) {
+	rt.rootlessMu.Lock()
+	defer rt.rootlessMu.Unlock()
+	if rt.rootlessKnown {
+		return rt.rootless, nil
+	}
+
+	out, err := executeFastCommand(context.Background(), rt.name, "info", "--format", "{{ .Host.Security.Rootless }}")
+	if err != nil {
+		return false, errors.Wrap(err, "checking if Podman is rootless")
+	}
+	rt.rootless = strings.TrimSpace(string(out)) == "true"
+	rt.rootlessKnown = true
+	return rt.rootless, nil
+}
+
+// parseImageID adds the algorithm to the IDs of Podman images, which Podman
+// prints without it, so that digests look the same for every runtime.
+func (rt *podmanRuntime) parseImageID(out []byte) string {
+	id := rt.cliRuntime.parseImageID(out)
+	if id != "" && !strings.Contains(id, ":") {
+		id = "sha256:" + id
+	}
+	return id
+}
+
+type nerdctlRuntime struct{ cliRuntime }
+
+// ImageRef returns the name of the image, since nerdctl looks up the image to
+// run by reference rather than by content digest. The image has just been
+// ensured, so this is the same image unless it's retagged in the meantime.
+func (rt *nerdctlRuntime) ImageRef(name, digest string) string { return name }
+
+// CreateVolume names the volume, since nerdctl doesn't generate names.
+func (rt *nerdctlRuntime) CreateVolume(ctx context.Context) (string, error) {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return "", errors.Wrap(err, "generating volume name")
+	}
+	name := "src-batch-" + hex.EncodeToString(token)
+
+	if out, err := rt.Command(ctx, "volume", "create", name).CombinedOutput(); err != nil {
+		return "", errors.Wrapf(err, "nerdctl output:\n\n%s\n\n", string(out))
+	}
+	return name, nil
+}
@@ -0,0 +1,51 @@
+package docker
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestPodman_BindMountUserArgs runs a container as a non-root user with rootless
+// Podman, and checks that the file it creates in a bind mount is owned by the
+// user running the test. It's skipped if rootless Podman isn't available.
+func TestPodman_BindMountUserArgs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test that runs a container in short mode")
+	}
+	if _, err := exec.LookPath("podman"); err != nil {
+		t.Skip("podman isn't installed")
+	}
+	if os.Getuid() == 0 {
+		t.Skip("podman isn't rootless when run by root")
+	}
+
+	ctx := context.Background()
+	rt := &podmanRuntime{cliRuntime: cliRuntime{name: "podman"}}
+	args, err := rt.BindMountUserArgs(ctx, &fakeImage{ug: UIDGID{UID: 1000, GID: 1000}})
+	if err != nil {
+		t.Skipf("podman isn't usable: %s", err)
+	}
+	if args == nil {
+		t.Skip("podman isn't rootless")
+	}
+
+	dir := t.TempDir()
+	run := append([]string{"run", "--rm"}, args...)
+	run = append(run, "--mount", "type=bind,source="+dir+",target=/work", "docker.io/library/alpine:3", "touch", "/work/created")
+	if out, err := exec.Command("podman", run...).CombinedOutput(); err != nil {
+		t.Fatalf("running container: %s\n%s", err, out)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "created"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+	if int(stat.Uid) != os.Getuid() || int(stat.Gid) != os.Getgid() {
+		t.Errorf("file is owned by %d:%d, want %d:%d", stat.Uid, stat.Gid, os.Getuid(), os.Getgid())
+	}
+}
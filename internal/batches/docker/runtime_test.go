@@ -0,0 +1,282 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sourcegraph/src-cli/internal/exec/expect"
+)
+
+func Test_CurrentContext(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("docker fails", func(t *testing.T) {
+		expect.Commands(t, contextInspectFailure())
+
+		name, err := RuntimeDocker.CurrentContext(ctx)
+		assert.Empty(t, name)
+		assert.Error(t, err)
+	})
+
+	t.Run("docker times out", func(t *testing.T) {
+		tctx, cancel := context.WithTimeout(ctx, -1*time.Second)
+		t.Cleanup(cancel)
+
+		expect.Commands(t, contextInspectSuccess("desktop-linux"))
+
+		name, err := RuntimeDocker.CurrentContext(tctx)
+		assert.Zero(t, name)
+		var terr *fastCommandTimeoutError
+		assert.ErrorAs(t, err, &terr)
+		assert.Equal(t, []string{"context", "inspect", "--format", "{{ .Name }}"}, terr.args)
+		assert.Equal(t, fastCommandTimeoutDefault, terr.timeout)
+	})
+
+	t.Run("docker succeeds, but returns nothing", func(t *testing.T) {
+		expect.Commands(t, contextInspectSuccess(""))
+
+		name, err := RuntimeDocker.CurrentContext(ctx)
+		assert.Empty(t, name)
+		assert.Error(t, err)
+	})
+
+	t.Run("docker succeeds", func(t *testing.T) {
+		expect.Commands(t, contextInspectSuccess("desktop-linux"))
+
+		name, err := RuntimeDocker.CurrentContext(ctx)
+		assert.Equal(t, "desktop-linux", name)
+		assert.NoError(t, err)
+	})
+}
+
+func contextInspectSuccess(ncpu string) *expect.Expectation {
+	return expect.NewLiteral(
+		expect.Behaviour{Stdout: []byte(fmt.Sprintf("%s\n", ncpu))},
+		"docker", "context", "inspect", "--format", "{{ .Name }}",
+	)
+}
+
+func contextInspectFailure() *expect.Expectation {
+	return expect.NewLiteral(
+		expect.Behaviour{ExitCode: 1},
+		"docker", "context", "inspect", "--format", "{{ .Name }}",
+	)
+}
+
+func Test_NCPU(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("docker fails", func(t *testing.T) {
+		expect.Commands(t, infoFailure())
+
+		ncpu, err := RuntimeDocker.NCPU(ctx)
+		assert.Zero(t, ncpu)
+		assert.Error(t, err)
+	})
+
+	t.Run("docker times out", func(t *testing.T) {
+		tctx, cancel := context.WithTimeout(ctx, -1*time.Second)
+		t.Cleanup(cancel)
+
+		expect.Commands(t, infoSuccess("4"))
+
+		ncpu, err := RuntimeDocker.NCPU(tctx)
+		assert.Zero(t, ncpu)
+		var terr *fastCommandTimeoutError
+		assert.ErrorAs(t, err, &terr)
+		assert.Equal(t, []string{"info", "--format", "{{ json .}}"}, terr.args)
+		assert.Equal(t, fastCommandTimeoutDefault, terr.timeout)
+	})
+
+	t.Run("docker succeeds, but returns nothing", func(t *testing.T) {
+		expect.Commands(t, infoSuccess(""))
+
+		ncpu, err := RuntimeDocker.NCPU(ctx)
+		assert.Zero(t, ncpu)
+		assert.Error(t, err)
+	})
+
+	t.Run("docker succeeds, but returns something invalid", func(t *testing.T) {
+		expect.Commands(t, infoSuccess("foo"))
+
+		ncpu, err := RuntimeDocker.NCPU(ctx)
+		assert.Zero(t, ncpu)
+		assert.Error(t, err)
+	})
+
+	t.Run("docker succeeds", func(t *testing.T) {
+		expect.Commands(t, infoSuccess("4"))
+
+		ncpu, err := RuntimeDocker.NCPU(ctx)
+		assert.Equal(t, 4, ncpu)
+		assert.NoError(t, err)
+	})
+}
+
+func infoSuccess(ncpu string) *expect.Expectation {
+	var b []byte
+	n, err := strconv.Atoi(ncpu)
+	if err == nil {
+		b, _ = json.Marshal(Info{NCPU: n})
+	} else {
+		b = []byte(ncpu)
+	}
+	return expect.NewLiteral(
+		expect.Behaviour{Stdout: b},
+		"docker", "info", "--format", "{{ json .}}",
+	)
+}
+
+func infoFailure() *expect.Expectation {
+	return expect.NewLiteral(
+		expect.Behaviour{ExitCode: 1},
+		"docker", "info", "--format", "{{ json .}}",
+	)
+}
+
+func TestNewRuntime(t *testing.T) {
+	for name, want := range map[string]Runtime{
+		"":        RuntimeDocker,
+		"docker":  RuntimeDocker,
+		"podman":  RuntimePodman,
+		"nerdctl": RuntimeNerdctl,
+	} {
+		have, err := NewRuntime(name)
+		assert.NoError(t, err)
+		assert.Equal(t, want, have, "runtime %q", name)
+	}
+
+	_, err := NewRuntime("rkt")
+	assert.Error(t, err)
+}
+
+func TestRuntime_Differences(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("podman image IDs", func(t *testing.T) {
+		expect.Commands(t, expect.NewGlob(
+			expect.Behaviour{Stdout: []byte("0123abcd\n")},
+			"podman", "image", "inspect", "--format", `\{\{ .Id }}`, "foo",
+		))
+
+		digest, err := (&image{name: "foo", runtime: RuntimePodman}).Digest(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "sha256:0123abcd", digest)
+		assert.Equal(t, "sha256:0123abcd", RuntimePodman.ImageRef("foo", digest))
+	})
+
+	t.Run("nerdctl image refs", func(t *testing.T) {
+		assert.Equal(t, "foo:1.0", RuntimeNerdctl.ImageRef("foo:1.0", "sha256:0123abcd"))
+		assert.Equal(t, "sha256:0123abcd", RuntimeDocker.ImageRef("foo:1.0", "sha256:0123abcd"))
+	})
+
+	t.Run("users", func(t *testing.T) {
+		assert.Equal(t, []string{"--user", "0:0"}, RuntimeDocker.UserArgs(Root))
+		assert.Equal(t, []string{"--user", "0:0"}, RuntimePodman.UserArgs(Root))
+		assert.Equal(t, []string{"--user", "1000:1000"}, RuntimePodman.UserArgs(UIDGID{UID: 1000, GID: 1000}))
+		assert.Equal(t, []string{"--user", "0:0"}, RuntimeNerdctl.UserArgs(Root))
+	})
+
+	t.Run("bind mount users", func(t *testing.T) {
+		args, err := RuntimeDocker.BindMountUserArgs(ctx, &fakeImage{ug: UIDGID{UID: 1000, GID: 100}})
+		assert.NoError(t, err)
+		assert.Nil(t, args)
+	})
+
+	t.Run("rootless podman bind mount users", func(t *testing.T) {
+		expect.Commands(t, expect.NewLiteral(
+			expect.Behaviour{Stdout: []byte("true\n")},
+			"podman", "info", "--format", "{{ .Host.Security.Rootless }}",
+		))
+
+		rt := &podmanRuntime{cliRuntime: cliRuntime{name: "podman"}}
+		args, err := rt.BindMountUserArgs(ctx, &fakeImage{ug: UIDGID{UID: 1000, GID: 100}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"--user", "1000:100", "--userns=keep-id:uid=1000,gid=100"}, args)
+
+		// Root is mapped to the user running src already.
+		args, err = rt.BindMountUserArgs(ctx, &fakeImage{ug: Root})
+		assert.NoError(t, err)
+		assert.Nil(t, args)
+	})
+
+	t.Run("podman rootless check errors", func(t *testing.T) {
+		expect.Commands(t,
+			expect.NewLiteral(
+				expect.Behaviour{Stderr: []byte("cannot connect to Podman"), ExitCode: 125},
+				"podman", "info", "--format", "{{ .Host.Security.Rootless }}",
+			),
+			expect.NewLiteral(
+				expect.Behaviour{Stdout: []byte("true\n")},
+				"podman", "info", "--format", "{{ .Host.Security.Rootless }}",
+			),
+		)
+
+		// Errors aren't remembered, and the context of the task that asks
+		// doesn't matter.
+		rt := &podmanRuntime{cliRuntime: cliRuntime{name: "podman"}}
+		_, err := rt.BindMountUserArgs(ctx, &fakeImage{ug: UIDGID{UID: 1000, GID: 100}})
+		assert.ErrorContains(t, err, "checking if Podman is rootless")
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		args, err := rt.BindMountUserArgs(canceled, &fakeImage{ug: UIDGID{UID: 1000, GID: 100}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"--user", "1000:100", "--userns=keep-id:uid=1000,gid=100"}, args)
+	})
+
+	t.Run("rootful podman bind mount users", func(t *testing.T) {
+		expect.Commands(t, expect.NewLiteral(
+			expect.Behaviour{Stdout: []byte("false\n")},
+			"podman", "info", "--format", "{{ .Host.Security.Rootless }}",
+		))
+
+		rt := &podmanRuntime{cliRuntime: cliRuntime{name: "podman"}}
+		args, err := rt.BindMountUserArgs(ctx, &fakeImage{ug: UIDGID{UID: 1000, GID: 100}})
+		assert.NoError(t, err)
+		assert.Nil(t, args)
+	})
+
+	t.Run("volumes", func(t *testing.T) {
+		expect.Commands(t,
+			expect.NewGlob(expect.Behaviour{Stdout: []byte("abcdef\n")}, "podman", "volume", "create"),
+			expect.NewGlob(expect.Behaviour{}, "nerdctl", "volume", "create", "src-batch-*"),
+		)
+
+		volume, err := RuntimePodman.CreateVolume(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "abcdef", volume)
+
+		volume, err = RuntimeNerdctl.CreateVolume(ctx)
+		assert.NoError(t, err)
+		assert.Regexp(t, "^src-batch-[0-9a-f]{16}$", volume)
+	})
+
+	t.Run("contexts", func(t *testing.T) {
+		name, err := RuntimePodman.CurrentContext(ctx)
+		assert.NoError(t, err)
+		assert.Empty(t, name)
+	})
+
+	t.Run("timeouts", func(t *testing.T) {
+		tctx, cancel := context.WithTimeout(ctx, -1*time.Second)
+		t.Cleanup(cancel)
+
+		expect.Commands(t, expect.NewLiteral(expect.Behaviour{}, "podman", "info", "--format", "{{ json .}}"))
+
+		_, err := RuntimePodman.NCPU(tctx)
+		assert.ErrorContains(t, err, "`podman info --format '{{ json .}}'` failed to respond")
+	})
+}
+
+// fakeImage is an image that runs as the given user.
+type fakeImage struct{ ug UIDGID }
+
+func (i *fakeImage) Digest(context.Context) (string, error) { return "sha256:0123abcd", nil }
+func (i *fakeImage) Ensure(context.Context) error           { return nil }
+func (i *fakeImage) UIDGID(context.Context) (UIDGID, error) { return i.ug, nil }
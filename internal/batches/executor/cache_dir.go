@@ -0,0 +1,230 @@
+package executor
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+// CacheEntryKind is the kind of a file in the cache directory.
+type CacheEntryKind string
+
+const (
+	// CacheEntryResult is a step result of the execution cache.
+	CacheEntryResult CacheEntryKind = "result"
+	// CacheEntryArchive is a downloaded repository archive, or one of the
+	// files that are downloaded along with it, such as .gitignore.
+	CacheEntryArchive CacheEntryKind = "archive"
+)
+
+// CacheEntry is a file in the cache directory that's shared by the execution
+// cache and the repository archives.
+type CacheEntry struct {
+	// Path is the path of the entry, relative to the cache directory.
+	Path string
+	Kind CacheEntryKind
+	// Slug identifies the repository and revision of the entry.
+	Slug string
+	// Key is the cache key of a step result.
+	Key string
+	// StepIndex is the index of the step of a step result, or -1 if it's
+	// unknown or the entry isn't a step result.
+	StepIndex int
+	Size      int64
+	// LastUsed is when the entry was last written or read.
+	LastUsed time.Time
+}
+
+// cacheKeyPattern matches the keys of step results, which are URL-safe base64
+// encoded hashes.
+var cacheKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{22}$`)
+
+// archivePattern matches the names that the repozip fetcher gives the files it
+// downloads, as returned by util.SlugForPathInRepo: the archive of a workspace
+// is "<repo>[-<path hash>]-<commit>.zip", and an additional file next to it is
+// "<repo>-<path hash>-<commit>".
+var archivePattern = regexp.MustCompile(`^.+-([A-Za-z0-9_-]{43}-)?[0-9a-f]{40}\.zip$|^.+-[A-Za-z0-9_-]{43}-[0-9a-f]{40}$`)
+
+// workspaceDirPrefix is the prefix of the directories that bind and native
+// workspaces are unzipped to, which can be in the cache directory too.
+const workspaceDirPrefix = "workspace-"
+
+// ListCacheEntries returns the entries in the cache directory dir, sorted by
+// path. A directory that doesn't exist has no entries.
+func ListCacheEntries(dir string) ([]CacheEntry, error) {
+	var entries []CacheEntry
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			if filepath.Dir(p) == filepath.Clean(dir) && strings.HasPrefix(d.Name(), workspaceDirPrefix) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// Removed by a concurrent run.
+				return nil
+			}
+			return err
+		}
+
+		entry := CacheEntry{
+			Path:      filepath.ToSlash(rel),
+			StepIndex: -1,
+			Size:      info.Size(),
+			LastUsed:  info.ModTime(),
+		}
+		parts := strings.Split(entry.Path, "/")
+		key, isResult := strings.CutSuffix(parts[len(parts)-1], cacheFileExt)
+		isResult = isResult && cacheKeyPattern.MatchString(key)
+		switch {
+		case len(parts) == 1 && archivePattern.MatchString(parts[0]):
+			entry.Kind = CacheEntryArchive
+			entry.Slug = strings.TrimSuffix(parts[0], ".zip")
+		case len(parts) == 2 && isResult:
+			entry.Kind = CacheEntryResult
+			entry.Slug = parts[0]
+			entry.Key = key
+			entry.StepIndex = readStepIndex(p)
+		default:
+			// Not written by us, so leave it alone.
+			return nil
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing cache directory")
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// readStepIndex returns the step index of the step result at path, or -1 if
+// it can't be read.
+func readStepIndex(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return -1
+	}
+	var result struct {
+		StepIndex *int `json:"stepIndex"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil || result.StepIndex == nil {
+		return -1
+	}
+	return *result.StepIndex
+}
+
+// CachePruneOpts are the options of PruneCache. At least one of MaxAge and
+// MaxSize should be set, or nothing is pruned.
+type CachePruneOpts struct {
+	// MaxAge removes the entries that haven't been used for longer than
+	// MaxAge.
+	MaxAge time.Duration
+	// MaxSize removes the least recently used entries until the entries take
+	// up at most MaxSize bytes.
+	MaxSize int64
+	// DryRun returns the entries that would be removed without removing
+	// them.
+	DryRun bool
+}
+
+// PruneCache removes entries from the cache directory dir, least recently
+// used first, and returns the removed entries.
+func PruneCache(dir string, opts CachePruneOpts) ([]CacheEntry, error) {
+	entries, err := ListCacheEntries(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+
+	var prune []CacheEntry
+	cutoff := time.Now().Add(-opts.MaxAge)
+	for _, e := range entries {
+		tooOld := opts.MaxAge > 0 && e.LastUsed.Before(cutoff)
+		tooBig := opts.MaxSize > 0 && total > opts.MaxSize
+		if !tooOld && !tooBig {
+			// Entries are sorted by age, so none of the remaining ones are
+			// too old, and the size limit is met.
+			break
+		}
+		prune = append(prune, e)
+		total -= e.Size
+	}
+
+	if opts.DryRun {
+		return prune, nil
+	}
+	return removeCacheEntries(dir, prune)
+}
+
+// ClearCacheDir removes all entries from the cache directory dir, and returns
+// them.
+func ClearCacheDir(dir string) ([]CacheEntry, error) {
+	entries, err := ListCacheEntries(dir)
+	if err != nil {
+		return nil, err
+	}
+	return removeCacheEntries(dir, entries)
+}
+
+// removeCacheEntries removes the entries from the cache directory dir, along
+// with the slug directories that are left empty, and returns the removed
+// entries.
+func removeCacheEntries(dir string, entries []CacheEntry) ([]CacheEntry, error) {
+	var (
+		removed []CacheEntry
+		errs    error
+	)
+	dirs := map[string]struct{}{}
+	for _, e := range entries {
+		p := filepath.Join(dir, filepath.FromSlash(e.Path))
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = errors.Append(errs, err)
+			continue
+		}
+		removed = append(removed, e)
+		if e.Kind == CacheEntryResult {
+			dirs[filepath.Dir(p)] = struct{}{}
+		}
+	}
+	for d := range dirs {
+		// This fails if the directory isn't empty, which is what we want.
+		_ = os.Remove(d)
+	}
+	return removed, errs
+}
+
+// touchCacheFile marks the file at path as used, so that PruneCache keeps it
+// over the entries that haven't been used as recently.
+func touchCacheFile(path string) {
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+}
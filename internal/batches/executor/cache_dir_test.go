@@ -0,0 +1,175 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/sourcegraph/lib/batches/execution"
+
+	"github.com/sourcegraph/src-cli/internal/batches/util"
+)
+
+// Cache keys, in the format of cache.CacheKey.Key.
+const (
+	key1 = "Bn0Ozx0k3E2QXvXfHNYzLg"
+	key2 = "Hbn8Rd7Jb0hMkd3o-t6o5A"
+	key3 = "yW_0YkYJPgZsPvw6fP3Zqw"
+)
+
+const testCommit = "d34db33fd34db33fd34db33fd34db33fd34db33f"
+
+// Files written by the repozip fetcher: the archive of repo-a and an
+// additional file of a workspace in it.
+var (
+	testArchiveSlug = util.SlugForPathInRepo("repo-a", testCommit, "")
+	testArchive     = testArchiveSlug + ".zip"
+	testExtraFile   = util.SlugForPathInRepo("repo-a", testCommit, "docs/.gitignore")
+)
+
+// writeCacheDirFile writes a file to the cache directory, which was last
+// used age ago.
+func writeCacheDirFile(t *testing.T, dir, name string, data []byte, age time.Duration) {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0700))
+	require.NoError(t, os.WriteFile(p, data, 0600))
+	mtime := time.Now().Add(-age)
+	require.NoError(t, os.Chtimes(p, mtime, mtime))
+}
+
+func newTestCacheDir(t *testing.T) string {
+	dir := t.TempDir()
+	writeCacheDirFile(t, dir, "repo-a/"+key1+".json", []byte(`{"stepIndex":1}`), 3*time.Hour)
+	writeCacheDirFile(t, dir, "repo-a/"+key2+".json", []byte(`not json`), 1*time.Hour)
+	writeCacheDirFile(t, dir, testArchive, make([]byte, 100), 2*time.Hour)
+	writeCacheDirFile(t, dir, testExtraFile, []byte("*"), 150*time.Minute)
+	writeCacheDirFile(t, dir, "repo-b/"+key3+".json", []byte(`{"stepIndex":0,"diff":""}`), 0)
+	// Files that aren't cache entries are left alone.
+	writeCacheDirFile(t, dir, "repo-b/notes.txt", []byte("hi"), 10*time.Hour)
+	writeCacheDirFile(t, dir, "repo-b/package.json", []byte("{}"), 10*time.Hour)
+	writeCacheDirFile(t, dir, "nested/dir/file.json", []byte("{}"), 10*time.Hour)
+	// Neither are other files next to the archives, such as with -cache ~.
+	writeCacheDirFile(t, dir, "notes.txt", []byte("hi"), 10*time.Hour)
+	writeCacheDirFile(t, dir, "repo-a.zip", make([]byte, 10), 10*time.Hour)
+	writeCacheDirFile(t, dir, "backup-"+testCommit, []byte("hi"), 10*time.Hour)
+	// Bind and native workspaces are unzipped into the cache directory.
+	writeCacheDirFile(t, dir, "workspace-repo-a-abc-1234/"+key1+".json", []byte("{}"), 10*time.Hour)
+	writeCacheDirFile(t, dir, "workspace-repo-a-abc-1234/README.md", []byte("hi"), 10*time.Hour)
+	return dir
+}
+
+func cacheEntryPaths(entries []CacheEntry) []string {
+	paths := []string{}
+	for _, e := range entries {
+		paths = append(paths, e.Path)
+	}
+	return paths
+}
+
+func TestListCacheEntries(t *testing.T) {
+	dir := newTestCacheDir(t)
+
+	entries, err := ListCacheEntries(dir)
+	require.NoError(t, err)
+	for i := range entries {
+		assert.WithinDuration(t, time.Now(), entries[i].LastUsed, 4*time.Hour)
+		entries[i].LastUsed = time.Time{}
+	}
+	assert.Equal(t, []CacheEntry{
+		{Path: testArchive, Kind: CacheEntryArchive, Slug: testArchiveSlug, StepIndex: -1, Size: 100},
+		{Path: testExtraFile, Kind: CacheEntryArchive, Slug: testExtraFile, StepIndex: -1, Size: 1},
+		{Path: "repo-a/" + key1 + ".json", Kind: CacheEntryResult, Slug: "repo-a", Key: key1, StepIndex: 1, Size: 15},
+		{Path: "repo-a/" + key2 + ".json", Kind: CacheEntryResult, Slug: "repo-a", Key: key2, StepIndex: -1, Size: 8},
+		{Path: "repo-b/" + key3 + ".json", Kind: CacheEntryResult, Slug: "repo-b", Key: key3, StepIndex: 0, Size: 25},
+	}, entries)
+
+	entries, err = ListCacheEntries(filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestPruneCache(t *testing.T) {
+	t.Run("max age", func(t *testing.T) {
+		dir := newTestCacheDir(t)
+		removed, err := PruneCache(dir, CachePruneOpts{MaxAge: 90 * time.Minute})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"repo-a/" + key1 + ".json", testExtraFile, testArchive}, cacheEntryPaths(removed))
+		assert.NoFileExists(t, filepath.Join(dir, testArchive))
+		assert.FileExists(t, filepath.Join(dir, "repo-a", key2+".json"))
+	})
+
+	t.Run("max size", func(t *testing.T) {
+		dir := newTestCacheDir(t)
+		// The archive has to go to get below 100 bytes.
+		removed, err := PruneCache(dir, CachePruneOpts{MaxSize: 99})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"repo-a/" + key1 + ".json", testExtraFile, testArchive}, cacheEntryPaths(removed))
+
+		removed, err = PruneCache(dir, CachePruneOpts{MaxSize: 30})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"repo-a/" + key2 + ".json"}, cacheEntryPaths(removed))
+		assert.NoDirExists(t, filepath.Join(dir, "repo-a"))
+		assert.FileExists(t, filepath.Join(dir, "repo-b", key3+".json"))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		dir := newTestCacheDir(t)
+		removed, err := PruneCache(dir, CachePruneOpts{MaxSize: 1, DryRun: true})
+		require.NoError(t, err)
+		assert.Len(t, removed, 5)
+		entries, err := ListCacheEntries(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 5)
+	})
+
+	t.Run("no limits", func(t *testing.T) {
+		dir := newTestCacheDir(t)
+		removed, err := PruneCache(dir, CachePruneOpts{})
+		require.NoError(t, err)
+		assert.Empty(t, removed)
+	})
+}
+
+func TestClearCacheDir(t *testing.T) {
+	dir := newTestCacheDir(t)
+	removed, err := ClearCacheDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, removed, 5)
+	assert.NoFileExists(t, filepath.Join(dir, testArchive))
+	assert.NoFileExists(t, filepath.Join(dir, testExtraFile))
+	assert.FileExists(t, filepath.Join(dir, "notes.txt"))
+	assert.FileExists(t, filepath.Join(dir, "repo-a.zip"))
+	assert.FileExists(t, filepath.Join(dir, "backup-"+testCommit))
+	assert.FileExists(t, filepath.Join(dir, "repo-b", "notes.txt"))
+	assert.FileExists(t, filepath.Join(dir, "repo-b", "package.json"))
+	assert.FileExists(t, filepath.Join(dir, "workspace-repo-a-abc-1234", key1+".json"))
+	assert.NoDirExists(t, filepath.Join(dir, "repo-a"))
+}
+
+func TestExecutionDiskCache_GetMarksEntryUsed(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	c := ExecutionDiskCache{Dir: dir}
+	key := testKeyer{key: key1, slug: "repo"}
+	require.NoError(t, c.Set(ctx, key, execution.AfterStepResult{StepIndex: 2}))
+
+	p := filepath.Join(dir, "repo", key1+".json")
+	old := time.Now().Add(-24 * time.Hour)
+	require.NoError(t, os.Chtimes(p, old, old))
+
+	_, found, err := c.Get(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	entries, err := ListCacheEntries(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, 2, entries[0].StepIndex)
+	assert.WithinDuration(t, time.Now(), entries[0].LastUsed, time.Minute)
+}
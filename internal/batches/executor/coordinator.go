@@ -2,7 +2,6 @@ package executor
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/sourcegraph/sourcegraph/lib/errors"
 
@@ -66,21 +65,23 @@ func (c *Coordinator) CheckCache(ctx context.Context, batchSpec *batcheslib.Batc
 	return uncached, specs, nil
 }
 
+// ClearCache removes the cached step results of the given Tasks.
 func (c *Coordinator) ClearCache(ctx context.Context, tasks []*Task) error {
-	fmt.Printf("tasks %d\n", len(tasks))
+	return ClearTasksCache(ctx, c.opts.Cache, tasks, c.opts.GlobalEnv, c.opts.ExecOpts.WorkingDirectory)
+}
+
+// ClearTasksCache removes the cached results of all steps of the given Tasks
+// from c. globalEnv and workingDir must be the ones the Tasks are
+// executed with, since they're part of the cache keys.
+func ClearTasksCache(ctx context.Context, c cache.Cache, tasks []*Task, globalEnv []string, workingDir string) error {
 	for _, task := range tasks {
-		fmt.Printf("task steps %d\n", len(task.Steps))
 		for i := len(task.Steps) - 1; i > -1; i-- {
-			key := task.CacheKey(c.opts.GlobalEnv, c.opts.ExecOpts.WorkingDirectory, i)
-			fmt.Printf("task cache key: %s\n", key.Slug())
-			fmt.Printf("placeholder\n")
-			if err := c.opts.Cache.Clear(ctx, key); err != nil {
+			key := task.CacheKey(globalEnv, workingDir, i)
+			if err := c.Clear(ctx, key); err != nil {
 				return errors.Wrapf(err, "clearing cache for step %d in %q", i, task.Repository.Name)
 			}
-			fmt.Printf("task cache key %s clear done\n", key.Slug())
 		}
 	}
-	fmt.Printf("Clear Cache done\n")
 	return nil
 }
 
@@ -93,6 +93,9 @@ func (c ExecutionDiskCache) Get(ctx context.Context, key cache.Keyer) (execution
 	if err != nil {
 		return result, false, err
 	}
+	if found {
+		touchCacheFile(path)
+	}
 
 	return result, found, nil
 }
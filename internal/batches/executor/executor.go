@@ -60,6 +60,8 @@ type NewExecutorOpts struct {
 	RepoArchiveRegistry repozip.ArchiveRegistry
 	EnsureImage         imageEnsurer
 	Logger              log.LogManager
+	// Runtime is the container runtime to run the steps with.
+	Runtime docker.Runtime
 
 	// Config
 	Parallelism      int
@@ -69,6 +71,7 @@ type NewExecutorOpts struct {
 	IsRemote         bool
 	GlobalEnv        []string
 	ForceRoot        bool
+	Native           bool
 
 	BinaryDiffs bool
 }
@@ -176,12 +179,14 @@ func (x *executor) do(ctx context.Context, task *Task, ui TaskExecutionUI) (err
 		Logger:           l,
 		WC:               x.opts.Creator,
 		EnsureImage:      x.opts.EnsureImage,
+		Runtime:          x.opts.Runtime,
 		TempDir:          x.opts.TempDir,
 		GlobalEnv:        x.opts.GlobalEnv,
 		Timeout:          x.opts.Timeout,
 		RepoArchive:      repoArchive,
 		WorkingDirectory: x.opts.WorkingDirectory,
 		ForceRoot:        x.opts.ForceRoot,
+		Native:           x.opts.Native,
 		BinaryDiffs:      x.opts.BinaryDiffs,
 
 		UI: ui.StepsExecutionUI(task),
@@ -376,12 +376,13 @@ func TestExecutor_Integration(t *testing.T) {
 			// Temp dir for log files and downloaded archives
 			testTempDir := t.TempDir()
 
-			cr, _ := workspace.NewCreator(context.Background(), "bind", testTempDir, testTempDir, images)
+			cr, _ := workspace.NewCreator(context.Background(), "bind", testTempDir, testTempDir, docker.RuntimeDocker, images)
 			// Setup executor
 			opts := NewExecutorOpts{
 				Creator:             cr,
 				RepoArchiveRegistry: repozip.NewArchiveRegistry(client, testTempDir, false),
 				Logger:              mock.LogNoOpManager{},
+				Runtime:             docker.RuntimeDocker,
 				EnsureImage:         imageMapEnsurer(images),
 
 				TempDir:     testTempDir,
@@ -797,12 +798,13 @@ func testExecuteTasks(t *testing.T, tasks []*Task, archives ...mock.RepoArchive)
 		}
 	}
 
-	cr, _ := workspace.NewCreator(context.Background(), "bind", testTempDir, testTempDir, images)
+	cr, _ := workspace.NewCreator(context.Background(), "bind", testTempDir, testTempDir, docker.RuntimeDocker, images)
 	// Setup executor
 	executor := NewExecutor(NewExecutorOpts{
 		Creator:             cr,
 		RepoArchiveRegistry: repozip.NewArchiveRegistry(client, testTempDir, false),
 		Logger:              mock.LogNoOpManager{},
+		Runtime:             docker.RuntimeDocker,
 		EnsureImage:         imageMapEnsurer(images),
 
 		TempDir:     testTempDir,
@@ -822,3 +824,93 @@ func imageMapEnsurer(m map[string]docker.Image) imageEnsurer {
 		return nil, errors.New(fmt.Sprintf("image for %s not found", container))
 	}
 }
+
+func TestExecutor_Native(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Test doesn't work on Windows because the steps are bash scripts")
+	}
+
+	archive := mock.RepoArchive{
+		RepoName: testRepo1.Name, Commit: testRepo1.Rev(), Files: map[string]string{
+			"README.md": "# Welcome to the README\n",
+			"a/b/c.txt": "hello\n",
+		},
+	}
+
+	testTempDir := t.TempDir()
+	mountDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(mountDir, "mounted.txt"), []byte("mounted line\n"), 0644))
+
+	mux := mock.NewZipArchivesMux(t, nil, archive)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	var clientBuffer bytes.Buffer
+	client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: &clientBuffer})
+
+	cr, typ := workspace.NewCreator(context.Background(), "native", testTempDir, testTempDir, nil, nil)
+	if typ != workspace.CreatorTypeNative {
+		t.Fatalf("wrong creator type %v", typ)
+	}
+
+	task := &Task{
+		Repository:            testRepo1,
+		Path:                  "a",
+		BatchChangeAttributes: &template.BatchChangeAttributes{},
+		Steps: []batcheslib.Step{
+			{
+				// The container is ignored when running natively.
+				Run:       `cat "$SRC_STEP_FILES/tmp/extra.txt" "$SRC_STEP_FILES/mnt/mounted.txt" >> b/c.txt`,
+				Container: "this-image-does-not-exist",
+				Files:     map[string]string{"/tmp/extra.txt": "extra line\n"},
+				Mount:     []batcheslib.Mount{{Path: "mounted.txt", Mountpoint: "/mnt/mounted.txt"}},
+			},
+			{Run: `echo "step 2 in $(basename "$PWD") for ${SRC_ACCESS_TOKEN:-nobody}" >> ../README.md`},
+		},
+	}
+
+	executor := NewExecutor(NewExecutorOpts{
+		Creator:             cr,
+		RepoArchiveRegistry: repozip.NewArchiveRegistry(client, testTempDir, false),
+		Logger:              mock.LogNoOpManager{},
+		EnsureImage: func(ctx context.Context, name string) (docker.Image, error) {
+			return nil, errors.Newf("image %q should not be used", name)
+		},
+		TempDir:          testTempDir,
+		WorkingDirectory: mountDir,
+		Parallelism:      1,
+		Timeout:          30 * time.Second,
+		// Only PATH and HOME are passed from the host.
+		GlobalEnv: []string{"PATH=" + os.Getenv("PATH"), "HOME=" + t.TempDir(), "SRC_ACCESS_TOKEN=secret"},
+		Native:    true,
+	})
+	executor.Start(context.Background(), []*Task{task}, newDummyTaskExecutionUI())
+	results, err := executor.Wait(context.Background())
+	if err != nil {
+		t.Fatalf("execution failed: %s", err)
+	}
+	if have, want := len(results), 1; have != want {
+		t.Fatalf("wrong number of results. want=%d, have=%d", want, have)
+	}
+
+	wantDiff := `diff --git README.md README.md
+index 02a19af..f9e4dba 100644
+--- README.md
++++ README.md
+@@ -1 +1,2 @@
+ # Welcome to the README
++step 2 in a for nobody
+diff --git a/b/c.txt a/b/c.txt
+index ce01362..d1031de 100644
+--- a/b/c.txt
++++ a/b/c.txt
+@@ -1 +1,3 @@
+ hello
++extra line
++mounted line
+`
+	stepResults := results[0].stepResults
+	if diff := cmp.Diff(wantDiff, string(stepResults[len(stepResults)-1].Diff)); diff != "" {
+		t.Fatalf("wrong diff (-want +have):\n%s", diff)
+	}
+}
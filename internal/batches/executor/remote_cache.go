@@ -0,0 +1,367 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/lib/batches/execution"
+	"github.com/sourcegraph/sourcegraph/lib/batches/execution/cache"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+// RemoteCacheOpts are the options of NewRemoteCache.
+type RemoteCacheOpts struct {
+	// URL is the location of the cache. It's either the http:// or https://
+	// URL of a key-value store that entries are read from with GET and
+	// written to with PUT below the URL, or the s3:// URL of a bucket and an
+	// optional key prefix in it.
+	URL string
+	// Local is the cache that entries are read from before the remote cache
+	// is asked, and that entries read from the remote cache are written to.
+	Local cache.Cache
+	// ReadOnly disables writing entries to the remote cache.
+	ReadOnly bool
+	// Compress compresses the entries that are written to the remote cache
+	// with gzip. Entries are read regardless of their compression.
+	Compress bool
+	// Client is the HTTP client to talk to the remote cache with. If nil, a
+	// client that gives up after DefaultRemoteCacheTimeout is used.
+	Client *http.Client
+	// OnError, if set, is called with the first error of the remote cache.
+	// The remote cache isn't essential, so its errors are treated like
+	// misses, and it isn't used for the rest of the run after an error.
+	OnError func(error)
+}
+
+// DefaultRemoteCacheTimeout is the time limit of requests to the remote cache,
+// if RemoteCacheOpts.Client isn't set.
+const DefaultRemoteCacheTimeout = 30 * time.Second
+
+// NewRemoteCache returns a cache that shares step results through a remote
+// store, so that the results of one user can be reused by another who runs
+// the same steps on the same commits.
+func NewRemoteCache(ctx context.Context, opts RemoteCacheOpts) (*ExecutionRemoteCache, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing cache URL")
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: DefaultRemoteCacheTimeout}
+	}
+	local := opts.Local
+	if local == nil {
+		local = ExecutionNoOpCache{}
+	}
+
+	var store remoteStore
+	switch u.Scheme {
+	case "http", "https":
+		store = &httpStore{base: u, client: client}
+	case "s3":
+		if store, err = newS3Store(ctx, u, client); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Newf("unsupported cache URL %q: expected an http://, https:// or s3:// URL", opts.URL)
+	}
+
+	onError := opts.OnError
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	return &ExecutionRemoteCache{
+		local:    local,
+		store:    store,
+		readOnly: opts.ReadOnly,
+		compress: opts.Compress,
+		onError:  onError,
+	}, nil
+}
+
+// ExecutionRemoteCache is a cache.Cache that stores step results in a remote
+// store, with a local cache in front of it.
+type ExecutionRemoteCache struct {
+	local    cache.Cache
+	store    remoteStore
+	readOnly bool
+	compress bool
+	onError  func(error)
+
+	mu     sync.Mutex
+	failed bool
+}
+
+var _ cache.Cache = &ExecutionRemoteCache{}
+
+// remoteStore is where ExecutionRemoteCache stores its entries.
+type remoteStore interface {
+	// get returns the entry with the given name, or false if there is none.
+	get(ctx context.Context, name string) ([]byte, bool, error)
+	put(ctx context.Context, name string, data []byte) error
+}
+
+// available returns false once the remote store has failed.
+func (c *ExecutionRemoteCache) available() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.failed
+}
+
+// fail stops using the remote store after err, and reports the first error.
+func (c *ExecutionRemoteCache) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.failed {
+		c.failed = true
+		c.onError(err)
+	}
+}
+
+// remoteEntryName returns the name of the entry of key in the remote store,
+// which is laid out like the directory of ExecutionDiskCache.
+func remoteEntryName(key cache.Keyer) (string, string, error) {
+	keyString, err := key.Key()
+	if err != nil {
+		return "", "", errors.Wrap(err, "calculating execution cache key")
+	}
+	return keyString, path.Join(key.Slug(), keyString+cacheFileExt), nil
+}
+
+func (c *ExecutionRemoteCache) Get(ctx context.Context, key cache.Keyer) (execution.AfterStepResult, bool, error) {
+	if result, found, err := c.local.Get(ctx, key); err != nil || found {
+		return result, found, err
+	}
+
+	var result execution.AfterStepResult
+	if !c.available() {
+		return result, false, nil
+	}
+	keyString, name, err := remoteEntryName(key)
+	if err != nil {
+		return result, false, err
+	}
+	data, found, err := c.store.get(ctx, name)
+	if err != nil {
+		c.fail(errors.Wrapf(err, "reading %s from remote cache", name))
+		return result, false, nil
+	}
+	if !found {
+		return result, false, nil
+	}
+
+	// An entry that is corrupt, or isn't the entry of this key, is treated
+	// like a missing one: the steps are run again, and the entry is replaced
+	// when their results are written to the cache.
+	raw, err := decodeRemoteEntry(data, keyString)
+	if err != nil {
+		return result, false, nil
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return result, false, nil
+	}
+
+	if err := c.local.Set(ctx, key, result); err != nil {
+		return result, false, err
+	}
+	return result, true, nil
+}
+
+func (c *ExecutionRemoteCache) Set(ctx context.Context, key cache.Keyer, result execution.AfterStepResult) error {
+	if err := c.local.Set(ctx, key, result); err != nil {
+		return err
+	}
+	if c.readOnly || !c.available() {
+		return nil
+	}
+
+	keyString, name, err := remoteEntryName(key)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(&result)
+	if err != nil {
+		return errors.Wrap(err, "serializing cache content to JSON")
+	}
+	data, err := encodeRemoteEntry(raw, keyString, c.compress)
+	if err != nil {
+		return err
+	}
+	if err := c.store.put(ctx, name, data); err != nil {
+		c.fail(errors.Wrapf(err, "writing %s to remote cache", name))
+	}
+	return nil
+}
+
+// Clear only removes the entry from the local cache, since the remote cache is
+// shared with others. The remote entry is replaced once the steps have been
+// run again, unless the cache is read-only.
+func (c *ExecutionRemoteCache) Clear(ctx context.Context, key cache.Keyer) error {
+	return c.local.Clear(ctx, key)
+}
+
+// remoteEntryVersion is the version of the format of remote cache entries.
+const remoteEntryVersion = 1
+
+// remoteEntryHeader is the first line of an entry in the remote cache. It's
+// followed by the JSON of the step result, encoded with Encoding.
+type remoteEntryHeader struct {
+	Version int `json:"version"`
+	// Key is the cache key of the entry, so that an entry that was stored
+	// under the wrong name isn't used.
+	Key string `json:"key"`
+	// Encoding is "gzip", or "identity" for uncompressed results.
+	Encoding string `json:"encoding"`
+	// SHA256 is the checksum of the JSON of the result.
+	SHA256 string `json:"sha256"`
+}
+
+func encodeRemoteEntry(raw []byte, key string, compress bool) ([]byte, error) {
+	sum := sha256.Sum256(raw)
+	header := remoteEntryHeader{
+		Version:  remoteEntryVersion,
+		Key:      key,
+		Encoding: "identity",
+		SHA256:   hex.EncodeToString(sum[:]),
+	}
+	if compress {
+		header.Encoding = "gzip"
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(&header); err != nil {
+		return nil, err
+	}
+	if !compress {
+		buf.Write(raw)
+		return buf.Bytes(), nil
+	}
+
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(raw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeRemoteEntry returns the JSON of the result in the entry, after
+// checking that it's the entry of key and that its checksum matches.
+func decodeRemoteEntry(data []byte, key string) ([]byte, error) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, errors.Wrap(err, "reading entry header")
+	}
+	var header remoteEntryHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return nil, errors.Wrap(err, "parsing entry header")
+	}
+	if header.Version != remoteEntryVersion {
+		return nil, errors.Newf("unsupported entry version %d", header.Version)
+	}
+	if header.Key != key {
+		return nil, errors.Newf("entry is for key %q, not %q", header.Key, key)
+	}
+
+	var body io.Reader = r
+	switch header.Encoding {
+	case "identity":
+	case "gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "decompressing entry")
+		}
+		defer zr.Close()
+		body = zr
+	default:
+		return nil, errors.Newf("unsupported entry encoding %q", header.Encoding)
+	}
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading entry")
+	}
+	sum := sha256.Sum256(raw)
+	if hex.EncodeToString(sum[:]) != header.SHA256 {
+		return nil, errors.New("entry checksum mismatch")
+	}
+	return raw, nil
+}
+
+// httpStore stores entries in an HTTP key-value store, below its base URL.
+// Credentials can be given as the user info of the URL.
+type httpStore struct {
+	base   *url.URL
+	client *http.Client
+}
+
+func (s *httpStore) url(name string) string {
+	u := *s.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + name
+	u.RawPath = ""
+	return u.String()
+}
+
+func (s *httpStore) do(ctx context.Context, method, name string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.url(name), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	return s.client.Do(req)
+}
+
+func (s *httpStore) get(ctx context.Context, name string) ([]byte, bool, error) {
+	resp, err := s.do(ctx, "GET", name, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if err := checkStoreResponse(resp); err != nil {
+		return nil, false, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	return data, err == nil, err
+}
+
+func (s *httpStore) put(ctx context.Context, name string, data []byte) error {
+	resp, err := s.do(ctx, "PUT", name, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStoreResponse(resp)
+}
+
+// checkStoreResponse returns an error with the body of the response if its
+// status isn't successful.
+func checkStoreResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return errors.New(strings.TrimSpace(fmt.Sprintf("unexpected HTTP status %d: %s", resp.StatusCode, body)))
+}
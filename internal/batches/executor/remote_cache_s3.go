@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+// s3Store stores entries in an S3-compatible bucket. It's configured with a
+// URL like s3://bucket/prefix?region=eu-west-1&endpoint=https://minio:9000,
+// where the prefix, region and endpoint are optional. Credentials and the
+// default region are taken from the usual AWS environment variables and
+// configuration files.
+//
+// Requests use path-style addressing, which every S3-compatible store
+// supports.
+type s3Store struct {
+	endpoint    *url.URL
+	bucket      string
+	prefix      string
+	region      string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+}
+
+// emptyPayloadHash is the SHA-256 of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func newS3Store(ctx context.Context, u *url.URL, client *http.Client) (*s3Store, error) {
+	if u.Host == "" {
+		return nil, errors.Newf("cache URL %q has no bucket", u.String())
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading AWS configuration")
+	}
+
+	query := u.Query()
+	region := query.Get("region")
+	if region == "" {
+		region = cfg.Region
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	endpoint := query.Get("endpoint")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil || endpointURL.Host == "" {
+		return nil, errors.Newf("invalid S3 endpoint %q", endpoint)
+	}
+
+	return &s3Store{
+		endpoint:    endpointURL,
+		bucket:      u.Host,
+		prefix:      strings.Trim(u.Path, "/"),
+		region:      region,
+		credentials: cfg.Credentials,
+		signer:      v4.NewSigner(),
+		client:      client,
+	}, nil
+}
+
+func (s *s3Store) url(name string) string {
+	u := *s.endpoint
+	key := name
+	if s.prefix != "" {
+		key = s.prefix + "/" + name
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + key
+	u.RawPath = ""
+	return u.String()
+}
+
+func (s *s3Store) do(ctx context.Context, method, name string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.url(name), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	payloadHash := emptyPayloadHash
+	if body != nil {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	if s.credentials == nil {
+		return nil, errors.New("no AWS credentials found")
+	}
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "retrieving AWS credentials")
+	}
+	if err := s.signer.SignHTTP(ctx, creds, req, payloadHash, "s3", s.region, time.Now()); err != nil {
+		return nil, errors.Wrap(err, "signing request")
+	}
+	return s.client.Do(req)
+}
+
+func (s *s3Store) get(ctx context.Context, name string) ([]byte, bool, error) {
+	resp, err := s.do(ctx, "GET", name, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	// Without the s3:ListBucket permission, S3 answers 403 rather than 404
+	// for keys that don't exist, so it can't tell a missing entry from one
+	// that can't be read. Both are treated as misses; writes still fail if
+	// the credentials are wrong.
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusForbidden {
+		return nil, false, nil
+	}
+	if err := checkStoreResponse(resp); err != nil {
+		return nil, false, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	return data, err == nil, err
+}
+
+func (s *s3Store) put(ctx context.Context, name string, data []byte) error {
+	resp, err := s.do(ctx, "PUT", name, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkStoreResponse(resp)
+}
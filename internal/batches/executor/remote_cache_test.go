@@ -0,0 +1,335 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sourcegraph/sourcegraph/lib/batches/execution"
+	"github.com/sourcegraph/sourcegraph/lib/batches/git"
+)
+
+type testKeyer struct{ key, slug string }
+
+func (k testKeyer) Key() (string, error) { return k.key, nil }
+func (k testKeyer) Slug() string         { return k.slug }
+
+// fakeKVStore is an HTTP key-value store that records the requests to it.
+type fakeKVStore struct {
+	mu       sync.Mutex
+	entries  map[string][]byte
+	requests []string
+	// check, if set, is called with every request.
+	check func(r *http.Request, body []byte)
+	// missingStatus, if set, is the status of GET requests for missing
+	// entries instead of 404.
+	missingStatus int
+}
+
+func newFakeKVStore(t *testing.T) (*fakeKVStore, *httptest.Server) {
+	store := &fakeKVStore{entries: map[string][]byte{}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		store.requests = append(store.requests, r.Method+" "+r.URL.Path)
+		if store.check != nil {
+			store.check(r, body)
+		}
+
+		switch r.Method {
+		case "GET":
+			data, ok := store.entries[r.URL.Path]
+			if !ok {
+				if store.missingStatus != 0 {
+					http.Error(w, http.StatusText(store.missingStatus), store.missingStatus)
+					return
+				}
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		case "PUT":
+			store.entries[r.URL.Path] = body
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return store, ts
+}
+
+func (s *fakeKVStore) takeRequests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := s.requests
+	s.requests = nil
+	return requests
+}
+
+var remoteCacheValue = execution.AfterStepResult{
+	Version:      2,
+	Diff:         testDiff,
+	ChangedFiles: git.Changes{Added: []string{"README.md"}},
+	Outputs:      map[string]interface{}{"greeting": "hello"},
+}
+
+func TestExecutionRemoteCache(t *testing.T) {
+	ctx := context.Background()
+	key := testKeyer{key: "abc123", slug: "github.com-sourcegraph-src-cli"}
+	const entryPath = "/cache/github.com-sourcegraph-src-cli/abc123.json"
+
+	newCache := func(t *testing.T, url string, readOnly, compress bool) (*ExecutionRemoteCache, string) {
+		dir := t.TempDir()
+		c, err := NewRemoteCache(ctx, RemoteCacheOpts{
+			URL:      url,
+			Local:    &ExecutionDiskCache{Dir: dir},
+			ReadOnly: readOnly,
+			Compress: compress,
+		})
+		require.NoError(t, err)
+		return c, dir
+	}
+
+	for _, compress := range []bool{true, false} {
+		t.Run(map[bool]string{true: "compressed", false: "uncompressed"}[compress], func(t *testing.T) {
+			store, ts := newFakeKVStore(t)
+
+			writer, _ := newCache(t, ts.URL+"/cache/", false, compress)
+			_, found, err := writer.Get(ctx, key)
+			require.NoError(t, err)
+			assert.False(t, found)
+			require.NoError(t, writer.Set(ctx, key, remoteCacheValue))
+			assert.Equal(t, []string{"GET " + entryPath, "PUT " + entryPath}, store.takeRequests())
+
+			wantEncoding := map[bool]string{true: `"encoding":"gzip"`, false: `"encoding":"identity"`}[compress]
+			assert.Contains(t, string(store.entries[entryPath]), wantEncoding)
+
+			// Another user reads the entry through their local cache.
+			reader, dir := newCache(t, ts.URL+"/cache", true, !compress)
+			result, found, err := reader.Get(ctx, key)
+			require.NoError(t, err)
+			assert.True(t, found)
+			if diff := cmp.Diff(remoteCacheValue, result); diff != "" {
+				t.Errorf("unexpected result (-want +have):\n%s", diff)
+			}
+			assert.FileExists(t, filepath.Join(dir, key.slug, key.key+cacheFileExt))
+			assert.Equal(t, []string{"GET " + entryPath}, store.takeRequests())
+
+			_, found, err = reader.Get(ctx, key)
+			require.NoError(t, err)
+			assert.True(t, found)
+			assert.Empty(t, store.takeRequests(), "expected the local cache to be used")
+		})
+	}
+
+	t.Run("read-only", func(t *testing.T) {
+		store, ts := newFakeKVStore(t)
+		c, dir := newCache(t, ts.URL+"/cache", true, true)
+
+		require.NoError(t, c.Set(ctx, key, remoteCacheValue))
+		assert.FileExists(t, filepath.Join(dir, key.slug, key.key+cacheFileExt))
+		require.NoError(t, c.Clear(ctx, key))
+		assert.NoFileExists(t, filepath.Join(dir, key.slug, key.key+cacheFileExt))
+		assert.Empty(t, store.takeRequests())
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		store, ts := newFakeKVStore(t)
+		c, dir := newCache(t, ts.URL+"/cache", false, true)
+
+		require.NoError(t, c.Set(ctx, key, remoteCacheValue))
+		require.NoError(t, c.Clear(ctx, key))
+		assert.NoFileExists(t, filepath.Join(dir, key.slug, key.key+cacheFileExt))
+		assert.Contains(t, store.entries, entryPath, "expected the shared entry to be kept")
+		assert.Equal(t, []string{"PUT " + entryPath}, store.takeRequests())
+	})
+
+	t.Run("corrupt entry", func(t *testing.T) {
+		store, ts := newFakeKVStore(t)
+		writer, _ := newCache(t, ts.URL+"/cache", false, false)
+		require.NoError(t, writer.Set(ctx, key, remoteCacheValue))
+		store.entries[entryPath] = bytes.Replace(store.entries[entryPath], []byte("README"), []byte("READYOU"), 1)
+
+		reader, dir := newCache(t, ts.URL+"/cache", false, false)
+		_, found, err := reader.Get(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, found)
+		assert.NoFileExists(t, filepath.Join(dir, key.slug, key.key+cacheFileExt))
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "broken", http.StatusInternalServerError)
+		}))
+		t.Cleanup(ts.Close)
+		var errs []error
+		dir := t.TempDir()
+		c, err := NewRemoteCache(ctx, RemoteCacheOpts{
+			URL:     ts.URL,
+			Local:   &ExecutionDiskCache{Dir: dir},
+			OnError: func(err error) { errs = append(errs, err) },
+		})
+		require.NoError(t, err)
+
+		_, found, err := c.Get(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, found)
+		require.NoError(t, c.Set(ctx, key, remoteCacheValue))
+		assert.FileExists(t, filepath.Join(dir, key.slug, key.key+cacheFileExt))
+
+		require.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], "unexpected HTTP status 500: broken")
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		store, ts := newFakeKVStore(t)
+		store.check = func(r *http.Request, _ []byte) {
+			if r.Method == "PUT" {
+				panic(http.ErrAbortHandler)
+			}
+		}
+		var errs []error
+		c, err := NewRemoteCache(ctx, RemoteCacheOpts{
+			URL:     ts.URL + "/cache",
+			Local:   &ExecutionDiskCache{Dir: t.TempDir()},
+			OnError: func(err error) { errs = append(errs, err) },
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, c.Set(ctx, key, remoteCacheValue))
+		result, found, err := c.Get(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, found, "expected the result to be kept in the local cache")
+		assert.Equal(t, remoteCacheValue.Diff, result.Diff)
+
+		_, found, err = c.Get(ctx, testKeyer{key: "def456", slug: key.slug})
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		require.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], "writing "+strings.TrimPrefix(entryPath, "/cache/")+" to remote cache")
+		assert.Equal(t, []string{"PUT " + entryPath}, store.takeRequests(), "expected the remote cache not to be used after an error")
+	})
+
+	t.Run("credentials in URL", func(t *testing.T) {
+		store, ts := newFakeKVStore(t)
+		store.check = func(r *http.Request, _ []byte) {
+			user, password, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, "ci", user)
+			assert.Equal(t, "s3cr3t", password)
+		}
+		c, _ := newCache(t, strings.Replace(ts.URL, "http://", "http://ci:s3cr3t@", 1)+"/cache", false, true)
+
+		require.NoError(t, c.Set(ctx, key, remoteCacheValue))
+		assert.Len(t, store.takeRequests(), 1)
+	})
+}
+
+func TestExecutionRemoteCache_S3(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	store, ts := newFakeKVStore(t)
+	store.check = func(r *http.Request, body []byte) {
+		sum := sha256.Sum256(body)
+		assert.Equal(t, hex.EncodeToString(sum[:]), r.Header.Get("X-Amz-Content-Sha256"))
+		auth := r.Header.Get("Authorization")
+		assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/"), auth)
+		assert.Contains(t, auth, "/eu-west-1/s3/aws4_request")
+	}
+
+	c, err := NewRemoteCache(ctx, RemoteCacheOpts{
+		URL:      "s3://batches/team/?region=eu-west-1&endpoint=" + ts.URL,
+		Compress: true,
+	})
+	require.NoError(t, err)
+
+	key := testKeyer{key: "abc123", slug: "repo"}
+	require.NoError(t, c.Set(ctx, key, remoteCacheValue))
+	result, found, err := c.Get(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, remoteCacheValue.Diff, result.Diff)
+
+	assert.Equal(t, []string{
+		"PUT /batches/team/repo/abc123.json",
+		"GET /batches/team/repo/abc123.json",
+	}, store.takeRequests())
+
+	t.Run("missing entry without list permission", func(t *testing.T) {
+		// S3 answers 403 for missing keys if the credentials can't list
+		// the bucket.
+		store, ts := newFakeKVStore(t)
+		store.missingStatus = http.StatusForbidden
+		var errs []error
+		c, err := NewRemoteCache(ctx, RemoteCacheOpts{
+			URL:     "s3://batches/team/?region=eu-west-1&endpoint=" + ts.URL,
+			Local:   &ExecutionDiskCache{Dir: t.TempDir()},
+			OnError: func(err error) { errs = append(errs, err) },
+		})
+		require.NoError(t, err)
+
+		_, found, err := c.Get(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, found)
+		require.NoError(t, c.Set(ctx, key, remoteCacheValue))
+		_, found, err = c.Get(ctx, testKeyer{key: "def456", slug: key.slug})
+		require.NoError(t, err)
+		assert.False(t, found)
+
+		assert.Empty(t, errs)
+		assert.Equal(t, []string{
+			"GET /batches/team/repo/abc123.json",
+			"PUT /batches/team/repo/abc123.json",
+			"GET /batches/team/repo/def456.json",
+		}, store.takeRequests(), "expected the remote cache to be used after a miss")
+	})
+}
+
+func TestNewRemoteCache_InvalidURL(t *testing.T) {
+	for _, u := range []string{"ftp://example.com/cache", "/tmp/cache", "s3:///prefix"} {
+		_, err := NewRemoteCache(context.Background(), RemoteCacheOpts{URL: u})
+		assert.Error(t, err, u)
+	}
+}
+
+func TestDecodeRemoteEntry(t *testing.T) {
+	raw := []byte(`{"version":2}`)
+	entry, err := encodeRemoteEntry(raw, "key", true)
+	require.NoError(t, err)
+
+	have, err := decodeRemoteEntry(entry, "key")
+	require.NoError(t, err)
+	assert.Equal(t, raw, have)
+
+	_, err = decodeRemoteEntry(entry, "other")
+	assert.ErrorContains(t, err, `entry is for key "key", not "other"`)
+
+	entry, err = encodeRemoteEntry(raw, "key", false)
+	require.NoError(t, err)
+	_, err = decodeRemoteEntry(bytes.Replace(entry, []byte("2}"), []byte("3}"), 1), "key")
+	assert.ErrorContains(t, err, "entry checksum mismatch")
+
+	_, err = decodeRemoteEntry(bytes.Replace(entry, []byte(`"version":1`), []byte(`"version":9`), 1), "key")
+	assert.ErrorContains(t, err, "unsupported entry version 9")
+
+	_, err = decodeRemoteEntry([]byte("garbage"), "key")
+	assert.Error(t, err)
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/sourcegraph/sourcegraph/lib/batches/template"
 	"github.com/sourcegraph/sourcegraph/lib/errors"
 
+	"github.com/sourcegraph/src-cli/internal/batches/docker"
 	"github.com/sourcegraph/src-cli/internal/batches/log"
 	"github.com/sourcegraph/src-cli/internal/batches/repozip"
 	"github.com/sourcegraph/src-cli/internal/batches/util"
@@ -35,6 +36,9 @@ type RunStepsOpts struct {
 	// EnsureImage is called in runSteps to make sure the used image has been
 	// pulled from the registry.
 	EnsureImage imageEnsurer
+	// Runtime is the container runtime to run the steps with. It's not used
+	// if Native is set.
+	Runtime docker.Runtime
 	// Task is the definition of the workspace execution.
 	Task *Task
 	// TempDir points to where temporary files of the execution should live at.
@@ -54,6 +58,10 @@ type RunStepsOpts struct {
 	// ForceRoot forces Docker containers to be run as root:root, rather than
 	// whatever the image's default user and group are.
 	ForceRoot bool
+	// Native runs the steps with a shell on the host, rather than in Docker
+	// containers. The step containers are ignored, and WC must create
+	// workspaces on the host FS.
+	Native bool
 
 	BinaryDiffs bool
 }
@@ -164,17 +172,26 @@ func RunSteps(ctx context.Context, opts *RunStepsOpts) (stepResults []execution.
 			continue
 		}
 
-		// We need to grab the digest for the exact image we're using.
-		img, err := opts.EnsureImage(ctx, step.Container)
-		if err != nil {
-			return nil, err
-		}
-		digest, err := img.Digest(ctx)
-		if err != nil {
-			return nil, err
+		// We need to grab the digest for the exact image we're using. Native
+		// steps don't run in an image.
+		var digest string
+		if !opts.Native {
+			img, err := opts.EnsureImage(ctx, step.Container)
+			if err != nil {
+				return nil, err
+			}
+			digest, err = img.Digest(ctx)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		stdoutBuffer, stderrBuffer, err := executeSingleStep(ctx, opts, ws, i, step, digest, &stepContext)
+		var stdoutBuffer, stderrBuffer bytes.Buffer
+		if opts.Native {
+			stdoutBuffer, stderrBuffer, err = executeSingleNativeStep(ctx, opts, ws, i, step, &stepContext)
+		} else {
+			stdoutBuffer, stderrBuffer, err = executeSingleStep(ctx, opts, ws, i, step, digest, &stepContext)
+		}
 		defer func() {
 			if err != nil {
 				exitCode := -1
@@ -258,15 +275,22 @@ func executeSingleStep(
 	// ----------
 	opts.UI.StepPreparingStart(stepIdx + 1)
 
-	cidFile, cleanup, err := createCidFile(ctx, opts.TempDir, util.SlugForRepo(opts.Task.Repository.Name, opts.Task.Repository.Rev()))
-	if err != nil {
-		opts.UI.StepPreparingFailed(stepIdx+1, err)
-		return bytes.Buffer{}, bytes.Buffer{}, err
+	// The Engine API removes the container itself, without a cidfile.
+	engine := opts.Runtime.Engine()
+	var cidArgs []string
+	if engine == nil {
+		cidFile, cleanup, err := createCidFile(ctx, opts.Runtime, opts.TempDir, util.SlugForRepo(opts.Task.Repository.Name, opts.Task.Repository.Rev()))
+		if err != nil {
+			opts.UI.StepPreparingFailed(stepIdx+1, err)
+			return bytes.Buffer{}, bytes.Buffer{}, err
+		}
+		defer cleanup()
+		cidArgs = []string{"--cidfile", cidFile}
 	}
-	defer cleanup()
 
 	// For now, we only support shell scripts provided via the Run field.
-	shell, containerTemp, err := probeImageForShell(ctx, imageDigest)
+	imageRef := opts.Runtime.ImageRef(step.Container, imageDigest)
+	shell, containerTemp, err := probeImageForShell(ctx, opts.Runtime, imageRef)
 	if err != nil {
 		err = errors.Wrapf(err, "probing image %q for shell", step.Container)
 		opts.UI.StepPreparingFailed(stepIdx+1, err)
@@ -288,18 +312,8 @@ func executeSingleStep(
 	}
 	defer cleanup()
 
-	// Resolve step.Env given the current environment.
-	stepEnv, err := step.Env.Resolve(opts.GlobalEnv)
-	if err != nil {
-		err = errors.Wrap(err, "resolving step environment")
-		opts.UI.StepPreparingFailed(stepIdx+1, err)
-		return bytes.Buffer{}, bytes.Buffer{}, err
-	}
-
-	// Render the step.Env variables as templates.
-	env, err := template.RenderStepMap(stepEnv, stepContext)
+	env, err := renderStepEnv(opts.GlobalEnv, step, stepContext)
 	if err != nil {
-		err = errors.Wrap(err, "parsing step environment")
 		opts.UI.StepPreparingFailed(stepIdx+1, err)
 		return bytes.Buffer{}, bytes.Buffer{}, err
 	}
@@ -322,17 +336,27 @@ func executeSingleStep(
 		scriptWorkDir = workDir + "/" + opts.Task.Path
 	}
 
-	args := append([]string{
-		"run",
-		"--rm",
-		"--init",
-		"--cidfile", cidFile,
+	args := append([]string{"run", "--rm", "--init"}, cidArgs...)
+	args = append(args,
 		"--workdir", scriptWorkDir,
 		"--mount", fmt.Sprintf("type=bind,source=%s,target=%s", runScriptFile, containerTemp),
-	}, workspaceOpts...)
+	)
+	args = append(args, workspaceOpts...)
 
 	if opts.ForceRoot {
-		args = append(args, "--user", "0:0")
+		args = append(args, opts.Runtime.UserArgs(docker.Root)...)
+	} else if workspace.WorkDir() != nil {
+		// The workspace is bind mounted, so the files that the step creates
+		// in it should be owned by the user running src.
+		image, err := opts.EnsureImage(ctx, step.Container)
+		if err != nil {
+			return bytes.Buffer{}, bytes.Buffer{}, err
+		}
+		userArgs, err := opts.Runtime.BindMountUserArgs(ctx, image)
+		if err != nil {
+			return bytes.Buffer{}, bytes.Buffer{}, errors.Wrapf(err, "determining user of image %q", step.Container)
+		}
+		args = append(args, userArgs...)
 	}
 
 	for target, source := range filesToMount {
@@ -352,14 +376,60 @@ func executeSingleStep(
 		args = append(args, "-e", k+"="+v)
 	}
 
-	args = append(args, "--entrypoint", shell)
+	args = append(args, "--entrypoint", shell, "--", imageRef, containerTemp)
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	cmd.Args = append(cmd.Args, "--", imageDigest, containerTemp)
+	what := opts.Runtime.Name() + " container"
+	if engine != nil {
+		run := func(stdout, stderr io.Writer) error {
+			return engine.Run(ctx, args, stdout, stderr)
+		}
+		return runStepCommand(ctx, opts, stepIdx, step, append([]string{opts.Runtime.Name()}, args...), run, runScript, containerTemp, what)
+	}
+
+	cmd := opts.Runtime.Command(ctx, args...)
 	if dir := workspace.WorkDir(); dir != nil {
 		cmd.Dir = *dir
 	}
 
+	return runStepCommand(ctx, opts, stepIdx, step, cmd.Args, commandRunner(ctx, cmd), runScript, containerTemp, what)
+}
+
+// commandRunner returns a function that runs cmd, and pipes its output into
+// the given writers.
+func commandRunner(ctx context.Context, cmd *exec.Cmd) func(stdout, stderr io.Writer) error {
+	return func(stdout, stderr io.Writer) error {
+		// Setup readers that pipe the output into the given buffers
+		wg, err := process.PipeOutput(ctx, cmd, stdout, stderr)
+		if err != nil {
+			return errors.Wrap(err, "piping process output")
+		}
+
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+
+		// Wait for the readers, because the pipes used by PipeOutput under the
+		// hood are closed when the command exits.
+		wg.Wait()
+
+		return cmd.Wait()
+	}
+}
+
+// runStepCommand runs the command of a step with run, and pipes its output into
+// the UI and the log. args are the arguments of the command, and what
+// describes it in the log.
+func runStepCommand(
+	ctx context.Context,
+	opts *RunStepsOpts,
+	stepIdx int,
+	step batcheslib.Step,
+	args []string,
+	run func(stdout, stderr io.Writer) error,
+	runScript string,
+	tmpFilename string,
+	what string,
+) (stdout bytes.Buffer, stderr bytes.Buffer, err error) {
 	writerCtx, writerCancel := context.WithCancel(ctx)
 	defer writerCancel()
 	outputWriter := opts.UI.StepOutputWriter(writerCtx, opts.Task, stepIdx+1)
@@ -370,49 +440,33 @@ func executeSingleStep(
 	stdoutWriter := io.MultiWriter(&stdout, outputWriter.StdoutWriter(), opts.Logger.PrefixWriter("stdout"))
 	stderrWriter := io.MultiWriter(&stderr, outputWriter.StderrWriter(), opts.Logger.PrefixWriter("stderr"))
 
-	// Setup readers that pipe the output into the given buffers
-	wg, err := process.PipeOutput(ctx, cmd, stdoutWriter, stderrWriter)
-	if err != nil {
-		return stdout, stderr, errors.Wrap(err, "piping process output")
-	}
-
 	newStepFailedErr := func(wrappedErr error) stepFailedErr {
 		exitCode := -1
-		exitErr := &exec.ExitError{}
+		// Both *exec.ExitError and *docker.ExitError have exit codes.
+		var exitErr interface{ ExitCode() int }
 		if errors.As(wrappedErr, &exitErr) {
 			exitCode = exitErr.ExitCode()
 		}
 		return stepFailedErr{
 			Err:         wrappedErr,
 			ExitCode:    exitCode,
-			Args:        cmd.Args,
+			Args:        args,
 			Run:         runScript,
 			Container:   step.Container,
-			TmpFilename: containerTemp,
+			TmpFilename: tmpFilename,
 			Stdout:      strings.TrimSpace(stdout.String()),
 			Stderr:      strings.TrimSpace(stderr.String()),
 		}
 	}
 
 	opts.Logger.Logf("[Step %d] run: %q, container: %q", stepIdx+1, step.Run, step.Container)
-	opts.Logger.Logf("[Step %d] full command: %q", stepIdx+1, strings.Join(cmd.Args, " "))
+	opts.Logger.Logf("[Step %d] full command: %q", stepIdx+1, strings.Join(args, " "))
 
-	// Start the command.
 	t0 := time.Now()
-	if err := cmd.Start(); err != nil {
-		opts.Logger.Logf("[Step %d] error starting Docker container: %+v", stepIdx+1, err)
-		return stdout, stderr, newStepFailedErr(err)
-	}
-
-	// Wait for the readers, because the pipes used by PipeOutput under the
-	// hood are closed when the command exits.
-	wg.Wait()
-
-	// Now wait for the command.
-	err = cmd.Wait()
+	err = run(stdoutWriter, stderrWriter)
 	elapsed := time.Since(t0).Round(time.Millisecond)
 	if err != nil {
-		opts.Logger.Logf("[Step %d] took %s; error running Docker container: %+v", stepIdx+1, elapsed, err)
+		opts.Logger.Logf("[Step %d] took %s; error running %s: %+v", stepIdx+1, elapsed, what, err)
 		return stdout, stderr, newStepFailedErr(err)
 	}
 
@@ -420,6 +474,23 @@ func executeSingleStep(
 	return stdout, stderr, nil
 }
 
+// renderStepEnv resolves step.Env given the global environment, and renders
+// the variables as templates.
+func renderStepEnv(globalEnv []string, step batcheslib.Step, stepContext *template.StepContext) (map[string]string, error) {
+	// Resolve step.Env given the current environment.
+	stepEnv, err := step.Env.Resolve(globalEnv)
+	if err != nil {
+		return nil, errors.Wrap(err, "resolving step environment")
+	}
+
+	// Render the step.Env variables as templates.
+	env, err := template.RenderStepMap(stepEnv, stepContext)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing step environment")
+	}
+	return env, nil
+}
+
 func setOutputs(stepOutputs batcheslib.Outputs, global map[string]interface{}, stepCtx *template.StepContext) error {
 	for name, output := range stepOutputs {
 		var value bytes.Buffer
@@ -453,7 +524,7 @@ func setOutputs(stepOutputs batcheslib.Outputs, global map[string]interface{}, s
 	return nil
 }
 
-func probeImageForShell(ctx context.Context, image string) (shell, tempfile string, err error) {
+func probeImageForShell(ctx context.Context, runtime docker.Runtime, image string) (shell, tempfile string, err error) {
 	// We need to know two things to be able to run a shell script:
 	//
 	// 1. Which shell is available. We're going to look for /bin/bash and then
@@ -475,11 +546,17 @@ func probeImageForShell(ctx context.Context, image string) (shell, tempfile stri
 
 		args := []string{"run", "--rm", "--entrypoint", shell, image, "-c", "mktemp"}
 
-		cmd := exec.CommandContext(ctx, "docker", args...)
-		cmd.Stdout = stdout
-		cmd.Stderr = stderr
+		var runErr error
+		if engine := runtime.Engine(); engine != nil {
+			runErr = engine.Run(ctx, args, stdout, stderr)
+		} else {
+			cmd := runtime.Command(ctx, args...)
+			cmd.Stdout = stdout
+			cmd.Stderr = stderr
+			runErr = cmd.Run()
+		}
 
-		if runErr := cmd.Run(); runErr != nil {
+		if runErr != nil {
 			err = errors.Append(err, errors.Wrapf(runErr, "probing shell %q:\n%s", shell, stderr.String()))
 		} else {
 			// Even if there were previous errors, we can now ignore them.
@@ -578,7 +655,7 @@ func createRunScriptFile(ctx context.Context, tempDir string, stepRun string, st
 // when executing steps.
 // It returns the location of the file and a function that cleans up the
 // file.
-func createCidFile(ctx context.Context, tempDir string, repoSlug string) (string, func(), error) {
+func createCidFile(ctx context.Context, runtime docker.Runtime, tempDir string, repoSlug string) (string, func(), error) {
 	// Find a location that we can use for a cidfile, which will contain the
 	// container ID that is used below. We can then use this to remove the
 	// container on a successful run, rather than leaving it dangling.
@@ -603,7 +680,7 @@ func createCidFile(ctx context.Context, tempDir string, repoSlug string) (string
 		if err == nil {
 			ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 			defer cancel()
-			_ = exec.CommandContext(ctx, "docker", "rm", "-f", "--", string(cid)).Run()
+			_ = runtime.Command(ctx, "rm", "-f", "--", string(cid)).Run()
 		}
 	}
 
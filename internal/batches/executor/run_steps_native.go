@@ -0,0 +1,182 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/batches/template"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
+	"github.com/sourcegraph/src-cli/internal/batches/workspace"
+)
+
+// stepFilesEnvVar is the environment variable that points native steps to the
+// directory that the step files and mounts are in.
+const stepFilesEnvVar = "SRC_STEP_FILES"
+
+// executeSingleNativeStep is the counterpart of executeSingleStep for steps
+// that are run with a shell on the host, rather than in a Docker container.
+//
+// The run script is run in the workspace directory with the step environment,
+// and PATH and HOME from the global one. The step container is ignored: the tools that the script uses
+// must be installed on the host. Files and mounts can't be placed at their
+// paths in a container, so they are made available at the same paths below
+// the directory in $SRC_STEP_FILES instead.
+func executeSingleNativeStep(
+	ctx context.Context,
+	opts *RunStepsOpts,
+	workspace workspace.Workspace,
+	stepIdx int,
+	step batcheslib.Step,
+	stepContext *template.StepContext,
+) (stdout bytes.Buffer, stderr bytes.Buffer, err error) {
+	// ----------
+	// PREPARATION
+	// ----------
+	opts.UI.StepPreparingStart(stepIdx + 1)
+
+	shell, err := findNativeShell()
+	if err != nil {
+		opts.UI.StepPreparingFailed(stepIdx+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+
+	runScriptFile, runScript, cleanup, err := createRunScriptFile(ctx, opts.TempDir, step.Run, stepContext)
+	if err != nil {
+		opts.UI.StepPreparingFailed(stepIdx+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+	defer cleanup()
+
+	// Parse and render the step.Files.
+	filesToMount, cleanup, err := createFilesToMount(opts.TempDir, step, stepContext)
+	if err != nil {
+		opts.UI.StepPreparingFailed(stepIdx+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+	defer cleanup()
+
+	stepFilesDir, cleanup, err := createStepFilesDir(opts, step, filesToMount)
+	if err != nil {
+		opts.UI.StepPreparingFailed(stepIdx+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+	defer cleanup()
+
+	env, err := renderStepEnv(opts.GlobalEnv, step, stepContext)
+	if err != nil {
+		opts.UI.StepPreparingFailed(stepIdx+1, err)
+		return bytes.Buffer{}, bytes.Buffer{}, err
+	}
+
+	opts.UI.StepPreparingSuccess(stepIdx + 1)
+
+	// ----------
+	// EXECUTION
+	// ----------
+	opts.UI.StepStarted(stepIdx+1, runScript, env)
+
+	dir := workspace.WorkDir()
+	if dir == nil {
+		return bytes.Buffer{}, bytes.Buffer{}, errors.New("workspace has no directory on the host to run the step in")
+	}
+
+	cmd := exec.CommandContext(ctx, shell, runScriptFile)
+	// Where should we execute the steps.run script?
+	cmd.Dir = filepath.Join(*dir, filepath.FromSlash(opts.Task.Path))
+	// Like in a container, the step only gets the environment it asks for,
+	// so that secrets such as SRC_ACCESS_TOKEN don't leak into it. The shell
+	// and the tools it runs still need to be found, though. Later values take
+	// precedence, so the step environment overrides the host one.
+	cmd.Env = nativeStepHostEnv(opts.GlobalEnv)
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Env = append(cmd.Env, stepFilesEnvVar+"="+stepFilesDir)
+
+	if step.Container != "" {
+		opts.Logger.Logf("[Step %d] running on the host, ignoring container %q", stepIdx+1, step.Container)
+	}
+
+	return runStepCommand(ctx, opts, stepIdx, step, cmd.Args, commandRunner(ctx, cmd), runScript, runScriptFile, "step script")
+}
+
+// findNativeShell returns the path of the shell to run native steps with,
+// preferring bash over sh like probeImageForShell does.
+func findNativeShell() (string, error) {
+	for _, shell := range []string{"bash", "sh"} {
+		if path, err := exec.LookPath(shell); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.New("neither bash nor sh found in $PATH, which are required to run steps natively")
+}
+
+// nativeStepHostEnvVars are the variables of the global environment that are
+// passed to native steps, on top of the step environment.
+var nativeStepHostEnvVars = []string{"PATH", "HOME"}
+
+// nativeStepHostEnv returns the variables of globalEnv that are passed to
+// native steps.
+func nativeStepHostEnv(globalEnv []string) []string {
+	var env []string
+	for _, kv := range globalEnv {
+		k, _, _ := strings.Cut(kv, "=")
+		for _, name := range nativeStepHostEnvVars {
+			if k == name {
+				env = append(env, kv)
+			}
+		}
+	}
+	return env
+}
+
+// createStepFilesDir creates a temporary directory with symlinks to the step
+// files and mounts, at their target paths relative to the directory.
+//
+// It returns the location of the directory and a function that removes it.
+func createStepFilesDir(opts *RunStepsOpts, step batcheslib.Step, filesToMount map[string]*os.File) (string, func(), error) {
+	dir, err := os.MkdirTemp(opts.TempDir, "step-files-")
+	if err != nil {
+		return "", nil, errors.Wrap(err, "creating step files directory")
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	link := func(source, target string) error {
+		dest := filepath.Join(dir, filepath.FromSlash(target))
+		if !strings.HasPrefix(dest, dir+string(filepath.Separator)) {
+			return errors.Newf("path %q is outside of the step files directory", target)
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return errors.Wrapf(err, "creating directory for %q", target)
+		}
+		return errors.Wrapf(os.Symlink(source, dest), "linking %q", target)
+	}
+
+	for target, source := range filesToMount {
+		if err := link(source.Name(), target); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+
+	// The paths have already been validated during parsing.
+	for _, mount := range step.Mount {
+		source, err := getAbsoluteMountPath(opts.WorkingDirectory, mount.Path)
+		if err != nil {
+			cleanup()
+			return "", nil, err
+		}
+		if err := link(strings.TrimSuffix(source, string(filepath.Separator)), mount.Mountpoint); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+	}
+
+	return dir, cleanup, nil
+}
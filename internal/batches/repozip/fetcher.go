@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/sourcegraph/sourcegraph/lib/errors"
 
@@ -267,7 +268,9 @@ func (rz *repoArchive) fetchArchiveAndFiles(ctx context.Context) (err error) {
 		return err
 	}
 
-	if !exists {
+	if exists {
+		touchFile(rz.zipPath)
+	} else {
 		// Unlike the mkdirAll() calls elsewhere in this file, this is only
 		// giving us a temporary place on the filesystem to keep the archive.
 		// Since it's never mounted into the containers being run, we can keep
@@ -292,6 +295,7 @@ func (rz *repoArchive) fetchArchiveAndFiles(ctx context.Context) (err error) {
 		}
 
 		if exists {
+			touchFile(addFile.localPath)
 			addFile.fetched = true
 			continue
 		}
@@ -308,6 +312,14 @@ func (rz *repoArchive) fetchArchiveAndFiles(ctx context.Context) (err error) {
 	return nil
 }
 
+// FetchFile fetches a single file of the repository at the given revision from
+// Sourcegraph's raw endpoint, and atomically writes it to dest, whose directory
+// must exist. It returns false if the file doesn't exist. If the revision has
+// no commit, the file is fetched from the default branch.
+func FetchFile(ctx context.Context, client HTTPClient, repo RepoRevision, pathInRepo string, dest string) (bool, error) {
+	return fetchRepositoryFile(ctx, client, repo, pathInRepo, dest)
+}
+
 // fetchRepositoryInFile fetches the given `pathInRepo` using the Sourcegraph's
 // raw endpoint and writes it to `dest`.
 // If `pathInRepo` is empty and `dest` ends in `.zip` a ZIP archive of the
@@ -362,7 +374,11 @@ func fetchRepositoryFile(ctx context.Context, client HTTPClient, repo RepoRevisi
 }
 
 func repositoryRawFileEndpoint(repo RepoRevision, pathInRepo string) string {
-	p := path.Join(repo.RepoName+"@"+repo.Commit, "-", "raw")
+	rev := repo.RepoName
+	if repo.Commit != "" {
+		rev += "@" + repo.Commit
+	}
+	p := path.Join(rev, "-", "raw")
 	if pathInRepo != "" {
 		p = path.Join(p, pathInRepo)
 	}
@@ -379,3 +395,11 @@ func fileExists(path string) (bool, error) {
 	}
 	return true, nil
 }
+
+// touchFile updates the modification time of a cached file when it's reused,
+// so that pruning the cache directory removes the files that haven't been
+// used for the longest time first.
+func touchFile(path string) {
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+}
@@ -54,4 +54,6 @@ type ExecUI interface {
 	UploadingWorkspaceFilesSuccess()
 
 	DockerWatchDogWarning(error)
+	RemoteCacheWarning(error)
+	CachePruneWarning(error)
 }
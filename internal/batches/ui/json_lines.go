@@ -64,6 +64,8 @@ func (ui *JSONLines) DeterminingWorkspaceCreatorTypeSuccess(wt workspace.Creator
 		t = "VOLUME"
 	case workspace.CreatorTypeBind:
 		t = "BIND"
+	case workspace.CreatorTypeNative:
+		t = "NATIVE"
 	}
 	logOperationSuccess(batcheslib.LogEventOperationDeterminingWorkspaceType, &batcheslib.DeterminingWorkspaceTypeMetadata{Type: t})
 }
@@ -185,6 +187,16 @@ Error: %s
 	logOperationFailure(batcheslib.LogEventOperationDockerWatchDog, &batcheslib.DockerWatchDogMetadata{Error: message})
 }
 
+func (ui *JSONLines) RemoteCacheWarning(err error) {
+	// There's no log event for this, and stdout only has log events.
+	fmt.Fprintf(os.Stderr, "warning: not using the remote cache: %s\n", err)
+}
+
+func (ui *JSONLines) CachePruneWarning(err error) {
+	// There's no log event for this, and stdout only has log events.
+	fmt.Fprintf(os.Stderr, "warning: pruning the cache failed: %s\n", err)
+}
+
 type taskExecutionJSONLines struct {
 	linesTasks  map[*executor.Task]batcheslib.JSONLinesTask
 	binaryDiffs bool
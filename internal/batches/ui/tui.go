@@ -92,6 +92,8 @@ func (ui *TUI) DeterminingWorkspaceCreatorTypeSuccess(wt workspace.CreatorType)
 		ui.pending.VerboseLine(output.Linef("🚧", output.StyleSuccess, "Workspace creator: bind"))
 	case workspace.CreatorTypeVolume:
 		ui.pending.VerboseLine(output.Linef("🚧", output.StyleSuccess, "Workspace creator: volume"))
+	case workspace.CreatorTypeNative:
+		ui.pending.VerboseLine(output.Linef("🚧", output.StyleSuccess, "Workspace creator: native"))
 	}
 
 	batchCompletePending(ui.pending, "Set workspace type")
@@ -225,6 +227,17 @@ func (ui *TUI) DockerWatchDogWarning(err error) {
 	dockerWatchDogWarning(ui.Out, err)
 }
 
+func (ui *TUI) RemoteCacheWarning(err error) {
+	block := ui.Out.Block(output.Line(output.EmojiWarning, output.StyleWarning, "The remote cache can't be used, so steps are run again unless their results are cached locally."))
+	block.WriteLine(output.Linef("", output.StyleWarning, "Error: %s", err.Error()))
+	block.Write("")
+	block.Close()
+}
+
+func (ui *TUI) CachePruneWarning(err error) {
+	ui.Out.WriteLine(output.Linef(output.EmojiWarning, output.StyleWarning, "Pruning the cache failed: %s", err.Error()))
+}
+
 func (ui *TUI) PreviewBatchSpec(batchSpecURL string) {
 	ui.Out.Write("")
 	block := ui.Out.Block(output.Line(batchSuccessEmoji, batchSuccessColor, "To preview or apply the batch spec, go to:"))
@@ -0,0 +1,41 @@
+package workspace
+
+import (
+	"context"
+
+	batcheslib "github.com/sourcegraph/sourcegraph/lib/batches"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
+	"github.com/sourcegraph/src-cli/internal/batches/graphql"
+	"github.com/sourcegraph/src-cli/internal/batches/repozip"
+)
+
+type nativeWorkspaceCreator struct {
+	Dir string
+}
+
+var _ Creator = &nativeWorkspaceCreator{}
+
+func (wc *nativeWorkspaceCreator) Create(ctx context.Context, repo *graphql.Repository, steps []batcheslib.Step, archive repozip.Archive) (Workspace, error) {
+	// A native workspace is set up just like a bind workspace: the repository
+	// is unzipped into a directory on the host FS, which is turned into a git
+	// repository to compute the diff from.
+	w, err := (&dockerBindWorkspaceCreator{Dir: wc.Dir}).Create(ctx, repo, steps, archive)
+	if err != nil {
+		return nil, err
+	}
+	return &nativeWorkspace{Workspace: w}, nil
+}
+
+// nativeWorkspace implements a workspace on the host FS for steps that are run
+// with a shell on the host, rather than in Docker containers. WorkDir returns
+// the directory that the steps are run in.
+type nativeWorkspace struct {
+	Workspace
+}
+
+var _ Workspace = &nativeWorkspace{}
+
+func (w *nativeWorkspace) DockerRunOpts(ctx context.Context, target string) ([]string, error) {
+	return nil, errors.New("native workspaces can't be used with Docker")
+}
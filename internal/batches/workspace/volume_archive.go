@@ -0,0 +1,161 @@
+package workspace
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
+	"github.com/sourcegraph/src-cli/internal/batches/docker"
+)
+
+// copyArchiveIntoVolume copies the tar archive that write writes into the
+// workspace volume with the Engine API. Since the entries of the archive are
+// owned by the workspace user, this replaces the containers that unzip the
+// repository and copy the additional files with a single request.
+func (w *dockerVolumeWorkspace) copyArchiveIntoVolume(ctx context.Context, engine *docker.Engine, write func(tw *tar.Writer) error) error {
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := write(tw)
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	// Unblock the writer if the request fails before the archive is read.
+	defer pr.Close()
+
+	return engine.CopyToVolume(ctx, DockerVolumeWorkspaceImage, w.volume, "/work", pr)
+}
+
+// writeZipAsTar writes the contents of the zip archive at zipPath to tw, owned
+// by ug. The archive starts with the root directory, so that the directory
+// the archive is extracted into is owned by ug as well.
+func writeZipAsTar(tw *tar.Writer, zipPath string, ug docker.UIDGID) error {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return errors.Wrap(err, "opening archive")
+	}
+	defer zr.Close()
+
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     "./",
+		Mode:     0755,
+		Uid:      ug.UID,
+		Gid:      ug.GID,
+	}); err != nil {
+		return err
+	}
+
+	for _, f := range zr.File {
+		if err := writeZipFileAsTar(tw, f, ug); err != nil {
+			return errors.Wrapf(err, "copying %q", f.Name)
+		}
+	}
+	return nil
+}
+
+func writeZipFileAsTar(tw *tar.Writer, f *zip.File, ug docker.UIDGID) error {
+	mode := f.Mode()
+	hdr := &tar.Header{
+		Name: path.Clean(f.Name),
+		// Archives that weren't created on a unix system have no
+		// permissions, and are given 0666 by archive/zip. Like unzip, we
+		// apply the default umask.
+		Mode:    int64(mode.Perm() &^ 0022),
+		Uid:     ug.UID,
+		Gid:     ug.GID,
+		ModTime: f.Modified,
+	}
+
+	switch {
+	case mode.IsDir():
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+		return tw.WriteHeader(hdr)
+
+	case mode&fs.ModeSymlink != 0:
+		target, err := readZipFile(f)
+		if err != nil {
+			return err
+		}
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = string(target)
+		return tw.WriteHeader(hdr)
+
+	default:
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = int64(f.UncompressedSize64)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		_, err = io.Copy(tw, rc)
+		return err
+	}
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// writeFilesAsTar writes the files to tw, owned by ug. files maps the paths in
+// the archive to the paths of the files on the host.
+func writeFilesAsTar(tw *tar.Writer, files map[string]string, ug docker.UIDGID) error {
+	// We sort these so our tests don't break. Sorry.
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := writeFileAsTar(tw, name, files[name], ug); err != nil {
+			return errors.Wrapf(err, "copying %q", name)
+		}
+	}
+	return nil
+}
+
+func writeFileAsTar(tw *tar.Writer, name, localPath string, ug docker.UIDGID) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(info.Mode().Perm()),
+		Size:     info.Size(),
+		Uid:      ug.UID,
+		Gid:      ug.GID,
+		ModTime:  info.ModTime(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,137 @@
+package workspace
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/batches/docker"
+)
+
+type tarEntry struct {
+	Name     string
+	Typeflag byte
+	Mode     int64
+	Uid, Gid int
+	Linkname string
+	Body     string
+}
+
+func readTarEntries(t *testing.T, data []byte) []tarEntry {
+	t.Helper()
+
+	var entries []tarEntry
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, tarEntry{
+			Name:     hdr.Name,
+			Typeflag: hdr.Typeflag,
+			Mode:     hdr.Mode,
+			Uid:      hdr.Uid,
+			Gid:      hdr.Gid,
+			Linkname: hdr.Linkname,
+			Body:     string(body),
+		})
+	}
+}
+
+func TestWriteZipAsTar(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "repo-zip-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for _, file := range []struct {
+		name string
+		mode fs.FileMode
+		body string
+	}{
+		{name: "dir/", mode: fs.ModeDir | 0750},
+		{name: "dir/run.sh", mode: 0755, body: "#!/bin/sh\n"},
+		{name: "README.md", body: "# Hello\n"}, // No unix permissions.
+		{name: "link", mode: fs.ModeSymlink | 0777, body: "README.md"},
+	} {
+		hdr := &zip.FileHeader{Name: file.name, Method: zip.Deflate}
+		if file.mode != 0 {
+			hdr.SetMode(file.mode)
+		}
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(file.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := writeZipAsTar(tw, f.Name(), docker.UIDGID{UID: 1000, GID: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []tarEntry{
+		{Name: "./", Typeflag: tar.TypeDir, Mode: 0755, Uid: 1000, Gid: 100},
+		{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0750, Uid: 1000, Gid: 100},
+		{Name: "dir/run.sh", Typeflag: tar.TypeReg, Mode: 0755, Uid: 1000, Gid: 100, Body: "#!/bin/sh\n"},
+		{Name: "README.md", Typeflag: tar.TypeReg, Mode: 0644, Uid: 1000, Gid: 100, Body: "# Hello\n"},
+		{Name: "link", Typeflag: tar.TypeSymlink, Mode: 0755, Uid: 1000, Gid: 100, Linkname: "README.md"},
+	}
+	if diff := cmp.Diff(want, readTarEntries(t, buf.Bytes())); diff != "" {
+		t.Errorf("unexpected archive (-want +have):\n%s", diff)
+	}
+}
+
+func TestWriteFilesAsTar(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{"a": "first", "b": "second"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	files := map[string]string{
+		".gitignore":     filepath.Join(dir, "b"),
+		".gitattributes": filepath.Join(dir, "a"),
+	}
+	if err := writeFilesAsTar(tw, files, docker.UIDGID{UID: 1, GID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []tarEntry{
+		{Name: ".gitattributes", Typeflag: tar.TypeReg, Mode: 0600, Uid: 1, Gid: 2, Body: "first"},
+		{Name: ".gitignore", Typeflag: tar.TypeReg, Mode: 0600, Uid: 1, Gid: 2, Body: "second"},
+	}
+	if diff := cmp.Diff(want, readTarEntries(t, buf.Bytes())); diff != "" {
+		t.Errorf("unexpected archive (-want +have):\n%s", diff)
+	}
+}
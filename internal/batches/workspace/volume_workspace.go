@@ -1,6 +1,7 @@
 package workspace
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
 	"crypto/rand"
@@ -17,7 +18,6 @@ import (
 	"github.com/sourcegraph/src-cli/internal/batches/docker"
 	"github.com/sourcegraph/src-cli/internal/batches/graphql"
 	"github.com/sourcegraph/src-cli/internal/batches/repozip"
-	"github.com/sourcegraph/src-cli/internal/exec"
 	"github.com/sourcegraph/src-cli/internal/version"
 )
 
@@ -25,6 +25,7 @@ type imageEnsurer func(ctx context.Context, image string) (docker.Image, error)
 
 type dockerVolumeWorkspaceCreator struct {
 	tempDir     string
+	runtime     docker.Runtime
 	EnsureImage imageEnsurer
 }
 
@@ -32,14 +33,14 @@ var _ Creator = &dockerVolumeWorkspaceCreator{}
 
 func (wc *dockerVolumeWorkspaceCreator) Create(ctx context.Context, repo *graphql.Repository,
 	steps []batcheslib.Step, archive repozip.Archive) (ws Workspace, err error) {
-	volume, err := wc.createVolume(ctx)
+	volume, err := wc.runtime.CreateVolume(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "creating Docker volume")
+		return nil, errors.Wrapf(err, "creating %s volume", wc.runtime.Name())
 	}
 
 	defer func() {
 		if err != nil {
-			deleteVolume(ctx, volume)
+			wc.runtime.RemoveVolume(ctx, volume)
 		}
 	}()
 
@@ -57,6 +58,7 @@ func (wc *dockerVolumeWorkspaceCreator) Create(ctx context.Context, repo *graphq
 
 	w := &dockerVolumeWorkspace{
 		tempDir: wc.tempDir,
+		runtime: wc.runtime,
 		volume:  volume,
 		uidGid:  ug,
 	}
@@ -71,19 +73,6 @@ func (wc *dockerVolumeWorkspaceCreator) Create(ctx context.Context, repo *graphq
 	return w, errors.Wrap(wc.prepareGitRepo(ctx, w), "preparing local git repo")
 }
 
-func (*dockerVolumeWorkspaceCreator) createVolume(ctx context.Context) (string, error) {
-	out, err := exec.CommandContext(ctx, "docker", "volume", "create").CombinedOutput()
-	if err != nil {
-		return "", err
-	}
-
-	return string(bytes.TrimSpace(out)), nil
-}
-
-func deleteVolume(ctx context.Context, volume string) error {
-	return exec.CommandContext(ctx, "docker", "volume", "rm", volume).Run()
-}
-
 func (*dockerVolumeWorkspaceCreator) prepareGitRepo(ctx context.Context, w *dockerVolumeWorkspace) error {
 	script := `#!/bin/sh
 	
@@ -110,6 +99,12 @@ git commit --quiet --all --allow-empty -m src-action-exec
 }
 
 func (wc *dockerVolumeWorkspaceCreator) unzipRepoIntoVolume(ctx context.Context, w *dockerVolumeWorkspace, zip string) error {
+	if engine := w.runtime.Engine(); engine != nil {
+		return w.copyArchiveIntoVolume(ctx, engine, func(tw *tar.Writer) error {
+			return writeZipAsTar(tw, zip, w.uidGid)
+		})
+	}
+
 	// We want to mount that temporary file into a Docker container that has the
 	// workspace volume attached, and unzip it into the volume.
 
@@ -147,7 +142,7 @@ func (wc *dockerVolumeWorkspaceCreator) unzipRepoIntoVolume(ctx context.Context,
 		fmt.Sprintf("touch /work/%s; chown -R %s /work", dummy, w.uidGid.String()),
 	)
 
-	if out, err := exec.CommandContext(ctx, "docker", opts...).CombinedOutput(); err != nil {
+	if out, err := w.run(ctx, opts); err != nil {
 		return errors.Wrapf(err, "chown output:\n\n%s\n\n", string(out))
 	}
 
@@ -166,7 +161,7 @@ func (wc *dockerVolumeWorkspaceCreator) unzipRepoIntoVolume(ctx context.Context,
 		fmt.Sprintf("unzip /tmp/zip; rm /work/%s", dummy),
 	)
 
-	if out, err := exec.CommandContext(ctx, "docker", opts...).CombinedOutput(); err != nil {
+	if out, err := w.run(ctx, opts); err != nil {
 		return errors.Wrapf(err, "unzip output:\n\n%s\n\n", string(out))
 	}
 
@@ -178,6 +173,12 @@ func (wc *dockerVolumeWorkspaceCreator) copyFilesIntoVolumes(ctx context.Context
 		return nil
 	}
 
+	if engine := w.runtime.Engine(); engine != nil {
+		return w.copyArchiveIntoVolume(ctx, engine, func(tw *tar.Writer) error {
+			return writeFilesAsTar(tw, files, w.uidGid)
+		})
+	}
+
 	opts := append([]string{
 		"run",
 		"--rm",
@@ -209,7 +210,7 @@ func (wc *dockerVolumeWorkspaceCreator) copyFilesIntoVolumes(ctx context.Context
 		strings.Join(copyCmds, " && ")+";",
 	)
 
-	if out, err := exec.CommandContext(ctx, "docker", opts...).CombinedOutput(); err != nil {
+	if out, err := w.run(ctx, opts); err != nil {
 		return errors.Wrapf(err, "unzip output:\n\n%s\n\n", string(out))
 	}
 	return nil
@@ -221,6 +222,7 @@ func (wc *dockerVolumeWorkspaceCreator) copyFilesIntoVolumes(ctx context.Context
 // debugging harder and is slower when it's time to actually retrieve the diff.
 type dockerVolumeWorkspace struct {
 	tempDir string
+	runtime docker.Runtime
 	volume  string
 	uidGid  docker.UIDGID
 }
@@ -229,7 +231,7 @@ var _ Workspace = &dockerVolumeWorkspace{}
 
 func (w *dockerVolumeWorkspace) Close(ctx context.Context) error {
 	// Cleanup here is easy: we just get rid of the Docker volume.
-	return deleteVolume(ctx, w.volume)
+	return w.runtime.RemoveVolume(ctx, w.volume)
 }
 
 func (w *dockerVolumeWorkspace) DockerRunOpts(ctx context.Context, target string) ([]string, error) {
@@ -336,7 +338,7 @@ func (w *dockerVolumeWorkspace) runScript(ctx context.Context, target, script st
 	}, common...)
 	opts = append(opts, DockerVolumeWorkspaceImage, "sh", "/run.sh")
 
-	out, err := exec.CommandContext(ctx, "docker", opts...).CombinedOutput()
+	out, err := w.run(ctx, opts)
 	if err != nil {
 		return out, errors.Wrapf(err, "Docker output:\n\n%s\n\n", string(out))
 	}
@@ -344,9 +346,20 @@ func (w *dockerVolumeWorkspace) runScript(ctx context.Context, target, script st
 	return out, nil
 }
 
-func (w *dockerVolumeWorkspace) dockerRunOptsWithUser(ug docker.UIDGID, target string) []string {
-	return []string{
-		"--user", ug.String(),
-		"--mount", "type=volume,source=" + w.volume + ",target=" + target,
+// run runs a container with the given `run` arguments, and returns its combined
+// output.
+func (w *dockerVolumeWorkspace) run(ctx context.Context, args []string) ([]byte, error) {
+	if engine := w.runtime.Engine(); engine != nil {
+		var out bytes.Buffer
+		err := engine.Run(ctx, args, &out, &out)
+		return out.Bytes(), err
 	}
+	return w.runtime.Command(ctx, args...).CombinedOutput()
+}
+
+func (w *dockerVolumeWorkspace) dockerRunOptsWithUser(ug docker.UIDGID, target string) []string {
+	return append(
+		w.runtime.UserArgs(ug),
+		"--mount", "type=volume,source="+w.volume+",target="+target,
+	)
 }
@@ -49,7 +49,7 @@ func TestVolumeWorkspaceCreator(t *testing.T) {
 		archiveWithAdditionalFiles.mockAdditionalFilePaths[name] = path
 	}
 
-	wc := &dockerVolumeWorkspaceCreator{}
+	wc := &dockerVolumeWorkspaceCreator{runtime: docker.RuntimeDocker}
 	// We'll set up a fake repository with just enough fields defined for init()
 	// and friends.
 	repo := &graphql.Repository{
@@ -387,7 +387,7 @@ func TestVolumeWorkspaceCreator(t *testing.T) {
 
 func TestVolumeWorkspace_Close(t *testing.T) {
 	ctx := context.Background()
-	w := &dockerVolumeWorkspace{volume: volumeID}
+	w := &dockerVolumeWorkspace{runtime: docker.RuntimeDocker, volume: volumeID}
 
 	t.Run("success", func(t *testing.T) {
 		expect.Commands(
@@ -421,8 +421,9 @@ func TestVolumeWorkspace_Close(t *testing.T) {
 func TestVolumeWorkspace_DockerRunOpts(t *testing.T) {
 	ctx := context.Background()
 	w := &dockerVolumeWorkspace{
-		volume: "VOLUME",
-		uidGid: docker.UIDGID{UID: 1, GID: 2},
+		runtime: docker.RuntimeDocker,
+		volume:  "VOLUME",
+		uidGid:  docker.UIDGID{UID: 1, GID: 2},
 	}
 
 	want := []string{
@@ -446,7 +447,7 @@ func TestVolumeWorkspace_WorkDir(t *testing.T) {
 
 func TestVolumeWorkspace_Diff(t *testing.T) {
 	ctx := context.Background()
-	w := &dockerVolumeWorkspace{volume: volumeID}
+	w := &dockerVolumeWorkspace{runtime: docker.RuntimeDocker, volume: volumeID}
 
 	t.Run("success", func(t *testing.T) {
 		for name, tc := range map[string]string{
@@ -517,7 +518,7 @@ index 06471f4..5f9d3fa 100644
 
 func TestVolumeWorkspace_ApplyDiff(t *testing.T) {
 	ctx := context.Background()
-	w := &dockerVolumeWorkspace{volume: volumeID}
+	w := &dockerVolumeWorkspace{runtime: docker.RuntimeDocker, volume: volumeID}
 
 	expect.Commands(
 		t,
@@ -544,7 +545,7 @@ func TestVolumeWorkspace_runScript(t *testing.T) {
 	// the temporary script file correct?
 	const script = "#!/bin/sh\n\necho FOO"
 	ctx := context.Background()
-	w := &dockerVolumeWorkspace{volume: volumeID}
+	w := &dockerVolumeWorkspace{runtime: docker.RuntimeDocker, volume: volumeID}
 
 	expect.Commands(
 		t,
@@ -52,9 +52,16 @@ type CreatorType int
 const (
 	CreatorTypeBind CreatorType = iota
 	CreatorTypeVolume
+	// CreatorTypeNative workspaces are used to run steps with a shell on the
+	// host, rather than in Docker containers.
+	CreatorTypeNative
 )
 
-func NewCreator(ctx context.Context, preference, cacheDir, tempDir string, images map[string]docker.Image) (Creator, CreatorType) {
+func NewCreator(ctx context.Context, preference, cacheDir, tempDir string, runtime docker.Runtime, images map[string]docker.Image) (Creator, CreatorType) {
+	if preference == "native" {
+		return &nativeWorkspaceCreator{Dir: cacheDir}, CreatorTypeNative
+	}
+
 	var workspaceType CreatorType
 	if preference == "volume" {
 		workspaceType = CreatorTypeVolume
@@ -72,7 +79,7 @@ func NewCreator(ctx context.Context, preference, cacheDir, tempDir string, image
 			}
 			return img, nil
 		}
-		return &dockerVolumeWorkspaceCreator{tempDir: tempDir, EnsureImage: ensureImage}, workspaceType
+		return &dockerVolumeWorkspaceCreator{tempDir: tempDir, runtime: runtime, EnsureImage: ensureImage}, workspaceType
 	}
 
 	return &dockerBindWorkspaceCreator{Dir: cacheDir}, workspaceType
@@ -0,0 +1,200 @@
+// Package bulk runs an operation on each record of an input file, with bounded
+// parallelism and rate limiting, so that commands that act on a single item
+// can be used for many.
+package bulk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/conc/pool"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+	"golang.org/x/time/rate"
+)
+
+// Func runs the operation on a single item.
+type Func func(ctx context.Context, item Item) error
+
+// Options configure Run.
+type Options struct {
+	// Concurrency is the maximum number of items processed at once. It
+	// defaults to 1.
+	Concurrency int
+	// Rate is the maximum number of items started per second. Zero means no
+	// limit.
+	Rate float64
+
+	// ResumeFile is the path of a file that records the items that were
+	// processed successfully. Items that it already lists are skipped, so a
+	// run can be resumed after a failure or interruption.
+	ResumeFile string
+	// DryRun is true if the items are not actually changed, in which case the
+	// resume file is read, but not written.
+	DryRun bool
+
+	// Out receives a line for each item that was processed.
+	Out io.Writer
+}
+
+// Report summarizes a run.
+type Report struct {
+	Total     int       `json:"total"`
+	Succeeded int       `json:"succeeded"`
+	Failed    int       `json:"failed"`
+	Skipped   int       `json:"skipped"`
+	Failures  []Failure `json:"failures"`
+}
+
+// Failure is an item that couldn't be processed.
+type Failure struct {
+	Line   int               `json:"line"`
+	Fields map[string]string `json:"fields"`
+	Error  string            `json:"error"`
+}
+
+// WriteFile writes the report as JSON to the file at path.
+func (r *Report) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(os.WriteFile(path, append(data, '\n'), 0o644), "writing report")
+}
+
+// Summary returns a one-line summary of the report, such as
+// "10 items: 8 succeeded, 1 failed, 1 skipped in 2s".
+func (r *Report) Summary(elapsed time.Duration) string {
+	noun := "items"
+	if r.Total == 1 {
+		noun = "item"
+	}
+	return fmt.Sprintf("%d %s: %d succeeded, %d failed, %d skipped in %s", r.Total, noun, r.Succeeded, r.Failed, r.Skipped, elapsed.Round(time.Millisecond))
+}
+
+// Run calls fn for each item, and returns a report of the results. The error is
+// only non-nil if the run couldn't be started or the resume file couldn't be
+// written; failures of individual items are in the report.
+func Run(ctx context.Context, items []Item, opts Options, fn Func) (*Report, error) {
+	if opts.Out == nil {
+		opts.Out = io.Discard
+	}
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	if opts.Rate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.Rate), 1)
+	}
+
+	state, err := openState(opts.ResumeFile, opts.DryRun)
+	if err != nil {
+		return nil, err
+	}
+	defer state.Close()
+
+	var (
+		mu     sync.Mutex
+		report = &Report{Total: len(items), Failures: []Failure{}}
+	)
+	// Results are printed as the items finish, so a record's line number is
+	// included to relate it to the input.
+	result := func(item Item, itemErr error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if itemErr != nil {
+			report.Failed++
+			report.Failures = append(report.Failures, Failure{Line: item.Line, Fields: item.Fields, Error: itemErr.Error()})
+			fmt.Fprintf(opts.Out, "failed   line %d: %s: %s\n", item.Line, item, itemErr)
+			return nil
+		}
+		report.Succeeded++
+		fmt.Fprintf(opts.Out, "ok       line %d: %s\n", item.Line, item)
+		return state.record(item)
+	}
+
+	p := pool.New().WithErrors().WithMaxGoroutines(concurrency)
+	for _, item := range items {
+		item := item
+		if state.done(item) {
+			report.Skipped++
+			fmt.Fprintf(opts.Out, "skipped  line %d: %s\n", item.Line, item)
+			continue
+		}
+		p.Go(func() error {
+			if err := limiter.Wait(ctx); err != nil {
+				return result(item, err)
+			}
+			return result(item, fn(ctx, item))
+		})
+	}
+	err = p.Wait()
+	sort.Slice(report.Failures, func(i, j int) bool { return report.Failures[i].Line < report.Failures[j].Line })
+	return report, err
+}
+
+// state is the resume file of a run: a line of JSON for each item that was
+// processed successfully.
+type state struct {
+	completed map[string]bool
+	file      *os.File
+	writer    *bufio.Writer
+}
+
+func openState(path string, readOnly bool) (*state, error) {
+	s := &state{completed: map[string]bool{}}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "reading resume file")
+	}
+	items, err := readNDJSON(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading resume file %s", path)
+	}
+	for _, item := range items {
+		s.completed[item.key()] = true
+	}
+
+	if readOnly {
+		return s, nil
+	}
+	if s.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644); err != nil {
+		return nil, errors.Wrap(err, "opening resume file")
+	}
+	s.writer = bufio.NewWriter(s.file)
+	return s, nil
+}
+
+func (s *state) done(item Item) bool {
+	return s.completed[item.key()]
+}
+
+// record adds the item to the resume file. The file is flushed after every
+// item, so that it's up to date if the run is interrupted.
+func (s *state) record(item Item) error {
+	if s.writer == nil {
+		return nil
+	}
+	if _, err := fmt.Fprintln(s.writer, item.key()); err != nil {
+		return errors.Wrap(err, "writing resume file")
+	}
+	return errors.Wrap(s.writer.Flush(), "writing resume file")
+}
+
+func (s *state) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
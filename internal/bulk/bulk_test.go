@@ -0,0 +1,160 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+func TestReadItems(t *testing.T) {
+	for name, tc := range map[string]struct {
+		input   string
+		format  Format
+		want    []Item
+		wantErr string
+	}{
+		"lines": {
+			input: "VXNlcjox\n\n# a comment\n  VXNlcjoy  \n",
+			want: []Item{
+				{Line: 1, Fields: map[string]string{"id": "VXNlcjox"}},
+				{Line: 4, Fields: map[string]string{"id": "VXNlcjoy"}},
+			},
+		},
+		"csv": {
+			input: "ID, Tag\nVXNlcjox,foo\n\nVXNlcjoy,\n",
+			want: []Item{
+				{Line: 2, Fields: map[string]string{"id": "VXNlcjox", "tag": "foo"}},
+				{Line: 4, Fields: map[string]string{"id": "VXNlcjoy"}},
+			},
+		},
+		"single column csv": {
+			input:  "id\nVXNlcjox\n",
+			format: CSV,
+			want:   []Item{{Line: 2, Fields: map[string]string{"id": "VXNlcjox"}}},
+		},
+		"ndjson": {
+			input: `{"id": "VXNlcjox", "tag": null}` + "\n\n" + `{"ID": 12, "tag": true}`,
+			want: []Item{
+				{Line: 1, Fields: map[string]string{"id": "VXNlcjox"}},
+				{Line: 3, Fields: map[string]string{"id": "12", "tag": "true"}},
+			},
+		},
+		"empty": {input: "\n\n"},
+		"unknown field": {
+			input:   "id,name\nVXNlcjox,alice\n",
+			wantErr: `line 2: unknown field "name", expected one of id, tag`,
+		},
+		"nested json": {
+			input:   `{"id": {"nested": true}}`,
+			wantErr: `line 1: field "id" must be a string, number or boolean`,
+		},
+		"invalid json": {
+			input:   `{"id": `,
+			wantErr: "line 1",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			format := tc.format
+			if format == "" {
+				format = Auto
+			}
+			have, err := ReadItems(strings.NewReader(tc.input), format, "id", "tag")
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("unexpected items (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	items, err := ReadItems(strings.NewReader("a\nb\nc\nd\n"), Lines, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumeFile := filepath.Join(t.TempDir(), "resume.ndjson")
+	ctx := context.Background()
+
+	var (
+		mu        sync.Mutex
+		processed []string
+	)
+	fail := map[string]bool{"b": true, "d": true}
+	fn := func(ctx context.Context, item Item) error {
+		name := item.Get("name", "")
+		mu.Lock()
+		processed = append(processed, name)
+		mu.Unlock()
+		if fail[name] {
+			return errors.Newf("%s failed", name)
+		}
+		return nil
+	}
+
+	var out bytes.Buffer
+	report, err := Run(ctx, items, Options{Concurrency: 3, ResumeFile: resumeFile, Out: &out}, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Report{
+		Total:     4,
+		Succeeded: 2,
+		Failed:    2,
+		Failures: []Failure{
+			{Line: 2, Fields: map[string]string{"name": "b"}, Error: "b failed"},
+			{Line: 4, Fields: map[string]string{"name": "d"}, Error: "d failed"},
+		},
+	}
+	if diff := cmp.Diff(want, report); diff != "" {
+		t.Errorf("unexpected report (-want +have):\n%s", diff)
+	}
+	if !strings.Contains(out.String(), "failed   line 2: name=b: b failed\n") {
+		t.Errorf("failure not printed:\n%s", out.String())
+	}
+
+	// Resuming only retries the items that failed.
+	processed = nil
+	delete(fail, "b")
+	report, err = Run(ctx, items, Options{ResumeFile: resumeFile}, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"b", "d"}, processed); diff != "" {
+		t.Errorf("unexpected items processed (-want +have):\n%s", diff)
+	}
+	if report.Skipped != 2 || report.Succeeded != 1 || report.Failed != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+
+	// Dry runs don't record anything.
+	before, err := os.ReadFile(resumeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(fail, "d")
+	if _, err := Run(ctx, items, Options{ResumeFile: resumeFile, DryRun: true}, fn); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.ReadFile(resumeFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("resume file was modified in a dry run:\n%s", after)
+	}
+}
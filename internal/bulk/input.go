@@ -0,0 +1,240 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+// Format is the format of the input of a bulk operation.
+type Format string
+
+const (
+	// Auto detects the format from the first line of the input: NDJSON if it
+	// starts with "{", CSV if it contains a comma, and Lines otherwise.
+	Auto Format = "auto"
+	// Lines is one value per line, for the default field of the command.
+	Lines Format = "lines"
+	// CSV is comma-separated values, with a header row of field names.
+	CSV Format = "csv"
+	// NDJSON is one JSON object per line.
+	NDJSON Format = "ndjson"
+)
+
+// Formats are the supported input formats, in the order they are documented.
+var Formats = []Format{Auto, Lines, CSV, NDJSON}
+
+// ParseFormat returns the format of the given name.
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(name, string(f)) {
+			return f, nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return "", errors.Newf("unknown input format %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// Item is a record of the input of a bulk operation.
+type Item struct {
+	// Line is the line number of the record in the input.
+	Line int
+	// Fields are the values of the record, by field name. Empty CSV cells and
+	// null JSON values are omitted.
+	Fields map[string]string
+}
+
+// Get returns the value of the field, or def if the record has none.
+func (i Item) Get(name, def string) string {
+	if v, ok := i.Fields[name]; ok {
+		return v
+	}
+	return def
+}
+
+// Has returns true if the record has a value for the field.
+func (i Item) Has(name string) bool {
+	_, ok := i.Fields[name]
+	return ok
+}
+
+// String returns the fields of the item as name=value pairs.
+func (i Item) String() string {
+	names := make([]string, 0, len(i.Fields))
+	for name := range i.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for j, name := range names {
+		pairs[j] = name + "=" + i.Fields[name]
+	}
+	return strings.Join(pairs, " ")
+}
+
+// key identifies the item in resume files. Items with the same fields have the
+// same key, regardless of where they are in the input.
+func (i Item) key() string {
+	// Map keys are sorted when encoded, so this is stable.
+	data, _ := json.Marshal(i.Fields)
+	return string(data)
+}
+
+// ReadItems reads the records of the input in the given format. fields are the
+// names of the fields the command accepts; the first is the field that is set
+// by the Lines format. Blank lines, and lines starting with "#" in the Lines
+// format, are skipped.
+func ReadItems(r io.Reader, format Format, fields ...string) ([]Item, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("no fields given")
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading input")
+	}
+
+	if format == Auto || format == "" {
+		format = detectFormat(data)
+	}
+
+	var items []Item
+	switch format {
+	case Lines:
+		items, err = readLines(data, fields[0])
+	case CSV:
+		items, err = readCSV(data)
+	case NDJSON:
+		items, err = readNDJSON(data)
+	default:
+		return nil, errors.Newf("unknown input format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		for name := range item.Fields {
+			if !contains(fields, name) {
+				return nil, errors.Newf("line %d: unknown field %q, expected one of %s", item.Line, name, strings.Join(fields, ", "))
+			}
+		}
+	}
+	return items, nil
+}
+
+func detectFormat(data []byte) Format {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		switch {
+		case line[0] == '{':
+			return NDJSON
+		case bytes.ContainsRune(line, ','):
+			return CSV
+		default:
+			return Lines
+		}
+	}
+	return Lines
+}
+
+func readLines(data []byte, field string) ([]Item, error) {
+	var items []Item
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items = append(items, Item{Line: n, Fields: map[string]string{field: line}})
+	}
+	return items, errors.Wrap(scanner.Err(), "reading input")
+}
+
+func readCSV(data []byte) ([]Item, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading CSV header")
+	}
+	for i, name := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+
+	var items []Item
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return items, nil
+		} else if err != nil {
+			return nil, errors.Wrap(err, "reading CSV")
+		}
+		line, _ := r.FieldPos(0)
+		item := Item{Line: line, Fields: map[string]string{}}
+		for i, value := range record {
+			if value = strings.TrimSpace(value); value != "" {
+				item.Fields[header[i]] = value
+			}
+		}
+		if len(item.Fields) > 0 {
+			items = append(items, item)
+		}
+	}
+}
+
+func readNDJSON(data []byte) ([]Item, error) {
+	var items []Item
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var record map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		if err := dec.Decode(&record); err != nil {
+			return nil, errors.Wrapf(err, "line %d", n)
+		}
+		item := Item{Line: n, Fields: map[string]string{}}
+		for name, value := range record {
+			switch v := value.(type) {
+			case nil:
+				continue
+			case string:
+				item.Fields[strings.ToLower(name)] = v
+			case json.Number, bool:
+				item.Fields[strings.ToLower(name)] = fmt.Sprint(v)
+			default:
+				return nil, errors.Newf("line %d: field %q must be a string, number or boolean", n, name)
+			}
+		}
+		items = append(items, item)
+	}
+	return items, errors.Wrap(scanner.Err(), "reading input")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package credentials
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	goexec "os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
+	"github.com/sourcegraph/src-cli/internal/exec"
+)
+
+// HelperGet asks an external credential helper for the access token of the
+// endpoint.
+//
+// Helpers follow the protocol of git credential helpers: the helper command is
+// run through the shell with the argument "get", and is given the endpoint as
+// protocol, host, path and url attributes on its standard input, one
+// "key=value" pair per line. It prints the token as the password attribute:
+//
+//	password=sgp_...
+//
+// A helper that doesn't know the endpoint prints nothing, in which case
+// ErrNotFound is returned.
+func HelperGet(ctx context.Context, helper, endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing endpoint %q", endpoint)
+	}
+
+	var stdin bytes.Buffer
+	fmt.Fprintf(&stdin, "protocol=%s\n", u.Scheme)
+	fmt.Fprintf(&stdin, "host=%s\n", u.Host)
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		fmt.Fprintf(&stdin, "path=%s\n", path)
+	}
+	fmt.Fprintf(&stdin, "url=%s\n\n", endpoint)
+
+	var stdout, stderr bytes.Buffer
+	cmd := helperCommand(ctx, helper+" get")
+	cmd.Stdin = &stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = errors.Newf("%s: %s", err, msg)
+		}
+		return "", errors.Wrapf(err, "running credential helper %q", helper)
+	}
+
+	s := bufio.NewScanner(&stdout)
+	for s.Scan() {
+		key, value, ok := strings.Cut(s.Text(), "=")
+		if !ok {
+			continue
+		}
+		if key == "password" && value != "" {
+			return value, nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", errors.Wrapf(err, "reading output of credential helper %q", helper)
+	}
+	return "", ErrNotFound
+}
+
+// helperCommand returns a command that runs the command line through the
+// shell, so that helpers can be given with arguments, like in git.
+func helperCommand(ctx context.Context, commandLine string) *goexec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", commandLine)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", commandLine)
+}
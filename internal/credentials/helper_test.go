@@ -0,0 +1,75 @@
+package credentials
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+func TestHelperGet(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test helpers are shell scripts")
+	}
+
+	dir := t.TempDir()
+	writeHelper := func(t *testing.T, name, script string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// The vault helper records its input, and only knows a single host.
+	input := filepath.Join(dir, "input")
+	vault := writeHelper(t, "vault", `
+[ "$1" = get ] || exit 1
+tee "`+input+`" | grep -q '^host=sourcegraph.example.com$' || exit 0
+echo "username=alice"
+echo "password=sgp_secret"
+`)
+
+	ctx := context.Background()
+
+	token, err := HelperGet(ctx, vault, "https://sourcegraph.example.com/sg")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if token != "sgp_secret" {
+		t.Errorf("unexpected token: %q", token)
+	}
+	data, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "protocol=https\nhost=sourcegraph.example.com\npath=sg\nurl=https://sourcegraph.example.com/sg\n\n"
+	if diff := cmp.Diff(want, string(data)); diff != "" {
+		t.Errorf("unexpected helper input (-want +have):\n%s", diff)
+	}
+
+	if _, err := HelperGet(ctx, vault, "https://other.example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unexpected error for unknown host: %v", err)
+	}
+
+	// Helpers are run through the shell, so they can take arguments.
+	args := writeHelper(t, "args", `echo "password=$1-$2"`)
+	if token, err := HelperGet(ctx, args+" --vault prod", "https://sourcegraph.example.com"); err != nil || token != "--vault-prod" {
+		t.Errorf("unexpected result: %q, %v", token, err)
+	}
+
+	failing := writeHelper(t, "failing", `echo "vault is sealed" >&2; exit 3`)
+	_, err = HelperGet(ctx, failing, "https://sourcegraph.example.com")
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "vault is sealed"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+}
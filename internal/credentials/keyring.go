@@ -0,0 +1,47 @@
+// Package credentials looks up and stores Sourcegraph access tokens outside of
+// the src config file: in the secret service of the operating system, or
+// through an external credential helper program.
+package credentials
+
+import (
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+	"github.com/zalando/go-keyring"
+)
+
+// keyringService is the service name access tokens are stored under. The
+// endpoint is used as the user name, so that each instance has its own token.
+const keyringService = "src-cli"
+
+// ErrNotFound is returned when no access token is stored for an endpoint.
+var ErrNotFound = errors.New("no access token is stored for the endpoint")
+
+// KeyringGet returns the access token stored for the endpoint in the OS
+// keyring: the Keychain on macOS, the Credential Manager on Windows, and the
+// Secret Service (e.g. GNOME Keyring or KWallet) on Linux.
+func KeyringGet(endpoint string) (string, error) {
+	token, err := keyring.Get(keyringService, endpoint)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	} else if err != nil {
+		return "", errors.Wrap(err, "reading access token from the OS keyring")
+	}
+	return token, nil
+}
+
+// KeyringSet stores the access token for the endpoint in the OS keyring,
+// replacing any token already stored for it.
+func KeyringSet(endpoint, token string) error {
+	if err := keyring.Set(keyringService, endpoint, token); err != nil {
+		return errors.Wrap(err, "storing access token in the OS keyring")
+	}
+	return nil
+}
+
+// KeyringDelete removes the access token stored for the endpoint from the OS
+// keyring. It is not an error if there is none.
+func KeyringDelete(endpoint string) error {
+	if err := keyring.Delete(keyringService, endpoint); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return errors.Wrap(err, "deleting access token from the OS keyring")
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package credentials
+
+import (
+	"testing"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+	"github.com/zalando/go-keyring"
+)
+
+func TestKeyring(t *testing.T) {
+	keyring.MockInit()
+
+	const endpoint = "https://sourcegraph.example.com"
+	if _, err := KeyringGet(endpoint); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := KeyringSet(endpoint, "sgp_secret"); err != nil {
+		t.Fatal(err)
+	}
+	if token, err := KeyringGet(endpoint); err != nil || token != "sgp_secret" {
+		t.Fatalf("unexpected result: %q, %v", token, err)
+	}
+	if _, err := KeyringGet("https://other.example.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unexpected error for other endpoint: %v", err)
+	}
+
+	if err := KeyringDelete(endpoint); err != nil {
+		t.Fatal(err)
+	}
+	if err := KeyringDelete(endpoint); err != nil {
+		t.Fatalf("unexpected error deleting twice: %v", err)
+	}
+	if _, err := KeyringGet(endpoint); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("unexpected error after delete: %v", err)
+	}
+}
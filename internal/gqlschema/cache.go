@@ -0,0 +1,192 @@
+package gqlschema
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+// Cache stores the schemas of Sourcegraph instances on disk, keyed by
+// endpoint and version, so that they only need to be introspected again after
+// an upgrade.
+type Cache struct {
+	// Dir is the directory schemas are stored in. If empty, schemas are
+	// downloaded every time.
+	Dir string
+	// VersionTTL is how long the version of an instance is remembered. Within
+	// it, a cached schema is loaded without asking the instance for its
+	// version first. If zero, the version is asked for on every load.
+	VersionTTL time.Duration
+}
+
+// DefaultVersionTTL is the VersionTTL that src api uses.
+const DefaultVersionTTL = time.Hour
+
+// DefaultCacheDir returns the directory schemas are cached in by default, or
+// an empty string if the user has no cache directory.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sourcegraph", "graphql")
+}
+
+const versionQuery = `query SourcegraphVersion {
+	site {
+		productVersion
+	}
+}`
+
+// Load returns the schema of the Sourcegraph instance at endpoint, which
+// client must be connected to. The schema is downloaded if it isn't cached
+// yet, or if refresh is set.
+func (c *Cache) Load(ctx context.Context, client api.Client, endpoint string, refresh bool) (*Schema, error) {
+	if !refresh {
+		if version, ok := c.rememberedVersion(endpoint); ok {
+			if schema, err := c.read(endpoint, version); err == nil && schema != nil {
+				return schema, nil
+			}
+		}
+	}
+
+	var version struct {
+		Site struct {
+			ProductVersion string
+		}
+	}
+	if ok, err := client.NewQuery(versionQuery).Do(ctx, &version); err != nil {
+		return nil, errors.Wrap(err, "getting Sourcegraph version")
+	} else if !ok {
+		return nil, errors.New("getting Sourcegraph version: no response")
+	}
+	productVersion := version.Site.ProductVersion
+
+	if !refresh {
+		schema, err := c.read(endpoint, productVersion)
+		if err != nil {
+			return nil, err
+		}
+		if schema != nil {
+			c.rememberVersion(endpoint, productVersion)
+			return schema, nil
+		}
+	}
+
+	schema, err := Fetch(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if path := c.path(endpoint, productVersion); path != "" {
+		if err := writeFileAtomic(path, []byte(schema.SDL())); err != nil {
+			return nil, errors.Wrap(err, "caching schema")
+		}
+		c.rememberVersion(endpoint, productVersion)
+	}
+	return schema, nil
+}
+
+// read returns the cached schema of the given endpoint and version, or nil if
+// there is none.
+func (c *Cache) read(endpoint, version string) (*Schema, error) {
+	path := c.path(endpoint, version)
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading cached schema")
+	}
+	schema, err := Parse(string(data))
+	if err != nil {
+		// A corrupt cache entry is replaced when the schema is downloaded
+		// again.
+		return nil, nil
+	}
+	return schema, nil
+}
+
+// rememberedVersion returns the version of the instance at endpoint if it was
+// last asked for within VersionTTL.
+func (c *Cache) rememberedVersion(endpoint string) (string, bool) {
+	path := c.versionPath(endpoint)
+	if path == "" || c.VersionTTL <= 0 {
+		return "", false
+	}
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > c.VersionTTL {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// rememberVersion records the version of the instance at endpoint. Since this
+// only saves a request, failing to do so isn't an error.
+func (c *Cache) rememberVersion(endpoint, version string) {
+	if path := c.versionPath(endpoint); path != "" {
+		_ = writeFileAtomic(path, []byte(version))
+	}
+}
+
+// Fetch downloads the schema of the Sourcegraph instance client is connected
+// to with an introspection query.
+func Fetch(ctx context.Context, client api.Client) (*Schema, error) {
+	var result introspectionResult
+	if ok, err := client.NewQuery(introspectionQuery).Do(ctx, &result); err != nil {
+		return nil, errors.Wrap(err, "introspecting schema")
+	} else if !ok {
+		return nil, errors.New("introspecting schema: no response")
+	}
+	return Parse(result.sdl())
+}
+
+func (c *Cache) versionPath(endpoint string) string {
+	if c.Dir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(endpoint))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:16])+".version")
+}
+
+func (c *Cache) path(endpoint, version string) string {
+	if c.Dir == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(endpoint + "\x00" + version))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:16])+".graphql")
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
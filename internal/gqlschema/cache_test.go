@@ -0,0 +1,193 @@
+package gqlschema
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+const testIntrospection = `{
+	"__schema": {
+		"queryType": {"name": "Query"},
+		"mutationType": null,
+		"subscriptionType": null,
+		"directives": [
+			{"name": "skip", "description": "", "locations": ["FIELD"], "args": []},
+			{"name": "internal", "description": "Internal only.", "locations": ["FIELD_DEFINITION", "OBJECT"], "args": []}
+		],
+		"types": [
+			{"kind": "SCALAR", "name": "String"},
+			{"kind": "SCALAR", "name": "DateTime", "description": "An RFC 3339 timestamp."},
+			{"kind": "OBJECT", "name": "__Schema", "fields": []},
+			{
+				"kind": "OBJECT",
+				"name": "Query",
+				"fields": [
+					{
+						"name": "node",
+						"args": [{"name": "id", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "ID"}}}],
+						"type": {"kind": "INTERFACE", "name": "Node"}
+					},
+					{
+						"name": "users",
+						"args": [{"name": "first", "type": {"kind": "SCALAR", "name": "Int"}, "defaultValue": "50"}],
+						"type": {"kind": "NON_NULL", "ofType": {"kind": "LIST", "ofType": {"kind": "NON_NULL", "ofType": {"kind": "OBJECT", "name": "User"}}}}
+					}
+				]
+			},
+			{
+				"kind": "INTERFACE",
+				"name": "Node",
+				"fields": [{"name": "id", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "ID"}}}]
+			},
+			{
+				"kind": "OBJECT",
+				"name": "User",
+				"description": "A \"user\" of Sourcegraph.",
+				"interfaces": [{"kind": "INTERFACE", "name": "Node"}],
+				"fields": [
+					{"name": "id", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "ID"}}},
+					{"name": "username", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "String"}}},
+					{"name": "createdAt", "type": {"kind": "SCALAR", "name": "DateTime"}, "isDeprecated": true, "deprecationReason": "Gone."},
+					{"name": "state", "type": {"kind": "ENUM", "name": "UserState"}}
+				]
+			},
+			{
+				"kind": "ENUM",
+				"name": "UserState",
+				"enumValues": [{"name": "ACTIVE"}, {"name": "SUSPENDED", "isDeprecated": true}]
+			},
+			{
+				"kind": "UNION",
+				"name": "Owner",
+				"possibleTypes": [{"kind": "OBJECT", "name": "User"}]
+			},
+			{
+				"kind": "INPUT_OBJECT",
+				"name": "UserInput",
+				"inputFields": [{"name": "username", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "String"}}}]
+			}
+		]
+	}
+}`
+
+// newTestInstance returns a Sourcegraph instance that answers version and
+// introspection queries, and counts them.
+func newTestInstance(t *testing.T) (ts *httptest.Server, version *string, versionQueries, introspections *int32) {
+	version, versionQueries, introspections = new(string), new(int32), new(int32)
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("decompressing request: %s", err)
+			return
+		}
+		var body struct{ Query string }
+		if err := json.NewDecoder(zr).Decode(&body); err != nil {
+			t.Errorf("decoding request: %s", err)
+			return
+		}
+
+		switch {
+		case strings.Contains(body.Query, "SourcegraphVersion"):
+			atomic.AddInt32(versionQueries, 1)
+			fmt.Fprintf(w, `{"data": {"site": {"productVersion": %q}}}`, *version)
+		case strings.Contains(body.Query, "IntrospectionQuery"):
+			atomic.AddInt32(introspections, 1)
+			fmt.Fprintf(w, `{"data": %s}`, testIntrospection)
+		default:
+			t.Errorf("unexpected query: %s", body.Query)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, version, versionQueries, introspections
+}
+
+func TestCache_Load(t *testing.T) {
+	ts, version, _, introspections := newTestInstance(t)
+	client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: io.Discard})
+	cache := &Cache{Dir: t.TempDir()}
+
+	load := func(t *testing.T, refresh bool, wantIntrospections int32) *Schema {
+		t.Helper()
+
+		schema, err := cache.Load(context.Background(), client, ts.URL, refresh)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if have := atomic.LoadInt32(introspections); have != wantIntrospections {
+			t.Errorf("unexpected number of introspections: have %d, want %d", have, wantIntrospections)
+		}
+		return schema
+	}
+
+	*version = "5.3.0"
+	schema := load(t, false, 1)
+	if err := schema.Validate(`query { users(first: 10) { username state } }`, nil); err != nil {
+		t.Errorf("unexpected validation error: %s", err)
+	}
+	if err := schema.Validate(`query { node(id: "x") { ... on User { createdAt } } }`, nil); err != nil {
+		t.Errorf("unexpected validation error: %s", err)
+	}
+	if err := schema.Validate(`query { users { email } }`, nil); err == nil {
+		t.Error("unexpected nil validation error")
+	}
+	if def, ok := schema.Type("User"); !ok || !strings.Contains(def, "implements Node") {
+		t.Errorf("unexpected User definition: %q", def)
+	}
+
+	// The second load must come from the cache.
+	load(t, false, 1)
+
+	entries, err := filepath.Glob(filepath.Join(cache.Dir, "*.graphql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("unexpected number of cache entries: %d", len(entries))
+	}
+
+	// Refreshing or upgrading invalidates the cache.
+	load(t, true, 2)
+	*version = "5.4.0"
+	load(t, false, 3)
+}
+
+func TestCache_Load_VersionTTL(t *testing.T) {
+	ts, version, versionQueries, introspections := newTestInstance(t)
+	client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: io.Discard})
+	cache := &Cache{Dir: t.TempDir(), VersionTTL: time.Hour}
+
+	*version = "5.3.0"
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Load(context.Background(), client, ts.URL, false); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if have := atomic.LoadInt32(versionQueries); have != 1 {
+		t.Errorf("unexpected number of version queries: have %d, want 1", have)
+	}
+	if have := atomic.LoadInt32(introspections); have != 1 {
+		t.Errorf("unexpected number of introspections: have %d, want 1", have)
+	}
+
+	// Without a TTL, the version is checked again and the upgrade noticed.
+	*version = "5.4.0"
+	cache.VersionTTL = 0
+	if _, err := cache.Load(context.Background(), client, ts.URL, false); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if have := atomic.LoadInt32(introspections); have != 2 {
+		t.Errorf("unexpected number of introspections: have %d, want 2", have)
+	}
+}
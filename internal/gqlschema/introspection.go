@@ -0,0 +1,334 @@
+package gqlschema
+
+import (
+	"sort"
+	"strings"
+)
+
+// introspectionQuery fetches everything needed to rebuild the schema as SDL.
+const introspectionQuery = `query IntrospectionQuery {
+	__schema {
+		queryType { name }
+		mutationType { name }
+		subscriptionType { name }
+		types { ...FullType }
+		directives {
+			name
+			description
+			locations
+			args { ...InputValue }
+		}
+	}
+}
+
+fragment FullType on __Type {
+	kind
+	name
+	description
+	fields(includeDeprecated: true) {
+		name
+		description
+		args { ...InputValue }
+		type { ...TypeRef }
+		isDeprecated
+		deprecationReason
+	}
+	inputFields { ...InputValue }
+	interfaces { ...TypeRef }
+	enumValues(includeDeprecated: true) {
+		name
+		description
+		isDeprecated
+		deprecationReason
+	}
+	possibleTypes { ...TypeRef }
+}
+
+fragment InputValue on __InputValue {
+	name
+	description
+	type { ...TypeRef }
+	defaultValue
+}
+
+fragment TypeRef on __Type {
+	kind
+	name
+	ofType {
+		kind
+		name
+		ofType {
+			kind
+			name
+			ofType {
+				kind
+				name
+				ofType {
+					kind
+					name
+					ofType {
+						kind
+						name
+						ofType {
+							kind
+							name
+						}
+					}
+				}
+			}
+		}
+	}
+}
+`
+
+type introspectionResult struct {
+	Schema struct {
+		QueryType        *namedRef
+		MutationType     *namedRef
+		SubscriptionType *namedRef
+		Types            []fullType
+		Directives       []directive
+	} `json:"__schema"`
+}
+
+type namedRef struct {
+	Name string
+}
+
+type fullType struct {
+	Kind          string
+	Name          string
+	Description   string
+	Fields        []field
+	InputFields   []inputValue
+	Interfaces    []typeRef
+	EnumValues    []enumValue
+	PossibleTypes []typeRef
+}
+
+type field struct {
+	Name              string
+	Description       string
+	Args              []inputValue
+	Type              typeRef
+	IsDeprecated      bool
+	DeprecationReason *string
+}
+
+type inputValue struct {
+	Name         string
+	Description  string
+	Type         typeRef
+	DefaultValue *string
+}
+
+type enumValue struct {
+	Name              string
+	Description       string
+	IsDeprecated      bool
+	DeprecationReason *string
+}
+
+type directive struct {
+	Name        string
+	Description string
+	Locations   []string
+	Args        []inputValue
+}
+
+type typeRef struct {
+	Kind   string
+	Name   string
+	OfType *typeRef
+}
+
+func (t typeRef) String() string {
+	switch t.Kind {
+	case "NON_NULL":
+		if t.OfType != nil {
+			return t.OfType.String() + "!"
+		}
+	case "LIST":
+		if t.OfType != nil {
+			return "[" + t.OfType.String() + "]"
+		}
+	}
+	return t.Name
+}
+
+// builtinDirectives are declared by the validator's prelude, and must not be
+// redeclared.
+var builtinDirectives = map[string]bool{
+	"defer":       true,
+	"deprecated":  true,
+	"include":     true,
+	"oneOf":       true,
+	"skip":        true,
+	"specifiedBy": true,
+}
+
+// builtinScalars are likewise declared by the prelude.
+var builtinScalars = map[string]bool{
+	"Boolean": true,
+	"Float":   true,
+	"ID":      true,
+	"Int":     true,
+	"String":  true,
+}
+
+// sdl renders the introspection result as a schema definition. The output
+// is not pretty, since it is reformatted once parsed.
+func (r *introspectionResult) sdl() string {
+	var b strings.Builder
+
+	b.WriteString("schema {\n")
+	for _, op := range []struct {
+		name string
+		ref  *namedRef
+	}{
+		{"query", r.Schema.QueryType},
+		{"mutation", r.Schema.MutationType},
+		{"subscription", r.Schema.SubscriptionType},
+	} {
+		if op.ref != nil {
+			b.WriteString(op.name + ": " + op.ref.Name + "\n")
+		}
+	}
+	b.WriteString("}\n")
+
+	for _, d := range r.Schema.Directives {
+		if builtinDirectives[d.Name] {
+			continue
+		}
+		writeDescription(&b, d.Description)
+		b.WriteString("directive @" + d.Name)
+		writeArgs(&b, d.Args)
+		b.WriteString(" on " + strings.Join(d.Locations, " | ") + "\n")
+	}
+
+	types := append([]fullType(nil), r.Schema.Types...)
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	for _, t := range types {
+		if strings.HasPrefix(t.Name, "__") || builtinScalars[t.Name] {
+			continue
+		}
+
+		writeDescription(&b, t.Description)
+		switch t.Kind {
+		case "SCALAR":
+			b.WriteString("scalar " + t.Name + "\n")
+
+		case "OBJECT", "INTERFACE":
+			if t.Kind == "OBJECT" {
+				b.WriteString("type " + t.Name)
+			} else {
+				b.WriteString("interface " + t.Name)
+			}
+			if len(t.Interfaces) > 0 {
+				names := make([]string, 0, len(t.Interfaces))
+				for _, i := range t.Interfaces {
+					names = append(names, i.Name)
+				}
+				b.WriteString(" implements " + strings.Join(names, " & "))
+			}
+			b.WriteString(" {\n")
+			for _, f := range t.Fields {
+				writeDescription(&b, f.Description)
+				b.WriteString(f.Name)
+				writeArgs(&b, f.Args)
+				b.WriteString(": " + f.Type.String())
+				writeDeprecated(&b, f.IsDeprecated, f.DeprecationReason)
+				b.WriteString("\n")
+			}
+			b.WriteString("}\n")
+
+		case "UNION":
+			names := make([]string, 0, len(t.PossibleTypes))
+			for _, p := range t.PossibleTypes {
+				names = append(names, p.Name)
+			}
+			b.WriteString("union " + t.Name + " = " + strings.Join(names, " | ") + "\n")
+
+		case "ENUM":
+			b.WriteString("enum " + t.Name + " {\n")
+			for _, v := range t.EnumValues {
+				writeDescription(&b, v.Description)
+				b.WriteString(v.Name)
+				writeDeprecated(&b, v.IsDeprecated, v.DeprecationReason)
+				b.WriteString("\n")
+			}
+			b.WriteString("}\n")
+
+		case "INPUT_OBJECT":
+			b.WriteString("input " + t.Name + " {\n")
+			for _, f := range t.InputFields {
+				writeInputValue(&b, f)
+				b.WriteString("\n")
+			}
+			b.WriteString("}\n")
+		}
+	}
+
+	return b.String()
+}
+
+func writeDescription(b *strings.Builder, description string) {
+	if description == "" {
+		return
+	}
+	b.WriteString(quote(description) + "\n")
+}
+
+func writeArgs(b *strings.Builder, args []inputValue) {
+	if len(args) == 0 {
+		return
+	}
+	b.WriteString("(\n")
+	for _, arg := range args {
+		writeInputValue(b, arg)
+		b.WriteString("\n")
+	}
+	b.WriteString(")")
+}
+
+func writeInputValue(b *strings.Builder, v inputValue) {
+	writeDescription(b, v.Description)
+	b.WriteString(v.Name + ": " + v.Type.String())
+	if v.DefaultValue != nil {
+		b.WriteString(" = " + *v.DefaultValue)
+	}
+}
+
+func writeDeprecated(b *strings.Builder, deprecated bool, reason *string) {
+	if !deprecated {
+		return
+	}
+	b.WriteString(" @deprecated")
+	if reason != nil {
+		b.WriteString("(reason: " + quote(*reason) + ")")
+	}
+}
+
+// quote returns s as a GraphQL string literal.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
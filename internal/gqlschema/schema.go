@@ -0,0 +1,136 @@
+// Package gqlschema validates GraphQL requests against the schema of a
+// Sourcegraph instance without sending them.
+package gqlschema
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/formatter"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/parser"
+	"github.com/vektah/gqlparser/v2/validator"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+)
+
+// Schema is a parsed GraphQL schema.
+type Schema struct {
+	doc    *ast.SchemaDocument
+	schema *ast.Schema
+}
+
+// Parse parses a schema definition.
+func Parse(sdl string) (*Schema, error) {
+	source := &ast.Source{Name: "schema.graphql", Input: sdl}
+
+	doc, err := parser.ParseSchema(source)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing schema")
+	}
+	schema, err := gqlparser.LoadSchema(source)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading schema")
+	}
+	return &Schema{doc: doc, schema: schema}, nil
+}
+
+// SDL returns the schema definition.
+func (s *Schema) SDL() string {
+	var buf bytes.Buffer
+	formatter.NewFormatter(&buf).FormatSchemaDocument(s.doc)
+	return buf.String()
+}
+
+// Type returns the definition of the named type, or false if the schema
+// doesn't define it. Type names are matched case insensitively, since that's
+// friendlier on the command line.
+func (s *Schema) Type(name string) (string, bool) {
+	for _, def := range s.doc.Definitions {
+		if strings.EqualFold(def.Name, name) {
+			var buf bytes.Buffer
+			formatter.NewFormatter(&buf).FormatSchemaDocument(&ast.SchemaDocument{
+				Definitions: ast.DefinitionList{def},
+			})
+			return buf.String(), true
+		}
+	}
+	return "", false
+}
+
+// Validate checks that query is valid against the schema, and that vars
+// satisfy the variables the query declares.
+//
+// Validation failures are returned as ValidationErrors.
+func (s *Schema) Validate(query string, vars map[string]interface{}) error {
+	doc, gqlErrs := gqlparser.LoadQuery(s.schema, query)
+	if len(gqlErrs) > 0 {
+		errs := make(ValidationErrors, 0, len(gqlErrs))
+		for _, err := range gqlErrs {
+			errs = append(errs, newValidationError(err))
+		}
+		return errs
+	}
+
+	// Variables can only be checked when it's clear which operation will be
+	// run, and Sourcegraph requires a single operation anyway.
+	if len(doc.Operations) != 1 {
+		return nil
+	}
+	if _, err := validator.VariableValues(s.schema, doc.Operations[0], vars); err != nil {
+		var gqlErr *gqlerror.Error
+		if errors.As(err, &gqlErr) {
+			return ValidationErrors{newValidationError(gqlErr)}
+		}
+		return ValidationErrors{{Message: err.Error()}}
+	}
+	return nil
+}
+
+// ValidationError is a single problem with a query. Line and Column are 1
+// based, and are zero for problems with variables.
+type ValidationError struct {
+	Line     int
+	Column   int
+	Variable string
+	Message  string
+}
+
+func newValidationError(err *gqlerror.Error) ValidationError {
+	ve := ValidationError{Message: err.Message}
+	if len(err.Locations) > 0 {
+		ve.Line = err.Locations[0].Line
+		ve.Column = err.Locations[0].Column
+	}
+	// Variable errors have a path of "variable", the variable name, and then
+	// the path within its value.
+	if len(err.Path) > 1 && err.Path[0] == ast.PathName("variable") {
+		ve.Variable = strings.TrimPrefix(ast.Path(err.Path[1:]).String(), ".")
+	}
+	return ve
+}
+
+func (e ValidationError) Error() string {
+	switch {
+	case e.Line > 0:
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+	case e.Variable != "":
+		return fmt.Sprintf("variable $%s: %s", e.Variable, e.Message)
+	default:
+		return e.Message
+	}
+}
+
+// ValidationErrors are all the problems found with a query.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
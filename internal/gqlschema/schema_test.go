@@ -0,0 +1,144 @@
+package gqlschema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testSDL = `
+schema {
+	query: Query
+	mutation: Mutation
+}
+
+type Query {
+	"The current user."
+	currentUser: User
+	repository(name: String!): Repository
+}
+
+type Mutation {
+	addTag(node: ID!, tag: String!, kind: TagKind = PLAIN): Boolean
+}
+
+enum TagKind {
+	PLAIN
+	SYSTEM
+}
+
+type User {
+	username: String!
+	displayName: String @deprecated(reason: "Use username.")
+}
+
+type Repository {
+	name: String!
+}
+`
+
+func TestSchema_Validate(t *testing.T) {
+	schema, err := Parse(testSDL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		query string
+		vars  map[string]interface{}
+		want  ValidationErrors
+	}{
+		"valid query": {
+			query: `query { currentUser { username } }`,
+		},
+		"valid mutation with variables": {
+			query: `mutation AddTag($node: ID!, $tag: String!) { addTag(node: $node, tag: $tag) }`,
+			vars:  map[string]interface{}{"node": "UmVwbzox", "tag": "foo"},
+		},
+		"unknown field": {
+			query: "query {\n  currentUser {\n    usernme\n  }\n}",
+			want: ValidationErrors{{
+				Line:    3,
+				Column:  5,
+				Message: `Cannot query field "usernme" on type "User". Did you mean "username"?`,
+			}},
+		},
+		"syntax error": {
+			query: `query { currentUser { username }`,
+			want: ValidationErrors{{
+				Line:    1,
+				Column:  33,
+				Message: `Expected Name, found <EOF>`,
+			}},
+		},
+		"missing variable": {
+			query: `query Repo($name: String!) { repository(name: $name) { name } }`,
+			want: ValidationErrors{{
+				Variable: "name",
+				Message:  "must be defined",
+			}},
+		},
+		"invalid enum variable": {
+			query: `mutation AddTag($kind: TagKind) { addTag(node: "x", tag: "y", kind: $kind) }`,
+			vars:  map[string]interface{}{"kind": "OTHER"},
+			want: ValidationErrors{{
+				Variable: "kind",
+				Message:  "OTHER is not a valid TagKind",
+			}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := schema.Validate(tc.query, tc.vars)
+			if tc.want == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			have, ok := err.(ValidationErrors)
+			if !ok {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, have); diff != "" {
+				t.Errorf("unexpected errors (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSchema_Type(t *testing.T) {
+	schema, err := Parse(testSDL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	have, ok := schema.Type("user")
+	if !ok {
+		t.Fatal("type not found")
+	}
+	for _, want := range []string{"type User {", "username: String!", "displayName: String @deprecated"} {
+		if !strings.Contains(have, want) {
+			t.Errorf("type definition does not contain %q:\n%s", want, have)
+		}
+	}
+	if strings.Contains(have, "Repository") {
+		t.Errorf("type definition contains other types:\n%s", have)
+	}
+
+	if _, ok := schema.Type("Missing"); ok {
+		t.Error("unexpected type found")
+	}
+}
+
+func TestValidationError_Error(t *testing.T) {
+	errs := ValidationErrors{
+		{Line: 3, Column: 5, Message: "Cannot query field"},
+		{Variable: "name", Message: "must be defined"},
+	}
+	want := "3:5: Cannot query field\nvariable $name: must be defined"
+	if have := errs.Error(); have != want {
+		t.Errorf("unexpected error: have %q, want %q", have, want)
+	}
+}
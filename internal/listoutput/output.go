@@ -0,0 +1,301 @@
+// Package listoutput writes the resources printed by src commands in one of
+// several machine-readable formats, so that scripts don't have to depend on
+// the text output of individual commands.
+package listoutput
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format.
+type Format string
+
+const (
+	// JSON writes a single resource as an indented JSON object, and a list of
+	// resources as a JSON array.
+	JSON Format = "json"
+	// NDJSON writes every resource as a JSON object on its own line.
+	NDJSON Format = "ndjson"
+	// YAML writes a single resource as a YAML mapping, and a list of
+	// resources as a YAML sequence.
+	YAML Format = "yaml"
+	// CSV writes a header, followed by a row of columns for every resource.
+	CSV Format = "csv"
+	// Table writes the same columns as CSV, aligned for humans to read.
+	Table Format = "table"
+	// Template executes a text/template for every resource.
+	Template Format = "template"
+)
+
+// Formats are all output formats, in the order they're documented.
+var Formats = []Format{JSON, NDJSON, YAML, CSV, Table, Template}
+
+// ParseFormat returns the format with the given name.
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return "", errors.Newf("unknown output format %q, must be one of %s", name, strings.Join(names, ", "))
+}
+
+// ParseColumns splits a comma-separated list of columns.
+func ParseColumns(s string) []string {
+	var columns []string
+	for _, c := range strings.Split(s, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// Options configure a Writer.
+type Options struct {
+	Format Format
+
+	// List is true if the command prints a list of resources, rather than a
+	// single one. It changes how JSON and YAML are written.
+	List bool
+
+	// Columns are the columns written by the CSV and Table formats. Each
+	// column is a dot-separated path of field names and array indexes into
+	// the JSON representation of a resource, e.g. "Emails.0.Email". Field
+	// names are matched case-insensitively. If empty, the top-level fields of
+	// the first resource are used.
+	Columns []string
+
+	// Template is executed for every resource by the Template format.
+	Template *template.Template
+}
+
+// Writer writes resources in an output format.
+type Writer interface {
+	// Write writes a resource. It can be any value that can be marshalled
+	// to JSON.
+	Write(v interface{}) error
+	// Close writes what is left of the output. It must be called once all
+	// resources have been written.
+	Close() error
+}
+
+// NewWriter returns a Writer that writes to w.
+func NewWriter(w io.Writer, opts Options) (Writer, error) {
+	switch opts.Format {
+	case JSON:
+		return &jsonWriter{w: w, list: opts.List}, nil
+	case NDJSON:
+		return &ndjsonWriter{w: w}, nil
+	case YAML:
+		return &yamlWriter{w: w, list: opts.List}, nil
+	case CSV:
+		cw := csv.NewWriter(w)
+		return &columnWriter{columns: opts.Columns, write: cw.Write, flush: func() error {
+			cw.Flush()
+			return cw.Error()
+		}}, nil
+	case Table:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		return &columnWriter{columns: opts.Columns, upper: true, write: func(row []string) error {
+			// Tabs and newlines would break the alignment of the table.
+			for i, cell := range row {
+				row[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(cell)
+			}
+			_, err := fmt.Fprintln(tw, strings.Join(row, "\t"))
+			return err
+		}, flush: tw.Flush}, nil
+	case Template:
+		if opts.Template == nil {
+			return nil, errors.New("the template output format requires a template")
+		}
+		return &templateWriter{w: w, tmpl: opts.Template}, nil
+	}
+	return nil, errors.Newf("unknown output format %q", opts.Format)
+}
+
+type jsonWriter struct {
+	w     io.Writer
+	list  bool
+	count int
+}
+
+func (j *jsonWriter) Write(v interface{}) error {
+	if !j.list {
+		if j.count > 0 {
+			return errors.New("cannot write more than one resource")
+		}
+		j.count++
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(j.w, "%s\n", data)
+		return err
+	}
+
+	// Lists are written as they go, so that long lists can be consumed
+	// before they are complete.
+	data, err := json.MarshalIndent(v, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if j.count == 0 {
+		sep = "[\n  "
+	}
+	j.count++
+	_, err = fmt.Fprintf(j.w, "%s%s", sep, data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	if !j.list {
+		return nil
+	}
+	end := "\n]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(j.w, end)
+	return err
+}
+
+type ndjsonWriter struct {
+	w io.Writer
+}
+
+func (n *ndjsonWriter) Write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(n.w, "%s\n", data)
+	return err
+}
+
+func (n *ndjsonWriter) Close() error { return nil }
+
+type yamlWriter struct {
+	w     io.Writer
+	list  bool
+	count int
+}
+
+func (y *yamlWriter) Write(v interface{}) error {
+	if !y.list && y.count > 0 {
+		return errors.New("cannot write more than one resource")
+	}
+	y.count++
+
+	value, err := toValue(v)
+	if err != nil {
+		return err
+	}
+	node := yamlNode(value)
+	if y.list {
+		// Written as a sequence of one, every item adds an entry to the
+		// sequence that the whole output forms.
+		node = &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{node}}
+	}
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	_, err = y.w.Write(data)
+	return err
+}
+
+func (y *yamlWriter) Close() error {
+	if y.list && y.count == 0 {
+		_, err := io.WriteString(y.w, "[]\n")
+		return err
+	}
+	return nil
+}
+
+// columnWriter writes the cells of the given columns as rows, preceded by a
+// header row.
+type columnWriter struct {
+	columns []string
+	upper   bool
+	write   func(row []string) error
+	flush   func() error
+
+	wroteHeader bool
+}
+
+func (c *columnWriter) Write(v interface{}) error {
+	value, err := toValue(v)
+	if err != nil {
+		return err
+	}
+	if len(c.columns) == 0 {
+		if obj, ok := value.(*object); ok {
+			c.columns = obj.keys
+		}
+	}
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	row := make([]string, len(c.columns))
+	for i, column := range c.columns {
+		if row[i], err = cell(lookup(value, column)); err != nil {
+			return err
+		}
+	}
+	return c.write(row)
+}
+
+func (c *columnWriter) writeHeader() error {
+	if c.wroteHeader || len(c.columns) == 0 {
+		return nil
+	}
+	c.wroteHeader = true
+
+	header := make([]string, len(c.columns))
+	for i, column := range c.columns {
+		header[i] = column
+		if c.upper {
+			header[i] = strings.ToUpper(column)
+		}
+	}
+	return c.write(header)
+}
+
+func (c *columnWriter) Close() error {
+	// Even an empty list has a header, if the columns are known.
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.flush()
+}
+
+type templateWriter struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func (t *templateWriter) Write(v interface{}) error {
+	if err := t.tmpl.Execute(t.w, v); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(t.w)
+	return err
+}
+
+func (t *templateWriter) Close() error { return nil }
@@ -0,0 +1,191 @@
+package listoutput
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type testEmail struct {
+	Email    string
+	Verified bool
+}
+
+type testUser struct {
+	ID        string
+	Username  string
+	SiteAdmin bool
+	Emails    []testEmail
+	Stats     map[string]int `json:"stats,omitempty"`
+}
+
+var testUsers = []testUser{
+	{ID: "VXNlcjox", Username: "alice", SiteAdmin: true, Emails: []testEmail{{"alice@example.com", true}}, Stats: map[string]int{"searches": 3}},
+	{ID: "VXNlcjoy", Username: "bob", Emails: []testEmail{}},
+}
+
+func TestWriter(t *testing.T) {
+	for name, tc := range map[string]struct {
+		opts  Options
+		items []interface{}
+		want  string
+	}{
+		"json list": {
+			opts:  Options{Format: JSON, List: true},
+			items: []interface{}{testUsers[1], testUsers[1]},
+			want: `[
+  {
+    "ID": "VXNlcjoy",
+    "Username": "bob",
+    "SiteAdmin": false,
+    "Emails": []
+  },
+  {
+    "ID": "VXNlcjoy",
+    "Username": "bob",
+    "SiteAdmin": false,
+    "Emails": []
+  }
+]
+`,
+		},
+		"json empty list": {
+			opts: Options{Format: JSON, List: true},
+			want: "[]\n",
+		},
+		"json item": {
+			opts:  Options{Format: JSON},
+			items: []interface{}{testEmail{"alice@example.com", true}},
+			want: `{
+  "Email": "alice@example.com",
+  "Verified": true
+}
+`,
+		},
+		"ndjson": {
+			opts:  Options{Format: NDJSON, List: true},
+			items: []interface{}{testUsers[0], testUsers[1]},
+			want: `{"ID":"VXNlcjox","Username":"alice","SiteAdmin":true,"Emails":[{"Email":"alice@example.com","Verified":true}],"stats":{"searches":3}}
+{"ID":"VXNlcjoy","Username":"bob","SiteAdmin":false,"Emails":[]}
+`,
+		},
+		"yaml list": {
+			opts:  Options{Format: YAML, List: true},
+			items: []interface{}{testUsers[0], testUsers[1]},
+			want: `- ID: VXNlcjox
+  Username: alice
+  SiteAdmin: true
+  Emails:
+    - Email: alice@example.com
+      Verified: true
+  stats:
+    searches: 3
+- ID: VXNlcjoy
+  Username: bob
+  SiteAdmin: false
+  Emails: []
+`,
+		},
+		"yaml empty list": {
+			opts: Options{Format: YAML, List: true},
+			want: "[]\n",
+		},
+		"yaml item": {
+			opts:  Options{Format: YAML},
+			items: []interface{}{map[string]interface{}{"contents": "a\nb\n", "count": 1.5, "none": nil}},
+			want: `contents: |
+    a
+    b
+count: 1.5
+none: null
+`,
+		},
+		"csv with default columns": {
+			opts:  Options{Format: CSV, List: true},
+			items: []interface{}{testUsers[0], testUsers[1]},
+			want: `ID,Username,SiteAdmin,Emails,stats
+VXNlcjox,alice,true,"[{""Email"":""alice@example.com"",""Verified"":true}]","{""searches"":3}"
+VXNlcjoy,bob,false,[],
+`,
+		},
+		"csv with columns": {
+			opts:  Options{Format: CSV, List: true, Columns: []string{"username", "Emails.0.Email", "stats.searches", "missing"}},
+			items: []interface{}{testUsers[0], testUsers[1]},
+			want: `username,Emails.0.Email,stats.searches,missing
+alice,alice@example.com,3,
+bob,,,
+`,
+		},
+		"csv empty list": {
+			opts: Options{Format: CSV, List: true, Columns: []string{"ID", "Username"}},
+			want: "ID,Username\n",
+		},
+		"table": {
+			opts:  Options{Format: Table, List: true, Columns: []string{"ID", "Username", "SiteAdmin"}},
+			items: []interface{}{testUsers[0], testUsers[1]},
+			want: `ID        USERNAME  SITEADMIN
+VXNlcjox  alice     true
+VXNlcjoy  bob       false
+`,
+		},
+		"template": {
+			opts:  Options{Format: Template, List: true, Template: template.Must(template.New("").Parse("{{.Username}}"))},
+			items: []interface{}{testUsers[0], testUsers[1]},
+			want:  "alice\nbob\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, item := range tc.items {
+				if err := w.Write(item); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("unexpected output (-want +have):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriter_SingleResource(t *testing.T) {
+	for _, format := range []Format{JSON, YAML} {
+		w, err := NewWriter(&bytes.Buffer{}, Options{Format: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(testUsers[0]); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(testUsers[1]); err == nil {
+			t.Errorf("%s: expected an error when writing a second resource", format)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range Formats {
+		if have, err := ParseFormat(string(f)); err != nil || have != f {
+			t.Errorf("ParseFormat(%q) = %q, %v", f, have, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	want := []string{"ID", "Emails.0.Email"}
+	if diff := cmp.Diff(want, ParseColumns(" ID, ,Emails.0.Email,")); diff != "" {
+		t.Errorf("unexpected columns (-want +have):\n%s", diff)
+	}
+}
@@ -0,0 +1,191 @@
+package listoutput
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// object is a JSON object that remembers the order of its keys, so that
+// resources keep the order of their struct fields in YAML and as default
+// columns.
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// toValue converts v to its JSON representation, made of *object,
+// []interface{}, string, json.Number, bool and nil values.
+func toValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return decodeValue(dec)
+}
+
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := &object{values: map[string]interface{}{}}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := tok.(string)
+			if !ok {
+				return nil, errors.Newf("unexpected object key %v", tok)
+			}
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := obj.values[key]; !ok {
+				obj.keys = append(obj.keys, key)
+			}
+			obj.values[key] = value
+		}
+		_, err := dec.Token()
+		return obj, err
+
+	case json.Delim('['):
+		list := []interface{}{}
+		for dec.More() {
+			value, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token()
+		return list, err
+	}
+	if _, ok := tok.(json.Delim); ok {
+		return nil, errors.Newf("unexpected %v", tok)
+	}
+	return tok, nil
+}
+
+// lookup returns the value at the dot-separated path, or nil if there is none.
+func lookup(v interface{}, path string) interface{} {
+	for _, name := range strings.Split(path, ".") {
+		switch value := v.(type) {
+		case *object:
+			field, ok := value.values[name]
+			if !ok {
+				for _, k := range value.keys {
+					if strings.EqualFold(k, name) {
+						field, ok = value.values[k], true
+						break
+					}
+				}
+			}
+			if !ok {
+				return nil
+			}
+			v = field
+
+		case []interface{}:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(value) {
+				return nil
+			}
+			v = value[i]
+
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// cell formats a value for CSV and tables. Objects and arrays are written as
+// compact JSON.
+func cell(v interface{}) (string, error) {
+	switch value := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	}
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// yamlNode converts a value to a YAML node that keeps the order of object keys.
+func yamlNode(v interface{}) *yaml.Node {
+	switch value := v.(type) {
+	case *object:
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, k := range value.keys {
+			node.Content = append(node.Content, scalarNode("!!str", k), yamlNode(value.values[k]))
+		}
+		return node
+
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, item := range value {
+			node.Content = append(node.Content, yamlNode(item))
+		}
+		return node
+
+	case string:
+		node := scalarNode("!!str", value)
+		if strings.Contains(value, "\n") {
+			node.Style = yaml.LiteralStyle
+		}
+		return node
+
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return scalarNode("!!int", value.String())
+		}
+		return scalarNode("!!float", value.String())
+
+	case bool:
+		return scalarNode("!!bool", strconv.FormatBool(value))
+	}
+	return scalarNode("!!null", "null")
+}
+
+func scalarNode(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
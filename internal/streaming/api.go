@@ -22,6 +22,12 @@ type Progress struct {
 	// same.  However, within a search stream when a new skipped reason is
 	// found, it may appear anywhere in the list.
 	Skipped []Skipped `json:"skipped"`
+
+	// Complete is set by Search on the final progress event. It is true if the
+	// search finished, and no results were skipped except for forks and
+	// archived repositories, which are excluded unless the query asks for
+	// them.
+	Complete bool `json:"complete"`
 }
 
 // Skipped is a description of shards or documents that were skipped.
@@ -74,8 +80,22 @@ const (
 	// ExcludedArchive is when we did not search a repository because it is
 	// archived.
 	ExcludedArchive = "excluded-archive"
+	// Interrupted is when the event stream was cut off, and couldn't be
+	// resumed, before the search was done. It is reported by Search rather
+	// than by Sourcegraph.
+	Interrupted = "interrupted"
 )
 
+// Incomplete returns true if results that were skipped for the reason may
+// have matched the query.
+func (r SkippedReason) Incomplete() bool {
+	switch r {
+	case ExcludedFork, ExcludedArchive:
+		return false
+	}
+	return true
+}
+
 // SkippedSeverity is an enum for Skipped.Severity.
 type SkippedSeverity string
 
@@ -34,8 +34,16 @@ type Decoder struct {
 }
 
 func (rr Decoder) ReadAll(r io.Reader) error {
+	_, err := rr.readAll(r)
+	return err
+}
+
+// readAll is ReadAll, but also returns whether the stream ended with the done
+// event, rather than being cut off.
+func (rr Decoder) readAll(r io.Reader) (bool, error) {
 	const maxPayloadSize = 10 * 1024 * 1024 // 10mb
-	scanner := bufio.NewScanner(r)
+	er := &errReader{r: r}
+	scanner := bufio.NewScanner(er)
 	scanner.Buffer(make([]byte, 0, 4096), maxPayloadSize)
 	// bufio.ScanLines, except we look for two \n\n which separate events.
 	split := func(data []byte, atEOF bool) (int, []byte, error) {
@@ -46,8 +54,12 @@ func (rr Decoder) ReadAll(r io.Reader) error {
 			return i + 2, data[:i], nil
 		}
 		// If we're at EOF, we have a final, non-terminated event. This should
-		// be empty.
+		// be empty. If the stream was cut off instead, the event is
+		// incomplete, and the error of the stream is what matters.
 		if atEOF {
+			if er.err != nil && er.err != io.EOF {
+				return 0, nil, er.err
+			}
 			return len(data), data, nil
 		}
 		// Request more data.
@@ -61,17 +73,17 @@ func (rr Decoder) ReadAll(r io.Reader) error {
 		data := scanner.Bytes()
 		nl := bytes.Index(data, []byte("\n"))
 		if nl < 0 {
-			return fmt.Errorf("malformed event, no newline: %s", data)
+			return false, fmt.Errorf("malformed event, no newline: %s", data)
 		}
 
 		eventK, event := splitColon(data[:nl])
 		dataK, data := splitColon(data[nl+1:])
 
 		if !bytes.Equal(eventK, []byte("event")) {
-			return fmt.Errorf("malformed event, expected event: %s", eventK)
+			return false, fmt.Errorf("malformed event, expected event: %s", eventK)
 		}
 		if !bytes.Equal(dataK, []byte("data")) {
-			return fmt.Errorf("malformed event %s, expected data: %s", eventK, dataK)
+			return false, fmt.Errorf("malformed event %s, expected data: %s", eventK, dataK)
 		}
 
 		if bytes.Equal(event, []byte("progress")) {
@@ -80,7 +92,7 @@ func (rr Decoder) ReadAll(r io.Reader) error {
 			}
 			var d Progress
 			if err := json.Unmarshal(data, &d); err != nil {
-				return fmt.Errorf("failed to decode progress payload: %w", err)
+				return false, fmt.Errorf("failed to decode progress payload: %w", err)
 			}
 			rr.OnProgress(&d)
 		} else if bytes.Equal(event, []byte("matches")) {
@@ -89,7 +101,7 @@ func (rr Decoder) ReadAll(r io.Reader) error {
 			}
 			var d []eventMatchUnmarshaller
 			if err := json.Unmarshal(data, &d); err != nil {
-				return fmt.Errorf("failed to decode matches payload: %w", err)
+				return false, fmt.Errorf("failed to decode matches payload: %w", err)
 			}
 			m := make([]EventMatch, 0, len(d))
 			for _, e := range d {
@@ -102,7 +114,7 @@ func (rr Decoder) ReadAll(r io.Reader) error {
 			}
 			var d []*EventFilter
 			if err := json.Unmarshal(data, &d); err != nil {
-				return fmt.Errorf("failed to decode filters payload: %w", err)
+				return false, fmt.Errorf("failed to decode filters payload: %w", err)
 			}
 			rr.OnFilters(d)
 		} else if bytes.Equal(event, []byte("alert")) {
@@ -111,7 +123,7 @@ func (rr Decoder) ReadAll(r io.Reader) error {
 			}
 			var d EventAlert
 			if err := json.Unmarshal(data, &d); err != nil {
-				return fmt.Errorf("failed to decode alert payload: %w", err)
+				return false, fmt.Errorf("failed to decode alert payload: %w", err)
 			}
 			rr.OnAlert(&d)
 		} else if bytes.Equal(event, []byte("error")) {
@@ -120,12 +132,12 @@ func (rr Decoder) ReadAll(r io.Reader) error {
 			}
 			var d EventError
 			if err := json.Unmarshal(data, &d); err != nil {
-				return fmt.Errorf("failed to decode error payload: %w", err)
+				return false, fmt.Errorf("failed to decode error payload: %w", err)
 			}
 			rr.OnError(&d)
 		} else if bytes.Equal(event, []byte("done")) {
 			// Always the last event
-			break
+			return true, nil
 		} else {
 			if rr.OnUnknown == nil {
 				continue
@@ -133,7 +145,21 @@ func (rr Decoder) ReadAll(r io.Reader) error {
 			rr.OnUnknown(event, data)
 		}
 	}
-	return scanner.Err()
+	return false, scanner.Err()
+}
+
+// errReader remembers the error that ended reading from r.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil {
+		r.err = err
+	}
+	return n, err
 }
 
 func splitColon(data []byte) ([]byte, []byte) {
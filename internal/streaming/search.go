@@ -2,62 +2,373 @@ package streaming
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sourcegraph/sourcegraph/lib/errors"
 
 	"github.com/sourcegraph/src-cli/internal/api"
 )
 
+// DefaultMaxReconnects is the number of times Search reconnects to an
+// interrupted event stream, unless Opts.MaxReconnects is set.
+const DefaultMaxReconnects = 5
+
+// The delays between reconnects double from reconnectMinDelay up to
+// reconnectMaxDelay. They are variables so that tests don't have to wait.
+var (
+	reconnectMinDelay = time.Second
+	reconnectMaxDelay = 30 * time.Second
+)
+
 // Opts contains the search options supported by Search.
 type Opts struct {
 	Display int
 	Trace   bool
 	Json    bool
+
+	// Format is the output format of the results: text, json, sarif, csv or
+	// gh-annotations. If empty, it's json if Json is set, and text otherwise.
+	Format string
+
+	// Timeout is the maximum duration of the search, including reconnects.
+	// Zero means no limit.
+	Timeout time.Duration
+
+	// PatternType is how the query is interpreted, such as standard, literal,
+	// regexp or structural. If empty, the default of the instance is used.
+	PatternType string
+
+	// Version is the version of the query syntax, such as V3. If empty, the
+	// default of the instance is used.
+	Version string
+
+	// ContextLines is the number of lines of context around each match. If
+	// nil, the default of the instance is used.
+	ContextLines *int
+
+	// MaxLineLen truncates matched lines longer than this many characters.
+	// Zero means no limit.
+	MaxLineLen int
+
+	// SearchContext is the search context to search, as if the query started
+	// with context:SearchContext. If empty, the query is used as-is.
+	SearchContext string
+
+	// MaxReconnects is the maximum number of times to reconnect when the event
+	// stream is cut off before the search is done. Zero means
+	// DefaultMaxReconnects, and a negative number disables reconnecting.
+	MaxReconnects int
 }
 
 // Search calls the streaming search endpoint and uses decoder to decode the
 // response body.
 func Search(query string, opts Opts, client api.Client, decoder Decoder) error {
-	// Create request.
-	req, err := client.NewHTTPRequest(context.Background(), "GET", ".api/search/stream?q="+url.QueryEscape(query), nil)
-	if err != nil {
-		return err
+	return SearchContext(context.Background(), query, opts, client, decoder)
+}
+
+// SearchContext is Search, but stops when ctx is done.
+//
+// If the event stream is cut off before the search is done, the search is run
+// again with backoff, up to opts.MaxReconnects times. Sourcegraph can't resume
+// a stream, so the new stream starts from the beginning: the matches, alerts
+// and errors that were already decoded are skipped, so that decoder never sees
+// the same one twice.
+//
+// The final progress event tells whether the result set is complete. If the
+// search can't be finished, decoder gets a final progress event with an
+// Interrupted skipped reason, and the error is returned.
+func SearchContext(ctx context.Context, query string, opts Opts, client api.Client, decoder Decoder) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
 	}
-	req.Header.Set("Accept", "text/event-stream")
-	if opts.Display >= 0 {
-		q := req.URL.Query()
-		q.Add("display", strconv.Itoa(opts.Display))
-		req.URL.RawQuery = q.Encode()
+	maxReconnects := opts.MaxReconnects
+	if maxReconnects == 0 {
+		maxReconnects = DefaultMaxReconnects
+	}
+
+	s := newResumableSearch(decoder)
+	for attempt := 0; ; attempt++ {
+		done, err := searchOnce(ctx, query, opts, client, s.decoder())
+		if err == nil && done {
+			s.finish()
+			return nil
+		}
+		if err == nil {
+			err = errStreamEnded
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = contextError(ctxErr, opts.Timeout)
+		} else if attempt < maxReconnects && reconnectable(err) {
+			delay := reconnectDelay(attempt)
+			fmt.Fprintf(os.Stderr, "Search interrupted: %s. Reconnecting in %s (%d/%d).\n", err, delay.Round(time.Millisecond), attempt+1, maxReconnects)
+			if sleepErr := sleepContext(ctx, delay); sleepErr == nil {
+				continue
+			}
+			err = contextError(ctx.Err(), opts.Timeout)
+		}
+		s.interrupt(err)
+		return err
 	}
+}
 
-	{
-		// Consume chunk matches for streaming search.
-		q := req.URL.Query()
-		q.Add("cm", "t")
-		req.URL.RawQuery = q.Encode()
+// searchOnce runs the search over a single event stream. It returns true if
+// the stream ended with the done event.
+func searchOnce(ctx context.Context, query string, opts Opts, client api.Client, decoder Decoder) (bool, error) {
+	// Create request.
+	req, err := client.NewHTTPRequest(ctx, "GET", ".api/search/stream?"+searchParams(query, opts).Encode(), nil)
+	if err != nil {
+		return false, err
 	}
+	req.Header.Set("Accept", "text/event-stream")
 
 	// Send request.
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("error sending request: %w", err)
+		return false, fmt.Errorf("error sending request: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return false, &statusError{code: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
 
 	// Process response.
-	err = decoder.ReadAll(resp.Body)
+	done, err := decoder.readAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("error during decoding: %w", err)
+		return false, fmt.Errorf("error during decoding: %w", err)
 	}
 
 	// Output trace.
 	if opts.Trace {
 		_, err = fmt.Fprintf(os.Stderr, "\nx-trace: %s\n", resp.Header.Get("x-trace"))
 		if err != nil {
-			return err
+			return done, err
+		}
+	}
+	return done, nil
+}
+
+// searchParams returns the URL query parameters of the streaming search
+// endpoint for the query and options.
+func searchParams(query string, opts Opts) url.Values {
+	q := url.Values{}
+	q.Set("q", QueryWithSearchContext(query, opts.SearchContext))
+	if opts.Version != "" {
+		q.Set("v", opts.Version)
+	}
+	if opts.PatternType != "" {
+		q.Set("t", opts.PatternType)
+	}
+	if opts.Display >= 0 {
+		q.Set("display", strconv.Itoa(opts.Display))
+	}
+	if opts.ContextLines != nil {
+		q.Set("cl", strconv.Itoa(*opts.ContextLines))
+	}
+	if opts.MaxLineLen > 0 {
+		q.Set("max-line-len", strconv.Itoa(opts.MaxLineLen))
+	}
+	// Consume chunk matches for streaming search.
+	q.Set("cm", "t")
+	return q
+}
+
+// QueryWithSearchContext returns the query restricted to the search context,
+// or the query as-is if searchContext is empty.
+func QueryWithSearchContext(query, searchContext string) string {
+	if searchContext == "" {
+		return query
+	}
+	return "context:" + searchContext + " " + query
+}
+
+// statusError is returned for a response to the search request that isn't
+// an event stream.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("unexpected HTTP status %d", e.code)
+	}
+	return fmt.Sprintf("unexpected HTTP status %d: %s", e.code, e.body)
+}
+
+// errStreamEnded is returned if the event stream ended without a done event.
+var errStreamEnded = errors.New("the event stream ended before the search was done")
+
+// reconnectable returns true if the search may succeed when it's run again,
+// because the event stream was cut off, or the connection failed. Requests
+// that were rejected, such as for bad credentials, and errors of the
+// configuration or of the events themselves won't.
+func reconnectable(err error) bool {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code == http.StatusTooManyRequests || statusErr.code >= 500
+	}
+	if errors.Is(err, errStreamEnded) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	// Failures to dial, read and write are *net.OpError. Being a net.Error
+	// isn't enough, since *url.Error and syscall.Errno implement it too.
+	var opErr *net.OpError
+	var netErr net.Error
+	return errors.As(err, &opErr) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// reconnectDelay returns the jittered delay to wait before the reconnect
+// following the given attempt.
+func reconnectDelay(attempt int) time.Duration {
+	d := reconnectMinDelay
+	for i := 0; i < attempt && d < reconnectMaxDelay; i++ {
+		d *= 2
+	}
+	if d > reconnectMaxDelay {
+		d = reconnectMaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+func contextError(err error, timeout time.Duration) error {
+	if errors.Is(err, context.DeadlineExceeded) && timeout > 0 {
+		return errors.Newf("search timed out after %s", timeout)
+	}
+	return errors.Wrap(err, "search canceled")
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// resumableSearch wraps a decoder so that it can be used for several event
+// streams of the same search, and decodes each match, alert and error once.
+type resumableSearch struct {
+	next Decoder
+
+	seen map[[sha256.Size]byte]struct{}
+	// progress is the latest progress event, which is held back if it's
+	// final until the stream is done.
+	progress *Progress
+}
+
+func newResumableSearch(next Decoder) *resumableSearch {
+	return &resumableSearch{next: next, seen: map[[sha256.Size]byte]struct{}{}}
+}
+
+// firstTime returns true the first time it's called with an event of the kind
+// that is equal to v.
+func (s *resumableSearch) firstTime(kind string, v any) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return true
+	}
+	key := sha256.Sum256(append([]byte(kind+"\x00"), data...))
+	if _, ok := s.seen[key]; ok {
+		return false
+	}
+	s.seen[key] = struct{}{}
+	return true
+}
+
+// decoder returns the decoder for the next event stream.
+func (s *resumableSearch) decoder() Decoder {
+	d := s.next
+	d.OnProgress = func(p *Progress) {
+		s.progress = p
+		if !p.Done && s.next.OnProgress != nil {
+			s.next.OnProgress(p)
+		}
+	}
+	if s.next.OnMatches != nil {
+		d.OnMatches = func(matches []EventMatch) {
+			unseen := make([]EventMatch, 0, len(matches))
+			for _, match := range matches {
+				if s.firstTime("match", match) {
+					unseen = append(unseen, match)
+				}
+			}
+			if len(unseen) > 0 {
+				s.next.OnMatches(unseen)
+			}
+		}
+	}
+	if s.next.OnAlert != nil {
+		d.OnAlert = func(alert *EventAlert) {
+			if s.firstTime("alert", alert) {
+				s.next.OnAlert(alert)
+			}
 		}
 	}
-	return nil
+	if s.next.OnError != nil {
+		d.OnError = func(eventError *EventError) {
+			if s.firstTime("error", eventError) {
+				s.next.OnError(eventError)
+			}
+		}
+	}
+	return d
+}
+
+// finish sends the final progress event once the search is done.
+func (s *resumableSearch) finish() {
+	p := s.progress
+	if p == nil || !p.Done || s.next.OnProgress == nil {
+		return
+	}
+	p.Complete = true
+	for _, skipped := range p.Skipped {
+		if skipped.Reason.Incomplete() {
+			p.Complete = false
+		}
+	}
+	s.next.OnProgress(p)
+}
+
+// interrupt sends a final progress event for a search that couldn't be done,
+// based on the latest progress event.
+func (s *resumableSearch) interrupt(err error) {
+	if s.next.OnProgress == nil {
+		return
+	}
+	var p Progress
+	if s.progress != nil {
+		p = *s.progress
+	}
+	p.Done = true
+	p.Complete = false
+	p.Skipped = append(p.Skipped, Skipped{
+		Reason:   Interrupted,
+		Title:    "search interrupted",
+		Message:  err.Error(),
+		Severity: SeverityWarn,
+	})
+	s.next.OnProgress(&p)
 }
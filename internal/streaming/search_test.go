@@ -0,0 +1,331 @@
+package streaming
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/sourcegraph/src-cli/internal/api"
+)
+
+func TestSearchReconnect(t *testing.T) {
+	reconnectMinDelay, reconnectMaxDelay = time.Millisecond, time.Millisecond
+	defer func() { reconnectMinDelay, reconnectMaxDelay = time.Second, 30*time.Second }()
+
+	match := func(path string) EventMatch {
+		return &EventPathMatch{Type: PathMatchType, Repository: "github.com/org/repo", Path: path}
+	}
+	// streams are the event streams that the server returns, in order. A
+	// stream without a done event is cut off.
+	type event struct {
+		name  string
+		value any
+	}
+	done := event{name: "done"}
+	final := event{name: "progress", value: Progress{Done: true, MatchCount: 3}}
+
+	for _, tc := range []struct {
+		name          string
+		streams       [][]event
+		status        int
+		opts          Opts
+		wantMatches   []string
+		wantAlerts    int
+		wantErr       string
+		wantRequests  int
+		wantComplete  bool
+		wantInterrupt bool
+	}{
+		{
+			name: "no interruption",
+			streams: [][]event{
+				{{"matches", []EventMatch{match("a"), match("b"), match("c")}}, final, done},
+			},
+			wantMatches:  []string{"a", "b", "c"},
+			wantRequests: 1,
+			wantComplete: true,
+		},
+		{
+			name: "resumed",
+			streams: [][]event{
+				{{"alert", EventAlert{Title: "slow"}}, {"matches", []EventMatch{match("a"), match("b")}}},
+				{{"alert", EventAlert{Title: "slow"}}, {"matches", []EventMatch{match("a")}}, {"matches", []EventMatch{match("b"), match("c")}}, final},
+				{{"alert", EventAlert{Title: "slow"}}, {"matches", []EventMatch{match("a"), match("b"), match("c")}}, final, done},
+			},
+			wantMatches:  []string{"a", "b", "c"},
+			wantAlerts:   1,
+			wantRequests: 3,
+			wantComplete: true,
+		},
+		{
+			name: "skipped results",
+			streams: [][]event{
+				{{"progress", Progress{Done: true, Skipped: []Skipped{{Reason: ShardTimeout, Title: "timed out"}}}}, done},
+			},
+			wantRequests: 1,
+		},
+		{
+			name: "reconnects exhausted",
+			streams: [][]event{
+				{{"matches", []EventMatch{match("a")}}},
+				{{"matches", []EventMatch{match("a")}}},
+				{{"matches", []EventMatch{match("a"), match("b")}}},
+			},
+			opts:          Opts{MaxReconnects: 2},
+			wantMatches:   []string{"a", "b"},
+			wantErr:       "the event stream ended before the search was done",
+			wantRequests:  3,
+			wantInterrupt: true,
+		},
+		{
+			name:          "reconnect disabled",
+			streams:       [][]event{{{"matches", []EventMatch{match("a")}}}},
+			opts:          Opts{MaxReconnects: -1},
+			wantMatches:   []string{"a"},
+			wantErr:       "the event stream ended before the search was done",
+			wantRequests:  1,
+			wantInterrupt: true,
+		},
+		{
+			name:          "not reconnectable",
+			status:        http.StatusUnauthorized,
+			wantErr:       "unexpected HTTP status 401",
+			wantRequests:  1,
+			wantInterrupt: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			requests := 0
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+					return
+				}
+				writer, _ := NewWriter(w)
+				for _, e := range tc.streams[requests-1] {
+					writer.Event(e.name, e.value)
+				}
+			}))
+			defer ts.Close()
+
+			var (
+				matches  []string
+				alerts   int
+				progress *Progress
+			)
+			decoder := Decoder{
+				OnProgress: func(p *Progress) {
+					progress = p
+				},
+				OnMatches: func(m []EventMatch) {
+					for _, match := range m {
+						matches = append(matches, match.(*EventPathMatch).Path)
+					}
+				},
+				OnAlert: func(*EventAlert) {
+					alerts++
+				},
+			}
+			client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: &bytes.Buffer{}})
+			err := SearchContext(context.Background(), "query", tc.opts, client, decoder)
+			if tc.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+
+			if diff := cmp.Diff(tc.wantMatches, matches); diff != "" {
+				t.Errorf("unexpected matches (-want +have):\n%s", diff)
+			}
+			if alerts != tc.wantAlerts {
+				t.Errorf("expected %d alerts, have %d", tc.wantAlerts, alerts)
+			}
+			if requests != tc.wantRequests {
+				t.Errorf("expected %d requests, have %d", tc.wantRequests, requests)
+			}
+			if progress == nil || !progress.Done {
+				t.Fatalf("expected a final progress event, have %+v", progress)
+			}
+			if progress.Complete != tc.wantComplete {
+				t.Errorf("expected complete to be %t", tc.wantComplete)
+			}
+			interrupted := false
+			for _, skipped := range progress.Skipped {
+				interrupted = interrupted || skipped.Reason == Interrupted
+			}
+			if interrupted != tc.wantInterrupt {
+				t.Errorf("expected interrupted to be %t, have skipped %+v", tc.wantInterrupt, progress.Skipped)
+			}
+		})
+	}
+}
+
+func TestSearchReconnectableErrors(t *testing.T) {
+	defer func() { reconnectMinDelay, reconnectMaxDelay = time.Second, 30*time.Second }()
+
+	// search runs a search that would time out if it reconnected, and returns
+	// its error.
+	search := func(t *testing.T, client api.Client) error {
+		t.Helper()
+		reconnectMinDelay, reconnectMaxDelay = time.Hour, time.Hour
+		err := Search("query", Opts{Timeout: 5 * time.Second}, client, Decoder{})
+		if err == nil || strings.Contains(err.Error(), "timed out") {
+			t.Fatalf("expected the search to fail without reconnecting, got %v", err)
+		}
+		return err
+	}
+
+	t.Run("malformed event", func(t *testing.T) {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("event: matches\ndata: [{\n\n"))
+		}))
+		defer ts.Close()
+
+		reconnectMinDelay, reconnectMaxDelay = time.Hour, time.Hour
+		client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: &bytes.Buffer{}})
+		err := Search("query", Opts{Timeout: 5 * time.Second}, client, Decoder{OnMatches: func([]EventMatch) {}})
+		if err == nil || !strings.Contains(err.Error(), "failed to decode matches payload") {
+			t.Errorf("unexpected error: %s", err)
+		}
+		if requests != 1 {
+			t.Errorf("expected 1 request, have %d", requests)
+		}
+	})
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer ts.Close()
+		ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+
+		err := search(t, api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: &bytes.Buffer{}}))
+		if !strings.Contains(err.Error(), "certificate") {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("missing CA bundle", func(t *testing.T) {
+		err := search(t, api.NewClient(api.ClientOpts{
+			Endpoint:  "https://sourcegraph.example.com",
+			Transport: api.TransportOpts{CABundlePath: filepath.Join(t.TempDir(), "missing.pem")},
+			Out:       &bytes.Buffer{},
+		}))
+		if !strings.Contains(err.Error(), "reading CA bundle") {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("not recorded", func(t *testing.T) {
+		cassette := filepath.Join(t.TempDir(), "cassette.jsonl")
+		if err := os.WriteFile(cassette, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		flagSet := flag.NewFlagSet("search", flag.ContinueOnError)
+		flags := api.NewFlags(flagSet)
+		if err := flagSet.Parse([]string{"-replay", cassette}); err != nil {
+			t.Fatal(err)
+		}
+
+		err := search(t, api.NewClient(api.ClientOpts{Endpoint: "https://sourcegraph.example.com", Flags: flags, Out: &bytes.Buffer{}}))
+		if !strings.Contains(err.Error(), "no recorded response") {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+
+	t.Run("connection cut off", func(t *testing.T) {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			writer, _ := NewWriter(w)
+			if requests == 1 {
+				// Promise more than is sent, and hang up.
+				conn, buf, _ := w.(http.Hijacker).Hijack()
+				_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nContent-Length: 1000\r\n\r\nevent: progress\n")
+				_ = buf.Flush()
+				conn.Close()
+				return
+			}
+			writer.Event("progress", Progress{Done: true})
+			writer.Event("done", nil)
+		}))
+		defer ts.Close()
+
+		reconnectMinDelay, reconnectMaxDelay = time.Millisecond, time.Millisecond
+		client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: &bytes.Buffer{}})
+		if err := Search("query", Opts{}, client, Decoder{}); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if requests != 2 {
+			t.Errorf("expected 2 requests, have %d", requests)
+		}
+	})
+}
+
+func TestSearchTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer, _ := NewWriter(w)
+		writer.Event("progress", Progress{MatchCount: 1})
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	var progress *Progress
+	client := api.NewClient(api.ClientOpts{Endpoint: ts.URL, Out: &bytes.Buffer{}})
+	err := Search("query", Opts{Timeout: 50 * time.Millisecond}, client, Decoder{
+		OnProgress: func(p *Progress) { progress = p },
+	})
+	if err == nil || err.Error() != "search timed out after 50ms" {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if progress == nil || !progress.Done || progress.Complete || progress.MatchCount != 1 {
+		t.Errorf("expected a final, incomplete progress event, have %+v", progress)
+	}
+}
+
+func TestSearchParams(t *testing.T) {
+	zero := 0
+	for _, tc := range []struct {
+		name string
+		opts Opts
+		want string
+	}{
+		{
+			name: "defaults",
+			opts: Opts{Display: -1},
+			want: "cm=t&q=foo",
+		},
+		{
+			name: "all",
+			opts: Opts{
+				Display:       10,
+				PatternType:   "regexp",
+				Version:       "V3",
+				ContextLines:  &zero,
+				MaxLineLen:    200,
+				SearchContext: "@alice/ctx",
+			},
+			want: "cl=0&cm=t&display=10&max-line-len=200&q=context%3A%40alice%2Fctx+foo&t=regexp&v=V3",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if have := searchParams("foo", tc.opts).Encode(); have != tc.want {
+				t.Errorf("want %q, have %q", tc.want, have)
+			}
+		})
+	}
+}
@@ -13,9 +13,19 @@ import (
 	"github.com/sourcegraph/sourcegraph/lib/errors"
 )
 
+// Doer sends HTTP requests. It's implemented by *http.Client, and by the API
+// client, so that requests use the configured TLS and proxy settings.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
 // NeedsSiteInit returns true if the instance hasn't done "Site admin init" step.
-func NeedsSiteInit(baseURL string) (bool, string, error) {
-	resp, err := http.Get(baseURL + "/sign-in")
+func NeedsSiteInit(httpClient Doer, baseURL string) (bool, string, error) {
+	req, err := http.NewRequest("GET", baseURL+"/sign-in", nil)
+	if err != nil {
+		return false, "", errors.Wrap(err, "new request")
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return false, "", errors.Wrap(err, "sign-in page")
 	}
@@ -30,17 +40,26 @@ func NeedsSiteInit(baseURL string) (bool, string, error) {
 
 // SiteAdminInit initializes the instance with given admin account.
 // It returns an authenticated client as the admin for doing testing.
-func SiteAdminInit(baseURL, email, username, password string) (*Client, error) {
-	return authenticate(baseURL, "/-/site-init", map[string]string{
+func SiteAdminInit(httpClient Doer, baseURL, email, username, password string) (*Client, error) {
+	return authenticate(httpClient, baseURL, "/-/site-init", map[string]string{
 		"email":    email,
 		"username": username,
 		"password": password,
 	})
 }
 
+// SignIn signs in to the instance with the given username or email and
+// password. It returns an authenticated client as the user.
+func SignIn(httpClient Doer, baseURL, usernameOrEmail, password string) (*Client, error) {
+	return authenticate(httpClient, baseURL, "/-/sign-in", map[string]string{
+		"email":    usernameOrEmail,
+		"password": password,
+	})
+}
+
 // authenticate initializes an authenticated client with given request body.
-func authenticate(baseURL, path string, body any) (*Client, error) {
-	c, err := NewClient(baseURL, nil, nil)
+func authenticate(httpClient Doer, baseURL, path string, body any) (*Client, error) {
+	c, err := NewClient(httpClient, baseURL, nil, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "new client")
 	}
@@ -57,6 +76,7 @@ func authenticate(baseURL, path string, body any) (*Client, error) {
 // The user may or may not be a site admin depends on how the client is instantiated.
 // It works by simulating how the browser would send HTTP requests to the server.
 type Client struct {
+	httpClient    Doer
 	baseURL       string
 	csrfToken     string
 	csrfCookie    *http.Cookie
@@ -74,8 +94,9 @@ func noopLog(payload []byte) {}
 // NewClient instantiates a new client by performing a GET request then obtains the
 // CSRF token and cookie from its response, if there is one (old versions of Sourcegraph only).
 // If request- or responseLogger are provided, the request and response bodies, respectively,
-// will be written to them for any GraphQL requests only.
-func NewClient(baseURL string, requestLogger, responseLogger logFunc) (*Client, error) {
+// will be written to them for any GraphQL requests only. All requests are sent
+// with httpClient.
+func NewClient(httpClient Doer, baseURL string, requestLogger, responseLogger logFunc) (*Client, error) {
 	if requestLogger == nil {
 		requestLogger = noopLog
 	}
@@ -83,7 +104,11 @@ func NewClient(baseURL string, requestLogger, responseLogger logFunc) (*Client,
 		responseLogger = noopLog
 	}
 
-	resp, err := http.Get(baseURL)
+	req, err := http.NewRequest("GET", baseURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, errors.Wrap(err, "get URL")
 	}
@@ -104,6 +129,7 @@ func NewClient(baseURL string, requestLogger, responseLogger logFunc) (*Client,
 	}
 
 	return &Client{
+		httpClient:     httpClient,
 		baseURL:        baseURL,
 		csrfToken:      csrfToken,
 		csrfCookie:     csrfCookie,
@@ -133,7 +159,7 @@ func (c *Client) authenticate(path string, body any) error {
 		req.AddCookie(c.csrfCookie)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return errors.Wrap(err, "do request")
 	}
@@ -155,7 +181,7 @@ func (c *Client) authenticate(path string, body any) error {
 		}
 	}
 	if sessionCookie == nil {
-		return errors.Wrap(err, `"sgs" cookie not found`)
+		return errors.New(`"sgs" cookie not found`)
 	}
 	c.sessionCookie = sessionCookie
 
@@ -268,7 +294,7 @@ func (c *Client) GraphQL(token, query string, variables map[string]any, target a
 
 	c.requestLogger(body)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return err
 	}